@@ -0,0 +1,218 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/auth"
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// newToken creates a user with a role and returns an API token for them.
+func newToken(t *testing.T, database *sql.DB, name string, role auth.Role) string {
+	var out strings.Builder
+	if err := createToken(&out, db.New(database), name, role, "test"); err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// wantStatus fails the test unless err is an API error with the status, or
+// there is no error when status is 0.
+func wantStatus(t *testing.T, what string, err error, status int) {
+	t.Helper()
+	if status == 0 {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", what, err)
+		}
+		return
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+		t.Errorf("%s: expected status %d, got %v", what, status, err)
+	}
+}
+
+func TestAuthRoles(t *testing.T) {
+	ts, database, cleanup := newTestServer(t, nil, true)
+	defer cleanup()
+
+	anon := client.New(ts.URL, nil)
+	reader := client.New(ts.URL, nil).WithToken(newToken(t, database, "alice", auth.RoleReader))
+	librarian := client.New(ts.URL, nil).WithToken(newToken(t, database, "bob", auth.RoleLibrarian))
+	admin := client.New(ts.URL, nil).WithToken(newToken(t, database, "carol", auth.RoleAdmin))
+	wrong := client.New(ts.URL, nil).WithToken("lsk_wrong")
+
+	_, err := anon.ListBooks()
+	wantStatus(t, "anonymous list", err, http.StatusUnauthorized)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Body.Code != api.CodeUnauthorized {
+		t.Errorf("expected code %q, got %q", api.CodeUnauthorized, apiErr.Body.Code)
+	}
+	_, err = wrong.ListBooks()
+	wantStatus(t, "unknown token", err, http.StatusUnauthorized)
+	_, err = reader.ListBooks()
+	wantStatus(t, "reader list", err, 0)
+
+	// The books do not exist, so getting past authorisation is a 404.
+	move := api.BookLocationRequest{ShelfID: 1}
+	_, err = reader.MoveBook("9780000000002", move, client.Scan{})
+	wantStatus(t, "reader move", err, http.StatusForbidden)
+	_, err = librarian.MoveBook("9780000000002", move, client.Scan{})
+	wantStatus(t, "librarian move", err, http.StatusNotFound)
+
+	wantStatus(t, "reader delete", reader.DeleteBook("9780000000002", client.Scan{}), http.StatusForbidden)
+	wantStatus(t, "librarian delete", librarian.DeleteBook("9780000000002", client.Scan{}), http.StatusForbidden)
+	wantStatus(t, "admin delete", admin.DeleteBook("9780000000002", client.Scan{}), http.StatusNotFound)
+
+	_, err = librarian.LookupBook("9780000000002")
+	wantStatus(t, "librarian lookup", err, http.StatusForbidden)
+
+	// Readers may only borrow for themselves.
+	wantStatus(t, "reader borrow for self", reader.BorrowBook(models.BorrowRequest{ISBN: 9780000000002}, client.Scan{}), http.StatusNotFound)
+	wantStatus(t, "reader borrow for other", reader.BorrowBook(models.BorrowRequest{ISBN: 9780000000002, PersonName: "bob"}, client.Scan{}), http.StatusForbidden)
+	wantStatus(t, "reader hold for other", reader.HoldBook(models.BorrowRequest{ISBN: 9780000000002, PersonName: "bob"}), http.StatusForbidden)
+	wantStatus(t, "librarian lend", librarian.BorrowBook(models.BorrowRequest{ISBN: 9780000000002, PersonName: "alice"}, client.Scan{}), http.StatusNotFound)
+
+	// The feed of every loan needs a librarian, the public route or not.
+	for token, want := range map[string]int{
+		"": http.StatusForbidden,
+		newToken(t, database, "dave", auth.RoleReader):    http.StatusForbidden,
+		newToken(t, database, "erin", auth.RoleLibrarian): http.StatusOK,
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/borrowings.ics", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to get calendar: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("expected status %d for the full calendar, got %d", want, resp.StatusCode)
+		}
+	}
+}
+
+func TestAuthPolicyRoutes(t *testing.T) {
+	e := echo.New()
+	SetupRoutes(e, nil, nil)
+
+	// The metrics are registered by runServer, next to their middleware.
+	served := map[string]bool{"GET /metrics": true}
+	for _, r := range e.Routes() {
+		served[r.Method+" "+r.Path] = true
+	}
+	for _, route := range auth.Routes() {
+		if !served[route] {
+			t.Errorf("the policy has %s, which is not served", route)
+		}
+	}
+}
+
+func TestAuthLogin(t *testing.T) {
+	ts, database, cleanup := newTestServer(t, nil, true)
+	defer cleanup()
+
+	if err := createUser(io.Discard, db.New(database), "alice", auth.RoleReader, "correct horse"); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	// Browsers are sent to sign in.
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/dashboard", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		t.Fatalf("failed to get dashboard: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login?next=%2Fdashboard" {
+		t.Fatalf("expected a redirect to sign in, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, err = http.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatalf("failed to get login page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the login page, got %d", resp.StatusCode)
+	}
+
+	login := func(password string) *http.Response {
+		form := url.Values{"name": {"alice"}, "password": {password}, "next": {"/dashboard"}}
+		resp, err := noRedirect.PostForm(ts.URL+"/login", form)
+		if err != nil {
+			t.Fatalf("failed to sign in: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := login("battery staple"); resp.StatusCode != http.StatusUnauthorized || len(resp.Cookies()) != 0 {
+		t.Errorf("expected a wrong password to be turned away, got %d", resp.StatusCode)
+	}
+	resp = login("correct horse")
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/dashboard" {
+		t.Fatalf("expected a redirect to the dashboard, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var session *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == auth.SessionCookie {
+			session = cookie
+		}
+	}
+	if session == nil || !session.HttpOnly {
+		t.Fatalf("expected an HttpOnly session cookie, got %v", resp.Cookies())
+	}
+
+	get := func(path string) int {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.AddCookie(session)
+		resp, err := noRedirect.Do(req)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := get(api.Prefix + "/books"); status != http.StatusOK {
+		t.Errorf("expected the session to list books, got %d", status)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, ts.URL+"/logout", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.AddCookie(session)
+	resp, err = noRedirect.Do(req)
+	if err != nil {
+		t.Fatalf("failed to sign out: %v", err)
+	}
+	resp.Body.Close()
+	if status := get(api.Prefix + "/books"); status != http.StatusUnauthorized {
+		t.Errorf("expected the session to end on signing out, got %d", status)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/labels"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// labelsConfig holds the options for the labels command.
+type labelsConfig struct {
+	serverURL string
+	token     string
+	shelfID   int
+	format    string
+	output    string
+	withQR    bool
+	// control writes the scanner control codes instead of shelf labels.
+	control bool
+	sheet   labels.Config
+}
+
+// writeLabels fetches the shelves from the server and writes their labels,
+// or writes the control codes. SVGs have one file per sheet, numbered when
+// there is more than one.
+func writeLabels(cfg labelsConfig) error {
+	opts, err := cfg.sheet.Options()
+	if err != nil {
+		return err
+	}
+	if cfg.format != "pdf" && cfg.format != "svg" {
+		return fmt.Errorf("format must be pdf or svg")
+	}
+
+	sheet := labels.ControlLabels(cfg.withQR)
+	if !cfg.control {
+		shelves, err := fetchShelves(cfg.serverURL, cfg.token)
+		if err != nil {
+			return err
+		}
+		if cfg.shelfID != 0 {
+			var found []models.Shelf
+			for _, shelf := range shelves {
+				if shelf.ID == cfg.shelfID {
+					found = append(found, shelf)
+				}
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("shelf %d not found", cfg.shelfID)
+			}
+			shelves = found
+		}
+		sheet = labels.ShelfLabels(shelves, cfg.withQR)
+	}
+
+	output := cfg.output
+	if output == "" {
+		output = "labels." + cfg.format
+	}
+
+	if cfg.format == "pdf" {
+		return writeFile(output, func(f *os.File) error {
+			return labels.WritePDF(f, sheet, opts)
+		})
+	}
+
+	pages := opts.Template.Pages(len(sheet))
+	for page := 1; page <= pages; page++ {
+		path := output
+		if pages > 1 {
+			ext := filepath.Ext(output)
+			path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(output, ext), page, ext)
+		}
+		err := writeFile(path, func(f *os.File) error {
+			return labels.WriteSVG(f, sheet, opts, page)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetchShelves(serverURL, token string) ([]models.Shelf, error) {
+	shelves, err := client.New(serverURL, nil).WithToken(token).ListShelves()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch shelves: %v", err)
+	}
+	return shelves, nil
+}
+
+func writeFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	return nil
+}
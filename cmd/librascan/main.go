@@ -2,13 +2,21 @@ package main
 
 import (
 	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	_ "github.com/gouthamve/librascan/migrations"
 	_ "modernc.org/sqlite"
 
+	"github.com/gouthamve/librascan/pkg/auth"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/labels"
 	"github.com/gouthamve/librascan/pkg/readIsbn"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
 	"github.com/gouthamve/librascan/pkg/tui"
 )
 
@@ -28,29 +36,160 @@ func main() {
 			if err != nil {
 				log.Fatalln("cannot get perplexity-key flag:", err)
 			}
-			serve(apiKey)
+			smtpAddr, err := cmd.Flags().GetString("smtp-addr")
+			if err != nil {
+				log.Fatalln("cannot get smtp-addr flag:", err)
+			}
+			smtpFrom, err := cmd.Flags().GetString("smtp-from")
+			if err != nil {
+				log.Fatalln("cannot get smtp-from flag:", err)
+			}
+			smtpUsername, err := cmd.Flags().GetString("smtp-username")
+			if err != nil {
+				log.Fatalln("cannot get smtp-username flag:", err)
+			}
+			smtpPassword, err := cmd.Flags().GetString("smtp-password")
+			if err != nil {
+				log.Fatalln("cannot get smtp-password flag:", err)
+			}
+			webhookURL, err := cmd.Flags().GetString("webhook-url")
+			if err != nil {
+				log.Fatalln("cannot get webhook-url flag:", err)
+			}
+			dueSoon, err := cmd.Flags().GetDuration("due-soon")
+			if err != nil {
+				log.Fatalln("cannot get due-soon flag:", err)
+			}
+			authOn, err := cmd.Flags().GetBool("auth")
+			if err != nil {
+				log.Fatalln("cannot get auth flag:", err)
+			}
+
+			serve(serveConfig{
+				pplxAPIKey: apiKey,
+				notifier:   newNotifier(smtpAddr, smtpFrom, smtpUsername, smtpPassword, webhookURL),
+				dueSoon:    dueSoon,
+				auth:       authOn,
+			})
 		},
 	}
 	serveCmd.Flags().String("perplexity-key", "", "The perplexity API key.")
+	serveCmd.Flags().String("smtp-addr", "", "SMTP server (host:port) for loan notification emails.")
+	serveCmd.Flags().String("smtp-from", "librascan@localhost", "Sender address for loan notification emails.")
+	serveCmd.Flags().String("smtp-username", "", "SMTP username, if the server requires authentication.")
+	serveCmd.Flags().String("smtp-password", "", "SMTP password, if the server requires authentication.")
+	serveCmd.Flags().String("webhook-url", "", "URL to POST loan notifications to as JSON.")
+	serveCmd.Flags().Duration("due-soon", 48*time.Hour, "How long before the due date to send a reminder.")
+	serveCmd.Flags().Bool("auth", true, "Require users to sign in or send an API token. Turn off to let anyone who can reach the server do anything.")
 
 	// Add a flag option for server URL in the read-isbn command.
 	waitCmd := &cobra.Command{
 		Use:   "read-isbn",
 		Short: "Start ISBN input loop",
 		Run: func(cmd *cobra.Command, args []string) {
-			serverURL, err := cmd.Flags().GetString("server-url")
+			cfg := readIsbn.Config{}
+			configPath, err := cmd.Flags().GetString("config")
 			if err != nil {
-				log.Fatalln("cannot get server URL:", err)
+				log.Fatalln("cannot get config flag:", err)
+			}
+			if configPath != "" {
+				if cfg, err = readIsbn.LoadConfig(configPath); err != nil {
+					log.Fatalln("cannot load config:", err)
+				}
+			}
+			// Flags on the command line win over the config file.
+			flags := cmd.Flags()
+			if cfg.ServerURL == "" || flags.Changed("server-url") {
+				if cfg.ServerURL, err = flags.GetString("server-url"); err != nil {
+					log.Fatalln("cannot get server URL:", err)
+				}
+			}
+			if cfg.Token == "" || flags.Changed("token") {
+				if cfg.Token, err = flags.GetString("token"); err != nil {
+					log.Fatalln("cannot get token flag:", err)
+				}
+			}
+			if cfg.MetricsAddr == "" || flags.Changed("metrics-addr") {
+				if cfg.MetricsAddr, err = flags.GetString("metrics-addr"); err != nil {
+					log.Fatalln("cannot get metrics-addr flag:", err)
+				}
+			}
+			if cfg.QueuePath == "" || flags.Changed("queue-path") {
+				if cfg.QueuePath, err = flags.GetString("queue-path"); err != nil {
+					log.Fatalln("cannot get queue-path flag:", err)
+				}
+			}
+			if cfg.LendingTimeout == 0 || flags.Changed("lending-timeout") {
+				if cfg.LendingTimeout, err = flags.GetDuration("lending-timeout"); err != nil {
+					log.Fatalln("cannot get lending-timeout flag:", err)
+				}
+			}
+			if cfg.Debounce == 0 || flags.Changed("debounce") {
+				if cfg.Debounce, err = flags.GetDuration("debounce"); err != nil {
+					log.Fatalln("cannot get debounce flag:", err)
+				}
 			}
-			inputDevicePath, err := cmd.Flags().GetString("input-device-path")
+			if flags.Changed("bell") {
+				if cfg.Bell, err = flags.GetBool("bell"); err != nil {
+					log.Fatalln("cannot get bell flag:", err)
+				}
+			}
+
+			devices, err := cfg.ParseDevices()
+			if err != nil {
+				log.Fatalln("invalid config:", err)
+			}
+
+			inputDevicePath, err := flags.GetString("input-device-path")
 			if err != nil {
 				log.Fatalln("cannot get inputPath flag:", err)
 			}
-			readIsbn.StartCLI(serverURL, inputDevicePath)
+			keyboardLayout, err := flags.GetString("keyboard-layout")
+			if err != nil {
+				log.Fatalln("cannot get keyboard-layout flag:", err)
+			}
+			specs, err := flags.GetStringArray("source")
+			if err != nil {
+				log.Fatalln("cannot get source flag:", err)
+			}
+			if inputDevicePath != "" {
+				specs = append(specs, "evdev:"+inputDevicePath+"?layout="+url.QueryEscape(keyboardLayout))
+			}
+			if len(specs) == 0 && len(devices) == 0 {
+				specs = []string{"stdin"}
+			}
+
+			// Sources given as flags make up one catalogue scanner.
+			if len(specs) > 0 {
+				device := readIsbn.Device{Name: "scanner", Role: readIsbn.RoleCatalogue, Debounce: cfg.Debounce, Bell: cfg.Bell}
+				for _, spec := range specs {
+					source, err := readIsbn.ParseSource(spec, cfg.ServerURL)
+					if err != nil {
+						log.Fatalln("invalid source:", err)
+					}
+					device.Sources = append(device.Sources, source)
+				}
+				devices = append(devices, device)
+			}
+
+			queue, err := scanqueue.Open(cfg.QueuePath)
+			if err != nil {
+				log.Fatalln("cannot open scan queue:", err)
+			}
+			readIsbn.StartCLI(cfg.ServerURL, cfg.Token, cfg.MetricsAddr, devices, queue, cfg.LendingTimeout)
 		},
 	}
+	waitCmd.Flags().String("config", "", "Config file with the scanners to read and their roles. See the README.")
 	waitCmd.Flags().String("server-url", "http://localhost:8080", "Server URL for posting ISBNs.")
-	waitCmd.Flags().String("input-device-path", "", "Path to the scanners udev device.")
+	waitCmd.Flags().String("token", os.Getenv("LIBRASCAN_TOKEN"), "API token to send to the server, made with `librascan token create`. Defaults to $LIBRASCAN_TOKEN.")
+	waitCmd.Flags().String("metrics-addr", ":8081", "Address to serve Prometheus metrics on. Empty to not serve them.")
+	waitCmd.Flags().String("input-device-path", "", "Path to the scanners udev device. Same as --source evdev:PATH.")
+	waitCmd.Flags().String("keyboard-layout", "us", "Keyboard layout the scanner at --input-device-path types with: "+strings.Join(readIsbn.LayoutNames(), ", ")+".")
+	waitCmd.Flags().StringArray("source", nil, "Where to read scans from: stdin, evdev:PATH[?layout=L&prefix=P&suffix=S], serial:PATH[?baud=N], tcp:ADDR or ws:ADDR?token=SECRET. Can be given more than once; defaults to stdin.")
+	waitCmd.Flags().String("queue-path", defaultQueuePath, "File to keep scans in while the server cannot be reached.")
+	waitCmd.Flags().Duration("lending-timeout", 2*time.Minute, "How long lending or return mode lasts without a scan before falling back to cataloguing.")
+	waitCmd.Flags().Duration("debounce", time.Second, "Drop a code scanned again this soon after the last time it was seen. 0 to keep every scan.")
+	waitCmd.Flags().Bool("bell", false, "Ring the terminal bell when a scanned book is already catalogued: once if it moved, twice if it was already there.")
 
 	tuiCmd := &cobra.Command{
 		Use:   "tui",
@@ -60,14 +199,267 @@ func main() {
 			if err != nil {
 				log.Fatalln("cannot get server URL:", err)
 			}
+			token, err := cmd.Flags().GetString("token")
+			if err != nil {
+				log.Fatalln("cannot get token flag:", err)
+			}
 
-			tui.Start(serverURL)
+			tui.Start(serverURL, token)
 		},
 	}
 	tuiCmd.Flags().String("server-url", "http://localhost:8080", "Server URL for posting ISBNs.")
+	tuiCmd.Flags().String("token", os.Getenv("LIBRASCAN_TOKEN"), "API token to send to the server. Defaults to $LIBRASCAN_TOKEN.")
 
 	rootCmd.AddCommand(tuiCmd)
 
+	labelsCmd := &cobra.Command{
+		Use:   "labels",
+		Short: "Write printable sheets of shelf labels",
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			cfg := labelsConfig{}
+			var err error
+			if cfg.serverURL, err = flags.GetString("server-url"); err != nil {
+				log.Fatalln("cannot get server URL:", err)
+			}
+			if cfg.token, err = flags.GetString("token"); err != nil {
+				log.Fatalln("cannot get token flag:", err)
+			}
+			if cfg.shelfID, err = flags.GetInt("shelf"); err != nil {
+				log.Fatalln("cannot get shelf flag:", err)
+			}
+			if cfg.format, err = flags.GetString("format"); err != nil {
+				log.Fatalln("cannot get format flag:", err)
+			}
+			if cfg.output, err = flags.GetString("output"); err != nil {
+				log.Fatalln("cannot get output flag:", err)
+			}
+			if cfg.withQR, err = flags.GetBool("qr"); err != nil {
+				log.Fatalln("cannot get qr flag:", err)
+			}
+			if cfg.control, err = flags.GetBool("control-codes"); err != nil {
+				log.Fatalln("cannot get control-codes flag:", err)
+			}
+			if cfg.sheet.Template, err = flags.GetString("template"); err != nil {
+				log.Fatalln("cannot get template flag:", err)
+			}
+			if cfg.sheet.Columns, err = flags.GetInt("columns"); err != nil {
+				log.Fatalln("cannot get columns flag:", err)
+			}
+			if cfg.sheet.Rows, err = flags.GetInt("rows"); err != nil {
+				log.Fatalln("cannot get rows flag:", err)
+			}
+			if flags.Changed("margin-top") {
+				v, err := flags.GetFloat64("margin-top")
+				if err != nil {
+					log.Fatalln("cannot get margin-top flag:", err)
+				}
+				cfg.sheet.MarginTop = &v
+			}
+			if flags.Changed("margin-left") {
+				v, err := flags.GetFloat64("margin-left")
+				if err != nil {
+					log.Fatalln("cannot get margin-left flag:", err)
+				}
+				cfg.sheet.MarginLeft = &v
+			}
+			if cfg.sheet.LabelWidth, err = flags.GetFloat64("label-width"); err != nil {
+				log.Fatalln("cannot get label-width flag:", err)
+			}
+			if cfg.sheet.LabelHeight, err = flags.GetFloat64("label-height"); err != nil {
+				log.Fatalln("cannot get label-height flag:", err)
+			}
+			if flags.Changed("gap-x") {
+				v, err := flags.GetFloat64("gap-x")
+				if err != nil {
+					log.Fatalln("cannot get gap-x flag:", err)
+				}
+				cfg.sheet.GapX = &v
+			}
+			if flags.Changed("gap-y") {
+				v, err := flags.GetFloat64("gap-y")
+				if err != nil {
+					log.Fatalln("cannot get gap-y flag:", err)
+				}
+				cfg.sheet.GapY = &v
+			}
+			if cfg.sheet.DPI, err = flags.GetFloat64("dpi"); err != nil {
+				log.Fatalln("cannot get dpi flag:", err)
+			}
+
+			if err := writeLabels(cfg); err != nil {
+				log.Fatalln("failed to write labels:", err)
+			}
+		},
+	}
+	labelsCmd.Flags().String("server-url", "http://localhost:8080", "Server URL to fetch shelves from.")
+	labelsCmd.Flags().String("token", os.Getenv("LIBRASCAN_TOKEN"), "API token to send to the server. Defaults to $LIBRASCAN_TOKEN.")
+	labelsCmd.Flags().Int("shelf", 0, "Only write labels for this shelf id. All shelves if 0.")
+	labelsCmd.Flags().String("format", "pdf", "Output format, pdf or svg.")
+	labelsCmd.Flags().String("output", "", "Output file. Defaults to labels.pdf or labels.svg; SVGs get a page number when there is more than one sheet.")
+	labelsCmd.Flags().Bool("qr", false, "Add a QR code next to the barcode.")
+	labelsCmd.Flags().Bool("control-codes", false, "Write the scanner control codes (MODE:DELETE, UNDO, ...) instead of shelf labels.")
+	labelsCmd.Flags().String("template", labels.DefaultTemplate, "Label paper, one of "+strings.Join(labels.TemplateNames(), ", ")+".")
+	labelsCmd.Flags().Int("columns", 0, "Labels across the sheet, overrides the template.")
+	labelsCmd.Flags().Int("rows", 0, "Labels down the sheet, overrides the template.")
+	labelsCmd.Flags().Float64("margin-top", 0, "Top margin in mm, overrides the template.")
+	labelsCmd.Flags().Float64("margin-left", 0, "Left margin in mm, overrides the template.")
+	labelsCmd.Flags().Float64("label-width", 0, "Label width in mm, overrides the template.")
+	labelsCmd.Flags().Float64("label-height", 0, "Label height in mm, overrides the template.")
+	labelsCmd.Flags().Float64("gap-x", 0, "Gap between columns in mm, overrides the template.")
+	labelsCmd.Flags().Float64("gap-y", 0, "Gap between rows in mm, overrides the template.")
+	labelsCmd.Flags().Float64("dpi", labels.DefaultDPI, "Printer resolution, bars are snapped to whole dots.")
+
+	rootCmd.AddCommand(labelsCmd)
+
+	queueCmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect or flush the scans read-isbn could not send",
+	}
+	queueCmd.PersistentFlags().String("queue-path", defaultQueuePath, "File read-isbn keeps unsent scans in.")
+	openQueue := func(cmd *cobra.Command) *scanqueue.Queue {
+		queuePath, err := cmd.Flags().GetString("queue-path")
+		if err != nil {
+			log.Fatalln("cannot get queue-path flag:", err)
+		}
+		queue, err := scanqueue.Open(queuePath)
+		if err != nil {
+			log.Fatalln("cannot open scan queue:", err)
+		}
+		return queue
+	}
+	queueListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the queued scans, oldest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := listQueue(os.Stdout, openQueue(cmd)); err != nil {
+				log.Fatalln("cannot list scan queue:", err)
+			}
+		},
+	}
+	queueFlushCmd := &cobra.Command{
+		Use:   "flush",
+		Short: "Send the queued scans to the server now",
+		Run: func(cmd *cobra.Command, args []string) {
+			serverURL, err := cmd.Flags().GetString("server-url")
+			if err != nil {
+				log.Fatalln("cannot get server URL:", err)
+			}
+			token, err := cmd.Flags().GetString("token")
+			if err != nil {
+				log.Fatalln("cannot get token flag:", err)
+			}
+			if err := flushQueue(os.Stdout, serverURL, token, openQueue(cmd)); err != nil {
+				log.Fatalln("cannot flush scan queue:", err)
+			}
+		},
+	}
+	queueFlushCmd.Flags().String("server-url", "http://localhost:8080", "Server URL for posting ISBNs.")
+	queueFlushCmd.Flags().String("token", os.Getenv("LIBRASCAN_TOKEN"), "API token to send to the server. Defaults to $LIBRASCAN_TOKEN.")
+	queueClearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Drop the queued scans without sending them",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := clearQueue(os.Stdout, openQueue(cmd)); err != nil {
+				log.Fatalln("cannot clear scan queue:", err)
+			}
+		},
+	}
+	queueCmd.AddCommand(queueListCmd, queueFlushCmd, queueClearCmd)
+
+	rootCmd.AddCommand(queueCmd)
+
+	userCmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage the users who may sign in to the server",
+	}
+	userCreateCmd := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Add a user, asking for their password",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			roleName, err := cmd.Flags().GetString("role")
+			if err != nil {
+				log.Fatalln("cannot get role flag:", err)
+			}
+			role, err := auth.ParseRole(roleName)
+			if err != nil {
+				log.Fatalln("invalid role:", err)
+			}
+			password, err := readPassword(os.Stderr)
+			if err != nil {
+				log.Fatalln("cannot read password:", err)
+			}
+			sqlDB := openDatabase()
+			defer sqlDB.Close()
+			if err := createUser(os.Stdout, db.New(sqlDB), args[0], role, password); err != nil {
+				log.Fatalln("cannot create user:", err)
+			}
+		},
+	}
+	userCreateCmd.Flags().String("role", string(auth.RoleReader), "What the user may do: reader, librarian or admin.")
+	userPasswordCmd := &cobra.Command{
+		Use:   "password NAME",
+		Short: "Change the password of a user, asking for the new one",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			password, err := readPassword(os.Stderr)
+			if err != nil {
+				log.Fatalln("cannot read password:", err)
+			}
+			sqlDB := openDatabase()
+			defer sqlDB.Close()
+			if err := setPassword(os.Stdout, db.New(sqlDB), args[0], password); err != nil {
+				log.Fatalln("cannot change password:", err)
+			}
+		},
+	}
+	userCmd.AddCommand(userCreateCmd, userPasswordCmd)
+
+	rootCmd.AddCommand(userCmd)
+
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the API tokens scanners and scripts use",
+	}
+	tokenCreateCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Make an API token for a user and print it",
+		Run: func(cmd *cobra.Command, args []string) {
+			flags := cmd.Flags()
+			userName, err := flags.GetString("user")
+			if err != nil {
+				log.Fatalln("cannot get user flag:", err)
+			}
+			roleName, err := flags.GetString("role")
+			if err != nil {
+				log.Fatalln("cannot get role flag:", err)
+			}
+			var role auth.Role
+			if roleName != "" {
+				if role, err = auth.ParseRole(roleName); err != nil {
+					log.Fatalln("invalid role:", err)
+				}
+			}
+			label, err := flags.GetString("name")
+			if err != nil {
+				log.Fatalln("cannot get name flag:", err)
+			}
+			sqlDB := openDatabase()
+			defer sqlDB.Close()
+			if err := createToken(os.Stdout, db.New(sqlDB), userName, role, label); err != nil {
+				log.Fatalln("cannot create token:", err)
+			}
+		},
+	}
+	tokenCreateCmd.Flags().String("user", "", "User the token signs in as.")
+	tokenCreateCmd.Flags().String("role", "", "Create the user with this role if they do not exist yet: reader, librarian or admin.")
+	tokenCreateCmd.Flags().String("name", "", "What the token is for, such as the scanner it is used by.")
+	_ = tokenCreateCmd.MarkFlagRequired("user")
+	tokenCmd.AddCommand(tokenCreateCmd)
+
+	rootCmd.AddCommand(tokenCmd)
+
 	rootCmd.AddCommand(serveCmd, waitCmd)
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
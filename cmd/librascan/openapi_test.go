@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/labstack/echo/v4"
+)
+
+// routeParam matches the parameters in Echo's paths.
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+// specPath turns an Echo path such as "/books/:isbn" into the path of the
+// OpenAPI document, "/books/{isbn}".
+func specPath(path string) string {
+	return routeParam.ReplaceAllString(path, "{$1}")
+}
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	e := echo.New()
+	SetupRoutes(e, nil, nil)
+	doc := api.Spec()
+
+	routes := map[string]bool{}
+	for _, r := range e.Routes() {
+		path, ok := strings.CutPrefix(r.Path, api.Prefix)
+		if !ok {
+			continue
+		}
+		route := r.Method + " " + specPath(path)
+		routes[route] = true
+		if doc.Operation(r.Method, specPath(path)) == nil {
+			t.Errorf("%s is not in the OpenAPI document", route)
+		}
+	}
+
+	var missing []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			route := strings.ToUpper(method) + " " + path
+			if !routes[route] {
+				missing = append(missing, route)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("the OpenAPI document has operations that are not served: %v", missing)
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("failed to get OpenAPI document: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	var doc api.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode OpenAPI document: %v", err)
+	}
+	if doc.Operation(http.MethodGet, "/books/{isbn}") == nil {
+		t.Errorf("expected GET /books/{isbn} in the served document")
+	}
+
+	resp, err = http.Get(ts.URL + "/docs")
+	if err != nil {
+		t.Fatalf("failed to get docs: %v", err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		t.Fatalf("failed to read docs: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body.String())
+	}
+	for _, want := range []string{api.Prefix + "/books/{isbn}", `id="schema-Book"`} {
+		if !strings.Contains(body.String(), want) {
+			t.Errorf("expected the docs to contain %q", want)
+		}
+	}
+}
+
+// responseRecorder keeps a copy of what is written to a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// checkResponses fails the test for successful JSON API responses that do
+// not match the OpenAPI document, so that every integration test also
+// checks the document against what the server sends.
+func checkResponses(t *testing.T) echo.MiddlewareFunc {
+	doc := api.Spec()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := specPath(strings.TrimPrefix(c.Path(), api.Prefix))
+			op := doc.Operation(c.Request().Method, path)
+			if op == nil || path == "/events" {
+				return next(c)
+			}
+
+			rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = rec
+			if err := next(c); err != nil {
+				return err
+			}
+
+			status := c.Response().Status
+			if status/100 != 2 {
+				return nil
+			}
+			route := c.Request().Method + " " + path
+			resp, ok := op.Responses[strconv.Itoa(status)]
+			if !ok {
+				t.Errorf("%s: status %d is not in the OpenAPI document", route, status)
+				return nil
+			}
+			media, ok := resp.Content["application/json"]
+			if !ok {
+				if rec.body.Len() > 0 {
+					t.Errorf("%s: expected no body with status %d, got %s", route, status, rec.body.String())
+				}
+				return nil
+			}
+
+			var body any
+			if err := json.Unmarshal(rec.body.Bytes(), &body); err != nil {
+				t.Errorf("%s: invalid JSON response: %v", route, err)
+				return nil
+			}
+			for _, problem := range validate(doc, media.Schema, body, "body") {
+				t.Errorf("%s: %s", route, problem)
+			}
+			return nil
+		}
+	}
+}
+
+// validate checks a decoded JSON value against a schema and returns what
+// does not match.
+func validate(doc api.Document, s *api.Schema, v any, at string) []string {
+	if s.Ref != "" {
+		return validate(doc, doc.Resolve(s), v, at)
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" && len(s.AllOf) == 0 {
+			return nil
+		}
+		return []string{at + " is null"}
+	}
+	var problems []string
+	for _, sub := range s.AllOf {
+		problems = append(problems, validate(doc, sub, v, at)...)
+	}
+
+	mismatch := func() []string {
+		return append(problems, fmt.Sprintf("%s: expected %s, got %T", at, s.Type, v))
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is missing", at, name))
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s is not in the OpenAPI document", at, name))
+				continue
+			}
+			problems = append(problems, validate(doc, prop, obj[name], at+"."+name)...)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return mismatch()
+		}
+		for i, item := range items {
+			problems = append(problems, validate(doc, s.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return mismatch()
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return mismatch()
+		}
+	}
+	return problems
+}
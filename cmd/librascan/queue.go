@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/readIsbn"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+)
+
+// defaultQueuePath is where read-isbn keeps scans the server has not got yet.
+const defaultQueuePath = "./.db/scan-queue.jsonl"
+
+// listQueue prints the scans waiting in the queue, oldest first.
+func listQueue(w io.Writer, queue *scanqueue.Queue) error {
+	scans, err := queue.List()
+	if err != nil {
+		return err
+	}
+	if len(scans) == 0 {
+		_, err := fmt.Fprintln(w, "The queue is empty.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SCANNED AT\tISBN\tSHELF\tROW\tSLOT\tSOURCE")
+	for _, s := range scans {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", s.ScannedAt.Local().Format(time.DateTime), s.ISBN, s.ShelfID, s.RowNumber, s.Slot, s.Source)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, len(scans), "scans waiting in", queue.Path())
+	return err
+}
+
+// flushQueue sends the queued scans to the server now.
+func flushQueue(w io.Writer, serverURL, token string, queue *scanqueue.Queue) error {
+	sent, rejected, err := readIsbn.FlushQueue(serverURL, token, queue)
+	_, _ = fmt.Fprintf(w, "Sent %d scans, %d rejected by the server.\n", sent, rejected)
+	if err != nil {
+		return fmt.Errorf("stopped at a scan that could not be sent: %w", err)
+	}
+	return nil
+}
+
+// clearQueue drops every queued scan without sending it.
+func clearQueue(w io.Writer, queue *scanqueue.Queue) error {
+	n, err := queue.Clear()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Dropped %d scans.\n", n)
+	return err
+}
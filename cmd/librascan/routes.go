@@ -3,28 +3,95 @@ package main
 import (
 	"database/sql"
 
+	"github.com/gouthamve/librascan/pkg/api"
 	"github.com/gouthamve/librascan/pkg/handlers"
+	"github.com/gouthamve/librascan/pkg/notify"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 // SetupRoutes registers HTTP endpoints using the Echo instance.
-func SetupRoutes(e *echo.Echo, database *sql.DB) {
+func SetupRoutes(e *echo.Echo, database *sql.DB, notifier notify.Notifier) {
+	e.Use(middleware.RequestID())
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
 
-	// Root endpoint that displays all books in an HTML table
-
-	ls := handlers.NewLibrascan(database)
+	ls := handlers.NewLibrascan(database, notifier)
 
+	// Pages and files for people rather than programs.
 	e.GET("/", ls.GenerateHTMLHandler)
-	e.GET("/debug/lookup/:isbn", ls.LookupBookHandler)
+	e.GET("/dashboard", ls.StatsHTMLHandler)
+	e.GET("/station", ls.ScanStationHandler)
+	e.GET("/shelves/labels", ls.ShelfLabelsHandler)
+	e.GET("/shelves/:id/labels", ls.ShelfLabelsHandler)
+	e.GET("/shelves/:id/render.png", ls.RenderShelf)
+	e.GET("/shelves/:id/view", ls.ShelfHTMLHandler)
+	e.GET("/plans/:id/checklist", ls.PlanChecklist)
+	e.GET("/borrowings.ics", ls.BorrowingsCalendarHandler)
+	e.GET("/openapi.json", ls.OpenAPIHandler)
+	e.GET("/docs", ls.DocsHandler)
+	e.GET("/login", ls.LoginPage)
+	e.POST("/login", ls.Login)
+	e.POST("/logout", ls.Logout)
+
+	registerAPIRoutes(e.Group(api.Prefix), ls)
+	// The API was served at the root before it was versioned, and older
+	// scanners and scripts still use those paths.
+	registerAPIRoutes(e.Group(""), ls)
+}
+
+// registerAPIRoutes registers the JSON API endpoints on g.
+func registerAPIRoutes(g *echo.Group, ls *handlers.Librascan) {
+	g.GET("/events", ls.StreamEvents)
+	g.GET("/debug/lookup/:isbn", ls.LookupBookHandler)
+
+	g.POST("/books/:isbn", ls.AddBookFromISBN)
+	g.GET("/books/:isbn", ls.GetBookByISBN)
+	g.GET("/books", ls.GetAllBooks)
+	g.DELETE("/books/:isbn", ls.DeleteBookByISBN)
+	g.PUT("/books/:isbn/location", ls.SetBookLocation)
+	g.GET("/books/:isbn/locate", ls.LocateBook)
+
+	g.GET("/shelf/:id", ls.LookupShelfNameHandler)
+	g.GET("/shelves", ls.GetShelves)
+	g.POST("/shelves", ls.CreateShelf)
+	g.PATCH("/shelves/:id", ls.UpdateShelf)
+	g.DELETE("/shelves/:id", ls.DeleteShelf)
+	g.GET("/shelves/:id/rows/:row/books", ls.GetRowBooks)
+	g.PUT("/shelves/:id/rows/:row/order", ls.SetRowOrder)
+
+	g.GET("/locations", ls.GetLocations)
+	g.POST("/locations", ls.CreateLocation)
+	g.GET("/locations/:id", ls.GetLocation)
+	g.PATCH("/locations/:id", ls.UpdateLocation)
+	g.DELETE("/locations/:id", ls.DeleteLocation)
+	g.GET("/locations/:id/books", ls.GetLocationBooks)
+
+	g.GET("/audits", ls.GetAudits)
+	g.POST("/audits", ls.StartAudit)
+	g.GET("/audits/:id", ls.GetAudit)
+	g.POST("/audits/:id/scans", ls.AddAuditScan)
+	g.POST("/audits/:id/close", ls.CloseAudit)
+	g.POST("/audits/:id/apply", ls.ApplyAudit)
+
+	g.GET("/plans", ls.GetPlans)
+	g.POST("/plans", ls.CreatePlan)
+	g.GET("/plans/:id", ls.GetPlan)
+	g.POST("/plans/:id/confirm", ls.ConfirmPlanMove)
 
-	e.POST("/books/:isbn", ls.AddBookFromISBN)
-	e.GET("/books/:isbn", ls.GetBookByISBN)
-	e.GET("/books", ls.GetAllBooks)
-	e.DELETE("/books/:isbn", ls.DeleteBookByISBN)
+	g.GET("/scans", ls.GetScans)
+	g.POST("/scans", ls.RecordScan)
+	g.POST("/scans/undo", ls.UndoLastScan)
+	g.POST("/scans/:id/undo", ls.UndoScan)
+	g.POST("/scan/image", ls.ScanImage)
 
-	e.GET("/shelf/:id", ls.LookupShelfNameHandler)
+	g.POST("/books/borrow", ls.BorrowBookByISBN)
+	g.POST("/books/return", ls.ReturnBookByISBN)
+	g.POST("/books/hold", ls.HoldBookByISBN)
 
-	e.POST("/books/borrow", ls.BorrowBookByISBN)
+	g.GET("/people", ls.GetPeople)
+	g.GET("/people/:id", ls.GetPersonByID)
+	g.PATCH("/people/:id", ls.UpdatePerson)
+	g.GET("/people/:id/calendar", ls.GetPersonCalendarHandler)
 
-	e.GET("/people", ls.GetPeople)
+	g.GET("/stats", ls.StatsHandler)
 }
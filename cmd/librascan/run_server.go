@@ -3,27 +3,49 @@ package main
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/auth"
 	"github.com/gouthamve/librascan/pkg/cron"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/notify"
+	"github.com/gouthamve/librascan/pkg/stats"
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/pressly/goose/v3"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
 	"github.com/XSAM/otelsql"
 	"go.opentelemetry.io/otel/semconv/v1.30.0"
 )
 
-// serve runs migrations, starts the HTTP server and routes.
-func serve(pplxAPIKey string) {
+// serveConfig holds the options for the serve command.
+type serveConfig struct {
+	pplxAPIKey string
+
+	// notifier is nil when no SMTP server or webhook is configured.
+	notifier notify.Notifier
+	dueSoon  time.Duration
+
+	// auth turns on authentication and the roles of users.
+	auth bool
+}
+
+// migrate creates the database if there is none and runs its migrations.
+func migrate() {
 	if err := os.MkdirAll("./.db", 0755); err != nil {
 		log.Fatalf("failed to create database directory: %v", err)
 	}
 
-	// Run the migrations
 	db, err := goose.OpenDBWithDriver("sqlite3", database)
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
@@ -34,8 +56,13 @@ func serve(pplxAPIKey string) {
 	if err := db.Close(); err != nil {
 		log.Fatalf("failed to close database: %v", err)
 	}
+}
 
-	db, err = otelsql.Open("sqlite", database, otelsql.WithAttributes(semconv.DBSystemNameSqlite))
+// serve runs migrations, starts the HTTP server and routes.
+func serve(cfg serveConfig) {
+	migrate()
+
+	db, err := otelsql.Open("sqlite", database, otelsql.WithAttributes(semconv.DBSystemNameSqlite))
 	if err != nil {
 		log.Fatalf("failed to open database: %v", err)
 	}
@@ -64,27 +91,141 @@ func serve(pplxAPIKey string) {
 	e.Use(middleware.Logger())
 	e.Use(echoprometheus.NewMiddleware("librascan"))
 	e.GET("/metrics", echoprometheus.NewHandler())
+	prometheus.MustRegister(stats.NewCollector(db))
 	e.Use(otelecho.Middleware("librascan"))
 
 	// Setup routes in routes.go
-	SetupRoutes(e, db)
+	SetupRoutes(e, db, cfg.notifier)
+	if cfg.auth {
+		e.Use(requireAuth(db))
+		warnWithoutUsers(db)
+	} else {
+		log.Println("Authentication is off, anyone who can reach the server may change the library")
+	}
 
 	// Setup cron jobs
-	setupCronJobs(db, pplxAPIKey)
+	setupCronJobs(db, cfg)
 
 	// Start the server
 	log.Println("Starting server on :8080")
 	e.Logger.Fatal(e.Start(":8080"))
 }
 
-func setupCronJobs(db *sql.DB, pplxAPIKey string) {
-	if pplxAPIKey == "" {
-		log.Println("Perplexity API key not set, skipping perplexity enrichment")
+// requireAuth signs in requests with an API token, sent as
+// "Authorization: Bearer TOKEN", or the session cookie of the web interface,
+// and turns away the ones whose user may not use the route. auth.Required
+// says which role each route needs.
+func requireAuth(database *sql.DB) echo.MiddlewareFunc {
+	queries := db.New(database)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			auth.SetEnabled(c)
+			user, ok, err := authenticate(c, queries)
+			if err != nil {
+				return fmt.Errorf("cannot authenticate request: %w", err)
+			}
+			if ok {
+				auth.SetUser(c, user)
+			}
+
+			req := c.Request()
+			role, restricted := auth.Required(req.Method, strings.TrimPrefix(c.Path(), api.Prefix))
+			if !restricted {
+				return next(c)
+			}
+			if !ok {
+				// People are sent to sign in, programs are told to.
+				if req.Method == http.MethodGet && strings.Contains(req.Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+					return c.Redirect(http.StatusSeeOther, "/login?next="+url.QueryEscape(req.URL.RequestURI()))
+				}
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "sign in or send an API token")
+			}
+			if !user.Role.Includes(role) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("%s is a %s, this needs a %s", user.Name, user.Role, role))
+			}
+			return next(c)
+		}
+	}
+}
+
+// authenticate returns the user who made a request. It reports false if the
+// request has no token or session, or they are not known.
+func authenticate(c echo.Context, queries *db.Queries) (auth.User, bool, error) {
+	ctx := c.Request().Context()
+
+	var user db.User
+	var err error
+	if token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		hash := auth.HashToken(strings.TrimSpace(token))
+		user, err = queries.GetUserByAPIToken(ctx, hash)
+		if err == nil {
+			err = queries.TouchAPIToken(ctx, hash)
+		}
+	} else if cookie, cookieErr := c.Cookie(auth.SessionCookie); cookieErr == nil {
+		user, err = queries.GetUserBySession(ctx, auth.HashToken(cookie.Value))
+	} else {
+		return auth.User{}, false, nil
+	}
+
+	if err == sql.ErrNoRows {
+		return auth.User{}, false, nil
+	}
+	if err != nil {
+		return auth.User{}, false, err
+	}
+	return auth.User{ID: int(user.ID), Name: user.Name, Role: auth.Role(user.Role)}, true, nil
+}
+
+// warnWithoutUsers says how to add the first user, as nobody can use the
+// server until there is one.
+func warnWithoutUsers(database *sql.DB) {
+	n, err := db.New(database).CountUsers(context.Background())
+	if err != nil {
+		log.Printf("failed to count users: %v", err)
 		return
 	}
+	if n == 0 {
+		log.Println("There are no users yet, add one with `librascan user create NAME --role admin`")
+	}
+}
+
+func setupCronJobs(db *sql.DB, cfg serveConfig) {
+	jobs := []cron.Job{}
+
+	if cfg.pplxAPIKey == "" {
+		log.Println("Perplexity API key not set, skipping perplexity enrichment")
+	} else {
+		jobs = append(jobs, cron.NewPerplexityJob(db, cfg.pplxAPIKey))
+	}
 
-	pplxCron := cron.NewPerplexityJob(db, pplxAPIKey)
-	cr := cron.NewCronRunner([]cron.Job{pplxCron})
+	if cfg.notifier == nil {
+		log.Println("No SMTP server or webhook set, skipping loan reminders")
+	} else {
+		jobs = append(jobs, cron.NewLoanReminderJob(db, cfg.notifier, cfg.dueSoon))
+	}
 
+	if len(jobs) == 0 {
+		return
+	}
+
+	cr := cron.NewCronRunner(jobs)
 	cr.Run()
 }
+
+// newNotifier builds a notifier from the SMTP and webhook settings. It returns
+// nil if neither is configured.
+func newNotifier(smtpAddr, smtpFrom, smtpUsername, smtpPassword, webhookURL string) notify.Notifier {
+	notifiers := notify.Multi{}
+	if smtpAddr != "" {
+		notifiers = append(notifiers, notify.NewSMTPNotifier(smtpAddr, smtpFrom, smtpUsername, smtpPassword))
+	}
+	if webhookURL != "" {
+		notifiers = append(notifiers, notify.NewWebhookNotifier(webhookURL))
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
@@ -1,24 +1,52 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+	"github.com/google/go-cmp/cmp"
 	"github.com/gouthamve/librascan/migrations"
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/events"
 	"github.com/gouthamve/librascan/pkg/handlers"
+	"github.com/gouthamve/librascan/pkg/labels"
 	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/notify"
+	"github.com/gouthamve/librascan/pkg/scancode"
 	"github.com/labstack/echo/v4"
 	_ "modernc.org/sqlite"
 )
 
 func setupTestServer(t *testing.T) (*httptest.Server, *sql.DB, func()) {
+	return setupTestServerWithNotifier(t, nil)
+}
+
+func setupTestServerWithNotifier(t *testing.T, notifier notify.Notifier) (*httptest.Server, *sql.DB, func()) {
+	return newTestServer(t, notifier, false)
+}
+
+// newTestServer serves the routes on an in-memory database, requiring users
+// to sign in if withAuth is set.
+func newTestServer(t *testing.T, notifier notify.Notifier, withAuth bool) (*httptest.Server, *sql.DB, func()) {
 	// Create in-memory database
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
@@ -44,6 +72,51 @@ func setupTestServer(t *testing.T) (*httptest.Server, *sql.DB, func()) {
 	if err := migrations.Up0004(ctx, tx); err != nil {
 		t.Fatalf("failed to run migration 0004: %v", err)
 	}
+	if err := migrations.Up0005(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0005: %v", err)
+	}
+	if err := migrations.Up0006(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0006: %v", err)
+	}
+	if err := migrations.Up0007(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0007: %v", err)
+	}
+	if err := migrations.Up0008(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0008: %v", err)
+	}
+	if err := migrations.Up0009(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0009: %v", err)
+	}
+	if err := migrations.Up0010(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0010: %v", err)
+	}
+	if err := migrations.Up0011(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0011: %v", err)
+	}
+	if err := migrations.Up0012(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0012: %v", err)
+	}
+	if err := migrations.Up0013(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0013: %v", err)
+	}
+	if err := migrations.Up0014(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0014: %v", err)
+	}
+	if err := migrations.Up0015(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0015: %v", err)
+	}
+	if err := migrations.Up0016(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0016: %v", err)
+	}
+	if err := migrations.Up0017(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0017: %v", err)
+	}
+	if err := migrations.Up0018(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0018: %v", err)
+	}
+	if err := migrations.Up0019(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0019: %v", err)
+	}
 
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("failed to commit transaction: %v", err)
@@ -51,7 +124,11 @@ func setupTestServer(t *testing.T) (*httptest.Server, *sql.DB, func()) {
 
 	// Setup Echo server
 	e := echo.New()
-	SetupRoutes(e, db)
+	e.Use(checkResponses(t))
+	SetupRoutes(e, db, notifier)
+	if withAuth {
+		e.Use(requireAuth(db))
+	}
 
 	// Create test server
 	ts := httptest.NewServer(e)
@@ -500,3 +577,1894 @@ func TestBorrowBookByISBN(t *testing.T) {
 		t.Errorf("expected to find John Doe in people list, but didn't")
 	}
 }
+
+// chanNotifier forwards every notification to a channel.
+type chanNotifier chan notify.Event
+
+func (c chanNotifier) Notify(_ context.Context, ev notify.Event) error {
+	c <- ev
+	return nil
+}
+
+func waitForEvent(t *testing.T, events chanNotifier) notify.Event {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return notify.Event{}
+	}
+}
+
+func postJSON(t *testing.T, url string, v any) *http.Response {
+	return sendJSON(t, http.MethodPost, url, v)
+}
+
+func sendJSON(t *testing.T, method, url string, v any) *http.Response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create %s request to %s: %v", method, url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make %s request to %s: %v", method, url, err)
+	}
+	t.Cleanup(func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	})
+
+	return resp
+}
+
+func TestReturnAndHoldNotifications(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	events := make(chanNotifier, 10)
+	ts, _, cleanup := setupTestServerWithNotifier(t, events)
+	defer cleanup()
+
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+
+	// Returning a book that is not borrowed fails.
+	resp = postJSON(t, fmt.Sprintf("%s/books/return", ts.URL), models.ReturnRequest{ISBN: 9783836526722})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404 for returning a book that is not borrowed, got %d", resp.StatusCode)
+	}
+
+	// Borrowing sends a notification with the due date.
+	resp = postJSON(t, fmt.Sprintf("%s/books/borrow", ts.URL), models.BorrowRequest{
+		ISBN:       9783836526722,
+		PersonName: "John Doe",
+		Email:      "john@example.com",
+		Days:       14,
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for borrow request, got %d", resp.StatusCode)
+	}
+
+	ev := waitForEvent(t, events)
+	if ev.Kind != notify.EventBorrowed || ev.PersonEmail != "john@example.com" || ev.Title != "The Fairy Tales of the Brothers Grimm" {
+		t.Errorf("unexpected borrow event: %+v", ev)
+	}
+	if loan := ev.DueAt.Sub(ev.BorrowedAt); loan != 14*24*time.Hour {
+		t.Errorf("expected a 14 day loan, got %v", loan)
+	}
+
+	// Jane puts a hold on the book and is told when it comes back.
+	resp = postJSON(t, fmt.Sprintf("%s/books/hold", ts.URL), models.BorrowRequest{
+		ISBN:       9783836526722,
+		PersonName: "Jane Doe",
+		Email:      "jane@example.com",
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for hold request, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, fmt.Sprintf("%s/books/return", ts.URL), models.ReturnRequest{ISBN: 9783836526722})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for return request, got %d", resp.StatusCode)
+	}
+
+	ev = waitForEvent(t, events)
+	if ev.Kind != notify.EventHoldAvailable || ev.PersonName != "Jane Doe" || ev.PersonEmail != "jane@example.com" {
+		t.Errorf("unexpected hold event: %+v", ev)
+	}
+
+	// A hold cannot send someone's notifications elsewhere.
+	resp = postJSON(t, fmt.Sprintf("%s/books/hold", ts.URL), models.BorrowRequest{
+		ISBN:       9783836526722,
+		PersonName: "John Doe",
+		Email:      "mallory@example.com",
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for hold request, got %d", resp.StatusCode)
+	}
+	resp, err := http.Get(ts.URL + "/people")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var people []models.Person
+	if err := json.NewDecoder(resp.Body).Decode(&people); err != nil {
+		t.Fatalf("failed to decode people: %v", err)
+	}
+	for _, person := range people {
+		if person.Name == "John Doe" && person.Email != "john@example.com" {
+			t.Errorf("expected John's email to be kept, got %q", person.Email)
+		}
+	}
+}
+
+func TestGetPersonByID(t *testing.T) {
+	ts, db, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	if _, err := db.Exec(`INSERT INTO people (id, name, email) VALUES (7, 'John Doe', 'john@example.com')`); err != nil {
+		t.Fatalf("failed to insert person: %v", err)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/people/7", ts.URL))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	var person models.Person
+	if err := json.NewDecoder(resp.Body).Decode(&person); err != nil {
+		t.Fatalf("failed to decode person: %v", err)
+	}
+	if person.ID != 7 || person.Name != "John Doe" || person.Email != "john@example.com" {
+		t.Errorf("unexpected person: %+v", person)
+	}
+
+	notFoundResp, err := http.Get(fmt.Sprintf("%s/people/8", ts.URL))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := notFoundResp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+
+	if notFoundResp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", notFoundResp.StatusCode)
+	}
+
+	// Emails are changed on the person.
+	for _, tt := range []struct {
+		id     int
+		email  string
+		status int
+	}{
+		{7, "johnny@example.com", http.StatusOK},
+		{7, "not an email", http.StatusBadRequest},
+		{8, "johnny@example.com", http.StatusNotFound},
+	} {
+		resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/people/%d", ts.URL, tt.id), models.PersonRequest{Email: tt.email})
+		if resp.StatusCode != tt.status {
+			t.Errorf("expected status %d for changing the email to %q, got %d", tt.status, tt.email, resp.StatusCode)
+		}
+	}
+	var email string
+	if err := db.QueryRow(`SELECT email FROM people WHERE id = 7`).Scan(&email); err != nil {
+		t.Fatalf("failed to get email: %v", err)
+	}
+	if email != "johnny@example.com" {
+		t.Errorf("expected the email to be changed, got %q", email)
+	}
+}
+
+func TestBorrowingsCalendar(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, fmt.Sprintf("%s/books/borrow", ts.URL), models.BorrowRequest{
+		ISBN:       9783836526722,
+		PersonName: "John Doe",
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for borrow request, got %d", resp.StatusCode)
+	}
+
+	getBody := func(url string, wantStatus int) string {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("expected status %d for %s, got %d, body: %s", wantStatus, url, resp.StatusCode, string(body))
+		}
+		return string(body)
+	}
+
+	// The full feed contains the loan.
+	ics := getBody(fmt.Sprintf("%s/borrowings.ics", ts.URL), http.StatusOK)
+	if !strings.Contains(ics, "SUMMARY:Return The Fairy Tales of the Brothers Grimm (John Doe)") {
+		t.Errorf("expected loan in calendar, got:\n%s", ics)
+	}
+
+	// The per-person feed needs the right token.
+	var feed models.CalendarFeed
+	if err := json.Unmarshal([]byte(getBody(fmt.Sprintf("%s/people/1/calendar", ts.URL), http.StatusOK)), &feed); err != nil {
+		t.Fatalf("failed to decode calendar feed: %v", err)
+	}
+
+	ics = getBody(feed.URL, http.StatusOK)
+	if strings.Count(ics, "BEGIN:VEVENT") != 1 {
+		t.Errorf("expected one event in personal feed, got:\n%s", ics)
+	}
+
+	getBody(fmt.Sprintf("%s/borrowings.ics?person=1&token=wrong", ts.URL), http.StatusForbidden)
+	getBody(fmt.Sprintf("%s/borrowings.ics?person=2&token=wrong", ts.URL), http.StatusForbidden)
+}
+
+func TestStatsEndpoints(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, fmt.Sprintf("%s/books/borrow", ts.URL), models.BorrowRequest{
+		ISBN:       9783836526722,
+		PersonName: "John Doe",
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for borrow request, got %d", resp.StatusCode)
+	}
+
+	statsResp, err := http.Get(fmt.Sprintf("%s/stats", ts.URL))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := statsResp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	if statsResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", statsResp.StatusCode)
+	}
+
+	var stats models.Stats
+	if err := json.NewDecoder(statsResp.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode stats: %v", err)
+	}
+	if stats.Books != 1 || len(stats.AddedByMonth) != 1 || stats.AddedByMonth[0].Books != 1 {
+		t.Errorf("unexpected book stats: %+v", stats)
+	}
+	if len(stats.TopBorrowers) != 1 || stats.TopBorrowers[0].Name != "John Doe" || stats.TopBorrowers[0].Loans != 1 {
+		t.Errorf("unexpected borrower stats: %+v", stats.TopBorrowers)
+	}
+
+	htmlResp, err := http.Get(fmt.Sprintf("%s/dashboard", ts.URL))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := htmlResp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	body, _ := io.ReadAll(htmlResp.Body)
+	if htmlResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d, body: %s", htmlResp.StatusCode, string(body))
+	}
+	if !strings.Contains(string(body), "The Fairy Tales of the Brothers Grimm") {
+		t.Errorf("expected most borrowed book in dashboard, got:\n%s", string(body))
+	}
+}
+
+func TestShelfManagement(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	getShelves := func() map[int]models.Shelf {
+		resp, err := http.Get(fmt.Sprintf("%s/shelves", ts.URL))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var shelves []models.Shelf
+		if err := json.NewDecoder(resp.Body).Decode(&shelves); err != nil {
+			t.Fatalf("failed to decode shelves: %v", err)
+		}
+		byID := map[int]models.Shelf{}
+		for _, shelf := range shelves {
+			byID[shelf.ID] = shelf
+		}
+		return byID
+	}
+
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?shelf_id=1&row_number=3", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+
+	shelves := getShelves()
+	if len(shelves) != 6 {
+		t.Fatalf("expected 6 shelves, got %d", len(shelves))
+	}
+	office := shelves[1]
+	if office.Books != 1 || len(office.Rows) != office.RowCount || office.Rows[2].Row != 3 || office.Rows[2].Books != 1 {
+		t.Errorf("unexpected row counts: %+v", office)
+	}
+
+	// Create a shelf.
+	name, rows := "garage", 4
+	resp = postJSON(t, fmt.Sprintf("%s/shelves", ts.URL), models.ShelfRequest{Name: &name, RowCount: &rows})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for shelf creation, got %d", resp.StatusCode)
+	}
+	var garage models.Shelf
+	if err := json.NewDecoder(resp.Body).Decode(&garage); err != nil {
+		t.Fatalf("failed to decode shelf: %v", err)
+	}
+	if garage.ID == 0 || garage.Name != "garage" || garage.RowCount != 4 {
+		t.Errorf("unexpected shelf: %+v", garage)
+	}
+
+	if resp := postJSON(t, fmt.Sprintf("%s/shelves", ts.URL), models.ShelfRequest{Name: &name, RowCount: &rows}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for duplicate shelf, got %d", resp.StatusCode)
+	}
+	tooMany := handlers.MaxShelfRows + 1
+	if resp := postJSON(t, fmt.Sprintf("%s/shelves", ts.URL), models.ShelfRequest{Name: &name, RowCount: &tooMany}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for too many rows, got %d", resp.StatusCode)
+	}
+
+	// Rows that still hold books cannot be removed.
+	two := 2
+	if resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/shelves/1", ts.URL), models.ShelfRequest{RowCount: &two}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when removing a used row, got %d", resp.StatusCode)
+	}
+
+	newName := "garage-left"
+	resp = sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/shelves/%d", ts.URL, garage.ID), models.ShelfRequest{Name: &newName})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for rename, got %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&garage); err != nil {
+		t.Fatalf("failed to decode shelf: %v", err)
+	}
+	if garage.Name != "garage-left" || garage.RowCount != 4 {
+		t.Errorf("unexpected shelf after rename: %+v", garage)
+	}
+
+	// A shelf with books is only deleted when its books are moved.
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/shelves/1", ts.URL), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when deleting a shelf with books, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/shelves/1?reassign_to=%d", ts.URL, garage.ID), nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 when deleting with reassign_to, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/shelves/0", ts.URL), nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 when deleting the unknown shelf, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/shelves/99", ts.URL), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 when deleting a missing shelf, got %d", resp.StatusCode)
+	}
+
+	shelves = getShelves()
+	if _, ok := shelves[1]; ok {
+		t.Errorf("expected shelf 1 to be deleted")
+	}
+	if shelves[garage.ID].Books != 1 || shelves[garage.ID].Rows[2].Books != 1 {
+		t.Errorf("expected book to move to row 3 of the new shelf: %+v", shelves[garage.ID])
+	}
+}
+
+func TestShelfLabels(t *testing.T) {
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/shelves/1/labels")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/pdf" {
+		t.Fatalf("expected a PDF, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(body, "%PDF-") || !strings.Contains(body, "(office-big) Tj") || strings.Contains(body, "(office-small) Tj") {
+		t.Errorf("expected labels for shelf 1 only")
+	}
+
+	resp, body = get("/shelves/labels?format=svg&qr=true&template=avery-l7651&page=1")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected an SVG, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(body, "office-small") || !strings.Contains(body, "home-bedroom-right") {
+		t.Errorf("expected labels for all shelves")
+	}
+
+	for path, status := range map[string]int{
+		"/shelves/99/labels":                http.StatusNotFound,
+		"/shelves/0/labels":                 http.StatusBadRequest,
+		"/shelves/labels?template=nope":     http.StatusBadRequest,
+		"/shelves/labels?columns=9":         http.StatusBadRequest,
+		"/shelves/labels?format=png":        http.StatusBadRequest,
+		"/shelves/labels?format=svg&page=9": http.StatusBadRequest,
+		"/shelves/labels?dpi=not-a-number":  http.StatusBadRequest,
+	} {
+		if resp, body := get(path); resp.StatusCode != status {
+			t.Errorf("expected status %d for %s, got %d: %s", status, path, resp.StatusCode, body)
+		}
+	}
+
+	// The labels command writes one SVG per sheet.
+	dir := t.TempDir()
+	err := writeLabels(labelsConfig{
+		serverURL: ts.URL,
+		format:    "svg",
+		output:    dir + "/shelves.svg",
+		sheet:     labels.Config{Template: "avery-l7163"},
+	})
+	if err != nil {
+		t.Fatalf("writeLabels() returned error: %v", err)
+	}
+	// 29 rows at 14 labels per sheet.
+	for _, name := range []string{"shelves-1.svg", "shelves-2.svg", "shelves-3.svg"} {
+		if _, err := os.Stat(dir + "/" + name); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(dir + "/shelves-4.svg"); err == nil {
+		t.Errorf("expected only 3 sheets")
+	}
+}
+
+func TestAddBookWithLocationCode(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// Shelves can have more rows than fit in an EAN-8 label.
+	name, rows := "cellar", 12
+	if resp := postJSON(t, fmt.Sprintf("%s/shelves", ts.URL), models.ShelfRequest{Name: &name, RowCount: &rows}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for shelf creation, got %d", resp.StatusCode)
+	}
+
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?location=LS:L:6:12:4", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+
+	getResp, err := http.Get(fmt.Sprintf("%s/books/9783836526722", ts.URL))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := getResp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var book models.Book
+	if err := json.NewDecoder(getResp.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if book.ShelfID != 6 || book.ShelfName != "cellar" || book.RowNumber != 12 || book.Slot != 4 {
+		t.Errorf("unexpected location: shelf %d (%s), row %d, slot %d", book.ShelfID, book.ShelfName, book.RowNumber, book.Slot)
+	}
+
+	// Scanning a book where it already is does not look it up again.
+	resp = postJSON(t, fmt.Sprintf("%s/books/9783836526722?location=LS:L:6:12:4", ts.URL), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for a book already here, got %d", resp.StatusCode)
+	}
+	var again models.AddedBook
+	if err := json.NewDecoder(resp.Body).Decode(&again); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if again.Outcome != models.AddOutcomeAlreadyHere || again.MovedFrom != nil || again.Title == "" {
+		t.Errorf("unexpected re-scan: %+v", again)
+	}
+
+	// Scanning it somewhere else moves it. The old EAN-8 shelf codes are
+	// understood too.
+	resp = postJSON(t, fmt.Sprintf("%s/books/9783836526722?location=00000239", ts.URL), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for a moved book, got %d", resp.StatusCode)
+	}
+	var moved models.AddedBook
+	if err := json.NewDecoder(resp.Body).Decode(&moved); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if moved.ShelfID != 2 || moved.RowNumber != 3 || moved.Slot != 0 {
+		t.Errorf("unexpected location: shelf %d, row %d, slot %d", moved.ShelfID, moved.RowNumber, moved.Slot)
+	}
+	want := &models.BookPlace{ShelfID: 6, ShelfName: "cellar", RowNumber: 12, Slot: 4, LocationPath: []string{"cellar", "Row 12"}}
+	if moved.Outcome != models.AddOutcomeMoved || !cmp.Equal(want, moved.MovedFrom) {
+		t.Errorf("unexpected move: outcome %q from %+v", moved.Outcome, moved.MovedFrom)
+	}
+
+	if resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?location=9783836526722", ts.URL), nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an ISBN as location, got %d", resp.StatusCode)
+	}
+	for _, query := range []string{"shelf_id=99", "shelf_id=2&row_number=40", "shelf_id=2&row_number=-1"} {
+		if resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?%s", ts.URL, query), nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %s, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestLocations(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	getLocations := func() []models.Location {
+		resp, err := http.Get(fmt.Sprintf("%s/locations", ts.URL))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		var locations []models.Location
+		if err := json.NewDecoder(resp.Body).Decode(&locations); err != nil {
+			t.Fatalf("failed to decode locations: %v", err)
+		}
+		return locations
+	}
+	shelfLocation := func(shelfID, row int) models.Location {
+		for _, l := range getLocations() {
+			if l.ShelfID == shelfID && l.Row == row {
+				return l
+			}
+		}
+		t.Fatalf("no location for shelf %d row %d", shelfID, row)
+		return models.Location{}
+	}
+	createLocation := func(parentID int, typ, name string) models.Location {
+		resp := postJSON(t, fmt.Sprintf("%s/locations", ts.URL), models.LocationRequest{ParentID: &parentID, Type: &typ, Name: &name})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status 201 for location creation, got %d", resp.StatusCode)
+		}
+		var l models.Location
+		if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+			t.Fatalf("failed to decode location: %v", err)
+		}
+		return l
+	}
+
+	// Every shelf and row is in the tree.
+	if got := len(getLocations()); got != 5+6+7+4+6+6 {
+		t.Errorf("expected 34 locations, got %d", got)
+	}
+
+	home := createLocation(0, "building", "Home")
+	room := createLocation(home.ID, "room", "Living room")
+	if diff := cmp.Diff([]string{"Home", "Living room"}, room.Path); diff != "" {
+		t.Errorf("unexpected path (-want +got):\n%s", diff)
+	}
+	if resp := postJSON(t, fmt.Sprintf("%s/locations", ts.URL), models.LocationRequest{Type: strPtr("attic"), Name: strPtr("Attic")}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown type, got %d", resp.StatusCode)
+	}
+
+	bookcase := shelfLocation(3, 0)
+	if resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/locations/%d", ts.URL, bookcase.ID), models.LocationRequest{ParentID: &room.ID}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when moving a bookcase, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/locations/%d", ts.URL, bookcase.ID), models.LocationRequest{Name: strPtr("big")}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 when renaming a shelf location, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/locations/%d", ts.URL, home.ID), models.LocationRequest{ParentID: &room.ID}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 when moving a location below itself, got %d", resp.StatusCode)
+	}
+
+	// Books scanned onto a shelf row show the whole path.
+	resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?shelf_id=3&row_number=2", ts.URL), nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+	}
+	var book models.Book
+	if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if diff := cmp.Diff([]string{"Home", "Living room", "home-living-room", "Row 2"}, book.LocationPath); diff != "" {
+		t.Errorf("unexpected path (-want +got):\n%s", diff)
+	}
+
+	// Books roll up to every location above them.
+	resp, err := http.Get(fmt.Sprintf("%s/locations/%d", ts.URL, home.ID))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	if err := json.NewDecoder(resp.Body).Decode(&home); err != nil {
+		t.Fatalf("failed to decode location: %v", err)
+	}
+	if home.Books != 1 {
+		t.Errorf("expected 1 book at home, got %d", home.Books)
+	}
+	booksResp, err := http.Get(fmt.Sprintf("%s/locations/%d/books", ts.URL, home.ID))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := booksResp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var books []models.Book
+	if err := json.NewDecoder(booksResp.Body).Decode(&books); err != nil {
+		t.Fatalf("failed to decode books: %v", err)
+	}
+	if len(books) != 1 || books[0].ISBN != 9783836526722 {
+		t.Errorf("unexpected books at home: %+v", books)
+	}
+
+	// Books go into leaf locations only, and keep the shelf they are on.
+	row := shelfLocation(3, 2)
+	box := createLocation(row.ID, "box", "Fairy tales")
+	if resp := sendJSON(t, http.MethodPut, fmt.Sprintf("%s/books/9783836526722/location", ts.URL), api.BookLocationRequest{LocationID: row.ID}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a location with sub-locations, got %d", resp.StatusCode)
+	}
+	resp = sendJSON(t, http.MethodPut, fmt.Sprintf("%s/books/9783836526722/location", ts.URL), api.BookLocationRequest{LocationID: box.ID})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when moving a book, got %d", resp.StatusCode)
+	}
+	var moved models.Book
+	if err := json.NewDecoder(resp.Body).Decode(&moved); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if moved.LocationID != box.ID || moved.ShelfID != 3 || moved.RowNumber != 2 || len(moved.LocationPath) != 5 {
+		t.Errorf("unexpected location: %+v", moved)
+	}
+
+	// Locations in use cannot be removed.
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/locations/%d", ts.URL, box.ID), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when deleting a location with books, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/locations/%d", ts.URL, room.ID), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when deleting a location with sub-locations, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/locations/%d", ts.URL, row.ID), nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 when deleting a shelf location, got %d", resp.StatusCode)
+	}
+	if resp := sendJSON(t, http.MethodDelete, fmt.Sprintf("%s/shelves/3?reassign_to=1", ts.URL), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when deleting a shelf holding a box, got %d", resp.StatusCode)
+	}
+
+	// Shelf changes are mirrored in the tree.
+	newName, rows := "living-room", 2
+	if resp := sendJSON(t, http.MethodPatch, fmt.Sprintf("%s/shelves/3", ts.URL), models.ShelfRequest{Name: &newName, RowCount: &rows}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when updating a shelf, got %d", resp.StatusCode)
+	}
+	if got := shelfLocation(3, 0); got.Name != "living-room" || got.ParentID != room.ID || got.Books != 1 {
+		t.Errorf("unexpected bookcase: %+v", got)
+	}
+	if got := len(getLocations()); got != 34+2+1-2 {
+		t.Errorf("expected 35 locations, got %d", got)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestAudit(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	const (
+		found     = 9783836526722
+		missing   = 9780000000002
+		lent      = 9780000000019
+		misplaced = 9780000000026
+		unknown   = 9780000000033
+	)
+	for _, book := range []struct {
+		isbn         int
+		shelfID, row int
+	}{{found, 1, 1}, {missing, 1, 1}, {lent, 1, 1}, {misplaced, 2, 1}} {
+		resp := postJSON(t, fmt.Sprintf("%s/books/%d?shelf_id=%d&row_number=%d", ts.URL, book.isbn, book.shelfID, book.row), nil)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+		}
+	}
+	if resp := postJSON(t, fmt.Sprintf("%s/books/borrow", ts.URL), models.BorrowRequest{ISBN: lent, PersonName: "Jane Doe"}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for borrow request, got %d", resp.StatusCode)
+	}
+
+	resp := postJSON(t, fmt.Sprintf("%s/audits", ts.URL), models.AuditRequest{Location: scancode.ShelfCode(1, 1)})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 when starting an audit, got %d", resp.StatusCode)
+	}
+	var a models.Audit
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		t.Fatalf("failed to decode audit: %v", err)
+	}
+	if diff := cmp.Diff([]string{"office-big", "Row 1"}, a.LocationPath); diff != "" {
+		t.Errorf("unexpected audit location (-want +got):\n%s", diff)
+	}
+	if a.ClosedAt != nil {
+		t.Errorf("expected an open audit, got %+v", a)
+	}
+
+	for isbn, status := range map[int]string{found: "found", misplaced: "misplaced", unknown: "unknown"} {
+		resp := postJSON(t, fmt.Sprintf("%s/audits/%d/scans", ts.URL, a.ID), models.AuditScanRequest{ISBN: isbn})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 for audit scan, got %d", resp.StatusCode)
+		}
+		var scan models.AuditScan
+		if err := json.NewDecoder(resp.Body).Decode(&scan); err != nil {
+			t.Fatalf("failed to decode scan: %v", err)
+		}
+		if scan.Status != status {
+			t.Errorf("expected %d to be %s, got %s", isbn, status, scan.Status)
+		}
+	}
+
+	if resp := postJSON(t, fmt.Sprintf("%s/audits/%d/apply", ts.URL, a.ID), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when applying an open audit, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, fmt.Sprintf("%s/audits/%d/close", ts.URL, a.ID), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when closing an audit, got %d", resp.StatusCode)
+	}
+	var report models.AuditReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	isbns := func(books []models.AuditBook) []int {
+		var result []int
+		for _, book := range books {
+			result = append(result, book.ISBN)
+		}
+		return result
+	}
+	if report.ClosedAt == nil {
+		t.Error("expected the audit to be closed")
+	}
+	if diff := cmp.Diff([]int{found}, isbns(report.Found)); diff != "" {
+		t.Errorf("unexpected found books (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{missing}, isbns(report.Missing)); diff != "" {
+		t.Errorf("unexpected missing books (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{misplaced}, isbns(report.Misplaced)); diff != "" {
+		t.Errorf("unexpected misplaced books (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{unknown}, report.Unknown); diff != "" {
+		t.Errorf("unexpected unknown books (-want +got):\n%s", diff)
+	}
+	if len(report.Borrowed) != 1 || report.Borrowed[0].ISBN != lent || report.Borrowed[0].Borrower != "Jane Doe" {
+		t.Errorf("unexpected borrowed books: %+v", report.Borrowed)
+	}
+	if len(report.Corrections) != 2 {
+		t.Errorf("expected 2 corrections, got %+v", report.Corrections)
+	}
+
+	if resp := postJSON(t, fmt.Sprintf("%s/audits/%d/scans", ts.URL, a.ID), models.AuditScanRequest{ISBN: missing}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when scanning into a closed audit, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, fmt.Sprintf("%s/audits/%d/apply", ts.URL, a.ID), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when applying an audit, got %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if report.AppliedAt == nil || len(report.Misplaced) != 1 {
+		t.Errorf("expected the applied audit to keep its report, got %+v", report)
+	}
+	if resp := postJSON(t, fmt.Sprintf("%s/audits/%d/apply", ts.URL, a.ID), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 when applying an audit twice, got %d", resp.StatusCode)
+	}
+
+	getJSON := func(url string, v any) {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", url, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+
+	var moved, removed models.Book
+	getJSON(fmt.Sprintf("%s/books/%d", ts.URL, misplaced), &moved)
+	if moved.ShelfID != 1 || moved.RowNumber != 1 || moved.LocationID != a.LocationID {
+		t.Errorf("expected the misplaced book on office-big row 1, got %+v", moved)
+	}
+	getJSON(fmt.Sprintf("%s/books/%d", ts.URL, missing), &removed)
+	if removed.ShelfID != 0 || removed.LocationID != 0 || removed.LocationPath != nil {
+		t.Errorf("expected the missing book to be off the shelf, got %+v", removed)
+	}
+
+	var location models.Location
+	getJSON(fmt.Sprintf("%s/locations/%d", ts.URL, a.LocationID), &location)
+	if location.LastAuditedAt == nil || location.LastAuditedAt.Before(a.StartedAt) {
+		t.Errorf("expected the row to be audited, got %+v", location)
+	}
+	var audits []models.Audit
+	getJSON(fmt.Sprintf("%s/audits?location_id=%d", ts.URL, a.LocationID), &audits)
+	if len(audits) != 1 || audits[0].AppliedAt == nil {
+		t.Errorf("unexpected audits: %+v", audits)
+	}
+}
+
+func TestBookPositions(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	getJSON := func(url string, wantStatus int, v any) {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("expected status %d for %s, got %d", wantStatus, url, resp.StatusCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+	}
+	scan := func(isbn int) {
+		resp := postJSON(t, fmt.Sprintf("%s/books/%d?shelf_id=1&row_number=2", ts.URL, isbn), nil)
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 201 or 200 for book creation, got %d", resp.StatusCode)
+		}
+	}
+	rowOrder := func() []int {
+		var books []models.Book
+		getJSON(ts.URL+"/shelves/1/rows/2/books", http.StatusOK, &books)
+		var isbns []int
+		for _, book := range books {
+			isbns = append(isbns, book.ISBN)
+		}
+		return isbns
+	}
+
+	const a, b, c, d = 9780000000002, 9780000000019, 9780000000026, 9780000000033
+	for _, isbn := range []int{a, b, c, d} {
+		scan(isbn)
+	}
+	if diff := cmp.Diff([]int{a, b, c, d}, rowOrder()); diff != "" {
+		t.Errorf("unexpected row order (-want +got):\n%s", diff)
+	}
+
+	var locator models.BookLocator
+	getJSON(fmt.Sprintf("%s/books/%d/locate", ts.URL, b), http.StatusOK, &locator)
+	if locator.Position != 2 || locator.RowBooks != 4 || locator.Description != "office-big, row 2, about 2nd from the left" {
+		t.Errorf("unexpected locator: %+v", locator)
+	}
+
+	// Scanning a book again puts it at the end of the row.
+	scan(a)
+	if diff := cmp.Diff([]int{b, c, d, a}, rowOrder()); diff != "" {
+		t.Errorf("unexpected row order after a re-scan (-want +got):\n%s", diff)
+	}
+
+	resp := sendJSON(t, http.MethodPut, ts.URL+"/shelves/1/rows/2/order", models.RowOrderRequest{ISBNs: []int{d, b}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when ordering a row, got %d", resp.StatusCode)
+	}
+	if diff := cmp.Diff([]int{d, b, c, a}, rowOrder()); diff != "" {
+		t.Errorf("unexpected row order after re-sequencing (-want +got):\n%s", diff)
+	}
+	resp = sendJSON(t, http.MethodPut, ts.URL+"/shelves/1/rows/2/order", models.RowOrderRequest{ISBNs: []int{9783836526722}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a book on another row, got %d", resp.StatusCode)
+	}
+
+	getJSON(fmt.Sprintf("%s/books/%d/locate", ts.URL, a), http.StatusOK, &locator)
+	if locator.Description != "office-big, row 2, at the right end" {
+		t.Errorf("unexpected locator: %+v", locator)
+	}
+
+	// Lent books are not on the shelf.
+	if resp := postJSON(t, ts.URL+"/books/borrow", models.BorrowRequest{ISBN: c, PersonName: "Jane Doe"}); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status 204 for borrow request, got %d", resp.StatusCode)
+	}
+	locator = models.BookLocator{}
+	getJSON(fmt.Sprintf("%s/books/%d/locate", ts.URL, c), http.StatusOK, &locator)
+	if locator.Description != "lent to Jane Doe" || locator.Position != 0 {
+		t.Errorf("unexpected locator: %+v", locator)
+	}
+	getJSON(fmt.Sprintf("%s/books/%d/locate", ts.URL, b), http.StatusOK, &locator)
+	if locator.Position != 2 || locator.RowBooks != 3 {
+		t.Errorf("unexpected locator: %+v", locator)
+	}
+
+	getJSON(fmt.Sprintf("%s/books/%d/locate", ts.URL, 9780000000040), http.StatusNotFound, nil)
+	getJSON(ts.URL+"/shelves/42/rows/1/books", http.StatusNotFound, nil)
+}
+
+func TestRenderShelf(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, database, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// A plain blue cover.
+	var coverRequests atomic.Int32
+	covers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coverRequests.Add(1)
+		img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{0x20, 0x40, 0xa0, 0xff}), image.Point{}, draw.Src)
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, img); err != nil {
+			t.Errorf("failed to encode cover: %v", err)
+		}
+	}))
+	defer covers.Close()
+
+	const a, b, c = 9780000000002, 9780000000019, 9780000000026
+	for _, book := range []struct{ isbn, row int }{{a, 1}, {b, 1}, {c, 2}} {
+		resp := postJSON(t, fmt.Sprintf("%s/books/%d?shelf_id=2&row_number=%d", ts.URL, book.isbn, book.row), nil)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+		}
+	}
+	if _, err := database.Exec(`UPDATE books SET cover_url = ? WHERE isbn = ?`, covers.URL+"/cover.png", b); err != nil {
+		t.Fatalf("failed to set cover: %v", err)
+	}
+
+	render := func(url string) image.Image {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 for %s, got %d", url, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+			t.Errorf("expected a PNG, got %q", ct)
+		}
+		img, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to decode image: %v", err)
+		}
+		return img
+	}
+
+	// office-small has 7 rows.
+	whole := render(ts.URL + "/shelves/2/render.png")
+	row := render(ts.URL + "/shelves/2/render.png?row=1")
+	if whole.Bounds().Dy() <= 6*row.Bounds().Dy() {
+		t.Errorf("expected the bookcase to be taller than 6 rows, got %v and %v", whole.Bounds(), row.Bounds())
+	}
+
+	var spineColor string
+	if err := database.QueryRow(`SELECT spine_color FROM books WHERE isbn = ?`, b).Scan(&spineColor); err != nil {
+		t.Fatalf("failed to get spine colour: %v", err)
+	}
+	if spineColor != "#2040a0" {
+		t.Errorf("expected the spine to take the cover colour, got %q", spineColor)
+	}
+	if n := coverRequests.Load(); n != 1 {
+		t.Errorf("expected the cover to be fetched once, got %d", n)
+	}
+
+	resp, err := http.Get(ts.URL + "/shelves/2/view?row=1")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("failed to close response body: %v", err)
+	}
+	for _, want := range []string{`src="/shelves/2/render.png?row=1"`, fmt.Sprintf(`href="/#book-%d"`, a), fmt.Sprintf(`href="/#book-%d"`, b)} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected the shelf page to contain %s", want)
+		}
+	}
+	if strings.Contains(string(body), fmt.Sprintf(`href="/#book-%d"`, c)) {
+		t.Error("expected only the books on row 1")
+	}
+
+	for url, want := range map[string]int{
+		"/shelves/42/render.png":      http.StatusNotFound,
+		"/shelves/2/render.png?row=0": http.StatusBadRequest,
+		"/shelves/42/view":            http.StatusNotFound,
+	} {
+		resp, err := http.Get(ts.URL + url)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("expected status %d for %s, got %d", want, url, resp.StatusCode)
+		}
+	}
+}
+
+func TestReshelvingPlan(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rowOrder := func(row int) []int {
+		resp, err := http.Get(fmt.Sprintf("%s/shelves/2/rows/%d/books", ts.URL, row))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		var books []models.Book
+		if err := json.NewDecoder(resp.Body).Decode(&books); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var isbns []int
+		for _, book := range books {
+			isbns = append(isbns, book.ISBN)
+		}
+		return isbns
+	}
+
+	// The books have no metadata, so every policy sorts them by ISBN.
+	const a, b, c, d = 9780000000002, 9780000000019, 9780000000026, 9780000000033
+	for _, book := range []struct{ isbn, row int }{{c, 1}, {a, 1}, {b, 2}, {d, 3}} {
+		resp := postJSON(t, fmt.Sprintf("%s/books/%d?shelf_id=2&row_number=%d", ts.URL, book.isbn, book.row), nil)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status 201 for book creation, got %d", resp.StatusCode)
+		}
+	}
+
+	resp := postJSON(t, ts.URL+"/plans", models.PlanRequest{Policy: "shoe size"})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown policy, got %d", resp.StatusCode)
+	}
+	resp = postJSON(t, ts.URL+"/plans", models.PlanRequest{ShelfIDs: []int{42}})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown shelf, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, ts.URL+"/plans", models.PlanRequest{Policy: "title", ShelfIDs: []int{2}, Capacity: 2})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for plan creation, got %d", resp.StatusCode)
+	}
+	var plan models.Plan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// Rows 1 and 2 end up as a, b and c, d. a is already on row 1, so it stays.
+	if plan.Books != 4 || plan.Done != 0 || plan.CompletedAt != nil {
+		t.Errorf("unexpected plan: %+v", plan)
+	}
+	var moved []int
+	for i, move := range plan.Moves {
+		if move.Step != i+1 {
+			t.Errorf("expected move %d to be step %d, got %d", i, i+1, move.Step)
+		}
+		moved = append(moved, move.ISBN)
+	}
+	if diff := cmp.Diff([]int{b, c, d}, moved); diff != "" {
+		t.Fatalf("unexpected moves (-want +got):\n%s", diff)
+	}
+	if want := fmt.Sprintf("Move %d from office-small row 2 to office-small row 1, after %d.", b, a); plan.Moves[0].Instruction != want {
+		t.Errorf("expected instruction %q, got %q", want, plan.Moves[0].Instruction)
+	}
+	if want := fmt.Sprintf("Move %d from office-small row 1 to office-small row 2, at the left end.", c); plan.Moves[1].Instruction != want {
+		t.Errorf("expected instruction %q, got %q", want, plan.Moves[1].Instruction)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/plans/%d/checklist", ts.URL, plan.ID))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), fmt.Sprintf("<td>%d</td>", d)) {
+		t.Errorf("unexpected checklist (status %d):\n%s", resp.StatusCode, body)
+	}
+
+	confirmURL := fmt.Sprintf("%s/plans/%d/confirm", ts.URL, plan.ID)
+	resp = postJSON(t, confirmURL, models.PlanConfirmRequest{ISBN: a})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for a book that stays, got %d", resp.StatusCode)
+	}
+
+	var confirmation models.PlanConfirmation
+	resp = postJSON(t, confirmURL, models.PlanConfirmRequest{ISBN: b})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 when confirming a move, got %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&confirmation); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !confirmation.Move.Done || confirmation.Remaining != 2 || confirmation.Completed || confirmation.Next == nil || confirmation.Next.ISBN != c {
+		t.Errorf("unexpected confirmation: %+v", confirmation)
+	}
+	if diff := cmp.Diff([]int{a, b, c}, rowOrder(1)); diff != "" {
+		t.Errorf("unexpected order of row 1 (-want +got):\n%s", diff)
+	}
+	resp = postJSON(t, confirmURL, models.PlanConfirmRequest{ISBN: b})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for a move already done, got %d", resp.StatusCode)
+	}
+
+	for _, isbn := range []int{d, c} {
+		confirmation = models.PlanConfirmation{}
+		resp = postJSON(t, confirmURL, models.PlanConfirmRequest{ISBN: isbn})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 when confirming a move, got %d", resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&confirmation); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	if !confirmation.Completed || confirmation.Remaining != 0 {
+		t.Errorf("expected the plan to be completed, got %+v", confirmation)
+	}
+	for row, want := range map[int][]int{1: {a, b}, 2: {c, d}, 3: nil} {
+		if diff := cmp.Diff(want, rowOrder(row)); diff != "" {
+			t.Errorf("unexpected order of row %d (-want +got):\n%s", row, diff)
+		}
+	}
+
+	resp = postJSON(t, confirmURL, models.PlanConfirmRequest{ISBN: c})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for a completed plan, got %d", resp.StatusCode)
+	}
+
+	// Everything is in order now, so a new plan has nothing to move.
+	resp = postJSON(t, ts.URL+"/plans", models.PlanRequest{Policy: "title", ShelfIDs: []int{2}, Capacity: 2})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for plan creation, got %d", resp.StatusCode)
+	}
+	plan = models.Plan{}
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(plan.Moves) != 0 || plan.CompletedAt == nil {
+		t.Errorf("expected an empty, completed plan, got %+v", plan)
+	}
+}
+
+func TestScanEventsAndUndo(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	const a, b = 9783836526722, 9780000000001
+	scanned := func(path, mode string) string {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		return fmt.Sprintf("%s%s%sscan_device=desk&scan_source=test&scan_mode=%s", ts.URL, path, sep, mode)
+	}
+	place := func(isbn int) string {
+		resp, err := http.Get(fmt.Sprintf("%s/books/%d/locate", ts.URL, isbn))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode == http.StatusNotFound {
+			return "gone"
+		}
+		var locator models.BookLocator
+		if err := json.NewDecoder(resp.Body).Decode(&locator); err != nil {
+			t.Fatalf("failed to decode locator: %v", err)
+		}
+		got := fmt.Sprintf("%d/%d", locator.ShelfID, locator.RowNumber)
+		if locator.Borrower != "" {
+			got += " lent to " + locator.Borrower
+		}
+		return got
+	}
+	scans := func() []models.ScanEvent {
+		resp, err := http.Get(ts.URL + "/scans?device=desk")
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		var events []models.ScanEvent
+		if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+			t.Fatalf("failed to decode scans: %v", err)
+		}
+		return events
+	}
+
+	requests := []struct {
+		method string
+		url    string
+		body   any
+	}{
+		{http.MethodPost, scanned(fmt.Sprintf("/books/%d?shelf_id=1&row_number=1", b), "catalogue"), nil},
+		{http.MethodPost, scanned(fmt.Sprintf("/books/%d?shelf_id=1&row_number=1", a), "catalogue"), nil},
+		{http.MethodPost, scanned(fmt.Sprintf("/books/%d?shelf_id=2&row_number=1", a), "catalogue"), nil},
+		{http.MethodPut, scanned(fmt.Sprintf("/books/%d/location", a), "move"), api.BookLocationRequest{ShelfID: 3, RowNumber: 2}},
+		{http.MethodPost, scanned("/books/borrow", "lending"), models.BorrowRequest{ISBN: a, PersonName: "Ann"}},
+		{http.MethodPost, scanned("/books/return", "return"), models.ReturnRequest{ISBN: a}},
+		{http.MethodDelete, scanned(fmt.Sprintf("/books/%d", b), "delete"), nil},
+		// Requests not made by a scanner are not recorded.
+		{http.MethodPut, fmt.Sprintf("%s/books/%d/location", ts.URL, a), api.BookLocationRequest{ShelfID: 3, RowNumber: 2}},
+	}
+	for _, r := range requests {
+		if resp := sendJSON(t, r.method, r.url, r.body); resp.StatusCode/100 != 2 {
+			t.Fatalf("%s %s: unexpected status %d", r.method, r.url, resp.StatusCode)
+		}
+	}
+
+	shelf := models.ScanEventRequest{Device: "desk", Code: "LS:L:3:2", Mode: "catalogue", Action: "shelf", Outcome: "ok", ShelfID: 3, RowNumber: 2}
+	if resp := postJSON(t, ts.URL+"/scans", shelf); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for a shelf scan, got %d", resp.StatusCode)
+	}
+	forged := models.ScanEventRequest{Device: "desk", Code: "9783836526722", Action: "add", Outcome: "ok"}
+	if resp := postJSON(t, ts.URL+"/scans", forged); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a book scan, got %d", resp.StatusCode)
+	}
+
+	events := scans()
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action)
+	}
+	if diff := cmp.Diff([]string{"shelf", "delete", "return", "borrow", "move", "move", "add", "add"}, actions); diff != "" {
+		t.Fatalf("unexpected scans (-want +got):\n%s", diff)
+	}
+	if e := events[1]; e.Code != "9780000000001" || e.Mode != "delete" || e.Source != "test" || e.Book == nil || e.Book.ShelfID != 1 || !e.Undoable {
+		t.Errorf("unexpected delete scan: %+v", e)
+	}
+	if e := events[0]; e.Undoable {
+		t.Errorf("shelf scans cannot be undone: %+v", e)
+	}
+
+	// Scans of a book that was scanned again since cannot be undone.
+	for _, e := range []models.ScanEvent{events[6], events[3]} {
+		if resp := postJSON(t, fmt.Sprintf("%s/scans/%d/undo", ts.URL, e.ID), nil); resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected status 409 for undoing an earlier %s, got %d", e.Action, resp.StatusCode)
+		}
+	}
+
+	// Each UNDO reverts the last scan that has not been undone yet.
+	for _, want := range []struct{ a, b string }{
+		{a: "3/2", b: "1/1"},
+		{a: "3/2 lent to Ann", b: "1/1"},
+		{a: "3/2", b: "1/1"},
+		{a: "2/1", b: "1/1"},
+		{a: "1/1", b: "1/1"},
+		{a: "gone", b: "1/1"},
+	} {
+		if resp := postJSON(t, ts.URL+"/scans/undo", models.ScanUndoRequest{Device: "desk"}); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 for undo, got %d", resp.StatusCode)
+		}
+		if got := (struct{ a, b string }{a: place(a), b: place(b)}); got != want {
+			t.Errorf("unexpected books after undo: got %+v, want %+v", got, want)
+		}
+	}
+
+	// The first scan can be undone by id too.
+	addB := events[len(events)-1]
+	if resp := postJSON(t, fmt.Sprintf("%s/scans/%d/undo", ts.URL, addB.ID), nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for undo, got %d", resp.StatusCode)
+	}
+	if got := place(b); got != "gone" {
+		t.Errorf("expected the added book to be gone, got %s", got)
+	}
+	if resp := postJSON(t, fmt.Sprintf("%s/scans/%d/undo", ts.URL, addB.ID), nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for a scan already undone, got %d", resp.StatusCode)
+	}
+	if resp := postJSON(t, ts.URL+"/scans/undo", models.ScanUndoRequest{Device: "desk"}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 with nothing to undo, got %d", resp.StatusCode)
+	}
+
+	undone := 0
+	for _, e := range scans() {
+		switch {
+		case e.Action == "undo":
+			undone++
+		case e.Undoable || (e.Action != "shelf" && e.UndoneAt == nil):
+			t.Errorf("expected scan to be undone: %+v", e)
+		}
+	}
+	if undone != 6 {
+		t.Errorf("expected 6 undo scans, got %d", undone)
+	}
+
+	// Undoing a loan only cancels the loan the scan made.
+	for _, r := range []struct {
+		method string
+		url    string
+		body   any
+	}{
+		{http.MethodPost, fmt.Sprintf("%s/books/%d?shelf_id=1&row_number=1", ts.URL, a), nil},
+		{http.MethodPost, scanned("/books/borrow", "lending"), models.BorrowRequest{ISBN: a, PersonName: "Ann"}},
+		{http.MethodPost, ts.URL + "/books/return", models.ReturnRequest{ISBN: a}},
+		{http.MethodPost, ts.URL + "/books/borrow", models.BorrowRequest{ISBN: a, PersonName: "Bob"}},
+	} {
+		if resp := sendJSON(t, r.method, r.url, r.body); resp.StatusCode/100 != 2 {
+			t.Fatalf("%s %s: unexpected status %d", r.method, r.url, resp.StatusCode)
+		}
+	}
+	if resp := postJSON(t, ts.URL+"/scans/undo", models.ScanUndoRequest{Device: "desk"}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 for undoing a loan that was returned, got %d", resp.StatusCode)
+	}
+	if got := place(a); got != "1/1 lent to Bob" {
+		t.Errorf("expected the book to stay lent to Bob, got %s", got)
+	}
+
+	// Moves and loans made with the API since a book was added keep it.
+	if resp := sendJSON(t, http.MethodPost, scanned(fmt.Sprintf("/books/%d?shelf_id=1&row_number=1", b), "catalogue"), nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201 for adding a book, got %d", resp.StatusCode)
+	}
+	for _, r := range []struct {
+		what   string
+		method string
+		url    string
+		body   any
+		want   string
+	}{
+		{"move", http.MethodPut, fmt.Sprintf("%s/books/%d/location", ts.URL, b), api.BookLocationRequest{ShelfID: 2, RowNumber: 1}, "2/1"},
+		{"loan", http.MethodPost, ts.URL + "/books/borrow", models.BorrowRequest{ISBN: b, PersonName: "Ann"}, "1/1 lent to Ann"},
+	} {
+		if resp := sendJSON(t, r.method, r.url, r.body); resp.StatusCode/100 != 2 {
+			t.Fatalf("%s: unexpected status %d", r.what, resp.StatusCode)
+		}
+		if resp := postJSON(t, ts.URL+"/scans/undo", models.ScanUndoRequest{Device: "desk"}); resp.StatusCode != http.StatusConflict {
+			t.Errorf("expected status 409 for undoing an add after a %s, got %d", r.what, resp.StatusCode)
+		}
+		if got := place(b); got != r.want {
+			t.Errorf("expected the book to stay at %s after the %s, got %s", r.want, r.what, got)
+		}
+		// Put the book back where it was added for the next case.
+		if resp := sendJSON(t, http.MethodPut, fmt.Sprintf("%s/books/%d/location", ts.URL, b), api.BookLocationRequest{ShelfID: 1, RowNumber: 1}); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200 for moving the book back, got %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestAddBookCopies(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	const isbn = 9783836526722
+	add := func(shelf, status int) models.AddedBook {
+		url := fmt.Sprintf("%s/books/%d?shelf_id=%d&row_number=1&copy=true&scan_device=desk&scan_source=test&scan_mode=catalogue", ts.URL, isbn, shelf)
+		resp := sendJSON(t, http.MethodPost, url, nil)
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		}()
+		if resp.StatusCode != status {
+			t.Fatalf("expected status %d, got %d", status, resp.StatusCode)
+		}
+		var added models.AddedBook
+		if err := json.NewDecoder(resp.Body).Decode(&added); err != nil {
+			t.Fatalf("failed to decode book: %v", err)
+		}
+		return added
+	}
+
+	// A book that is not catalogued yet is added as usual.
+	if added := add(1, http.StatusCreated); added.Outcome != models.AddOutcomeAdded || added.Copies != 1 {
+		t.Fatalf("expected the book to be added with one copy, got %s with %d", added.Outcome, added.Copies)
+	}
+	// Scanning it again counts another copy, which stays where the book is.
+	added := add(2, http.StatusOK)
+	if added.Outcome != models.AddOutcomeNewCopy || added.Copies != 2 || added.ShelfID != 1 {
+		t.Fatalf("expected a second copy on shelf 1, got %s with %d on shelf %d", added.Outcome, added.Copies, added.ShelfID)
+	}
+
+	if resp := postJSON(t, ts.URL+"/scans/undo", models.ScanUndoRequest{Device: "desk"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 for undo, got %d", resp.StatusCode)
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/books/%d", ts.URL, isbn))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var book models.Book
+	if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if book.Copies != 1 {
+		t.Errorf("expected undo to take the copy off, got %d copies", book.Copies)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events?device=desk", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := stream.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+
+	scanned := func(path, device string) string {
+		return fmt.Sprintf("%s%s&scan_device=%s&scan_source=test&scan_mode=catalogue", ts.URL, path, device)
+	}
+	postJSON(t, ts.URL+"/scans", models.ScanEventRequest{Device: "desk", Code: "LS:L:1:2", Mode: "catalogue", Action: "shelf", Outcome: "ok", ShelfID: 1, RowNumber: 2})
+	postJSON(t, scanned("/books/9783836526722?shelf_id=1&row_number=2", "desk"), nil)
+	postJSON(t, scanned("/books/9783836526722?shelf_id=1&row_number=2", "desk"), nil)
+	// Other scanners are not streamed.
+	postJSON(t, scanned("/books/9783836526722?shelf_id=1&row_number=1", "hall"), nil)
+	postJSON(t, scanned("/books/9783836526722?shelf_id=1&row_number=3", "desk"), nil)
+	postJSON(t, ts.URL+"/scans", models.ScanEventRequest{Device: "desk", Code: "12345", Mode: "catalogue", Action: "invalid", Outcome: "failed", ShelfID: 1, RowNumber: 3})
+
+	type event struct {
+		name string
+		data events.Event
+	}
+	var got []event
+	reader := bufio.NewReader(stream.Body)
+	var name string
+	for len(got) < 5 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event stream after %d events: %v", len(got), err)
+		}
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			var e events.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				t.Fatalf("failed to decode event: %v", err)
+			}
+			got = append(got, event{name: name, data: e})
+		}
+	}
+
+	wantNames := []string{"shelf-changed", "book-added", "book-added", "book-moved", "scan-failed"}
+	for i, e := range got {
+		if e.name != wantNames[i] || string(e.data.Kind) != wantNames[i] || e.data.Device != "desk" {
+			t.Fatalf("event %d: expected %s from desk, got %s %+v", i, wantNames[i], e.name, e.data)
+		}
+	}
+	if shelf := got[0].data.Shelf; shelf == nil || shelf.ShelfID != 1 || shelf.RowNumber != 2 || shelf.ShelfName == "" {
+		t.Errorf("expected the shelf change to name shelf 1 row 2, got %+v", shelf)
+	}
+	if got[1].data.Outcome != models.AddOutcomeAdded || got[1].data.Book == nil || got[1].data.Book.Title == "" {
+		t.Errorf("expected the book to be added, got %+v", got[1].data)
+	}
+	if got[2].data.Outcome != models.AddOutcomeAlreadyHere || got[2].data.Code != "9783836526722" {
+		t.Errorf("expected the book to be already here, got %+v", got[2].data)
+	}
+	if from := got[3].data.MovedFrom; from == nil || from.RowNumber != 1 || got[3].data.Book.RowNumber != 3 {
+		t.Errorf("expected the book to move from row 1 to row 3, got %+v", got[3].data)
+	}
+	if got[4].data.Action != "invalid" || got[4].data.Code != "12345" {
+		t.Errorf("expected the invalid scan to fail, got %+v", got[4].data)
+	}
+
+	resp, err := http.Get(ts.URL + "/station?device=desk")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+	}
+	page := string(body)
+	for _, want := range []string{got[3].data.Book.Title, "Moved", "invalid failed: 12345", "/events"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the scan station to show %q", want)
+		}
+	}
+}
+
+func TestScanImage(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	// barcodePhoto draws a barcode on white, the way it is printed on the
+	// back of a book.
+	barcodePhoto := func(code string) image.Image {
+		img := image.NewGray(image.Rect(0, 0, 400, 200))
+		draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+		if code == "" {
+			return img
+		}
+		bc, err := ean.Encode(code)
+		if err != nil {
+			t.Fatalf("failed to encode barcode: %v", err)
+		}
+		scaled, err := barcode.Scale(bc, 95*3, 120)
+		if err != nil {
+			t.Fatalf("failed to scale barcode: %v", err)
+		}
+		draw.Draw(img, scaled.Bounds().Add(image.Pt(50, 40)), scaled, image.Point{}, draw.Src)
+		return img
+	}
+	upload := func(url string, img image.Image, form bool) *http.Response {
+		var body bytes.Buffer
+		contentType := "image/png"
+		if form {
+			w := multipart.NewWriter(&body)
+			part, err := w.CreateFormFile("image", "photo.png")
+			if err != nil {
+				t.Fatalf("failed to create form file: %v", err)
+			}
+			if err := png.Encode(part, img); err != nil {
+				t.Fatalf("failed to encode photo: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("failed to close form: %v", err)
+			}
+			contentType = w.FormDataContentType()
+		} else if err := png.Encode(&body, img); err != nil {
+			t.Fatalf("failed to encode photo: %v", err)
+		}
+
+		resp, err := http.Post(url, contentType, &body)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := resp.Body.Close(); err != nil {
+				t.Logf("failed to close response body: %v", err)
+			}
+		})
+		return resp
+	}
+	decode := func(resp *http.Response) api.ImageScan {
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, body)
+		}
+		var scan api.ImageScan
+		if err := json.NewDecoder(resp.Body).Decode(&scan); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return scan
+	}
+
+	url := ts.URL + "/scan/image?shelf_id=1&row_number=2&scan_device=phone&scan_source=photo&scan_mode=catalogue"
+	scan := decode(upload(url, barcodePhoto("9783836526722"), true))
+	if diff := cmp.Diff([]string{"9783836526722"}, scan.Codes); diff != "" {
+		t.Errorf("codes mismatch (-want +got):\n%s", diff)
+	}
+	if len(scan.Books) != 1 || scan.Books[0].Outcome != models.AddOutcomeAdded || scan.Books[0].ISBN != 9783836526722 ||
+		scan.Books[0].ShelfID != 1 || scan.Books[0].RowNumber != 2 || scan.Books[0].Title == "" {
+		t.Errorf("expected the book to be added to shelf 1 row 2, got %+v", scan.Books)
+	}
+
+	// The photo can also be the whole request body.
+	scan = decode(upload(url, barcodePhoto("9783836526722"), false))
+	if len(scan.Books) != 1 || scan.Books[0].Outcome != models.AddOutcomeAlreadyHere {
+		t.Errorf("expected the book to be already here, got %+v", scan.Books)
+	}
+
+	resp := upload(url, barcodePhoto(""), true)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for a photo without a barcode, got %d", resp.StatusCode)
+	}
+
+	// Photos that would take too much memory to decode are turned away.
+	resp = upload(url, image.NewGray(image.Rect(0, 0, 8000, 6000)), false)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a 48 megapixel photo, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Post(ts.URL+"/scan/image", "image/png", strings.NewReader("not a photo"))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Logf("failed to close response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a request without a photo, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/scans?device=phone")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var scans []models.ScanEvent
+	if err := json.NewDecoder(resp.Body).Decode(&scans); err != nil {
+		t.Fatalf("failed to decode scans: %v", err)
+	}
+	var got []string
+	for _, s := range scans {
+		got = append(got, s.Code+" "+s.Outcome)
+	}
+	want := []string{"photo failed", "9783836526722 already_here", "9783836526722 ok"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("scans mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAddBookWithPriceSupplement(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	add := func(query string) models.AddedBook {
+		resp := postJSON(t, fmt.Sprintf("%s/books/9783836526722?shelf_id=1&row_number=1%s", ts.URL, query), nil)
+		if resp.StatusCode/100 != 2 {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("expected success, got %d: %s", resp.StatusCode, body)
+		}
+		var book models.AddedBook
+		if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return book
+	}
+
+	book := add("&add_on=51299")
+	if book.PriceSupplement != "51299" || !cmp.Equal(book.Price, &models.Price{Currency: "USD", Amount: "12.99"}) {
+		t.Errorf("expected the price to be $12.99, got %q %+v", book.PriceSupplement, book.Price)
+	}
+
+	// Scanners that do not send the add-on keep the one the book has.
+	book = add("")
+	if book.PriceSupplement != "51299" {
+		t.Errorf("expected the price supplement to be kept, got %q", book.PriceSupplement)
+	}
+
+	book = add("&add_on=00799")
+	if book.PriceSupplement != "00799" || !cmp.Equal(book.Price, &models.Price{Currency: "GBP", Amount: "7.99"}) {
+		t.Errorf("expected the price to be £7.99, got %q %+v", book.PriceSupplement, book.Price)
+	}
+
+	resp, err := http.Get(ts.URL + "/books/9783836526722")
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Logf("failed to close response body: %v", err)
+		}
+	}()
+	var got models.Book
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode book: %v", err)
+	}
+	if got.PriceSupplement != "00799" || got.Price == nil || got.Price.Amount != "7.99" {
+		t.Errorf("expected the stored price to be £7.99, got %q %+v", got.PriceSupplement, got.Price)
+	}
+
+	resp = postJSON(t, ts.URL+"/books/9783836526722?add_on=5129", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a bad add-on, got %d", resp.StatusCode)
+	}
+}
+
+func TestAPIVersionAndErrors(t *testing.T) {
+	cleanupMocks := setupMockServers(t)
+	defer cleanupMocks()
+
+	ts, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	decodeError := func(resp *http.Response) api.Error {
+		t.Helper()
+		var body api.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode error: %v", err)
+		}
+		if body.Error.RequestID == "" || body.Error.RequestID != resp.Header.Get(echo.HeaderXRequestID) {
+			t.Errorf("expected the error to carry the request id %q, got %q", resp.Header.Get(echo.HeaderXRequestID), body.Error.RequestID)
+		}
+		return body.Error
+	}
+
+	// Books added under /api/v1 are there at the old paths too.
+	resp := postJSON(t, ts.URL+"/api/v1/books/9783836526722?shelf_id=1&row_number=1", nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", resp.StatusCode)
+	}
+	for _, path := range []string{"/api/v1/books/9783836526722", "/books/9783836526722"} {
+		resp := sendJSON(t, http.MethodGet, ts.URL+path, nil)
+		var book api.Book
+		if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
+			t.Fatalf("%s: failed to decode book: %v", path, err)
+		}
+		if resp.StatusCode != http.StatusOK || book.ISBN != 9783836526722 {
+			t.Errorf("%s: expected the book, got %d %+v", path, resp.StatusCode, book)
+		}
+	}
+
+	tests := []struct {
+		method, path string
+		body         any
+		status       int
+		code         api.ErrorCode
+		message      string
+	}{
+		{http.MethodGet, "/api/v1/books/9780000000002", nil, http.StatusNotFound, api.CodeNotFound, "Book not found"},
+		{http.MethodGet, "/books/978", nil, http.StatusBadRequest, api.CodeBadRequest, "Invalid ISBN"},
+		{http.MethodGet, "/api/v1/nothing", nil, http.StatusNotFound, api.CodeNotFound, "Not Found"},
+		{http.MethodPut, "/api/v1/stats", nil, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, "Method Not Allowed"},
+	}
+	for _, tc := range tests {
+		resp := sendJSON(t, tc.method, ts.URL+tc.path, tc.body)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, resp.StatusCode)
+			continue
+		}
+		got := decodeError(resp)
+		got.RequestID = ""
+		want := api.Error{Code: tc.code, Message: tc.message}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s %s: error mismatch (-want +got):\n%s", tc.method, tc.path, diff)
+		}
+	}
+
+	// Bodies that cannot be read say why.
+	resp = postJSON(t, ts.URL+"/api/v1/shelves", "not a shelf")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", resp.StatusCode)
+	}
+	if got := decodeError(resp); got.Message != "invalid request body" || !strings.Contains(got.Details["reason"], "Unmarshal type error") {
+		t.Errorf("expected the reason the body is invalid, got %+v", got)
+	}
+}
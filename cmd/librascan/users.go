@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/auth"
+	"github.com/gouthamve/librascan/pkg/db"
+	"golang.org/x/term"
+)
+
+// openDatabase migrates and opens the server's database, for the commands
+// that manage it directly.
+func openDatabase() *sql.DB {
+	migrate()
+	db, err := sql.Open("sqlite", database)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	return db
+}
+
+// readPassword asks for a password on the terminal without echoing it, or
+// reads the first line of stdin when it is not a terminal.
+func readPassword(w io.Writer) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		_, _ = fmt.Fprint(w, "Password (empty for none): ")
+		password, err := term.ReadPassword(fd)
+		_, _ = fmt.Fprintln(w)
+		return string(password), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// passwordHash hashes a password. Users without one can only use API
+// tokens.
+func passwordHash(password string) (sql.NullString, error) {
+	if password == "" {
+		return sql.NullString{}, nil
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: hash, Valid: true}, nil
+}
+
+// createUser adds a user with a role and, if it is not empty, a password to
+// sign in to the web interface with.
+func createUser(w io.Writer, queries *db.Queries, name string, role auth.Role, password string) error {
+	if name == "" {
+		return fmt.Errorf("name is empty")
+	}
+	hash, err := passwordHash(password)
+	if err != nil {
+		return err
+	}
+	user, err := queries.CreateUser(context.Background(), db.CreateUserParams{
+		Name:         name,
+		Role:         string(role),
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create user %q: %w", name, err)
+	}
+	_, err = fmt.Fprintf(w, "Created %s %q.\n", user.Role, user.Name)
+	return err
+}
+
+// setPassword changes the password of a user, or removes it if it is
+// empty.
+func setPassword(w io.Writer, queries *db.Queries, name, password string) error {
+	ctx := context.Background()
+	user, err := queries.GetUserByName(ctx, name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user %q", name)
+	}
+	if err != nil {
+		return err
+	}
+	hash, err := passwordHash(password)
+	if err != nil {
+		return err
+	}
+	if err := queries.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{PasswordHash: hash, ID: user.ID}); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Changed the password of %q.\n", name)
+	return err
+}
+
+// createToken makes an API token for a user and prints it. Only its hash is
+// kept, so it cannot be shown again. A user that does not exist is created
+// with role, unless role is empty.
+func createToken(w io.Writer, queries *db.Queries, userName string, role auth.Role, label string) error {
+	ctx := context.Background()
+	user, err := queries.GetUserByName(ctx, userName)
+	if err == sql.ErrNoRows {
+		if role == "" {
+			return fmt.Errorf("no user %q, give --role to create them", userName)
+		}
+		user, err = queries.CreateUser(ctx, db.CreateUserParams{Name: userName, Role: string(role)})
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get user %q: %w", userName, err)
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	_, err = queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:    user.ID,
+		Name:      label,
+		TokenHash: auth.HashToken(token),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create token: %w", err)
+	}
+	_, err = fmt.Fprintln(w, token)
+	return err
+}
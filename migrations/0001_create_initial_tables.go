@@ -11,8 +11,10 @@ func init() {
 	goose.AddMigrationContext(Up0001, Down0001)
 }
 
+// initialShelfs are the shelves the library started out with. New shelves are
+// added through the API, this list must not change.
 var (
-	Shelfs = []struct {
+	initialShelfs = []struct {
 		Name string
 		Rows int
 	}{
@@ -92,7 +94,7 @@ CREATE TABLE categories (
 		return err
 	}
 
-	for _, shelf := range Shelfs {
+	for _, shelf := range initialShelfs {
 		query = `INSERT INTO shelfs (name, rows_count) VALUES (?, ?);`
 		_, err = tx.ExecContext(ctx, query, shelf.Name, shelf.Rows)
 		if err != nil {
@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0005, Down0005)
+}
+
+func Up0005(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE people
+	ADD COLUMN email TEXT;
+
+	ALTER TABLE borrowing
+	ADD COLUMN due_at TEXT;
+
+	UPDATE borrowing SET due_at = datetime(borrowed_at, '+28 days') WHERE due_at IS NULL;
+
+	CREATE TABLE holds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		isbn INTEGER NOT NULL,
+		person_id INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		notified_at TEXT,
+		fulfilled_at TEXT,
+		FOREIGN KEY(isbn) REFERENCES books(ISBN),
+		FOREIGN KEY(person_id) REFERENCES people(id)
+	);
+
+	CREATE TABLE loan_notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		borrowing_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		sent_at TEXT NOT NULL,
+		UNIQUE(borrowing_id, kind),
+		FOREIGN KEY(borrowing_id) REFERENCES borrowing(id)
+	);
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0005(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	DROP TABLE loan_notifications;
+	DROP TABLE holds;
+
+	ALTER TABLE borrowing
+	DROP COLUMN due_at;
+
+	ALTER TABLE people
+	DROP COLUMN email;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0006, Down0006)
+}
+
+func Up0006(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE people
+	ADD COLUMN calendar_token TEXT;
+
+	UPDATE people SET calendar_token = lower(hex(randomblob(16))) WHERE calendar_token IS NULL;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0006(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE people
+	DROP COLUMN calendar_token;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
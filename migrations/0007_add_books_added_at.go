@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0007, Down0007)
+}
+
+// Up0007 records when books are added. Books added before this migration
+// have no date.
+func Up0007(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE books
+	ADD COLUMN added_at TEXT;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0007(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE books
+	DROP COLUMN added_at;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
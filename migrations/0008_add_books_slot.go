@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0008, Down0008)
+}
+
+// Up0008 adds the slot within a shelf row, for bins, boxes or positions
+// carried by the versioned location codes.
+func Up0008(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE books
+	ADD COLUMN slot INTEGER;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0008(ctx context.Context, tx *sql.Tx) error {
+	query := `
+	ALTER TABLE books
+	DROP COLUMN slot;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,123 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0009, Down0009)
+}
+
+// Up0009 creates the location tree and moves every shelf into it as a
+// bookcase with one child per row. Books are attached to the row they sit on,
+// or to the bookcase when the row is not known.
+func Up0009(ctx context.Context, tx *sql.Tx) error {
+	query := `
+CREATE TABLE locations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	parent_id INTEGER,
+	type TEXT NOT NULL,
+	name TEXT NOT NULL,
+	shelf_id INTEGER,
+	row_number INTEGER,
+	UNIQUE(parent_id, name),
+	FOREIGN KEY(parent_id) REFERENCES locations(id),
+	FOREIGN KEY(shelf_id) REFERENCES shelfs(id)
+);
+
+ALTER TABLE books
+ADD COLUMN location_id INTEGER;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// Books can sit on rows past rows_count, those rows get a location too.
+	rows, err := tx.QueryContext(ctx, `
+SELECT s.id, s.name, MAX(COALESCE(s.rows_count, 0), COALESCE(MAX(b.row_number), 0))
+FROM shelfs s
+LEFT JOIN books b ON b.shelf_id = s.id
+WHERE s.id != 0
+GROUP BY s.id
+ORDER BY s.id;`)
+	if err != nil {
+		return err
+	}
+	type shelf struct {
+		id   int64
+		name string
+		rows int
+	}
+	var shelves []shelf
+	for rows.Next() {
+		var s shelf
+		var name sql.NullString
+		if err := rows.Scan(&s.id, &name, &s.rows); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		s.name = name.String
+		if s.name == "" {
+			s.name = fmt.Sprintf("shelf-%d", s.id)
+		}
+		shelves = append(shelves, s)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range shelves {
+		query = `INSERT INTO locations (type, name, shelf_id) VALUES ('bookcase', ?, ?);`
+		res, err := tx.ExecContext(ctx, query, s.name, s.id)
+		if err != nil {
+			return err
+		}
+		parentID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for row := 1; row <= s.rows; row++ {
+			query = `INSERT INTO locations (parent_id, type, name, shelf_id, row_number) VALUES (?, 'row', ?, ?, ?);`
+			_, err = tx.ExecContext(ctx, query, parentID, fmt.Sprintf("Row %d", row), s.id, row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	query = `
+UPDATE books
+SET location_id = (
+	SELECT l.id FROM locations l
+	WHERE l.shelf_id = books.shelf_id
+		AND (l.row_number = books.row_number OR l.row_number IS NULL)
+	ORDER BY l.row_number IS NULL
+	LIMIT 1
+)
+WHERE shelf_id IS NOT NULL;
+`
+	_, err = tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0009(ctx context.Context, tx *sql.Tx) error {
+	query := `
+ALTER TABLE books
+DROP COLUMN location_id;
+
+DROP TABLE IF EXISTS locations;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
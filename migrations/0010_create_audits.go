@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0010, Down0010)
+}
+
+// Up0010 creates the tables for shelf audits. The report of an audit is kept
+// as JSON once it is closed, so later moves do not change it.
+func Up0010(ctx context.Context, tx *sql.Tx) error {
+	query := `
+CREATE TABLE audits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	location_id INTEGER NOT NULL,
+	started_at TEXT NOT NULL,
+	closed_at TEXT,
+	applied_at TEXT,
+	report TEXT,
+	FOREIGN KEY(location_id) REFERENCES locations(id)
+);
+
+CREATE TABLE audit_scans (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	audit_id INTEGER NOT NULL,
+	isbn INTEGER NOT NULL,
+	scanned_at TEXT NOT NULL,
+	UNIQUE(audit_id, isbn),
+	FOREIGN KEY(audit_id) REFERENCES audits(id)
+);
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0010(ctx context.Context, tx *sql.Tx) error {
+	query := `
+DROP TABLE IF EXISTS audit_scans;
+DROP TABLE IF EXISTS audits;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
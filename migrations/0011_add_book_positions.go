@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0011, Down0011)
+}
+
+// Up0011 records the order books were scanned onto their row in. Books that
+// are already on a row are numbered in the order they were added.
+func Up0011(ctx context.Context, tx *sql.Tx) error {
+	query := `
+ALTER TABLE books ADD COLUMN position INTEGER;
+
+UPDATE books
+SET position = (
+	SELECT COUNT(*) FROM books b
+	WHERE b.shelf_id = books.shelf_id
+		AND b.row_number = books.row_number
+		AND (COALESCE(b.added_at, '') < COALESCE(books.added_at, '')
+			OR (COALESCE(b.added_at, '') = COALESCE(books.added_at, '') AND b.isbn <= books.isbn))
+)
+WHERE shelf_id IS NOT NULL AND row_number IS NOT NULL;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0011(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books DROP COLUMN position;`)
+	return err
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0012, Down0012)
+}
+
+// Up0012 adds the colour book spines are drawn in, taken from their cover the
+// first time the shelf is rendered.
+func Up0012(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books ADD COLUMN spine_color TEXT;`)
+	return err
+}
+
+func Down0012(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books DROP COLUMN spine_color;`)
+	return err
+}
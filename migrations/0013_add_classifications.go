@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0013, Down0013)
+}
+
+// Up0013 adds the Dewey Decimal class of books, so they can be shelved by
+// classification.
+func Up0013(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books ADD COLUMN classification TEXT;`)
+	return err
+}
+
+func Down0013(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books DROP COLUMN classification;`)
+	return err
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0014, Down0014)
+}
+
+// Up0014 creates the tables for reshelving plans. Every book in a plan has a
+// row in plan_books with where it is and where it goes; books that have to
+// move also have the step they are moved in.
+func Up0014(ctx context.Context, tx *sql.Tx) error {
+	query := `
+CREATE TABLE plans (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	policy TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	completed_at TEXT
+);
+
+CREATE TABLE plan_books (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	plan_id INTEGER NOT NULL,
+	isbn INTEGER NOT NULL,
+	from_shelf_id INTEGER NOT NULL,
+	from_row INTEGER NOT NULL,
+	from_position INTEGER NOT NULL,
+	to_shelf_id INTEGER NOT NULL,
+	to_row INTEGER NOT NULL,
+	to_position INTEGER NOT NULL,
+	after_isbn INTEGER,
+	step INTEGER,
+	done_at TEXT,
+	UNIQUE(plan_id, isbn),
+	FOREIGN KEY(plan_id) REFERENCES plans(id)
+);
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0014(ctx context.Context, tx *sql.Tx) error {
+	query := `
+DROP TABLE IF EXISTS plan_books;
+DROP TABLE IF EXISTS plans;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
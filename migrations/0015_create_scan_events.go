@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0015, Down0015)
+}
+
+// Up0015 creates the log of scans. Scans that change the library keep the
+// book and where it was before, so that they can be undone.
+func Up0015(ctx context.Context, tx *sql.Tx) error {
+	query := `
+CREATE TABLE scan_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	scanned_at TEXT NOT NULL,
+	device TEXT NOT NULL,
+	source TEXT,
+	code TEXT NOT NULL,
+	mode TEXT NOT NULL,
+	action TEXT NOT NULL,
+	outcome TEXT NOT NULL,
+	shelf_id INTEGER,
+	row_number INTEGER,
+	slot INTEGER,
+	isbn INTEGER,
+	person_id INTEGER,
+	book TEXT,
+	previous TEXT,
+	undone_at TEXT
+);
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0015(ctx context.Context, tx *sql.Tx) error {
+	query := `
+DROP TABLE IF EXISTS scan_events;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
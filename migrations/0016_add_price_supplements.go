@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0016, Down0016)
+}
+
+// Up0016 adds the price supplement of books, the 5-digit add-on printed after
+// the ISBN barcode.
+func Up0016(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books ADD COLUMN price_supplement TEXT;`)
+	return err
+}
+
+func Down0016(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books DROP COLUMN price_supplement;`)
+	return err
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0017, Down0017)
+}
+
+// Up0017 creates the users, the API tokens of the scanners and scripts they
+// run, and the sessions of the ones signed in to the web interface. Only
+// hashes of passwords, tokens and sessions are kept.
+func Up0017(ctx context.Context, tx *sql.Tx) error {
+	query := `
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	role TEXT NOT NULL,
+	password_hash TEXT,
+	created_at TEXT NOT NULL
+);
+
+CREATE TABLE api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TEXT NOT NULL,
+	last_used_at TEXT
+);
+
+CREATE TABLE sessions (
+	token_hash TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TEXT NOT NULL,
+	expires_at TEXT NOT NULL
+);
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func Down0017(ctx context.Context, tx *sql.Tx) error {
+	query := `
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS users;
+`
+
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0018, Down0018)
+}
+
+// Up0018 records the loan a borrow or return scan made or ended, so that
+// undoing it changes that loan and no other.
+func Up0018(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE scan_events ADD COLUMN borrowing_id INTEGER;`)
+	return err
+}
+
+func Down0018(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE scan_events DROP COLUMN borrowing_id;`)
+	return err
+}
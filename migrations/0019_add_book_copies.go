@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(Up0019, Down0019)
+}
+
+// Up0019 counts the copies of a book. They share its catalogue entry and
+// place.
+func Up0019(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books ADD COLUMN copies INTEGER NOT NULL DEFAULT 1;`)
+	return err
+}
+
+func Down0019(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE books DROP COLUMN copies;`)
+	return err
+}
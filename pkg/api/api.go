@@ -0,0 +1,8 @@
+// Package api holds the types librascan's JSON API sends and receives. They
+// are kept apart from the models the server works with, so that the models
+// can change without breaking the scanners and scripts that talk to it.
+package api
+
+// Prefix is where the JSON API is served. The same endpoints are also
+// served at the root for clients from before it was versioned.
+const Prefix = "/api/v1"
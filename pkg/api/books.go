@@ -0,0 +1,132 @@
+package api
+
+import "github.com/gouthamve/librascan/pkg/models"
+
+// Book is a catalogued book.
+type Book struct {
+	ISBN          int      `json:"isbn"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Authors       []string `json:"authors"`
+	Publisher     string   `json:"publisher"`
+	PublishedDate string   `json:"published_date"`
+	Categories    []string `json:"categories"`
+	Pages         int      `json:"pages"`
+	Language      string   `json:"language"`
+	CoverURL      string   `json:"cover_url"`
+	// Classification is the Dewey Decimal class, when Open Library knows it.
+	Classification string `json:"classification,omitempty"`
+	// PriceSupplement is the add-on scanned after the ISBN, and Price the
+	// price in it if it has one.
+	PriceSupplement string `json:"price_supplement,omitempty"`
+	Price           *Price `json:"price,omitempty"`
+	// Copies is how many copies of the book the library has.
+	Copies int `json:"copies,omitempty"`
+
+	ShelfID   int    `json:"shelf_id"`
+	ShelfName string `json:"shelf_name"`
+	RowNumber int    `json:"row_number"`
+	Slot      int    `json:"slot,omitempty"`
+	// Position is the order the book was scanned onto its row in.
+	Position int `json:"position,omitempty"`
+
+	LocationID int `json:"location_id,omitempty"`
+	// LocationPath is the breadcrumb of the book's location, starting from
+	// the root. It is empty when the location is not known.
+	LocationPath []string `json:"location_path"`
+}
+
+// Price is a price printed on a book. Amount is a decimal, e.g. "12.99".
+type Price struct {
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
+}
+
+// Place is a place on a shelf row.
+type Place struct {
+	ShelfID      int      `json:"shelf_id,omitempty"`
+	ShelfName    string   `json:"shelf_name,omitempty"`
+	RowNumber    int      `json:"row_number,omitempty"`
+	Slot         int      `json:"slot,omitempty"`
+	LocationPath []string `json:"location_path,omitempty"`
+}
+
+// AddedBook is a scanned book and what adding it did: one of
+// models.AddOutcomeAdded, AddOutcomeAlreadyHere, AddOutcomeMoved or
+// AddOutcomeNewCopy.
+type AddedBook struct {
+	Book
+	Outcome string `json:"outcome"`
+	// MovedFrom is where a moved book was before.
+	MovedFrom *Place `json:"moved_from,omitempty"`
+}
+
+// ImageScan is what was found in an uploaded photo: the barcodes decoded,
+// and the books added for the ones that are ISBNs.
+type ImageScan struct {
+	Codes []string    `json:"codes"`
+	Books []AddedBook `json:"books"`
+}
+
+// BookLocationRequest moves a book to a location, or to a shelf row when
+// LocationID is not set.
+type BookLocationRequest struct {
+	LocationID int `json:"location_id,omitempty"`
+	ShelfID    int `json:"shelf_id,omitempty"`
+	RowNumber  int `json:"row_number,omitempty"`
+	Slot       int `json:"slot,omitempty"`
+}
+
+// NewBook returns the API form of a book.
+func NewBook(b models.Book) Book {
+	book := Book{
+		ISBN:            b.ISBN,
+		Title:           b.Title,
+		Description:     b.Description,
+		Authors:         b.Authors,
+		Publisher:       b.Publisher,
+		PublishedDate:   b.PublishedDate,
+		Categories:      b.Categories,
+		Pages:           b.Pages,
+		Language:        b.Language,
+		CoverURL:        b.CoverURL,
+		Classification:  b.Classification,
+		PriceSupplement: b.PriceSupplement,
+		Copies:          b.Copies,
+		ShelfID:         b.ShelfID,
+		ShelfName:       b.ShelfName,
+		RowNumber:       b.RowNumber,
+		Slot:            b.Slot,
+		Position:        b.Position,
+		LocationID:      b.LocationID,
+		LocationPath:    b.LocationPath,
+	}
+	if b.Price != nil {
+		book.Price = &Price{Currency: b.Price.Currency, Amount: b.Price.Amount}
+	}
+	return book
+}
+
+// NewBooks returns the API form of a list of books.
+func NewBooks(books []models.Book) []Book {
+	out := make([]Book, 0, len(books))
+	for _, b := range books {
+		out = append(out, NewBook(b))
+	}
+	return out
+}
+
+// NewAddedBook returns the API form of an added book.
+func NewAddedBook(b models.AddedBook) AddedBook {
+	added := AddedBook{Book: NewBook(b.Book), Outcome: b.Outcome}
+	if b.MovedFrom != nil {
+		added.MovedFrom = &Place{
+			ShelfID:      b.MovedFrom.ShelfID,
+			ShelfName:    b.MovedFrom.ShelfName,
+			RowNumber:    b.MovedFrom.RowNumber,
+			Slot:         b.MovedFrom.Slot,
+			LocationPath: b.MovedFrom.LocationPath,
+		}
+	}
+	return added
+}
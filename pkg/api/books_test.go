@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// The API types started out as the models, and clients still expect the
+// same JSON.
+func TestBooksMatchModels(t *testing.T) {
+	book := models.Book{
+		ISBN:            9783836526722,
+		Title:           "Art in Theory",
+		Description:     "An anthology",
+		Authors:         []string{"Charles Harrison", "Paul Wood"},
+		Publisher:       "Blackwell",
+		PublishedDate:   "2003",
+		Categories:      []string{"Art"},
+		Pages:           1288,
+		Language:        "en",
+		CoverURL:        "https://covers.example/1.jpg",
+		Classification:  "701",
+		PriceSupplement: "51299",
+		Price:           &models.Price{Currency: "USD", Amount: "12.99"},
+		ShelfID:         2,
+		ShelfName:       "office",
+		RowNumber:       3,
+		Slot:            4,
+		Position:        5,
+		LocationID:      6,
+		LocationPath:    []string{"Home", "office", "Row 3"},
+	}
+	added := models.AddedBook{
+		Book:      book,
+		Outcome:   models.AddOutcomeMoved,
+		MovedFrom: &models.BookPlace{ShelfID: 1, ShelfName: "hall", RowNumber: 2, Slot: 1, LocationPath: []string{"Home", "hall", "Row 2"}},
+	}
+
+	for name, tc := range map[string]struct{ model, dto any }{
+		"book":       {book, NewBook(book)},
+		"empty book": {models.Book{}, NewBook(models.Book{})},
+		"added book": {added, NewAddedBook(added)},
+	} {
+		want, err := json.Marshal(tc.model)
+		if err != nil {
+			t.Fatalf("%s: cannot marshal model: %v", name, err)
+		}
+		got, err := json.Marshal(tc.dto)
+		if err != nil {
+			t.Fatalf("%s: cannot marshal API type: %v", name, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
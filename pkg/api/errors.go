@@ -0,0 +1,62 @@
+package api
+
+import "net/http"
+
+// ErrorCode says what kind of error a request ran into, so that clients do
+// not have to match on messages.
+type ErrorCode string
+
+const (
+	CodeBadRequest       ErrorCode = "bad_request"
+	CodeUnauthorized     ErrorCode = "unauthorized"
+	CodeForbidden        ErrorCode = "forbidden"
+	CodeNotFound         ErrorCode = "not_found"
+	CodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	CodeConflict         ErrorCode = "conflict"
+	CodeTooLarge         ErrorCode = "too_large"
+	CodeUnprocessable    ErrorCode = "unprocessable"
+	CodeInternal         ErrorCode = "internal"
+)
+
+// CodeForStatus returns the error code for an HTTP status.
+func CodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// ErrorResponse is the body of every error the API returns.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// Error describes what went wrong with a request.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Details says more about the error where there is more to say, such
+	// as why a request body could not be read.
+	Details map[string]string `json:"details,omitempty"`
+	// RequestID is the X-Request-Id of the request, to find it in the
+	// server's logs.
+	RequestID string `json:"request_id,omitempty"`
+}
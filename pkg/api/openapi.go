@@ -0,0 +1,352 @@
+package api
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/auth"
+)
+
+// OpenAPI is the OpenAPI 3 document of the API, as generated by Spec. It is
+// checked in so that changes to the API show up in review.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Document is an OpenAPI 3 document, with only the parts the API uses.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers"`
+	Tags       []Tag               `json:"tags"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	// Security are the ways to authenticate, by the name of their schemes.
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PathItem holds the operations on a path by their lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema describes a JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Operation returns the operation for a method on a path, such as
+// "/books/{isbn}", or nil if there is none.
+func (d Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Resolve returns the schema a reference points to, or s itself if it is
+// not a reference.
+func (d Document) Resolve(s *Schema) *Schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+}
+
+// pathParam matches the parameters in the paths of operations.
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// Spec builds the OpenAPI document from the operations of the API and the
+// types they send and receive.
+func Spec() Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title: "librascan",
+			Description: "The JSON API of librascan, a home library catalogue. " +
+				"Errors are returned as an ErrorResponse. The same endpoints are also " +
+				"served without the /api/v1 prefix for older clients. Requests are " +
+				"authenticated with an API token or the session cookie of the web " +
+				"interface, and each operation needs the role it describes.",
+			Version: "1",
+		},
+		Servers: []Server{{URL: Prefix}},
+		Tags:    tags,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				"token":   {Type: "http", Scheme: "bearer", Description: `Made with "librascan token create".`},
+				"session": {Type: "apiKey", In: "cookie", Name: auth.SessionCookie, Description: "Set by signing in at /login."},
+			},
+		},
+		Security: []map[string][]string{{"token": {}}, {"session": {}}},
+	}
+	g := schemaGenerator{schemas: doc.Components.Schemas, types: map[string]reflect.Type{}}
+	errorSchema := g.schema(reflect.TypeOf(ErrorResponse{}))
+
+	for _, op := range operations {
+		o := &Operation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Description: roleDescription(op.method, op.path),
+			Tags:        []string{op.tag},
+			Responses:   map[string]Response{},
+		}
+		for _, m := range pathParam.FindAllStringSubmatch(op.path, -1) {
+			o.Parameters = append(o.Parameters, pathParameter(m[1]))
+		}
+		for _, p := range op.query {
+			o.Parameters = append(o.Parameters, Parameter{Name: p.name, In: "query", Description: p.description, Schema: &Schema{Type: p.typ}})
+		}
+		if op.scan {
+			o.Parameters = append(o.Parameters, scanParameters...)
+		}
+
+		switch {
+		case op.upload:
+			o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"multipart/form-data": {Schema: &Schema{Type: "object", Properties: map[string]*Schema{"image": {Type: "string", Format: "binary"}}, Required: []string{"image"}}},
+				"image/*":             {Schema: &Schema{Type: "string", Format: "binary"}},
+			}}
+		case op.request != nil:
+			o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/json": {Schema: g.schema(reflect.TypeOf(op.request))},
+			}}
+		}
+
+		resp := Response{Description: http.StatusText(op.status)}
+		switch {
+		case op.stream:
+			resp.Content = map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}}
+		case op.response != nil:
+			resp.Content = map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(op.response))}}
+		}
+		o.Responses[strconv.Itoa(op.status)] = resp
+		for _, status := range op.also {
+			o.Responses[strconv.Itoa(status)] = Response{Description: http.StatusText(status), Content: resp.Content}
+		}
+		o.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+
+		item := doc.Paths[op.path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = o
+	}
+	return doc
+}
+
+// roleDescription says which role an operation needs.
+func roleDescription(method, path string) string {
+	role, ok := auth.Required(method, pathParam.ReplaceAllString(path, ":$1"))
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Needs the %s role.", role)
+}
+
+// pathParameter describes a parameter in a path by its name.
+func pathParameter(name string) Parameter {
+	p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "integer"}}
+	switch name {
+	case "isbn":
+		p.Description = "ISBN-13, with or without hyphens"
+		p.Schema = &Schema{Type: "string"}
+	case "row":
+		p.Description = "Row number, counting from 1"
+	}
+	return p
+}
+
+// scanParameters name the scan a request is made for, so that the server
+// records it with the change the request makes.
+var scanParameters = []Parameter{
+	{Name: "scan_device", In: "query", Description: "Scanner the request is made for; the scan is only recorded with it", Schema: &Schema{Type: "string"}},
+	{Name: "scan_source", In: "query", Description: "Source of the scanner the code was read from", Schema: &Schema{Type: "string"}},
+	{Name: "scan_mode", In: "query", Description: "Mode the scanner was in", Schema: &Schema{Type: "string"}},
+	{Name: "scan_code", In: "query", Description: "Code that was scanned", Schema: &Schema{Type: "string"}},
+}
+
+// schemaGenerator describes Go types as schemas. Named struct types go into
+// schemas and are referred to by name.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g schemaGenerator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return g.schema(t.Elem())
+	case t.Kind() == reflect.Slice:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Interface:
+		return &Schema{}
+	case t.Kind() != reflect.Struct:
+		panic(fmt.Sprintf("api: cannot describe %s", t))
+	case t.Name() == "":
+		return g.object(t)
+	}
+
+	ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+	if seen, ok := g.types[t.Name()]; ok {
+		// The types sent by the API mirror some of the models, which can
+		// share a schema as long as they are sent the same way.
+		if seen != t && !reflect.DeepEqual(g.object(t), g.schemas[t.Name()]) {
+			panic(fmt.Sprintf("api: %s and %s have the same name", seen, t))
+		}
+		return ref
+	}
+	g.types[t.Name()] = t
+	g.schemas[t.Name()] = g.object(t)
+	return ref
+}
+
+// object describes the fields of a struct, with those of embedded structs
+// in line as encoding/json does.
+func (g schemaGenerator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for f := range fields(t) {
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		omitEmpty := slices.Contains(strings.Split(opts, ","), "omitempty")
+
+		prop := g.schema(f.Type)
+		if !omitEmpty {
+			s.Required = append(s.Required, name)
+			switch f.Type.Kind() {
+			case reflect.Pointer, reflect.Slice, reflect.Map:
+				// Nil ones are sent as null.
+				prop = nullable(prop)
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// nullable returns a schema that allows null as well. References cannot
+// have siblings, so they are wrapped.
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	s.Nullable = true
+	return s
+}
+
+// fields yields the exported, JSON encoded fields of a struct, going into
+// embedded structs.
+func fields(t reflect.Type) func(yield func(reflect.StructField) bool) {
+	return func(yield func(reflect.StructField) bool) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+				for ef := range fields(f.Type) {
+					if !yield(ef) {
+						return
+					}
+				}
+				continue
+			}
+			if !f.IsExported() || f.Tag.Get("json") == "-" {
+				continue
+			}
+			if !yield(f) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite openapi.json from Spec")
+
+// The checked in document is what clients and the docs page see, so it has
+// to be regenerated whenever an operation or a type it sends changes.
+func TestOpenAPIUpToDate(t *testing.T) {
+	want, err := json.MarshalIndent(Spec(), "", "  ")
+	if err != nil {
+		t.Fatalf("cannot marshal spec: %v", err)
+	}
+	want = append(want, '\n')
+
+	if *update {
+		if err := os.WriteFile("openapi.json", want, 0o644); err != nil {
+			t.Fatalf("cannot write openapi.json: %v", err)
+		}
+		return
+	}
+	if !bytes.Equal(OpenAPI, want) {
+		t.Fatal("openapi.json is out of date; run go test ./pkg/api -run TestOpenAPIUpToDate -update")
+	}
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	doc := Spec()
+
+	var check func(where string, s *Schema)
+	check = func(where string, s *Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" && doc.Resolve(s) == nil {
+			t.Errorf("%s: %s does not exist", where, s.Ref)
+		}
+		check(where, s.Items)
+		check(where, s.AdditionalProperties)
+		for _, sub := range s.AllOf {
+			check(where, sub)
+		}
+		for _, prop := range s.Properties {
+			check(where, prop)
+		}
+	}
+
+	ids := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			where := strings.ToUpper(method) + " " + path
+			if ids[op.OperationID] {
+				t.Errorf("%s: operation id %s is used twice", where, op.OperationID)
+			}
+			ids[op.OperationID] = true
+
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				found := false
+				for _, p := range op.Parameters {
+					found = found || (p.In == "path" && p.Name == m[1])
+				}
+				if !found {
+					t.Errorf("%s: path parameter %s is not described", where, m[1])
+				}
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					check(where, media.Schema)
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, media := range resp.Content {
+					check(where, media.Schema)
+				}
+			}
+		}
+	}
+	for name, s := range doc.Components.Schemas {
+		check(name, s)
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// operation is an endpoint of the API, as described in the OpenAPI
+// document.
+type operation struct {
+	method, path string
+	id, summary  string
+	tag          string
+	query        []queryParam
+	// scan is set for the operations that record the scan they are made
+	// for.
+	scan bool
+
+	// request is the JSON body the operation takes, and upload is set for
+	// the one that takes a photo instead.
+	request any
+	upload  bool
+
+	// status is the status of a successful response, with response as its
+	// body. Some operations can also answer with the statuses in also.
+	status   int
+	also     []int
+	response any
+	// stream is set for the operation that streams events instead.
+	stream bool
+}
+
+type queryParam struct {
+	name, typ, description string
+}
+
+// placeParams say where to put a book.
+var placeParams = []queryParam{
+	{"shelf_id", "integer", "Shelf to put the book on"},
+	{"row_number", "integer", "Row of the shelf to put the book on"},
+	{"slot", "integer", "Slot of the row to put the book in"},
+	{"location_id", "integer", "Location without sub-locations to put the book in, instead of a shelf row"},
+	{"location", "string", "Scanned shelf or location label to put the book in, instead of the other parameters"},
+}
+
+var tags = []Tag{
+	{Name: "books", Description: "The catalogue and where the books are"},
+	{Name: "lending", Description: "Lending books to people"},
+	{Name: "people", Description: "The people books are lent to"},
+	{Name: "shelves", Description: "Bookcases and their rows"},
+	{Name: "locations", Description: "The tree of places books are kept in"},
+	{Name: "audits", Description: "Checking the books in a location"},
+	{Name: "plans", Description: "Reshelving books in sorted order"},
+	{Name: "scans", Description: "The scan log and undoing scans"},
+	{Name: "stats", Description: "Statistics about the collection"},
+	{Name: "debug", Description: "Help with finding out why a book is looked up wrongly"},
+}
+
+var operations = []operation{
+	{method: http.MethodGet, path: "/books", id: "listBooks", tag: "books",
+		summary: "Get all books",
+		status:  http.StatusOK, response: []Book{}},
+	{method: http.MethodPost, path: "/books/{isbn}", id: "addBook", tag: "books",
+		summary: "Add a book by ISBN, or move it here if it is already catalogued",
+		query: append(placeParams,
+			queryParam{"add_on", "string", "Two or five digit add-on printed after the ISBN"},
+			queryParam{"copy", "boolean", "Count a book that is already catalogued as another copy of it, instead of moving it"}),
+		scan:   true,
+		status: http.StatusCreated, also: []int{http.StatusOK}, response: AddedBook{}},
+	{method: http.MethodGet, path: "/books/{isbn}", id: "getBook", tag: "books",
+		summary: "Get a book",
+		status:  http.StatusOK, response: Book{}},
+	{method: http.MethodDelete, path: "/books/{isbn}", id: "deleteBook", tag: "books",
+		summary: "Delete a book",
+		scan:    true,
+		status:  http.StatusNoContent},
+	{method: http.MethodPut, path: "/books/{isbn}/location", id: "moveBook", tag: "books",
+		summary: "Move a book to a location, or to a shelf row",
+		scan:    true, request: BookLocationRequest{},
+		status: http.StatusOK, response: Book{}},
+	{method: http.MethodGet, path: "/books/{isbn}/locate", id: "locateBook", tag: "books",
+		summary: "Say where on its shelf a book is",
+		status:  http.StatusOK, response: models.BookLocator{}},
+	{method: http.MethodPost, path: "/scan/image", id: "scanImage", tag: "books",
+		summary: "Add the books whose barcodes are on a photo",
+		query:   placeParams, scan: true, upload: true,
+		status: http.StatusOK, response: ImageScan{}},
+
+	{method: http.MethodPost, path: "/books/borrow", id: "borrowBook", tag: "lending",
+		summary: "Lend a book to a person",
+		scan:    true, request: models.BorrowRequest{},
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/books/return", id: "returnBook", tag: "lending",
+		summary: "Return a borrowed book",
+		scan:    true, request: models.ReturnRequest{},
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/books/hold", id: "holdBook", tag: "lending",
+		summary: "Put a hold on a book",
+		request: models.BorrowRequest{},
+		status:  http.StatusNoContent},
+
+	{method: http.MethodGet, path: "/people", id: "listPeople", tag: "people",
+		summary: "Get all people",
+		status:  http.StatusOK, response: []models.Person{}},
+	{method: http.MethodGet, path: "/people/{id}", id: "getPerson", tag: "people",
+		summary: "Get a person",
+		status:  http.StatusOK, response: models.Person{}},
+	{method: http.MethodPatch, path: "/people/{id}", id: "updatePerson", tag: "people",
+		summary: "Change where a person's notifications are sent",
+		request: models.PersonRequest{},
+		status:  http.StatusOK, response: models.Person{}},
+	{method: http.MethodGet, path: "/people/{id}/calendar", id: "getPersonCalendar", tag: "people",
+		summary: "Get a person's private calendar feed URL",
+		status:  http.StatusOK, response: models.CalendarFeed{}},
+
+	{method: http.MethodGet, path: "/shelf/{id}", id: "getShelf", tag: "shelves",
+		summary: "Get a shelf",
+		status:  http.StatusOK, response: models.Shelf{}},
+	{method: http.MethodGet, path: "/shelves", id: "listShelves", tag: "shelves",
+		summary: "Get all shelves with the number of books on each row",
+		status:  http.StatusOK, response: []models.Shelf{}},
+	{method: http.MethodPost, path: "/shelves", id: "createShelf", tag: "shelves",
+		summary: "Add a shelf",
+		request: models.ShelfRequest{},
+		status:  http.StatusCreated, response: models.Shelf{}},
+	{method: http.MethodPatch, path: "/shelves/{id}", id: "updateShelf", tag: "shelves",
+		summary: "Rename a shelf or change its number of rows",
+		request: models.ShelfRequest{},
+		status:  http.StatusOK, response: models.Shelf{}},
+	{method: http.MethodDelete, path: "/shelves/{id}", id: "deleteShelf", tag: "shelves",
+		summary: "Delete a shelf",
+		query:   []queryParam{{"reassign_to", "integer", "Shelf to move the shelf's books to"}},
+		status:  http.StatusNoContent},
+	{method: http.MethodGet, path: "/shelves/{id}/rows/{row}/books", id: "listRowBooks", tag: "shelves",
+		summary: "Get the books on a shelf row from left to right",
+		status:  http.StatusOK, response: []Book{}},
+	{method: http.MethodPut, path: "/shelves/{id}/rows/{row}/order", id: "setRowOrder", tag: "shelves",
+		summary: "Re-sequence the books on a shelf row",
+		request: models.RowOrderRequest{},
+		status:  http.StatusOK, response: []Book{}},
+
+	{method: http.MethodGet, path: "/locations", id: "listLocations", tag: "locations",
+		summary: "Get all locations with their paths and number of books",
+		status:  http.StatusOK, response: []models.Location{}},
+	{method: http.MethodPost, path: "/locations", id: "createLocation", tag: "locations",
+		summary: "Add a location",
+		request: models.LocationRequest{},
+		status:  http.StatusCreated, response: models.Location{}},
+	{method: http.MethodGet, path: "/locations/{id}", id: "getLocation", tag: "locations",
+		summary: "Get a location",
+		status:  http.StatusOK, response: models.Location{}},
+	{method: http.MethodPatch, path: "/locations/{id}", id: "updateLocation", tag: "locations",
+		summary: "Rename, retype or move a location",
+		request: models.LocationRequest{},
+		status:  http.StatusOK, response: models.Location{}},
+	{method: http.MethodDelete, path: "/locations/{id}", id: "deleteLocation", tag: "locations",
+		summary: "Delete an empty location",
+		status:  http.StatusNoContent},
+	{method: http.MethodGet, path: "/locations/{id}/books", id: "listLocationBooks", tag: "locations",
+		summary: "Get the books in a location and everywhere below it",
+		status:  http.StatusOK, response: []Book{}},
+
+	{method: http.MethodGet, path: "/audits", id: "listAudits", tag: "audits",
+		summary: "Get all audits",
+		query:   []queryParam{{"location_id", "integer", "Only list the audits of this location"}},
+		status:  http.StatusOK, response: []models.Audit{}},
+	{method: http.MethodPost, path: "/audits", id: "startAudit", tag: "audits",
+		summary: "Start an audit of a location",
+		request: models.AuditRequest{},
+		status:  http.StatusCreated, response: models.Audit{}},
+	{method: http.MethodGet, path: "/audits/{id}", id: "getAudit", tag: "audits",
+		summary: "Get an audit with its report",
+		status:  http.StatusOK, response: models.AuditReport{}},
+	{method: http.MethodPost, path: "/audits/{id}/scans", id: "addAuditScan", tag: "audits",
+		summary: "Record a book scanned during an audit",
+		request: models.AuditScanRequest{},
+		status:  http.StatusOK, response: models.AuditScan{}},
+	{method: http.MethodPost, path: "/audits/{id}/close", id: "closeAudit", tag: "audits",
+		summary: "Close an audit and save its report",
+		status:  http.StatusOK, response: models.AuditReport{}},
+	{method: http.MethodPost, path: "/audits/{id}/apply", id: "applyAudit", tag: "audits",
+		summary: "Apply the corrections of a closed audit",
+		status:  http.StatusOK, response: models.AuditReport{}},
+
+	{method: http.MethodGet, path: "/plans", id: "listPlans", tag: "plans",
+		summary: "Get all reshelving plans, newest first",
+		status:  http.StatusOK, response: []models.Plan{}},
+	{method: http.MethodPost, path: "/plans", id: "createPlan", tag: "plans",
+		summary: "Plan how to reshelve books in sorted order",
+		request: models.PlanRequest{},
+		status:  http.StatusCreated, response: models.Plan{}},
+	{method: http.MethodGet, path: "/plans/{id}", id: "getPlan", tag: "plans",
+		summary: "Get a reshelving plan with its moves",
+		status:  http.StatusOK, response: models.Plan{}},
+	{method: http.MethodPost, path: "/plans/{id}/confirm", id: "confirmPlanMove", tag: "plans",
+		summary: "Confirm that a book has been moved",
+		request: models.PlanConfirmRequest{},
+		status:  http.StatusOK, response: models.PlanConfirmation{}},
+
+	{method: http.MethodGet, path: "/scans", id: "listScans", tag: "scans",
+		summary: "Get the latest scans, newest first",
+		query: []queryParam{
+			{"device", "string", "Only list the scans of this scanner"},
+			{"limit", "integer", "How many scans to list, 50 by default"},
+		},
+		status: http.StatusOK, response: []models.ScanEvent{}},
+	{method: http.MethodPost, path: "/scans", id: "recordScan", tag: "scans",
+		summary: "Record a scan that did not change any books",
+		request: models.ScanEventRequest{},
+		status:  http.StatusCreated, response: models.ScanEvent{}},
+	{method: http.MethodPost, path: "/scans/undo", id: "undoLastScan", tag: "scans",
+		summary: "Undo the last scan of a scanner",
+		request: models.ScanUndoRequest{},
+		status:  http.StatusOK, response: models.ScanEvent{}},
+	{method: http.MethodPost, path: "/scans/{id}/undo", id: "undoScan", tag: "scans",
+		summary: "Undo a scan",
+		status:  http.StatusOK, response: models.ScanEvent{}},
+	{method: http.MethodGet, path: "/events", id: "streamEvents", tag: "scans",
+		summary: "Live stream of scans as Server-Sent Events",
+		query:   []queryParam{{"device", "string", "Only stream the scans of this scanner"}},
+		status:  http.StatusOK, stream: true},
+
+	{method: http.MethodGet, path: "/stats", id: "getStats", tag: "stats",
+		summary: "Collection and lending statistics",
+		status:  http.StatusOK, response: models.Stats{}},
+
+	{method: http.MethodGet, path: "/debug/lookup/{isbn}", id: "lookupBook", tag: "debug",
+		summary: "Look a book up without adding it, with the responses of the book databases",
+		status:  http.StatusOK, response: map[string]any{}},
+}
@@ -0,0 +1,108 @@
+// Package audit compares the books found on a shelf during an audit with the
+// books recorded there.
+package audit
+
+import (
+	"slices"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// Statuses of a scanned book.
+const (
+	StatusFound     = "found"
+	StatusMisplaced = "misplaced"
+	StatusUnknown   = "unknown"
+)
+
+// Actions of a correction.
+const (
+	ActionMove   = "move"
+	ActionRemove = "remove"
+)
+
+// Input is what Compare needs to know about an audit.
+type Input struct {
+	// Expected are the books recorded in the audited location.
+	Expected []int64
+	// Scanned are the ISBNs scanned during the audit, in order.
+	Scanned []int64
+	// Books are all books in the library.
+	Books map[int64]models.Book
+	// Borrowers maps the books on loan to the people who have them.
+	Borrowers map[int64]string
+	// CanMove is set when misplaced books can be recorded in the audited
+	// location, which is only possible if it has no sub-locations.
+	CanMove bool
+}
+
+// Classify returns the status of a book scanned during an audit.
+func Classify(isbn int64, expected []int64, books map[int64]models.Book) string {
+	if _, ok := books[isbn]; !ok {
+		return StatusUnknown
+	}
+	if slices.Contains(expected, isbn) {
+		return StatusFound
+	}
+	return StatusMisplaced
+}
+
+// Compare builds the report of an audit.
+func Compare(a models.Audit, in Input) models.AuditReport {
+	report := models.AuditReport{
+		Audit:       a,
+		Found:       []models.AuditBook{},
+		Missing:     []models.AuditBook{},
+		Misplaced:   []models.AuditBook{},
+		Unknown:     []int{},
+		Borrowed:    []models.AuditBook{},
+		Corrections: []models.AuditCorrection{},
+	}
+
+	for _, isbn := range in.Scanned {
+		book := in.auditBook(isbn)
+		switch Classify(isbn, in.Expected, in.Books) {
+		case StatusUnknown:
+			report.Unknown = append(report.Unknown, int(isbn))
+			continue
+		case StatusFound:
+			report.Found = append(report.Found, book)
+		case StatusMisplaced:
+			report.Misplaced = append(report.Misplaced, book)
+			if in.CanMove {
+				report.Corrections = append(report.Corrections, models.AuditCorrection{ISBN: int(isbn), Action: ActionMove})
+			}
+		}
+
+		// The book is here, but the loan was never closed.
+		if book.Borrower != "" {
+			report.Borrowed = append(report.Borrowed, book)
+		}
+	}
+
+	for _, isbn := range in.Expected {
+		if slices.Contains(in.Scanned, isbn) {
+			continue
+		}
+
+		book := in.auditBook(isbn)
+		if book.Borrower != "" {
+			report.Borrowed = append(report.Borrowed, book)
+			continue
+		}
+		report.Missing = append(report.Missing, book)
+		report.Corrections = append(report.Corrections, models.AuditCorrection{ISBN: int(isbn), Action: ActionRemove})
+	}
+
+	return report
+}
+
+func (in Input) auditBook(isbn int64) models.AuditBook {
+	book := in.Books[isbn]
+	return models.AuditBook{
+		ISBN:         int(isbn),
+		Title:        book.Title,
+		LocationPath: book.LocationPath,
+		Borrower:     in.Borrowers[isbn],
+	}
+}
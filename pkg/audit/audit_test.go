@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+func TestCompare(t *testing.T) {
+	row := []string{"office-big", "Row 1"}
+	other := []string{"office-small", "Row 2"}
+	books := map[int64]models.Book{
+		1: {ISBN: 1, Title: "Found", LocationPath: row},
+		2: {ISBN: 2, Title: "Missing", LocationPath: row},
+		3: {ISBN: 3, Title: "Lent", LocationPath: row},
+		4: {ISBN: 4, Title: "Elsewhere", LocationPath: other},
+		5: {ISBN: 5, Title: "Back early", LocationPath: row},
+	}
+	in := Input{
+		Expected:  []int64{1, 2, 3, 5},
+		Scanned:   []int64{1, 4, 9, 5},
+		Books:     books,
+		Borrowers: map[int64]string{3: "Alice", 5: "Bob"},
+		CanMove:   true,
+	}
+
+	got := Compare(models.Audit{ID: 7}, in)
+	want := models.AuditReport{
+		Audit: models.Audit{ID: 7},
+		Found: []models.AuditBook{
+			{ISBN: 1, Title: "Found", LocationPath: row},
+			{ISBN: 5, Title: "Back early", LocationPath: row, Borrower: "Bob"},
+		},
+		Missing:   []models.AuditBook{{ISBN: 2, Title: "Missing", LocationPath: row}},
+		Misplaced: []models.AuditBook{{ISBN: 4, Title: "Elsewhere", LocationPath: other}},
+		Unknown:   []int{9},
+		Borrowed: []models.AuditBook{
+			{ISBN: 5, Title: "Back early", LocationPath: row, Borrower: "Bob"},
+			{ISBN: 3, Title: "Lent", LocationPath: row, Borrower: "Alice"},
+		},
+		Corrections: []models.AuditCorrection{
+			{ISBN: 4, Action: ActionMove},
+			{ISBN: 2, Action: ActionRemove},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected report (-want +got):\n%s", diff)
+	}
+
+	// Misplaced books cannot be moved into a location with sub-locations.
+	in.CanMove = false
+	got = Compare(models.Audit{ID: 7}, in)
+	if diff := cmp.Diff([]models.AuditCorrection{{ISBN: 2, Action: ActionRemove}}, got.Corrections); diff != "" {
+		t.Errorf("unexpected corrections (-want +got):\n%s", diff)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	books := map[int64]models.Book{1: {ISBN: 1}, 2: {ISBN: 2}}
+	expected := []int64{1}
+
+	for isbn, want := range map[int64]string{1: StatusFound, 2: StatusMisplaced, 3: StatusUnknown} {
+		if got := Classify(isbn, expected, books); got != want {
+			t.Errorf("Classify(%d) = %q, want %q", isbn, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package auth holds librascan's users, their roles and what each role may
+// do, and the tokens and passwords they sign in with.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role says what a user may do.
+type Role string
+
+const (
+	// RoleReader may browse the library, and borrow and hold books for
+	// themself.
+	RoleReader Role = "reader"
+	// RoleLibrarian may also add, move and lend books, run the scanners,
+	// audits and reshelving plans.
+	RoleLibrarian Role = "librarian"
+	// RoleAdmin may do everything, including deleting books and changing
+	// shelves and locations.
+	RoleAdmin Role = "admin"
+)
+
+// Roles are the roles from the least to the most allowed.
+var Roles = []Role{RoleReader, RoleLibrarian, RoleAdmin}
+
+// ParseRole returns the role with the given name.
+func ParseRole(name string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == name {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q, expected one of reader, librarian or admin", name)
+}
+
+// Includes reports whether r may do everything other may.
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// User is someone signed in to librascan.
+type User struct {
+	ID   int
+	Name string
+	Role Role
+}
+
+const (
+	userKey    = "librascan.user"
+	enabledKey = "librascan.auth"
+)
+
+// SetEnabled records that a request went through authentication, so that
+// handlers can tell nobody signing in from authentication being turned off.
+func SetEnabled(c echo.Context) {
+	c.Set(enabledKey, true)
+}
+
+// Enabled reports whether authentication is turned on for a request.
+func Enabled(c echo.Context) bool {
+	enabled, _ := c.Get(enabledKey).(bool)
+	return enabled
+}
+
+// SetUser records who made a request.
+func SetUser(c echo.Context, user User) {
+	c.Set(userKey, user)
+}
+
+// UserFrom returns who made a request. It reports false if nobody signed in,
+// or authentication is turned off.
+func UserFrom(c echo.Context) (User, bool) {
+	user, ok := c.Get(userKey).(User)
+	return user, ok
+}
+
+// SessionCookie is the cookie that keeps someone signed in to the web
+// interface.
+const SessionCookie = "librascan_session"
+
+// SessionTTL is how long someone stays signed in.
+const SessionTTL = 30 * 24 * time.Hour
+
+// tokenPrefix starts every API token, so that they are easy to recognise in
+// config files and to find when they leak.
+const tokenPrefix = "lsk_"
+
+// NewToken returns a new random secret, for API tokens and sessions.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot make token: %w", err)
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns what is stored of a token. Tokens are random enough that
+// a plain hash cannot be reversed, and it can be looked up.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword returns what is stored of a password.
+func HashPassword(password string) (string, error) {
+	if strings.TrimSpace(password) == "" {
+		return "", fmt.Errorf("password is empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("cannot hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password is the one hash was made from.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequired(t *testing.T) {
+	tests := []struct {
+		method, path string
+		want         Role
+		restricted   bool
+	}{
+		{"GET", "/login", "", false},
+		{"GET", "/borrowings.ics", "", false},
+		{"GET", "/books", RoleReader, true},
+		{"GET", "/", RoleReader, true},
+		{"POST", "/books/borrow", RoleReader, true},
+		{"POST", "/books/:isbn", RoleLibrarian, true},
+		{"PUT", "/books/:isbn/location", RoleLibrarian, true},
+		{"POST", "/books/return", RoleLibrarian, true},
+		{"POST", "/audits/:id/apply", RoleLibrarian, true},
+		{"DELETE", "/books/:isbn", RoleAdmin, true},
+		{"GET", "/debug/lookup/:isbn", RoleAdmin, true},
+		{"POST", "/shelves", RoleAdmin, true},
+	}
+	for _, tt := range tests {
+		got, restricted := Required(tt.method, tt.path)
+		if got != tt.want || restricted != tt.restricted {
+			t.Errorf("Required(%s, %s) = %q, %v, want %q, %v", tt.method, tt.path, got, restricted, tt.want, tt.restricted)
+		}
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	for i, r := range Roles {
+		for j, other := range Roles {
+			if got, want := r.Includes(other), i >= j; got != want {
+				t.Errorf("%s.Includes(%s) = %v, want %v", r, other, got, want)
+			}
+		}
+	}
+	if Role("guest").Includes(RoleReader) {
+		t.Errorf("an unknown role should not include reader")
+	}
+
+	if _, err := ParseRole("librarian"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Errorf("expected an error for an unknown role")
+	}
+}
+
+func TestTokensAndPasswords(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := NewToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a == b || !strings.HasPrefix(a, tokenPrefix) {
+		t.Errorf("expected two different tokens starting with %q, got %q and %q", tokenPrefix, a, b)
+	}
+	if HashToken(a) != HashToken(a) || HashToken(a) == HashToken(b) {
+		t.Errorf("expected hashes to match only for the same token")
+	}
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Errorf("expected the password to match its hash")
+	}
+	if CheckPassword(hash, "battery staple") {
+		t.Errorf("expected another password not to match")
+	}
+	if _, err := HashPassword(" "); err == nil {
+		t.Errorf("expected an error for an empty password")
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// public are the routes anyone may use. The calendar feed has tokens of its
+// own, and the login page has to be reachable to sign in.
+var public = map[string]bool{
+	"GET /login":          true,
+	"POST /login":         true,
+	"POST /logout":        true,
+	"GET /borrowings.ics": true,
+	"GET /openapi.json":   true,
+	"GET /docs":           true,
+	"GET /metrics":        true,
+}
+
+// roles are the roles needed for the routes that do not follow the defaults
+// of Required.
+var roles = map[string]Role{
+	// Readers may only borrow and hold books for themselves, which the
+	// handlers check.
+	"POST /books/borrow": RoleReader,
+	"POST /books/hold":   RoleReader,
+
+	"POST /books/:isbn":                RoleLibrarian,
+	"PUT /books/:isbn/location":        RoleLibrarian,
+	"POST /scan/image":                 RoleLibrarian,
+	"POST /books/return":               RoleLibrarian,
+	"PUT /shelves/:id/rows/:row/order": RoleLibrarian,
+	"POST /audits":                     RoleLibrarian,
+	"POST /audits/:id/scans":           RoleLibrarian,
+	"POST /audits/:id/close":           RoleLibrarian,
+	"POST /audits/:id/apply":           RoleLibrarian,
+	"POST /plans":                      RoleLibrarian,
+	"POST /plans/:id/confirm":          RoleLibrarian,
+	"POST /scans":                      RoleLibrarian,
+	"POST /scans/undo":                 RoleLibrarian,
+	"POST /scans/:id/undo":             RoleLibrarian,
+	"PATCH /people/:id":                RoleLibrarian,
+
+	"GET /debug/lookup/:isbn":  RoleAdmin,
+	"GET /people/:id/calendar": RoleAdmin,
+}
+
+// Routes returns the routes, as "METHOD /path", that are public or have a
+// role of their own, so that they can be checked against the ones served.
+func Routes() []string {
+	routes := slices.Collect(maps.Keys(public))
+	routes = slices.AppendSeq(routes, maps.Keys(roles))
+	slices.Sort(routes)
+	return routes
+}
+
+// Required returns the role needed for a route, given by its method and its
+// path as registered with Echo relative to the API prefix, such as
+// "/books/:isbn". It reports false for the routes anyone may use. Reading
+// needs a reader and changing anything an admin, unless said otherwise.
+func Required(method, path string) (Role, bool) {
+	route := method + " " + path
+	if public[route] {
+		return "", false
+	}
+	if role, ok := roles[route]; ok {
+		return role, true
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return RoleReader, true
+	}
+	return RoleAdmin, true
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// ListAudits returns the audits of a location, or all of them if
+// locationID is zero.
+func (c *Client) ListAudits(locationID int) ([]models.Audit, error) {
+	query := url.Values{}
+	setInt(query, "location_id", locationID)
+
+	audits := []models.Audit{}
+	err := c.do(http.MethodGet, apiPath("/audits"), query, nil, &audits)
+	return audits, err
+}
+
+// StartAudit starts an audit of a location.
+func (c *Client) StartAudit(req models.AuditRequest) (models.Audit, error) {
+	audit := models.Audit{}
+	err := c.do(http.MethodPost, apiPath("/audits"), nil, req, &audit)
+	return audit, err
+}
+
+// GetAudit returns an audit with its report.
+func (c *Client) GetAudit(id int) (models.AuditReport, error) {
+	report := models.AuditReport{}
+	err := c.do(http.MethodGet, apiPath("/audits/%d", id), nil, nil, &report)
+	return report, err
+}
+
+// AddAuditScan records a book scanned during an audit.
+func (c *Client) AddAuditScan(id int, req models.AuditScanRequest) (models.AuditScan, error) {
+	scan := models.AuditScan{}
+	err := c.do(http.MethodPost, apiPath("/audits/%d/scans", id), nil, req, &scan)
+	return scan, err
+}
+
+// CloseAudit closes an audit and returns its report.
+func (c *Client) CloseAudit(id int) (models.AuditReport, error) {
+	report := models.AuditReport{}
+	err := c.do(http.MethodPost, apiPath("/audits/%d/close", id), nil, nil, &report)
+	return report, err
+}
+
+// ApplyAudit applies the corrections of a closed audit.
+func (c *Client) ApplyAudit(id int) (models.AuditReport, error) {
+	report := models.AuditReport{}
+	err := c.do(http.MethodPost, apiPath("/audits/%d/apply", id), nil, nil, &report)
+	return report, err
+}
+
+// ListPlans returns all the reshelving plans, newest first.
+func (c *Client) ListPlans() ([]models.Plan, error) {
+	plans := []models.Plan{}
+	err := c.do(http.MethodGet, apiPath("/plans"), nil, nil, &plans)
+	return plans, err
+}
+
+// CreatePlan plans how to reshelve books in sorted order.
+func (c *Client) CreatePlan(req models.PlanRequest) (models.Plan, error) {
+	plan := models.Plan{}
+	err := c.do(http.MethodPost, apiPath("/plans"), nil, req, &plan)
+	return plan, err
+}
+
+// GetPlan returns a reshelving plan with its moves.
+func (c *Client) GetPlan(id int) (models.Plan, error) {
+	plan := models.Plan{}
+	err := c.do(http.MethodGet, apiPath("/plans/%d", id), nil, nil, &plan)
+	return plan, err
+}
+
+// ConfirmPlanMove confirms that a book of a plan has been moved.
+func (c *Client) ConfirmPlanMove(id int, req models.PlanConfirmRequest) (models.PlanConfirmation, error) {
+	result := models.PlanConfirmation{}
+	err := c.do(http.MethodPost, apiPath("/plans/%d/confirm", id), nil, req, &result)
+	return result, err
+}
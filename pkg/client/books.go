@@ -0,0 +1,135 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// ListBooks returns all the books.
+func (c *Client) ListBooks() ([]api.Book, error) {
+	books := []api.Book{}
+	err := c.do(http.MethodGet, apiPath("/books"), nil, nil, &books)
+	return books, err
+}
+
+// AddBook adds a book by its ISBN to place, or moves it there if it is
+// already catalogued. addOn is the add-on printed after the ISBN, if any.
+func (c *Client) AddBook(isbn string, place Place, addOn string, scan Scan) (api.AddedBook, error) {
+	query := url.Values{}
+	place.addTo(query)
+	if addOn != "" {
+		query.Set("add_on", addOn)
+	}
+	scan.addTo(query)
+
+	book := api.AddedBook{}
+	err := c.do(http.MethodPost, apiPath("/books/%s", isbn), query, nil, &book)
+	return book, err
+}
+
+// AddCopy adds a book by its ISBN to place, or counts another copy of it if
+// it is already catalogued.
+func (c *Client) AddCopy(isbn string, place Place, scan Scan) (api.AddedBook, error) {
+	query := url.Values{}
+	place.addTo(query)
+	query.Set("copy", "true")
+	scan.addTo(query)
+
+	book := api.AddedBook{}
+	err := c.do(http.MethodPost, apiPath("/books/%s", isbn), query, nil, &book)
+	return book, err
+}
+
+// GetBook returns a book.
+func (c *Client) GetBook(isbn string) (api.Book, error) {
+	book := api.Book{}
+	err := c.do(http.MethodGet, apiPath("/books/%s", isbn), nil, nil, &book)
+	return book, err
+}
+
+// DeleteBook deletes a book.
+func (c *Client) DeleteBook(isbn string, scan Scan) error {
+	query := url.Values{}
+	scan.addTo(query)
+	return c.do(http.MethodDelete, apiPath("/books/%s", isbn), query, nil, nil)
+}
+
+// MoveBook moves a book to a location or a shelf row.
+func (c *Client) MoveBook(isbn string, req api.BookLocationRequest, scan Scan) (api.Book, error) {
+	query := url.Values{}
+	scan.addTo(query)
+
+	book := api.Book{}
+	err := c.do(http.MethodPut, apiPath("/books/%s/location", isbn), query, req, &book)
+	return book, err
+}
+
+// LocateBook says where on its shelf a book is.
+func (c *Client) LocateBook(isbn string) (models.BookLocator, error) {
+	locator := models.BookLocator{}
+	err := c.do(http.MethodGet, apiPath("/books/%s/locate", isbn), nil, nil, &locator)
+	return locator, err
+}
+
+// ScanImage adds the books whose barcodes are on a JPEG or PNG photo to
+// place. contentType is the type of the photo, such as "image/jpeg".
+func (c *Client) ScanImage(photo io.Reader, contentType string, place Place, scan Scan) (api.ImageScan, error) {
+	query := url.Values{}
+	place.addTo(query)
+	scan.addTo(query)
+
+	result := api.ImageScan{}
+	err := c.upload(apiPath("/scan/image"), query, contentType, photo, &result)
+	return result, err
+}
+
+// BorrowBook lends a book to a person.
+func (c *Client) BorrowBook(req models.BorrowRequest, scan Scan) error {
+	query := url.Values{}
+	scan.addTo(query)
+	return c.do(http.MethodPost, apiPath("/books/borrow"), query, req, nil)
+}
+
+// ReturnBook returns a borrowed book.
+func (c *Client) ReturnBook(req models.ReturnRequest, scan Scan) error {
+	query := url.Values{}
+	scan.addTo(query)
+	return c.do(http.MethodPost, apiPath("/books/return"), query, req, nil)
+}
+
+// HoldBook puts a hold on a book for a person.
+func (c *Client) HoldBook(req models.BorrowRequest) error {
+	return c.do(http.MethodPost, apiPath("/books/hold"), nil, req, nil)
+}
+
+// ListPeople returns all the people.
+func (c *Client) ListPeople() ([]models.Person, error) {
+	people := []models.Person{}
+	err := c.do(http.MethodGet, apiPath("/people"), nil, nil, &people)
+	return people, err
+}
+
+// GetPerson returns a person.
+func (c *Client) GetPerson(id int) (models.Person, error) {
+	person := models.Person{}
+	err := c.do(http.MethodGet, apiPath("/people/%d", id), nil, nil, &person)
+	return person, err
+}
+
+// UpdatePerson changes where a person's notifications are sent.
+func (c *Client) UpdatePerson(id int, req models.PersonRequest) (models.Person, error) {
+	person := models.Person{}
+	err := c.do(http.MethodPatch, apiPath("/people/%d", id), nil, req, &person)
+	return person, err
+}
+
+// GetPersonCalendar returns the URL of a person's private calendar feed.
+func (c *Client) GetPersonCalendar(id int) (models.CalendarFeed, error) {
+	feed := models.CalendarFeed{}
+	err := c.do(http.MethodGet, apiPath("/people/%d/calendar", id), nil, nil, &feed)
+	return feed, err
+}
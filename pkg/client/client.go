@@ -0,0 +1,188 @@
+// Package client talks to librascan's JSON API. Its methods are the
+// operations of the OpenAPI document in package api, named after their
+// operation IDs, and send and receive the same types.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/api"
+)
+
+// Client makes requests to a librascan server.
+type Client struct {
+	serverURL  string
+	httpClient *http.Client
+	token      string
+}
+
+// New returns a client for the server at serverURL, such as
+// "http://localhost:8080". A nil httpClient uses http.DefaultClient.
+func New(serverURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{serverURL: serverURL, httpClient: httpClient}
+}
+
+// WithToken returns a copy of the client that sends an API token with every
+// request, for servers with authentication on. The token is made with
+// "librascan token create".
+func (c *Client) WithToken(token string) *Client {
+	withToken := *c
+	withToken.token = token
+	return &withToken
+}
+
+// Error is returned for the responses that are not successful.
+type Error struct {
+	StatusCode int
+	// Body is the error the server sent. It is empty if the response did
+	// not have one, as from a proxy in front of the server.
+	Body api.Error
+}
+
+func (e *Error) Error() string {
+	if e.Body.Message == "" {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d: %s", e.StatusCode, e.Body.Message)
+}
+
+// Scan names the scan a request is made for, so that the server records it
+// with the change the request makes. The zero Scan records nothing.
+type Scan struct {
+	Device string
+	Source string
+	Mode   string
+	Code   string
+}
+
+func (s Scan) addTo(query url.Values) {
+	if s.Device == "" {
+		return
+	}
+	query.Set("scan_device", s.Device)
+	query.Set("scan_source", s.Source)
+	query.Set("scan_mode", s.Mode)
+	query.Set("scan_code", s.Code)
+}
+
+// Place says where to put a book. The zero fields are left out, so the
+// server uses its defaults for them.
+type Place struct {
+	ShelfID    int
+	RowNumber  int
+	Slot       int
+	LocationID int
+	// Location is a scanned shelf or location label, used instead of the
+	// other fields.
+	Location string
+}
+
+func (p Place) addTo(query url.Values) {
+	setInt(query, "shelf_id", p.ShelfID)
+	setInt(query, "row_number", p.RowNumber)
+	setInt(query, "slot", p.Slot)
+	setInt(query, "location_id", p.LocationID)
+	if p.Location != "" {
+		query.Set("location", p.Location)
+	}
+}
+
+// setInt sets a query parameter unless n is zero.
+func setInt(query url.Values, name string, n int) {
+	if n != 0 {
+		query.Set(name, strconv.Itoa(n))
+	}
+}
+
+// apiPath joins the parts of an API path, escaping the ones that are not
+// constant.
+func apiPath(format string, args ...any) string {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = url.PathEscape(s)
+		}
+	}
+	return api.Prefix + fmt.Sprintf(format, args...)
+}
+
+// do sends req as JSON, unless it is nil, and decodes the response into
+// resp, unless it is nil.
+func (c *Client) do(method, path string, query url.Values, req, resp any) error {
+	var body io.Reader
+	if req != nil {
+		reqBytes, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("cannot encode request: %w", err)
+		}
+		body = bytes.NewReader(reqBytes)
+	}
+
+	httpReq, err := http.NewRequest(method, c.url(path, query), body)
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(httpReq, resp)
+}
+
+// upload sends a file as the body of a request and decodes the response
+// into resp.
+func (c *Client) upload(path string, query url.Values, contentType string, file io.Reader, resp any) error {
+	httpReq, err := http.NewRequest(http.MethodPost, c.url(path, query), file)
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	return c.send(httpReq, resp)
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	if len(query) == 0 {
+		return c.serverURL + path
+	}
+	return c.serverURL + path + "?" + query.Encode()
+}
+
+func (c *Client) send(httpReq *http.Request, resp any) error {
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("cannot send request: %w", err)
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			slog.Error("cannot close response body", "error", err)
+		}
+	}()
+
+	if httpResp.StatusCode/100 != 2 {
+		apiErr := &Error{StatusCode: httpResp.StatusCode}
+		errResp := api.ErrorResponse{}
+		if err := json.NewDecoder(httpResp.Body).Decode(&errResp); err == nil {
+			apiErr.Body = errResp.Error
+		}
+		return apiErr
+	}
+
+	if resp == nil {
+		return nil
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("cannot decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// specServer answers the requests that are operations of the OpenAPI
+// document with empty values, and fails the test for any other request.
+type specServer struct {
+	t   *testing.T
+	doc api.Document
+
+	mu  sync.Mutex
+	ops map[string]bool
+}
+
+func (s *specServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	op := s.operation(r.Method, r.URL.Path)
+	if op == nil {
+		s.t.Errorf("%s %s is not in the OpenAPI document", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if op.RequestBody != nil && op.RequestBody.Content["application/json"].Schema != nil && r.Header.Get("Content-Type") != "application/json" {
+		s.t.Errorf("%s: expected a JSON body, got %q", op.OperationID, r.Header.Get("Content-Type"))
+	}
+	for name := range r.URL.Query() {
+		if !hasQueryParam(op, name) {
+			s.t.Errorf("%s: query parameter %q is not in the OpenAPI document", op.OperationID, name)
+		}
+	}
+
+	s.mu.Lock()
+	s.ops[op.OperationID] = true
+	s.mu.Unlock()
+
+	for status, resp := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		media, ok := resp.Content["application/json"]
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if s.doc.Resolve(media.Schema).Type == "array" {
+			_, _ = w.Write([]byte("[]"))
+		} else {
+			_, _ = w.Write([]byte("{}"))
+		}
+		return
+	}
+}
+
+// quotedParam matches the parameters of a path quoted by regexp.QuoteMeta.
+var quotedParam = regexp.MustCompile(`\\\{\w+\\\}`)
+
+// operation finds the operation a request is for. Paths without parameters
+// win over the ones with, as /books/borrow does over /books/{isbn}.
+func (s *specServer) operation(method, path string) *api.Operation {
+	path, ok := strings.CutPrefix(path, api.Prefix)
+	if !ok {
+		return nil
+	}
+	if op := s.doc.Operation(method, path); op != nil {
+		return op
+	}
+	for template := range s.doc.Paths {
+		pattern := "^" + quotedParam.ReplaceAllString(regexp.QuoteMeta(template), `[^/]+`) + "$"
+		if regexp.MustCompile(pattern).MatchString(path) {
+			if op := s.doc.Operation(method, template); op != nil {
+				return op
+			}
+		}
+	}
+	return nil
+}
+
+func hasQueryParam(op *api.Operation, name string) bool {
+	for _, p := range op.Parameters {
+		if p.In == "query" && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClientCoversOpenAPI(t *testing.T) {
+	server := &specServer{t: t, doc: api.Spec(), ops: map[string]bool{}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	c := New(ts.URL, nil)
+
+	scan := Scan{Device: "desk", Source: "keyboard", Mode: "catalogue", Code: "9780000000002"}
+	place := Place{ShelfID: 1, RowNumber: 2, Slot: 3, LocationID: 4, Location: "SHELF-1-2"}
+	calls := []func() error{
+		func() error { _, err := c.ListBooks(); return err },
+		func() error { _, err := c.AddBook("9780000000002", place, "51299", scan); return err },
+		func() error { _, err := c.AddCopy("9780000000002", place, scan); return err },
+		func() error { _, err := c.GetBook("9780000000002"); return err },
+		func() error { return c.DeleteBook("9780000000002", scan) },
+		func() error {
+			_, err := c.MoveBook("9780000000002", api.BookLocationRequest{ShelfID: 1}, scan)
+			return err
+		},
+		func() error { _, err := c.LocateBook("9780000000002"); return err },
+		func() error {
+			_, err := c.ScanImage(strings.NewReader("photo"), "image/jpeg", place, scan)
+			return err
+		},
+		func() error { return c.BorrowBook(models.BorrowRequest{ISBN: 9780000000002}, scan) },
+		func() error { return c.ReturnBook(models.ReturnRequest{ISBN: 9780000000002}, scan) },
+		func() error { return c.HoldBook(models.BorrowRequest{ISBN: 9780000000002}) },
+		func() error { _, err := c.ListPeople(); return err },
+		func() error { _, err := c.GetPerson(1); return err },
+		func() error { _, err := c.UpdatePerson(1, models.PersonRequest{}); return err },
+		func() error { _, err := c.GetPersonCalendar(1); return err },
+		func() error { _, err := c.GetShelf(1); return err },
+		func() error { _, err := c.ListShelves(); return err },
+		func() error { _, err := c.CreateShelf(models.ShelfRequest{}); return err },
+		func() error { _, err := c.UpdateShelf(1, models.ShelfRequest{}); return err },
+		func() error { return c.DeleteShelf(1, 2) },
+		func() error { _, err := c.ListRowBooks(1, 2); return err },
+		func() error { _, err := c.SetRowOrder(1, 2, models.RowOrderRequest{}); return err },
+		func() error { _, err := c.ListLocations(); return err },
+		func() error { _, err := c.CreateLocation(models.LocationRequest{}); return err },
+		func() error { _, err := c.GetLocation(1); return err },
+		func() error { _, err := c.UpdateLocation(1, models.LocationRequest{}); return err },
+		func() error { return c.DeleteLocation(1) },
+		func() error { _, err := c.ListLocationBooks(1); return err },
+		func() error { _, err := c.ListAudits(1); return err },
+		func() error { _, err := c.StartAudit(models.AuditRequest{}); return err },
+		func() error { _, err := c.GetAudit(1); return err },
+		func() error { _, err := c.AddAuditScan(1, models.AuditScanRequest{}); return err },
+		func() error { _, err := c.CloseAudit(1); return err },
+		func() error { _, err := c.ApplyAudit(1); return err },
+		func() error { _, err := c.ListPlans(); return err },
+		func() error { _, err := c.CreatePlan(models.PlanRequest{}); return err },
+		func() error { _, err := c.GetPlan(1); return err },
+		func() error { _, err := c.ConfirmPlanMove(1, models.PlanConfirmRequest{}); return err },
+		func() error { _, err := c.ListScans("desk", 10); return err },
+		func() error { _, err := c.RecordScan(models.ScanEventRequest{}); return err },
+		func() error { _, err := c.UndoLastScan(models.ScanUndoRequest{}); return err },
+		func() error { _, err := c.UndoScan(1); return err },
+		func() error { _, err := c.GetStats(); return err },
+		func() error { _, err := c.LookupBook("9780000000002"); return err },
+	}
+	for i, call := range calls {
+		if err := call(); err != nil {
+			t.Errorf("call %d: %v", i, err)
+		}
+	}
+
+	// Every operation has a method, apart from the event stream which is
+	// read by browsers.
+	var missing []string
+	for _, item := range server.doc.Paths {
+		for _, op := range item {
+			if !server.ops[op.OperationID] && op.OperationID != "streamEvents" {
+				missing = append(missing, op.OperationID)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("no client method for %v", missing)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == api.Prefix+"/books/1" {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":"not_found","message":"book not found","request_id":"abc"}}`))
+	}))
+	defer ts.Close()
+	c := New(ts.URL, nil)
+
+	_, err := c.GetBook("9780000000002")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	want := &Error{StatusCode: http.StatusNotFound, Body: api.Error{Code: api.CodeNotFound, Message: "book not found", RequestID: "abc"}}
+	if diff := cmp.Diff(want, apiErr); diff != "" {
+		t.Errorf("unexpected error (-want +got):\n%s", diff)
+	}
+	if got := err.Error(); got != "unexpected status code: 404: book not found" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	// Errors that do not come from the server still have their status.
+	_, err = c.GetBook("1")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Body.Code != "" {
+		t.Errorf("expected a 502 without a body, got %v", err)
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, nil)
+	for _, client := range []*Client{c, c.WithToken("lsk_secret")} {
+		if _, err := client.ListBooks(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff([]string{"", "Bearer lsk_secret"}, got); diff != "" {
+		t.Errorf("unexpected Authorization headers (-want +got):\n%s", diff)
+	}
+}
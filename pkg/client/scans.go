@@ -0,0 +1,59 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// ListScans returns the latest scans of a scanner, or of all of them if
+// device is empty, newest first. A zero limit lists the server's default
+// number of scans.
+func (c *Client) ListScans(device string, limit int) ([]models.ScanEvent, error) {
+	query := url.Values{}
+	if device != "" {
+		query.Set("device", device)
+	}
+	setInt(query, "limit", limit)
+
+	scans := []models.ScanEvent{}
+	err := c.do(http.MethodGet, apiPath("/scans"), query, nil, &scans)
+	return scans, err
+}
+
+// RecordScan records a scan that did not change any books.
+func (c *Client) RecordScan(req models.ScanEventRequest) (models.ScanEvent, error) {
+	e := models.ScanEvent{}
+	err := c.do(http.MethodPost, apiPath("/scans"), nil, req, &e)
+	return e, err
+}
+
+// UndoLastScan undoes the last scan of a scanner.
+func (c *Client) UndoLastScan(req models.ScanUndoRequest) (models.ScanEvent, error) {
+	e := models.ScanEvent{}
+	err := c.do(http.MethodPost, apiPath("/scans/undo"), nil, req, &e)
+	return e, err
+}
+
+// UndoScan undoes a scan.
+func (c *Client) UndoScan(id int) (models.ScanEvent, error) {
+	e := models.ScanEvent{}
+	err := c.do(http.MethodPost, apiPath("/scans/%d/undo", id), nil, nil, &e)
+	return e, err
+}
+
+// GetStats returns the collection and lending statistics.
+func (c *Client) GetStats() (models.Stats, error) {
+	stats := models.Stats{}
+	err := c.do(http.MethodGet, apiPath("/stats"), nil, nil, &stats)
+	return stats, err
+}
+
+// LookupBook looks a book up without adding it, with the responses of the
+// book databases.
+func (c *Client) LookupBook(isbn string) (map[string]any, error) {
+	result := map[string]any{}
+	err := c.do(http.MethodGet, apiPath("/debug/lookup/%s", isbn), nil, nil, &result)
+	return result, err
+}
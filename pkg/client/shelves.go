@@ -0,0 +1,101 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// GetShelf returns a shelf.
+func (c *Client) GetShelf(id int) (models.Shelf, error) {
+	shelf := models.Shelf{}
+	err := c.do(http.MethodGet, apiPath("/shelf/%d", id), nil, nil, &shelf)
+	return shelf, err
+}
+
+// ListShelves returns all the shelves with the number of books on each row.
+func (c *Client) ListShelves() ([]models.Shelf, error) {
+	shelves := []models.Shelf{}
+	err := c.do(http.MethodGet, apiPath("/shelves"), nil, nil, &shelves)
+	return shelves, err
+}
+
+// CreateShelf adds a shelf.
+func (c *Client) CreateShelf(req models.ShelfRequest) (models.Shelf, error) {
+	shelf := models.Shelf{}
+	err := c.do(http.MethodPost, apiPath("/shelves"), nil, req, &shelf)
+	return shelf, err
+}
+
+// UpdateShelf renames a shelf or changes its number of rows.
+func (c *Client) UpdateShelf(id int, req models.ShelfRequest) (models.Shelf, error) {
+	shelf := models.Shelf{}
+	err := c.do(http.MethodPatch, apiPath("/shelves/%d", id), nil, req, &shelf)
+	return shelf, err
+}
+
+// DeleteShelf deletes a shelf. Its books are moved to the shelf reassignTo,
+// unless it is zero.
+func (c *Client) DeleteShelf(id, reassignTo int) error {
+	query := url.Values{}
+	setInt(query, "reassign_to", reassignTo)
+	return c.do(http.MethodDelete, apiPath("/shelves/%d", id), query, nil, nil)
+}
+
+// ListRowBooks returns the books on a shelf row from left to right.
+func (c *Client) ListRowBooks(shelfID, row int) ([]api.Book, error) {
+	books := []api.Book{}
+	err := c.do(http.MethodGet, apiPath("/shelves/%d/rows/%d/books", shelfID, row), nil, nil, &books)
+	return books, err
+}
+
+// SetRowOrder re-sequences the books on a shelf row.
+func (c *Client) SetRowOrder(shelfID, row int, req models.RowOrderRequest) ([]api.Book, error) {
+	books := []api.Book{}
+	err := c.do(http.MethodPut, apiPath("/shelves/%d/rows/%d/order", shelfID, row), nil, req, &books)
+	return books, err
+}
+
+// ListLocations returns all the locations with their paths and number of
+// books.
+func (c *Client) ListLocations() ([]models.Location, error) {
+	locations := []models.Location{}
+	err := c.do(http.MethodGet, apiPath("/locations"), nil, nil, &locations)
+	return locations, err
+}
+
+// CreateLocation adds a location.
+func (c *Client) CreateLocation(req models.LocationRequest) (models.Location, error) {
+	location := models.Location{}
+	err := c.do(http.MethodPost, apiPath("/locations"), nil, req, &location)
+	return location, err
+}
+
+// GetLocation returns a location.
+func (c *Client) GetLocation(id int) (models.Location, error) {
+	location := models.Location{}
+	err := c.do(http.MethodGet, apiPath("/locations/%d", id), nil, nil, &location)
+	return location, err
+}
+
+// UpdateLocation renames, retypes or moves a location.
+func (c *Client) UpdateLocation(id int, req models.LocationRequest) (models.Location, error) {
+	location := models.Location{}
+	err := c.do(http.MethodPatch, apiPath("/locations/%d", id), nil, req, &location)
+	return location, err
+}
+
+// DeleteLocation deletes an empty location.
+func (c *Client) DeleteLocation(id int) error {
+	return c.do(http.MethodDelete, apiPath("/locations/%d", id), nil, nil, nil)
+}
+
+// ListLocationBooks returns the books in a location and everywhere below
+// it.
+func (c *Client) ListLocationBooks(id int) ([]api.Book, error) {
+	books := []api.Book{}
+	err := c.do(http.MethodGet, apiPath("/locations/%d/books", id), nil, nil, &books)
+	return books, err
+}
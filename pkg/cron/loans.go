@@ -0,0 +1,92 @@
+package cron
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/notify"
+)
+
+// LoanReminderJob notifies borrowers about loans that are due soon or overdue.
+// Each reminder is only sent once per loan.
+type LoanReminderJob struct {
+	queries  *db.Queries
+	notifier notify.Notifier
+	dueSoon  time.Duration
+	now      func() time.Time
+}
+
+func NewLoanReminderJob(database *sql.DB, notifier notify.Notifier, dueSoon time.Duration) *LoanReminderJob {
+	return &LoanReminderJob{
+		queries:  db.New(database),
+		notifier: notifier,
+		dueSoon:  dueSoon,
+		now:      time.Now,
+	}
+}
+
+func (l *LoanReminderJob) Name() string {
+	return "loan_reminder"
+}
+
+func (l *LoanReminderJob) Period() time.Duration {
+	return time.Hour
+}
+
+func (l *LoanReminderJob) Run() error {
+	ctx := context.Background()
+	now := l.now().UTC()
+
+	// Overdue first, so a loan that is already late does not get a "due soon" mail.
+	overdueErr := l.remind(ctx, notify.EventOverdue, time.Time{}, now)
+	dueSoonErr := l.remind(ctx, notify.EventDueSoon, now, now.Add(l.dueSoon))
+
+	return errors.Join(overdueErr, dueSoonErr)
+}
+
+func (l *LoanReminderJob) remind(ctx context.Context, kind notify.EventKind, dueAfter, dueBefore time.Time) error {
+	loans, err := l.queries.GetLoansDueBetween(ctx, db.GetLoansDueBetweenParams{
+		DueAfter:  sql.NullString{String: dueAfter.UTC().Format(db.SQLiteTimeLayout), Valid: true},
+		DueBefore: sql.NullString{String: dueBefore.UTC().Format(db.SQLiteTimeLayout), Valid: true},
+		Kind:      string(kind),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query %s loans: %v", kind, err)
+	}
+
+	var errs error
+	for _, loan := range loans {
+		ev := notify.Event{
+			Kind:        kind,
+			ISBN:        int(loan.Isbn),
+			Title:       db.NullStringToString(loan.Title),
+			PersonName:  loan.PersonName,
+			PersonEmail: db.NullStringToString(loan.PersonEmail),
+		}
+		if t, err := db.ParseSQLiteTime(loan.BorrowedAt); err == nil {
+			ev.BorrowedAt = t
+		}
+		if t, err := db.ParseSQLiteTime(db.NullStringToString(loan.DueAt)); err == nil {
+			ev.DueAt = t
+		}
+
+		if err := l.notifier.Notify(ctx, ev); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to notify %s about %d: %v", loan.PersonName, loan.Isbn, err))
+			continue
+		}
+
+		err := l.queries.RecordLoanNotification(ctx, db.RecordLoanNotificationParams{
+			BorrowingID: loan.ID,
+			Kind:        string(kind),
+		})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to record notification: %v", err))
+		}
+	}
+
+	return errs
+}
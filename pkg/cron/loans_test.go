@@ -0,0 +1,89 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/notify"
+)
+
+// recordingNotifier collects every event it is asked to send.
+type recordingNotifier struct {
+	events []notify.Event
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, ev notify.Event) error {
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func TestLoanReminderJob_Run(t *testing.T) {
+	db := setupTestDB(t)
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Logf("failed to close database: %v", err)
+		}
+	}()
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	stmts := []string{
+		`INSERT INTO books (isbn, title) VALUES (9783836526722, 'Grimm'), (9780141182550, '1984'), (9780261103573, 'The Hobbit')`,
+		`INSERT INTO people (id, name, email) VALUES (1, 'John Doe', 'john@example.com'), (2, 'Jane Doe', NULL)`,
+		// Due tomorrow.
+		`INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at) VALUES (9783836526722, 1, '2025-02-11 12:00:00', '2025-03-11 12:00:00')`,
+		// Overdue.
+		`INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at) VALUES (9780141182550, 2, '2025-01-01 12:00:00', '2025-02-01 12:00:00')`,
+		// Due in a month, and an already returned loan.
+		`INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at) VALUES (9780261103573, 1, '2025-03-09 12:00:00', '2025-04-09 12:00:00')`,
+		`INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at, returned_at) VALUES (9780261103573, 2, '2025-01-01 12:00:00', '2025-02-01 12:00:00', '2025-01-20 12:00:00')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to insert test data: %v", err)
+		}
+	}
+
+	notifier := &recordingNotifier{}
+	job := NewLoanReminderJob(db, notifier, 48*time.Hour)
+	job.now = func() time.Time { return now }
+
+	if err := job.Run(); err != nil {
+		t.Fatalf("Run() returned error: %v", err)
+	}
+
+	if len(notifier.events) != 2 {
+		t.Fatalf("expected 2 events, got %d: %+v", len(notifier.events), notifier.events)
+	}
+
+	overdue := notifier.events[0]
+	if overdue.Kind != notify.EventOverdue || overdue.ISBN != 9780141182550 || overdue.PersonName != "Jane Doe" {
+		t.Errorf("unexpected overdue event: %+v", overdue)
+	}
+
+	dueSoon := notifier.events[1]
+	if dueSoon.Kind != notify.EventDueSoon || dueSoon.ISBN != 9783836526722 || dueSoon.PersonEmail != "john@example.com" {
+		t.Errorf("unexpected due soon event: %+v", dueSoon)
+	}
+	if !dueSoon.DueAt.Equal(time.Date(2025, 3, 11, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected due date: %v", dueSoon.DueAt)
+	}
+
+	// Reminders are only sent once.
+	if err := job.Run(); err != nil {
+		t.Fatalf("second Run() returned error: %v", err)
+	}
+	if len(notifier.events) != 2 {
+		t.Fatalf("expected no new events on second run, got %d", len(notifier.events))
+	}
+
+	// The due soon loan becomes overdue later and is reminded again.
+	job.now = func() time.Time { return now.Add(72 * time.Hour) }
+	if err := job.Run(); err != nil {
+		t.Fatalf("third Run() returned error: %v", err)
+	}
+	if len(notifier.events) != 3 || notifier.events[2].Kind != notify.EventOverdue || notifier.events[2].ISBN != 9783836526722 {
+		t.Fatalf("expected overdue event for 9783836526722, got %+v", notifier.events)
+	}
+}
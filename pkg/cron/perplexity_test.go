@@ -47,6 +47,51 @@ func setupTestDB(t *testing.T) *sql.DB {
 	if err := migrations.Up0004(ctx, tx); err != nil {
 		t.Fatalf("failed to run migration 0004: %v", err)
 	}
+	if err := migrations.Up0005(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0005: %v", err)
+	}
+	if err := migrations.Up0006(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0006: %v", err)
+	}
+	if err := migrations.Up0007(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0007: %v", err)
+	}
+	if err := migrations.Up0008(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0008: %v", err)
+	}
+	if err := migrations.Up0009(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0009: %v", err)
+	}
+	if err := migrations.Up0010(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0010: %v", err)
+	}
+	if err := migrations.Up0011(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0011: %v", err)
+	}
+	if err := migrations.Up0012(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0012: %v", err)
+	}
+	if err := migrations.Up0013(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0013: %v", err)
+	}
+	if err := migrations.Up0014(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0014: %v", err)
+	}
+	if err := migrations.Up0015(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0015: %v", err)
+	}
+	if err := migrations.Up0016(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0016: %v", err)
+	}
+	if err := migrations.Up0017(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0017: %v", err)
+	}
+	if err := migrations.Up0018(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0018: %v", err)
+	}
+	if err := migrations.Up0019(ctx, tx); err != nil {
+		t.Fatalf("failed to run migration 0019: %v", err)
+	}
 
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("failed to commit transaction: %v", err)
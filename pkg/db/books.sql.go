@@ -10,6 +10,18 @@ import (
 	"database/sql"
 )
 
+const addBookCopy = `-- name: AddBookCopy :execrows
+UPDATE books SET copies = copies + 1 WHERE isbn = ?
+`
+
+func (q *Queries) AddBookCopy(ctx context.Context, isbn int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addBookCopy, isbn)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteBook = `-- name: DeleteBook :execrows
 DELETE FROM books WHERE isbn = ?
 `
@@ -23,21 +35,27 @@ func (q *Queries) DeleteBook(ctx context.Context, isbn int64) (int64, error) {
 }
 
 const getAllBooks = `-- name: GetAllBooks :many
-SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, shelf_id, row_number 
+SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, shelf_id, row_number, slot, location_id, position, classification, price_supplement, copies 
 FROM books
 `
 
 type GetAllBooksRow struct {
-	Isbn          int64          `json:"isbn"`
-	Title         sql.NullString `json:"title"`
-	Description   sql.NullString `json:"description"`
-	Publisher     sql.NullString `json:"publisher"`
-	PublishedDate sql.NullString `json:"published_date"`
-	Pages         sql.NullInt64  `json:"pages"`
-	Language      sql.NullString `json:"language"`
-	CoverUrl      sql.NullString `json:"cover_url"`
-	ShelfID       sql.NullInt64  `json:"shelf_id"`
-	RowNumber     sql.NullInt64  `json:"row_number"`
+	Isbn            int64          `json:"isbn"`
+	Title           sql.NullString `json:"title"`
+	Description     sql.NullString `json:"description"`
+	Publisher       sql.NullString `json:"publisher"`
+	PublishedDate   sql.NullString `json:"published_date"`
+	Pages           sql.NullInt64  `json:"pages"`
+	Language        sql.NullString `json:"language"`
+	CoverUrl        sql.NullString `json:"cover_url"`
+	ShelfID         sql.NullInt64  `json:"shelf_id"`
+	RowNumber       sql.NullInt64  `json:"row_number"`
+	Slot            sql.NullInt64  `json:"slot"`
+	LocationID      sql.NullInt64  `json:"location_id"`
+	Position        sql.NullInt64  `json:"position"`
+	Classification  sql.NullString `json:"classification"`
+	PriceSupplement sql.NullString `json:"price_supplement"`
+	Copies          int64          `json:"copies"`
 }
 
 func (q *Queries) GetAllBooks(ctx context.Context) ([]GetAllBooksRow, error) {
@@ -60,6 +78,12 @@ func (q *Queries) GetAllBooks(ctx context.Context) ([]GetAllBooksRow, error) {
 			&i.CoverUrl,
 			&i.ShelfID,
 			&i.RowNumber,
+			&i.Slot,
+			&i.LocationID,
+			&i.Position,
+			&i.Classification,
+			&i.PriceSupplement,
+			&i.Copies,
 		); err != nil {
 			return nil, err
 		}
@@ -75,22 +99,28 @@ func (q *Queries) GetAllBooks(ctx context.Context) ([]GetAllBooksRow, error) {
 }
 
 const getBook = `-- name: GetBook :one
-SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id 
+SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id, slot, location_id, position, classification, price_supplement, copies 
 FROM books 
 WHERE isbn = ?
 `
 
 type GetBookRow struct {
-	Isbn          int64          `json:"isbn"`
-	Title         sql.NullString `json:"title"`
-	Description   sql.NullString `json:"description"`
-	Publisher     sql.NullString `json:"publisher"`
-	PublishedDate sql.NullString `json:"published_date"`
-	Pages         sql.NullInt64  `json:"pages"`
-	Language      sql.NullString `json:"language"`
-	CoverUrl      sql.NullString `json:"cover_url"`
-	RowNumber     sql.NullInt64  `json:"row_number"`
-	ShelfID       sql.NullInt64  `json:"shelf_id"`
+	Isbn            int64          `json:"isbn"`
+	Title           sql.NullString `json:"title"`
+	Description     sql.NullString `json:"description"`
+	Publisher       sql.NullString `json:"publisher"`
+	PublishedDate   sql.NullString `json:"published_date"`
+	Pages           sql.NullInt64  `json:"pages"`
+	Language        sql.NullString `json:"language"`
+	CoverUrl        sql.NullString `json:"cover_url"`
+	RowNumber       sql.NullInt64  `json:"row_number"`
+	ShelfID         sql.NullInt64  `json:"shelf_id"`
+	Slot            sql.NullInt64  `json:"slot"`
+	LocationID      sql.NullInt64  `json:"location_id"`
+	Position        sql.NullInt64  `json:"position"`
+	Classification  sql.NullString `json:"classification"`
+	PriceSupplement sql.NullString `json:"price_supplement"`
+	Copies          int64          `json:"copies"`
 }
 
 func (q *Queries) GetBook(ctx context.Context, isbn int64) (GetBookRow, error) {
@@ -107,10 +137,100 @@ func (q *Queries) GetBook(ctx context.Context, isbn int64) (GetBookRow, error) {
 		&i.CoverUrl,
 		&i.RowNumber,
 		&i.ShelfID,
+		&i.Slot,
+		&i.LocationID,
+		&i.Position,
+		&i.Classification,
+		&i.PriceSupplement,
+		&i.Copies,
 	)
 	return i, err
 }
 
+type GetNextRowPositionParams struct {
+	ShelfID   sql.NullInt64 `json:"shelf_id"`
+	RowNumber sql.NullInt64 `json:"row_number"`
+}
+
+const getNextRowPosition = `-- name: GetNextRowPosition :one
+SELECT CAST(COALESCE(MAX(position), 0) + 1 AS INTEGER) AS position
+FROM books
+WHERE shelf_id = ? AND row_number = ?
+`
+
+func (q *Queries) GetNextRowPosition(ctx context.Context, arg GetNextRowPositionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getNextRowPosition, arg.ShelfID, arg.RowNumber)
+	var position int64
+	err := row.Scan(&position)
+	return position, err
+}
+
+type GetRowBooksParams struct {
+	ShelfID   sql.NullInt64 `json:"shelf_id"`
+	RowNumber sql.NullInt64 `json:"row_number"`
+}
+
+const getRowBooks = `-- name: GetRowBooks :many
+SELECT isbn FROM books
+WHERE shelf_id = ? AND row_number = ?
+ORDER BY position IS NULL, position, isbn
+`
+
+func (q *Queries) GetRowBooks(ctx context.Context, arg GetRowBooksParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getRowBooks, arg.ShelfID, arg.RowNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var isbn int64
+		if err := rows.Scan(&isbn); err != nil {
+			return nil, err
+		}
+		items = append(items, isbn)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSpineColors = `-- name: GetSpineColors :many
+SELECT isbn, CAST(spine_color AS TEXT) AS spine_color FROM books WHERE spine_color IS NOT NULL
+`
+
+type GetSpineColorsRow struct {
+	Isbn       int64  `json:"isbn"`
+	SpineColor string `json:"spine_color"`
+}
+
+func (q *Queries) GetSpineColors(ctx context.Context) ([]GetSpineColorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSpineColors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetSpineColorsRow{}
+	for rows.Next() {
+		var i GetSpineColorsRow
+		if err := rows.Scan(&i.Isbn, &i.SpineColor); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUnenrichedBooks = `-- name: GetUnenrichedBooks :many
 SELECT isbn FROM books WHERE is_ai_enriched = 0
 `
@@ -140,24 +260,33 @@ func (q *Queries) GetUnenrichedBooks(ctx context.Context) ([]int64, error) {
 
 const insertBook = `-- name: InsertBook :exec
 INSERT INTO books 
-(isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+(isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id, slot, location_id, position, classification, price_supplement, added_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
 ON CONFLICT(isbn) DO UPDATE SET
     row_number = excluded.row_number,
-    shelf_id = excluded.shelf_id
+    shelf_id = excluded.shelf_id,
+    slot = excluded.slot,
+    location_id = excluded.location_id,
+    position = excluded.position,
+    price_supplement = COALESCE(excluded.price_supplement, books.price_supplement)
 `
 
 type InsertBookParams struct {
-	Isbn          int64          `json:"isbn"`
-	Title         sql.NullString `json:"title"`
-	Description   sql.NullString `json:"description"`
-	Publisher     sql.NullString `json:"publisher"`
-	PublishedDate sql.NullString `json:"published_date"`
-	Pages         sql.NullInt64  `json:"pages"`
-	Language      sql.NullString `json:"language"`
-	CoverUrl      sql.NullString `json:"cover_url"`
-	RowNumber     sql.NullInt64  `json:"row_number"`
-	ShelfID       sql.NullInt64  `json:"shelf_id"`
+	Isbn            int64          `json:"isbn"`
+	Title           sql.NullString `json:"title"`
+	Description     sql.NullString `json:"description"`
+	Publisher       sql.NullString `json:"publisher"`
+	PublishedDate   sql.NullString `json:"published_date"`
+	Pages           sql.NullInt64  `json:"pages"`
+	Language        sql.NullString `json:"language"`
+	CoverUrl        sql.NullString `json:"cover_url"`
+	RowNumber       sql.NullInt64  `json:"row_number"`
+	ShelfID         sql.NullInt64  `json:"shelf_id"`
+	Slot            sql.NullInt64  `json:"slot"`
+	LocationID      sql.NullInt64  `json:"location_id"`
+	Position        sql.NullInt64  `json:"position"`
+	Classification  sql.NullString `json:"classification"`
+	PriceSupplement sql.NullString `json:"price_supplement"`
 }
 
 func (q *Queries) InsertBook(ctx context.Context, arg InsertBookParams) error {
@@ -172,6 +301,11 @@ func (q *Queries) InsertBook(ctx context.Context, arg InsertBookParams) error {
 		arg.CoverUrl,
 		arg.RowNumber,
 		arg.ShelfID,
+		arg.Slot,
+		arg.LocationID,
+		arg.Position,
+		arg.Classification,
+		arg.PriceSupplement,
 	)
 	return err
 }
@@ -185,6 +319,60 @@ func (q *Queries) MarkBookAsEnriched(ctx context.Context, isbn int64) error {
 	return err
 }
 
+const removeBookCopy = `-- name: RemoveBookCopy :execrows
+UPDATE books SET copies = copies - 1 WHERE isbn = ? AND copies > 1
+`
+
+func (q *Queries) RemoveBookCopy(ctx context.Context, isbn int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeBookCopy, isbn)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setBookPosition = `-- name: SetBookPosition :exec
+UPDATE books SET position = ? WHERE isbn = ?
+`
+
+type SetBookPositionParams struct {
+	Position sql.NullInt64 `json:"position"`
+	Isbn     int64         `json:"isbn"`
+}
+
+func (q *Queries) SetBookPosition(ctx context.Context, arg SetBookPositionParams) error {
+	_, err := q.db.ExecContext(ctx, setBookPosition, arg.Position, arg.Isbn)
+	return err
+}
+
+const setBookPriceSupplement = `-- name: SetBookPriceSupplement :exec
+UPDATE books SET price_supplement = ? WHERE isbn = ?
+`
+
+type SetBookPriceSupplementParams struct {
+	PriceSupplement sql.NullString `json:"price_supplement"`
+	Isbn            int64          `json:"isbn"`
+}
+
+func (q *Queries) SetBookPriceSupplement(ctx context.Context, arg SetBookPriceSupplementParams) error {
+	_, err := q.db.ExecContext(ctx, setBookPriceSupplement, arg.PriceSupplement, arg.Isbn)
+	return err
+}
+
+const setSpineColor = `-- name: SetSpineColor :exec
+UPDATE books SET spine_color = ? WHERE isbn = ?
+`
+
+type SetSpineColorParams struct {
+	SpineColor sql.NullString `json:"spine_color"`
+	Isbn       int64          `json:"isbn"`
+}
+
+func (q *Queries) SetSpineColor(ctx context.Context, arg SetSpineColorParams) error {
+	_, err := q.db.ExecContext(ctx, setSpineColor, arg.SpineColor, arg.Isbn)
+	return err
+}
+
 const updateBookDescription = `-- name: UpdateBookDescription :exec
 UPDATE books SET description = ? WHERE isbn = ? AND (description IS NULL OR description = '')
 `
@@ -2,7 +2,11 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
 	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
 )
 
 // NullStringToString converts sql.NullString to string
@@ -40,38 +44,65 @@ func IntToNullInt64(i int) sql.NullInt64 {
 // ConvertDBBookToModel converts database Book to models.Book
 func ConvertDBBookToModel(dbBook GetBookRow, authors []string, categories []string, shelfName string) models.Book {
 	return models.Book{
-		ISBN:          int(dbBook.Isbn),
-		Title:         NullStringToString(dbBook.Title),
-		Description:   NullStringToString(dbBook.Description),
-		Publisher:     NullStringToString(dbBook.Publisher),
-		PublishedDate: NullStringToString(dbBook.PublishedDate),
-		Pages:         NullInt64ToInt(dbBook.Pages),
-		Language:      NullStringToString(dbBook.Language),
-		CoverURL:      NullStringToString(dbBook.CoverUrl),
-		RowNumber:     NullInt64ToInt(dbBook.RowNumber),
-		ShelfID:       NullInt64ToInt(dbBook.ShelfID),
-		ShelfName:     shelfName,
-		Authors:       authors,
-		Categories:    categories,
+		ISBN:            int(dbBook.Isbn),
+		Title:           NullStringToString(dbBook.Title),
+		Description:     NullStringToString(dbBook.Description),
+		Publisher:       NullStringToString(dbBook.Publisher),
+		PublishedDate:   NullStringToString(dbBook.PublishedDate),
+		Pages:           NullInt64ToInt(dbBook.Pages),
+		Language:        NullStringToString(dbBook.Language),
+		CoverURL:        NullStringToString(dbBook.CoverUrl),
+		RowNumber:       NullInt64ToInt(dbBook.RowNumber),
+		ShelfID:         NullInt64ToInt(dbBook.ShelfID),
+		ShelfName:       shelfName,
+		Slot:            NullInt64ToInt(dbBook.Slot),
+		LocationID:      NullInt64ToInt(dbBook.LocationID),
+		Position:        NullInt64ToInt(dbBook.Position),
+		Classification:  NullStringToString(dbBook.Classification),
+		PriceSupplement: NullStringToString(dbBook.PriceSupplement),
+		Price:           AddOnPrice(NullStringToString(dbBook.PriceSupplement)),
+		Copies:          int(dbBook.Copies),
+		Authors:         authors,
+		Categories:      categories,
 	}
 }
 
 // ConvertDBBookRowToModel converts GetAllBooksRow to models.Book
 func ConvertDBBookRowToModel(dbBook GetAllBooksRow, authors []string, categories []string, shelfName string) models.Book {
 	return models.Book{
-		ISBN:          int(dbBook.Isbn),
-		Title:         NullStringToString(dbBook.Title),
-		Description:   NullStringToString(dbBook.Description),
-		Publisher:     NullStringToString(dbBook.Publisher),
-		PublishedDate: NullStringToString(dbBook.PublishedDate),
-		Pages:         NullInt64ToInt(dbBook.Pages),
-		Language:      NullStringToString(dbBook.Language),
-		CoverURL:      NullStringToString(dbBook.CoverUrl),
-		RowNumber:     NullInt64ToInt(dbBook.RowNumber),
-		ShelfID:       NullInt64ToInt(dbBook.ShelfID),
-		ShelfName:     shelfName,
-		Authors:       authors,
-		Categories:    categories,
+		ISBN:            int(dbBook.Isbn),
+		Title:           NullStringToString(dbBook.Title),
+		Description:     NullStringToString(dbBook.Description),
+		Publisher:       NullStringToString(dbBook.Publisher),
+		PublishedDate:   NullStringToString(dbBook.PublishedDate),
+		Pages:           NullInt64ToInt(dbBook.Pages),
+		Language:        NullStringToString(dbBook.Language),
+		CoverURL:        NullStringToString(dbBook.CoverUrl),
+		RowNumber:       NullInt64ToInt(dbBook.RowNumber),
+		ShelfID:         NullInt64ToInt(dbBook.ShelfID),
+		ShelfName:       shelfName,
+		Slot:            NullInt64ToInt(dbBook.Slot),
+		LocationID:      NullInt64ToInt(dbBook.LocationID),
+		Position:        NullInt64ToInt(dbBook.Position),
+		Classification:  NullStringToString(dbBook.Classification),
+		PriceSupplement: NullStringToString(dbBook.PriceSupplement),
+		Price:           AddOnPrice(NullStringToString(dbBook.PriceSupplement)),
+		Copies:          int(dbBook.Copies),
+		Authors:         authors,
+		Categories:      categories,
+	}
+}
+
+// AddOnPrice returns the price in a book's price supplement, or nil if it has
+// none.
+func AddOnPrice(addOn string) *models.Price {
+	price, ok := scancode.AddOnPrice(addOn)
+	if !ok {
+		return nil
+	}
+	return &models.Price{
+		Currency: price.Currency,
+		Amount:   fmt.Sprintf("%d.%02d", price.Amount/100, price.Amount%100),
 	}
 }
 
@@ -84,4 +115,20 @@ func ConvertNullStringSliceToStringSlice(nullStrings []sql.NullString) []string
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// SQLiteTimeLayout is the layout used by SQLite's datetime('now').
+const SQLiteTimeLayout = "2006-01-02 15:04:05"
+
+// TimeToNullString formats t in the SQLite datetime layout, in UTC.
+func TimeToNullString(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{Valid: false}
+	}
+	return sql.NullString{String: t.UTC().Format(SQLiteTimeLayout), Valid: true}
+}
+
+// ParseSQLiteTime parses a timestamp written by SQLite's datetime('now').
+func ParseSQLiteTime(s string) (time.Time, error) {
+	return time.Parse(SQLiteTimeLayout, s)
+}
@@ -8,6 +8,31 @@ import (
 	"database/sql"
 )
 
+type ApiToken struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+	Name       string         `json:"name"`
+	TokenHash  string         `json:"token_hash"`
+	CreatedAt  string         `json:"created_at"`
+	LastUsedAt sql.NullString `json:"last_used_at"`
+}
+
+type Audit struct {
+	ID         int64          `json:"id"`
+	LocationID int64          `json:"location_id"`
+	StartedAt  string         `json:"started_at"`
+	ClosedAt   sql.NullString `json:"closed_at"`
+	AppliedAt  sql.NullString `json:"applied_at"`
+	Report     sql.NullString `json:"report"`
+}
+
+type AuditScan struct {
+	ID        int64  `json:"id"`
+	AuditID   int64  `json:"audit_id"`
+	Isbn      int64  `json:"isbn"`
+	ScannedAt string `json:"scanned_at"`
+}
+
 type Author struct {
 	ID   int64          `json:"id"`
 	Name sql.NullString `json:"name"`
@@ -15,17 +40,25 @@ type Author struct {
 }
 
 type Book struct {
-	Isbn          int64          `json:"isbn"`
-	Title         sql.NullString `json:"title"`
-	Description   sql.NullString `json:"description"`
-	Publisher     sql.NullString `json:"publisher"`
-	PublishedDate sql.NullString `json:"published_date"`
-	Pages         sql.NullInt64  `json:"pages"`
-	Language      sql.NullString `json:"language"`
-	CoverUrl      sql.NullString `json:"cover_url"`
-	ShelfID       sql.NullInt64  `json:"shelf_id"`
-	RowNumber     sql.NullInt64  `json:"row_number"`
-	IsAiEnriched  sql.NullInt64  `json:"is_ai_enriched"`
+	Isbn            int64          `json:"isbn"`
+	Title           sql.NullString `json:"title"`
+	Description     sql.NullString `json:"description"`
+	Publisher       sql.NullString `json:"publisher"`
+	PublishedDate   sql.NullString `json:"published_date"`
+	Pages           sql.NullInt64  `json:"pages"`
+	Language        sql.NullString `json:"language"`
+	CoverUrl        sql.NullString `json:"cover_url"`
+	ShelfID         sql.NullInt64  `json:"shelf_id"`
+	RowNumber       sql.NullInt64  `json:"row_number"`
+	IsAiEnriched    sql.NullInt64  `json:"is_ai_enriched"`
+	AddedAt         sql.NullString `json:"added_at"`
+	Slot            sql.NullInt64  `json:"slot"`
+	LocationID      sql.NullInt64  `json:"location_id"`
+	Position        sql.NullInt64  `json:"position"`
+	SpineColor      sql.NullString `json:"spine_color"`
+	Classification  sql.NullString `json:"classification"`
+	PriceSupplement sql.NullString `json:"price_supplement"`
+	Copies          int64          `json:"copies"`
 }
 
 type Borrowing struct {
@@ -34,6 +67,7 @@ type Borrowing struct {
 	PersonID   int64          `json:"person_id"`
 	BorrowedAt string         `json:"borrowed_at"`
 	ReturnedAt sql.NullString `json:"returned_at"`
+	DueAt      sql.NullString `json:"due_at"`
 }
 
 type Category struct {
@@ -42,9 +76,85 @@ type Category struct {
 	Isbn sql.NullInt64  `json:"isbn"`
 }
 
+type Hold struct {
+	ID          int64          `json:"id"`
+	Isbn        int64          `json:"isbn"`
+	PersonID    int64          `json:"person_id"`
+	CreatedAt   string         `json:"created_at"`
+	NotifiedAt  sql.NullString `json:"notified_at"`
+	FulfilledAt sql.NullString `json:"fulfilled_at"`
+}
+
+type LoanNotification struct {
+	ID          int64  `json:"id"`
+	BorrowingID int64  `json:"borrowing_id"`
+	Kind        string `json:"kind"`
+	SentAt      string `json:"sent_at"`
+}
+
+type Location struct {
+	ID        int64         `json:"id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Type      string        `json:"type"`
+	Name      string        `json:"name"`
+	ShelfID   sql.NullInt64 `json:"shelf_id"`
+	RowNumber sql.NullInt64 `json:"row_number"`
+}
+
 type Person struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID            int64          `json:"id"`
+	Name          string         `json:"name"`
+	Email         sql.NullString `json:"email"`
+	CalendarToken sql.NullString `json:"calendar_token"`
+}
+
+type Plan struct {
+	ID          int64          `json:"id"`
+	Policy      string         `json:"policy"`
+	CreatedAt   string         `json:"created_at"`
+	CompletedAt sql.NullString `json:"completed_at"`
+}
+
+type PlanBook struct {
+	ID           int64          `json:"id"`
+	PlanID       int64          `json:"plan_id"`
+	Isbn         int64          `json:"isbn"`
+	FromShelfID  int64          `json:"from_shelf_id"`
+	FromRow      int64          `json:"from_row"`
+	FromPosition int64          `json:"from_position"`
+	ToShelfID    int64          `json:"to_shelf_id"`
+	ToRow        int64          `json:"to_row"`
+	ToPosition   int64          `json:"to_position"`
+	AfterIsbn    sql.NullInt64  `json:"after_isbn"`
+	Step         sql.NullInt64  `json:"step"`
+	DoneAt       sql.NullString `json:"done_at"`
+}
+
+type ScanEvent struct {
+	ID          int64          `json:"id"`
+	ScannedAt   string         `json:"scanned_at"`
+	Device      string         `json:"device"`
+	Source      sql.NullString `json:"source"`
+	Code        string         `json:"code"`
+	Mode        string         `json:"mode"`
+	Action      string         `json:"action"`
+	Outcome     string         `json:"outcome"`
+	ShelfID     sql.NullInt64  `json:"shelf_id"`
+	RowNumber   sql.NullInt64  `json:"row_number"`
+	Slot        sql.NullInt64  `json:"slot"`
+	Isbn        sql.NullInt64  `json:"isbn"`
+	PersonID    sql.NullInt64  `json:"person_id"`
+	Book        sql.NullString `json:"book"`
+	Previous    sql.NullString `json:"previous"`
+	UndoneAt    sql.NullString `json:"undone_at"`
+	BorrowingID sql.NullInt64  `json:"borrowing_id"`
+}
+
+type Session struct {
+	TokenHash string `json:"token_hash"`
+	UserID    int64  `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
 }
 
 type Shelf struct {
@@ -52,3 +162,11 @@ type Shelf struct {
 	Name      sql.NullString `json:"name"`
 	RowsCount sql.NullInt64  `json:"rows_count"`
 }
+
+type User struct {
+	ID           int64          `json:"id"`
+	Name         string         `json:"name"`
+	Role         string         `json:"role"`
+	PasswordHash sql.NullString `json:"password_hash"`
+	CreatedAt    string         `json:"created_at"`
+}
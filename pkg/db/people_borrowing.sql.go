@@ -7,8 +7,21 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
+const deleteActiveBorrowing = `-- name: DeleteActiveBorrowing :execrows
+DELETE FROM borrowing WHERE id = ? AND returned_at IS NULL
+`
+
+func (q *Queries) DeleteActiveBorrowing(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteActiveBorrowing, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getActiveBorrowings = `-- name: GetActiveBorrowings :many
 SELECT b.id, b.isbn, b.person_id, b.borrowed_at, p.name as person_name
 FROM borrowing b
@@ -53,20 +66,77 @@ func (q *Queries) GetActiveBorrowings(ctx context.Context) ([]GetActiveBorrowing
 	return items, nil
 }
 
+const getActiveLoans = `-- name: GetActiveLoans :many
+SELECT b.id, b.isbn, b.borrowed_at, b.due_at, b.person_id, p.name AS person_name, bk.title
+FROM borrowing b
+JOIN people p ON b.person_id = p.id
+JOIN books bk ON b.isbn = bk.isbn
+WHERE b.returned_at IS NULL
+    AND (?1 IS NULL OR b.person_id = ?1)
+ORDER BY b.due_at
+`
+
+type GetActiveLoansRow struct {
+	ID         int64          `json:"id"`
+	Isbn       int64          `json:"isbn"`
+	BorrowedAt string         `json:"borrowed_at"`
+	DueAt      sql.NullString `json:"due_at"`
+	PersonID   int64          `json:"person_id"`
+	PersonName string         `json:"person_name"`
+	Title      sql.NullString `json:"title"`
+}
+
+func (q *Queries) GetActiveLoans(ctx context.Context, personID sql.NullInt64) ([]GetActiveLoansRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveLoans, personID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetActiveLoansRow{}
+	for rows.Next() {
+		var i GetActiveLoansRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Isbn,
+			&i.BorrowedAt,
+			&i.DueAt,
+			&i.PersonID,
+			&i.PersonName,
+			&i.Title,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllPeople = `-- name: GetAllPeople :many
-SELECT id, name FROM people
+SELECT id, name, email FROM people
 `
 
-func (q *Queries) GetAllPeople(ctx context.Context) ([]Person, error) {
+type GetAllPeopleRow struct {
+	ID    int64          `json:"id"`
+	Name  string         `json:"name"`
+	Email sql.NullString `json:"email"`
+}
+
+func (q *Queries) GetAllPeople(ctx context.Context) ([]GetAllPeopleRow, error) {
 	rows, err := q.db.QueryContext(ctx, getAllPeople)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []Person{}
+	items := []GetAllPeopleRow{}
 	for rows.Next() {
-		var i Person
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+		var i GetAllPeopleRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.Email); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -91,22 +161,53 @@ func (q *Queries) GetPerson(ctx context.Context, name string) (int64, error) {
 	return id, err
 }
 
-const insertBorrowing = `-- name: InsertBorrowing :exec
-INSERT INTO borrowing (isbn, person_id, borrowed_at) VALUES (?, ?, datetime('now'))
+const getPersonByID = `-- name: GetPersonByID :one
+SELECT id, name, email FROM people WHERE id = ?
+`
+
+type GetPersonByIDRow struct {
+	ID    int64          `json:"id"`
+	Name  string         `json:"name"`
+	Email sql.NullString `json:"email"`
+}
+
+func (q *Queries) GetPersonByID(ctx context.Context, id int64) (GetPersonByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getPersonByID, id)
+	var i GetPersonByIDRow
+	err := row.Scan(&i.ID, &i.Name, &i.Email)
+	return i, err
+}
+
+const getPersonCalendarToken = `-- name: GetPersonCalendarToken :one
+SELECT calendar_token FROM people WHERE id = ?
+`
+
+func (q *Queries) GetPersonCalendarToken(ctx context.Context, id int64) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getPersonCalendarToken, id)
+	var calendar_token sql.NullString
+	err := row.Scan(&calendar_token)
+	return calendar_token, err
+}
+
+const insertBorrowing = `-- name: InsertBorrowing :one
+INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at) VALUES (?, ?, datetime('now'), ?) RETURNING id
 `
 
 type InsertBorrowingParams struct {
-	Isbn     int64 `json:"isbn"`
-	PersonID int64 `json:"person_id"`
+	Isbn     int64          `json:"isbn"`
+	PersonID int64          `json:"person_id"`
+	DueAt    sql.NullString `json:"due_at"`
 }
 
-func (q *Queries) InsertBorrowing(ctx context.Context, arg InsertBorrowingParams) error {
-	_, err := q.db.ExecContext(ctx, insertBorrowing, arg.Isbn, arg.PersonID)
-	return err
+func (q *Queries) InsertBorrowing(ctx context.Context, arg InsertBorrowingParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertBorrowing, arg.Isbn, arg.PersonID, arg.DueAt)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
 }
 
 const insertPerson = `-- name: InsertPerson :one
-INSERT INTO people (name) VALUES (?) RETURNING id
+INSERT INTO people (name, calendar_token) VALUES (?, lower(hex(randomblob(16)))) RETURNING id
 `
 
 func (q *Queries) InsertPerson(ctx context.Context, name string) (int64, error) {
@@ -116,6 +217,32 @@ func (q *Queries) InsertPerson(ctx context.Context, name string) (int64, error)
 	return id, err
 }
 
+const isBookBorrowed = `-- name: IsBookBorrowed :one
+SELECT EXISTS (SELECT 1 FROM borrowing WHERE isbn = ? AND returned_at IS NULL)
+`
+
+func (q *Queries) IsBookBorrowed(ctx context.Context, isbn int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, isBookBorrowed, isbn)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const reopenBorrowing = `-- name: ReopenBorrowing :execrows
+UPDATE borrowing
+SET returned_at = NULL
+WHERE id = ? AND returned_at IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM borrowing b WHERE b.isbn = borrowing.isbn AND b.returned_at IS NULL)
+`
+
+func (q *Queries) ReopenBorrowing(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reopenBorrowing, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const returnBook = `-- name: ReturnBook :exec
 UPDATE borrowing 
 SET returned_at = datetime('now') 
@@ -131,3 +258,31 @@ func (q *Queries) ReturnBook(ctx context.Context, arg ReturnBookParams) error {
 	_, err := q.db.ExecContext(ctx, returnBook, arg.Isbn, arg.PersonID)
 	return err
 }
+
+const returnBookByISBN = `-- name: ReturnBookByISBN :one
+UPDATE borrowing
+SET returned_at = datetime('now')
+WHERE isbn = ? AND returned_at IS NULL
+RETURNING id
+`
+
+func (q *Queries) ReturnBookByISBN(ctx context.Context, isbn int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, returnBookByISBN, isbn)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const updatePersonEmail = `-- name: UpdatePersonEmail :exec
+UPDATE people SET email = ? WHERE id = ?
+`
+
+type UpdatePersonEmailParams struct {
+	Email sql.NullString `json:"email"`
+	ID    int64          `json:"id"`
+}
+
+func (q *Queries) UpdatePersonEmail(ctx context.Context, arg UpdatePersonEmailParams) error {
+	_, err := q.db.ExecContext(ctx, updatePersonEmail, arg.Email, arg.ID)
+	return err
+}
@@ -10,31 +10,115 @@ import (
 )
 
 type Querier interface {
+	AddBookCopy(ctx context.Context, isbn int64) (int64, error)
+	CloseAudit(ctx context.Context, arg CloseAuditParams) (int64, error)
+	CompletePlan(ctx context.Context, id int64) (int64, error)
+	CompletePlanMove(ctx context.Context, arg CompletePlanMoveParams) (int64, error)
 	CountAuthors(ctx context.Context, isbn sql.NullInt64) (int64, error)
+	CountBooksAddedByMonth(ctx context.Context) ([]CountBooksAddedByMonthRow, error)
+	CountBooksByCategory(ctx context.Context) ([]CountBooksByCategoryRow, error)
+	CountBooksByDecade(ctx context.Context) ([]CountBooksByDecadeRow, error)
+	CountBooksByLanguage(ctx context.Context) ([]CountBooksByLanguageRow, error)
+	CountBooksByShelf(ctx context.Context) ([]CountBooksByShelfRow, error)
 	CountCategories(ctx context.Context, isbn sql.NullInt64) (int64, error)
+	CountLocationBooks(ctx context.Context, locationID sql.NullInt64) (int64, error)
+	CountLocationChildren(ctx context.Context, parentID sql.NullInt64) (int64, error)
+	CountShelfBooks(ctx context.Context, shelfID sql.NullInt64) (int64, error)
+	CountShelfSubLocations(ctx context.Context, arg CountShelfSubLocationsParams) (int64, error)
+	CountUsers(ctx context.Context) (int64, error)
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) error
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteActiveBorrowing(ctx context.Context, id int64) (int64, error)
 	DeleteBook(ctx context.Context, isbn int64) (int64, error)
+	DeleteExpiredSessions(ctx context.Context) error
+	DeleteLocation(ctx context.Context, id int64) (int64, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteShelf(ctx context.Context, id int64) (int64, error)
+	DeleteShelfLocations(ctx context.Context, arg DeleteShelfLocationsParams) error
+	FulfillHold(ctx context.Context, arg FulfillHoldParams) error
 	GetActiveBorrowings(ctx context.Context) ([]GetActiveBorrowingsRow, error)
+	GetActiveLoans(ctx context.Context, personID sql.NullInt64) ([]GetActiveLoansRow, error)
 	GetAllBooks(ctx context.Context) ([]GetAllBooksRow, error)
-	GetAllPeople(ctx context.Context) ([]Person, error)
+	GetAllLocations(ctx context.Context) ([]Location, error)
+	GetAllPeople(ctx context.Context) ([]GetAllPeopleRow, error)
 	GetAllShelfs(ctx context.Context) ([]Shelf, error)
+	GetAudit(ctx context.Context, id int64) (Audit, error)
+	GetAuditScans(ctx context.Context, auditID int64) ([]int64, error)
+	GetAudits(ctx context.Context, locationID sql.NullInt64) ([]Audit, error)
 	GetAuthors(ctx context.Context, isbn sql.NullInt64) ([]sql.NullString, error)
 	GetBook(ctx context.Context, isbn int64) (GetBookRow, error)
+	GetBookTotals(ctx context.Context) (GetBookTotalsRow, error)
 	GetCategories(ctx context.Context, isbn sql.NullInt64) ([]sql.NullString, error)
+	GetLastAudits(ctx context.Context) ([]GetLastAuditsRow, error)
+	GetLastUndoableBookScanEventID(ctx context.Context, isbn sql.NullInt64) (int64, error)
+	GetLastUndoableScanEvent(ctx context.Context, device string) (ScanEvent, error)
+	GetLoanDurations(ctx context.Context) (GetLoanDurationsRow, error)
+	GetLoansDueBetween(ctx context.Context, arg GetLoansDueBetweenParams) ([]GetLoansDueBetweenRow, error)
+	GetLocation(ctx context.Context, id int64) (Location, error)
+	GetLocationBookCounts(ctx context.Context) ([]GetLocationBookCountsRow, error)
+	GetLocationBooks(ctx context.Context, id int64) ([]int64, error)
+	GetMostActiveBorrowers(ctx context.Context, limit int64) ([]GetMostActiveBorrowersRow, error)
+	GetMostBorrowedBooks(ctx context.Context, limit int64) ([]GetMostBorrowedBooksRow, error)
+	GetNextHold(ctx context.Context, isbn int64) (GetNextHoldRow, error)
+	GetNextRowPosition(ctx context.Context, arg GetNextRowPositionParams) (int64, error)
 	GetPerson(ctx context.Context, name string) (int64, error)
+	GetPersonByID(ctx context.Context, id int64) (GetPersonByIDRow, error)
+	GetPersonCalendarToken(ctx context.Context, id int64) (sql.NullString, error)
+	GetPlan(ctx context.Context, id int64) (Plan, error)
+	GetPlanBooks(ctx context.Context, planID int64) ([]PlanBook, error)
+	GetPlans(ctx context.Context) ([]Plan, error)
+	GetRowBooks(ctx context.Context, arg GetRowBooksParams) ([]int64, error)
+	GetScanEvent(ctx context.Context, id int64) (ScanEvent, error)
+	GetScanEvents(ctx context.Context, arg GetScanEventsParams) ([]ScanEvent, error)
 	GetShelf(ctx context.Context, id int64) (Shelf, error)
+	GetShelfLocations(ctx context.Context, shelfID sql.NullInt64) ([]Location, error)
+	GetShelfMaxRow(ctx context.Context, shelfID sql.NullInt64) (int64, error)
 	GetShelfName(ctx context.Context, id int64) (sql.NullString, error)
+	GetShelfRowCounts(ctx context.Context) ([]GetShelfRowCountsRow, error)
+	GetSpineColors(ctx context.Context) ([]GetSpineColorsRow, error)
 	GetUnenrichedBooks(ctx context.Context) ([]int64, error)
+	GetUserByAPIToken(ctx context.Context, tokenHash string) (User, error)
+	GetUserByName(ctx context.Context, name string) (User, error)
+	GetUserBySession(ctx context.Context, tokenHash string) (User, error)
+	InsertAudit(ctx context.Context, locationID int64) (Audit, error)
+	InsertAuditScan(ctx context.Context, arg InsertAuditScanParams) error
 	InsertAuthor(ctx context.Context, arg InsertAuthorParams) error
 	InsertBook(ctx context.Context, arg InsertBookParams) error
-	InsertBorrowing(ctx context.Context, arg InsertBorrowingParams) error
+	InsertBorrowing(ctx context.Context, arg InsertBorrowingParams) (int64, error)
 	InsertCategory(ctx context.Context, arg InsertCategoryParams) error
+	InsertHold(ctx context.Context, arg InsertHoldParams) error
+	InsertLocation(ctx context.Context, arg InsertLocationParams) (Location, error)
 	InsertPerson(ctx context.Context, name string) (int64, error)
-	InsertShelf(ctx context.Context, arg InsertShelfParams) error
+	InsertPlan(ctx context.Context, policy string) (Plan, error)
+	InsertPlanBook(ctx context.Context, arg InsertPlanBookParams) error
+	InsertScanEvent(ctx context.Context, arg InsertScanEventParams) (ScanEvent, error)
+	InsertShelf(ctx context.Context, arg InsertShelfParams) (Shelf, error)
+	IsBookBorrowed(ctx context.Context, isbn int64) (int64, error)
+	MarkAuditApplied(ctx context.Context, id int64) (int64, error)
 	MarkBookAsEnriched(ctx context.Context, isbn int64) error
+	MarkHoldNotified(ctx context.Context, id int64) error
+	MarkScanEventUndone(ctx context.Context, id int64) (int64, error)
+	MoveShelfBooks(ctx context.Context, arg MoveShelfBooksParams) (int64, error)
+	RecordLoanNotification(ctx context.Context, arg RecordLoanNotificationParams) error
+	RemoveBookCopy(ctx context.Context, isbn int64) (int64, error)
+	RenameShelfLocation(ctx context.Context, arg RenameShelfLocationParams) error
+	ReopenBorrowing(ctx context.Context, id int64) (int64, error)
+	ResolveShelfLocation(ctx context.Context, arg ResolveShelfLocationParams) (int64, error)
 	ReturnBook(ctx context.Context, arg ReturnBookParams) error
+	ReturnBookByISBN(ctx context.Context, isbn int64) (int64, error)
+	SetBookLocation(ctx context.Context, arg SetBookLocationParams) (int64, error)
+	SetBookPosition(ctx context.Context, arg SetBookPositionParams) error
+	SetBookPriceSupplement(ctx context.Context, arg SetBookPriceSupplementParams) error
+	SetSpineColor(ctx context.Context, arg SetSpineColorParams) error
+	TouchAPIToken(ctx context.Context, tokenHash string) error
 	UpdateBookDescription(ctx context.Context, arg UpdateBookDescriptionParams) error
 	UpdateBookPublishedDate(ctx context.Context, arg UpdateBookPublishedDateParams) error
 	UpdateBookTitle(ctx context.Context, arg UpdateBookTitleParams) error
+	UpdateLocation(ctx context.Context, arg UpdateLocationParams) (int64, error)
+	UpdatePersonEmail(ctx context.Context, arg UpdatePersonEmailParams) error
+	UpdateShelf(ctx context.Context, arg UpdateShelfParams) (int64, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -10,6 +10,29 @@ import (
 	"database/sql"
 )
 
+const countShelfBooks = `-- name: CountShelfBooks :one
+SELECT COUNT(*) FROM books WHERE shelf_id = ?
+`
+
+func (q *Queries) CountShelfBooks(ctx context.Context, shelfID sql.NullInt64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countShelfBooks, shelfID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteShelf = `-- name: DeleteShelf :execrows
+DELETE FROM shelfs WHERE id = ?
+`
+
+func (q *Queries) DeleteShelf(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteShelf, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllShelfs = `-- name: GetAllShelfs :many
 SELECT id, name, rows_count FROM shelfs
 `
@@ -48,6 +71,17 @@ func (q *Queries) GetShelf(ctx context.Context, id int64) (Shelf, error) {
 	return i, err
 }
 
+const getShelfMaxRow = `-- name: GetShelfMaxRow :one
+SELECT CAST(COALESCE(MAX(row_number), 0) AS INTEGER) AS max_row FROM books WHERE shelf_id = ?
+`
+
+func (q *Queries) GetShelfMaxRow(ctx context.Context, shelfID sql.NullInt64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getShelfMaxRow, shelfID)
+	var max_row int64
+	err := row.Scan(&max_row)
+	return max_row, err
+}
+
 const getShelfName = `-- name: GetShelfName :one
 SELECT name FROM shelfs WHERE id = ?
 `
@@ -59,8 +93,47 @@ func (q *Queries) GetShelfName(ctx context.Context, id int64) (sql.NullString, e
 	return name, err
 }
 
-const insertShelf = `-- name: InsertShelf :exec
+const getShelfRowCounts = `-- name: GetShelfRowCounts :many
+SELECT CAST(COALESCE(shelf_id, 0) AS INTEGER) AS shelf_id,
+    CAST(COALESCE(row_number, 0) AS INTEGER) AS row_number,
+    COUNT(*) AS books
+FROM books
+GROUP BY 1, 2
+ORDER BY 1, 2
+`
+
+type GetShelfRowCountsRow struct {
+	ShelfID   int64 `json:"shelf_id"`
+	RowNumber int64 `json:"row_number"`
+	Books     int64 `json:"books"`
+}
+
+func (q *Queries) GetShelfRowCounts(ctx context.Context) ([]GetShelfRowCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getShelfRowCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetShelfRowCountsRow{}
+	for rows.Next() {
+		var i GetShelfRowCountsRow
+		if err := rows.Scan(&i.ShelfID, &i.RowNumber, &i.Books); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertShelf = `-- name: InsertShelf :one
 INSERT INTO shelfs (name, rows_count) VALUES (?, ?)
+RETURNING id, name, rows_count
 `
 
 type InsertShelfParams struct {
@@ -68,7 +141,60 @@ type InsertShelfParams struct {
 	RowsCount sql.NullInt64  `json:"rows_count"`
 }
 
-func (q *Queries) InsertShelf(ctx context.Context, arg InsertShelfParams) error {
-	_, err := q.db.ExecContext(ctx, insertShelf, arg.Name, arg.RowsCount)
-	return err
+func (q *Queries) InsertShelf(ctx context.Context, arg InsertShelfParams) (Shelf, error) {
+	row := q.db.QueryRowContext(ctx, insertShelf, arg.Name, arg.RowsCount)
+	var i Shelf
+	err := row.Scan(&i.ID, &i.Name, &i.RowsCount)
+	return i, err
+}
+
+const moveShelfBooks = `-- name: MoveShelfBooks :execrows
+UPDATE books
+SET shelf_id = ?1,
+    row_number = CASE WHEN row_number <= ?2 THEN row_number ELSE NULL END,
+    slot = CASE WHEN row_number <= ?2 THEN slot ELSE NULL END,
+    position = CASE WHEN row_number <= ?2 THEN position ELSE NULL END,
+    location_id = (
+        SELECT l.id FROM locations l
+        WHERE l.shelf_id = ?1
+            AND (l.row_number = CASE WHEN books.row_number <= ?2 THEN books.row_number END OR l.row_number IS NULL)
+        ORDER BY l.row_number IS NULL
+        LIMIT 1
+    )
+WHERE shelf_id = ?3
+`
+
+type MoveShelfBooksParams struct {
+	ToShelfID   sql.NullInt64 `json:"to_shelf_id"`
+	MaxRow      sql.NullInt64 `json:"max_row"`
+	FromShelfID sql.NullInt64 `json:"from_shelf_id"`
+}
+
+func (q *Queries) MoveShelfBooks(ctx context.Context, arg MoveShelfBooksParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveShelfBooks, arg.ToShelfID, arg.MaxRow, arg.FromShelfID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateShelf = `-- name: UpdateShelf :execrows
+UPDATE shelfs
+SET name = COALESCE(?1, name),
+    rows_count = COALESCE(?2, rows_count)
+WHERE id = ?3
+`
+
+type UpdateShelfParams struct {
+	Name      sql.NullString `json:"name"`
+	RowsCount sql.NullInt64  `json:"rows_count"`
+	ID        int64          `json:"id"`
+}
+
+func (q *Queries) UpdateShelf(ctx context.Context, arg UpdateShelfParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateShelf, arg.Name, arg.RowsCount, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,293 @@
+// Package ean finds EAN-13 barcodes, such as the ISBN on the back of a book,
+// in pictures.
+//
+// Lines are scanned across the picture, both across and down so that the
+// barcode can be on its side, and each line is read in both directions so
+// that it can be upside down. A line is split into light and dark runs, and
+// each digit is matched by the widths of its four runs relative to each
+// other, which copes with barcodes photographed at any size and at a slight
+// angle. The guard bars and the check digit keep out misreads.
+package ean
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// scanLines is how many lines are scanned across the picture each way.
+const scanLines = 64
+
+// minContrast is the difference in brightness a line needs between its
+// lightest and darkest pixels to be read at all.
+const minContrast = 48
+
+// maxDigitError is how far the runs of a digit can be off from their pattern,
+// in modules summed over the four runs.
+const maxDigitError = 1.5
+
+// quietZone is how many modules of light a barcode needs on either side.
+const quietZone = 3
+
+// Runs of an EAN-13 barcode: 3 for each guard at the ends, 5 for the middle
+// guard and 4 for each of the 12 encoded digits.
+const (
+	barcodeRuns = 3 + 6*4 + 5 + 6*4 + 3
+	modules     = 3 + 6*7 + 5 + 6*7 + 3
+)
+
+// digitRuns are the widths of the runs of the digits in the first half,
+// starting with a light run, with odd parity. The second half uses the same
+// widths starting with a dark run, and even parity is the reverse.
+var digitRuns = [10][4]int{
+	{3, 2, 1, 1},
+	{2, 2, 2, 1},
+	{2, 1, 2, 2},
+	{1, 4, 1, 1},
+	{1, 1, 3, 2},
+	{1, 2, 3, 1},
+	{1, 1, 1, 4},
+	{1, 3, 1, 2},
+	{1, 2, 1, 3},
+	{3, 1, 1, 2},
+}
+
+// firstDigits maps the parities of the first half's digits, a bit set for
+// each even one starting from the highest, to the first digit of the code,
+// which is not drawn itself.
+var firstDigits = map[int]int{
+	0b000000: 0,
+	0b001011: 1,
+	0b001101: 2,
+	0b001110: 3,
+	0b010011: 4,
+	0b011001: 5,
+	0b011100: 6,
+	0b010100: 7,
+	0b010110: 8,
+	0b011010: 9,
+}
+
+// Decode returns the EAN-13 codes found in a picture, each once, in the
+// order they are found.
+func Decode(img image.Image) []string {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	draw.Draw(gray, b, img, b.Min, draw.Src)
+
+	var codes []string
+	seen := map[string]bool{}
+	read := func(line []uint8) {
+		for _, code := range decodeLine(line) {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	w, h := b.Dx(), b.Dy()
+	for i := range scanLines {
+		y := b.Min.Y + (2*i+1)*h/(2*scanLines)
+		read(row(gray, y))
+	}
+	for i := range scanLines {
+		x := b.Min.X + (2*i+1)*w/(2*scanLines)
+		read(column(gray, x))
+	}
+	return codes
+}
+
+// row returns the brightness along a row of the picture, averaged with the
+// rows next to it to smooth out noise.
+func row(gray *image.Gray, y int) []uint8 {
+	b := gray.Bounds()
+	line := make([]uint8, b.Dx())
+	for x := b.Min.X; x < b.Max.X; x++ {
+		sum, n := 0, 0
+		for dy := -1; dy <= 1; dy++ {
+			if y+dy >= b.Min.Y && y+dy < b.Max.Y {
+				sum += int(gray.GrayAt(x, y+dy).Y)
+				n++
+			}
+		}
+		line[x-b.Min.X] = uint8(sum / n)
+	}
+	return line
+}
+
+// column returns the brightness down a column of the picture, averaged with
+// the columns next to it.
+func column(gray *image.Gray, x int) []uint8 {
+	b := gray.Bounds()
+	line := make([]uint8, b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		sum, n := 0, 0
+		for dx := -1; dx <= 1; dx++ {
+			if x+dx >= b.Min.X && x+dx < b.Max.X {
+				sum += int(gray.GrayAt(x+dx, y).Y)
+				n++
+			}
+		}
+		line[y-b.Min.Y] = uint8(sum / n)
+	}
+	return line
+}
+
+// decodeLine returns the codes read along a line, in either direction.
+func decodeLine(line []uint8) []string {
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range line {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+	if int(hi)-int(lo) < minContrast {
+		return nil
+	}
+	threshold := (int(lo) + int(hi)) / 2
+
+	// Runs alternate between light and dark, starting with light.
+	runs := []int{0}
+	dark := false
+	for _, v := range line {
+		if (int(v) < threshold) != dark {
+			dark = !dark
+			runs = append(runs, 0)
+		}
+		runs[len(runs)-1]++
+	}
+
+	codes := decodeRuns(runs)
+
+	reversed := make([]int, 0, len(runs)+1)
+	if len(runs)%2 == 0 {
+		// The line ends dark, so the reversed runs start with an empty
+		// light one.
+		reversed = append(reversed, 0)
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		reversed = append(reversed, runs[i])
+	}
+	return append(codes, decodeRuns(reversed)...)
+}
+
+// decodeRuns returns the codes found in a line's runs, which start with a
+// light one.
+func decodeRuns(runs []int) []string {
+	var codes []string
+	for i := 1; i+barcodeRuns <= len(runs); i += 2 {
+		code, ok := decodeBarcode(runs, i)
+		if ok {
+			codes = append(codes, code)
+			i += barcodeRuns - 1
+		}
+	}
+	return codes
+}
+
+// decodeBarcode reads a barcode starting with the dark run at start.
+func decodeBarcode(runs []int, start int) (string, bool) {
+	bars := runs[start : start+barcodeRuns]
+	total := 0
+	for _, r := range bars {
+		total += r
+	}
+	module := float64(total) / modules
+
+	if float64(runs[start-1]) < quietZone*module {
+		return "", false
+	}
+	if end := start + barcodeRuns; end < len(runs) && float64(runs[end]) < quietZone*module {
+		return "", false
+	}
+	for _, guard := range [][]int{bars[0:3], bars[27:32], bars[56:59]} {
+		for _, r := range guard {
+			if float64(r) < module/2 || float64(r) > module*2 {
+				return "", false
+			}
+		}
+	}
+
+	digits := make([]int, 13)
+	parities := 0
+	for d := range 6 {
+		digit, even, ok := matchDigit(bars[3+4*d:7+4*d], true)
+		if !ok {
+			return "", false
+		}
+		digits[1+d] = digit
+		parities <<= 1
+		if even {
+			parities |= 1
+		}
+	}
+	first, ok := firstDigits[parities]
+	if !ok {
+		return "", false
+	}
+	digits[0] = first
+	for d := range 6 {
+		digit, _, ok := matchDigit(bars[32+4*d:36+4*d], false)
+		if !ok {
+			return "", false
+		}
+		digits[7+d] = digit
+	}
+
+	if !validCheckDigit(digits) {
+		return "", false
+	}
+	code := make([]byte, 0, 13)
+	for _, d := range digits {
+		code = strconv.AppendInt(code, int64(d), 10)
+	}
+	return string(code), true
+}
+
+// matchDigit finds the digit whose pattern is closest to four runs. Digits in
+// the first half can have either parity; even reports it was even.
+func matchDigit(runs []int, bothParities bool) (digit int, even bool, ok bool) {
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+	if total == 0 {
+		return 0, false, false
+	}
+
+	best := math.Inf(1)
+	for d, pattern := range digitRuns {
+		for _, reverse := range []bool{false, true} {
+			if reverse && !bothParities {
+				continue
+			}
+			e := 0.0
+			for k, r := range runs {
+				p := pattern[k]
+				if reverse {
+					p = pattern[3-k]
+				}
+				e += math.Abs(float64(r)*7/float64(total) - float64(p))
+			}
+			if e < best {
+				best, digit, even = e, d, reverse
+			}
+		}
+	}
+	return digit, even, best <= maxDigitError
+}
+
+// validCheckDigit reports whether the last of the 13 digits checks the
+// others.
+func validCheckDigit(digits []int) bool {
+	sum := 0
+	for i, d := range digits[:12] {
+		if i%2 == 0 {
+			sum += d
+		} else {
+			sum += 3 * d
+		}
+	}
+	return (10-sum%10)%10 == digits[12]
+}
@@ -0,0 +1,119 @@
+package ean
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+	"github.com/google/go-cmp/cmp"
+)
+
+// photo draws EAN-13 barcodes side by side on a light background with some
+// noise, the way they would be on the back of a book.
+func photo(t *testing.T, width int, codes ...string) *image.Gray {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 60+len(codes)*(width+60), 200))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 230}), image.Point{}, draw.Src)
+	for i, code := range codes {
+		bc, err := ean.Encode(code)
+		if err != nil {
+			t.Fatalf("cannot encode %s: %v", code, err)
+		}
+		scaled, err := barcode.Scale(bc, width, 120)
+		if err != nil {
+			t.Fatalf("cannot scale %s: %v", code, err)
+		}
+		at := image.Pt(60+i*(width+60), 40)
+		draw.Draw(img, scaled.Bounds().Add(at), scaled, image.Point{}, draw.Src)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := range img.Pix {
+		v := int(img.Pix[i]) + rng.Intn(41) - 20
+		img.Pix[i] = uint8(min(max(v, 0), 255))
+	}
+	return img
+}
+
+// rotate turns a picture a quarter turn clockwise.
+func rotate(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetGray(b.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		img  image.Image
+		want []string
+	}{
+		"upright": {
+			img:  photo(t, 95*3, "9783836526722"),
+			want: []string{"9783836526722"},
+		},
+		"uneven modules": {
+			img:  photo(t, 250, "9780134685991"),
+			want: []string{"9780134685991"},
+		},
+		"on its side": {
+			img:  rotate(photo(t, 95*2, "9780000000002")),
+			want: []string{"9780000000002"},
+		},
+		"upside down": {
+			img:  rotate(rotate(photo(t, 95*2, "9781234567897"))),
+			want: []string{"9781234567897"},
+		},
+		"several": {
+			img:  photo(t, 95*2, "9783836526722", "4006381333931"),
+			want: []string{"9783836526722", "4006381333931"},
+		},
+		"no barcode": {
+			img:  photo(t, 95*2),
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Decode(tc.img)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeEncodedPhotos(t *testing.T) {
+	img := photo(t, 95*3, "9783836526722")
+
+	var jpg, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatalf("cannot encode JPEG: %v", err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("cannot encode PNG: %v", err)
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{"jpeg": &jpg, "png": &pngBuf} {
+		decoded, _, err := image.Decode(buf)
+		if err != nil {
+			t.Fatalf("cannot decode %s: %v", name, err)
+		}
+		if got := Decode(decoded); len(got) != 1 || got[0] != "9783836526722" {
+			t.Errorf("%s: expected 9783836526722, got %v", name, got)
+		}
+	}
+}
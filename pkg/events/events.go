@@ -0,0 +1,95 @@
+// Package events is a bus that tells subscribers about scans and the changes
+// they make while they happen, e.g. to show them on a scan station screen.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// Kind identifies what happened.
+type Kind string
+
+const (
+	KindBookAdded    Kind = "book-added"
+	KindBookMoved    Kind = "book-moved"
+	KindScanFailed   Kind = "scan-failed"
+	KindShelfChanged Kind = "shelf-changed"
+)
+
+// Event is something that happened in the library.
+type Event struct {
+	ID     uint64    `json:"id"`
+	Kind   Kind      `json:"kind"`
+	Time   time.Time `json:"time"`
+	Device string    `json:"device,omitempty"`
+	Code   string    `json:"code,omitempty"`
+	// Book is the book that was added or moved. Outcome says whether an
+	// added book is new or was already there.
+	Book    *models.Book `json:"book,omitempty"`
+	Outcome string       `json:"outcome,omitempty"`
+	// MovedFrom is where a moved book was before.
+	MovedFrom *models.BookPlace `json:"moved_from,omitempty"`
+	// Shelf is the place a scanner changed to.
+	Shelf *models.BookPlace `json:"shelf,omitempty"`
+	// Action is what a failed scan tried to do.
+	Action string `json:"action,omitempty"`
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind by before
+// it misses some.
+const subscriberBuffer = 64
+
+// Bus passes published events on to every subscriber.
+type Bus struct {
+	mu     sync.Mutex
+	lastID uint64
+	subs   map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[chan Event]struct{}{}}
+}
+
+// Publish numbers and timestamps an event and sends it to the subscribers.
+// It does not wait for slow subscribers; they miss the event instead.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	return e
+}
+
+// Subscribe returns a channel with the events published from now on. Call
+// the returned function to stop them; it closes the channel.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	// Events published before subscribing are not seen.
+	bus.Publish(Event{Kind: KindScanFailed})
+
+	first, stopFirst := bus.Subscribe()
+	second, stopSecond := bus.Subscribe()
+	defer stopSecond()
+
+	published := bus.Publish(Event{Kind: KindBookAdded, Device: "desk"})
+	if published.ID != 2 || published.Time.IsZero() {
+		t.Fatalf("expected the event to be numbered and timestamped, got %+v", published)
+	}
+
+	for _, ch := range []<-chan Event{first, second} {
+		e := <-ch
+		if e.ID != 2 || e.Kind != KindBookAdded || e.Device != "desk" {
+			t.Fatalf("expected the published event, got %+v", e)
+		}
+	}
+
+	stopFirst()
+	stopFirst()
+	if _, ok := <-first; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+	bus.Publish(Event{Kind: KindBookMoved})
+	if e := <-second; e.Kind != KindBookMoved {
+		t.Fatalf("expected the other subscriber to still get events, got %+v", e)
+	}
+
+	// A subscriber that falls behind misses events instead of holding up
+	// the bus.
+	for range subscriberBuffer + 10 {
+		bus.Publish(Event{Kind: KindShelfChanged})
+	}
+	if n := len(second); n != subscriberBuffer {
+		t.Fatalf("expected %d buffered events, got %d", subscriberBuffer, n)
+	}
+}
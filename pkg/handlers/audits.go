@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/audit"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/locations"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"github.com/labstack/echo/v4"
+)
+
+// StartAudit opens an audit of a shelf row or another location.
+func (ls *Librascan) StartAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.AuditRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+
+	locationID := int64(req.LocationID)
+	if req.Location != "" {
+		code := scancode.Parse(req.Location)
+		if code.Kind != scancode.Shelf {
+			return errorJSON(c, http.StatusBadRequest, "invalid location")
+		}
+		id, err := ls.queries.ResolveShelfLocation(ctx, db.ResolveShelfLocationParams{
+			ShelfID:   db.IntToNullInt64(code.ShelfID),
+			RowNumber: db.IntToNullInt64(code.Row),
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errorJSON(c, http.StatusBadRequest, "location not found")
+			}
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		locationID = id
+	}
+	if locationID == 0 {
+		return errorJSON(c, http.StatusBadRequest, "location or location_id is required")
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if _, ok := tree.Get(locationID); !ok {
+		return errorJSON(c, http.StatusBadRequest, "location not found")
+	}
+
+	a, err := ls.queries.InsertAudit(ctx, locationID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, toModelAudit(tree, a))
+}
+
+// GetAudits lists audits, newest first. location_id limits the list to one
+// location.
+func (ls *Librascan) GetAudits(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	locationID := 0
+	if locationIDStr := c.QueryParam("location_id"); locationIDStr != "" {
+		var err error
+		locationID, err = strconv.Atoi(locationIDStr)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, "invalid location_id")
+		}
+	}
+
+	audits, err := ls.queries.GetAudits(ctx, db.IntToNullInt64(locationID))
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	result := make([]models.Audit, 0, len(audits))
+	for _, a := range audits {
+		result = append(result, toModelAudit(tree, a))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetAudit returns the report of an audit. The report of an open audit is
+// worked out from the scans so far.
+func (ls *Librascan) GetAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid audit id")
+	}
+	a, err := ls.queries.GetAudit(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "audit not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	report, err := ls.auditReport(ctx, a)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// AddAuditScan records a book found during an open audit and says whether it
+// belongs there.
+func (ls *Librascan) AddAuditScan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid audit id")
+	}
+	a, err := ls.queries.GetAudit(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "audit not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if a.ClosedAt.Valid {
+		return errorJSON(c, http.StatusConflict, "audit is closed")
+	}
+
+	var req models.AuditScanRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.ISBN == 0 {
+		return errorJSON(c, http.StatusBadRequest, "isbn is required")
+	}
+
+	err = ls.queries.InsertAuditScan(ctx, db.InsertAuditScanParams{AuditID: a.ID, Isbn: int64(req.ISBN)})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	in, err := ls.auditInput(ctx, tree, a)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	isbn := int64(req.ISBN)
+	scan := models.AuditScan{
+		ISBN:   req.ISBN,
+		Status: audit.Classify(isbn, in.Expected, in.Books),
+	}
+	if scan.Status != audit.StatusUnknown {
+		book := in.Books[isbn]
+		scan.Book = &models.AuditBook{
+			ISBN:         book.ISBN,
+			Title:        book.Title,
+			LocationPath: book.LocationPath,
+			Borrower:     in.Borrowers[isbn],
+		}
+		scan.Borrowed = scan.Book.Borrower != ""
+	}
+
+	return c.JSON(http.StatusOK, scan)
+}
+
+// CloseAudit ends an audit and stores its report.
+func (ls *Librascan) CloseAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid audit id")
+	}
+	a, err := ls.queries.GetAudit(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "audit not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if a.ClosedAt.Valid {
+		return errorJSON(c, http.StatusConflict, "audit is already closed")
+	}
+
+	report, err := ls.auditReport(ctx, a)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "encode error: "+err.Error())
+	}
+
+	n, err := ls.queries.CloseAudit(ctx, db.CloseAuditParams{
+		Report: db.StringToNullString(string(reportJSON)),
+		ID:     a.ID,
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if n == 0 {
+		return errorJSON(c, http.StatusConflict, "audit is already closed")
+	}
+
+	return ls.GetAudit(c)
+}
+
+// ApplyAudit applies the corrections of a closed audit: misplaced books are
+// recorded in the audited location and missing books are taken off their
+// shelf.
+func (ls *Librascan) ApplyAudit(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid audit id")
+	}
+	a, err := ls.queries.GetAudit(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "audit not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if !a.ClosedAt.Valid {
+		return errorJSON(c, http.StatusConflict, "audit is still open")
+	}
+	if a.AppliedAt.Valid {
+		return errorJSON(c, http.StatusConflict, "audit has already been applied")
+	}
+
+	report, err := ls.auditReport(ctx, a)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	shelfID, row := tree.Shelf(a.LocationID)
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	for _, correction := range report.Corrections {
+		params := db.SetBookLocationParams{Isbn: int64(correction.ISBN)}
+		if correction.Action == audit.ActionMove {
+			params.LocationID = sql.NullInt64{Int64: a.LocationID, Valid: true}
+			params.ShelfID = db.IntToNullInt64(shelfID)
+			params.RowNumber = db.IntToNullInt64(row)
+			params.Position, err = nextRowPosition(ctx, qtx, shelfID, row)
+			if err != nil {
+				return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+			}
+		}
+		if _, err := qtx.SetBookLocation(ctx, params); err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+		}
+	}
+
+	// An audit of a row scans it from left to right, so it also puts the
+	// row's books in order.
+	if n, ok := tree.Get(a.LocationID); ok && n.RowNumber.Valid {
+		scanned, err := qtx.GetAuditScans(ctx, a.ID)
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		if err := resequenceRow(ctx, qtx, shelfID, row, scanned); err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+		}
+	}
+
+	n, err := qtx.MarkAuditApplied(ctx, a.ID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if n == 0 {
+		return errorJSON(c, http.StatusConflict, "audit has already been applied")
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	return ls.GetAudit(c)
+}
+
+// auditReport returns the stored report of a closed audit, or works out the
+// report of an open one.
+func (ls *Librascan) auditReport(ctx context.Context, a db.Audit) (models.AuditReport, error) {
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return models.AuditReport{}, err
+	}
+
+	if a.Report.Valid {
+		var report models.AuditReport
+		if err := json.Unmarshal([]byte(a.Report.String), &report); err != nil {
+			return models.AuditReport{}, err
+		}
+		report.Audit = toModelAudit(tree, a)
+		return report, nil
+	}
+
+	in, err := ls.auditInput(ctx, tree, a)
+	if err != nil {
+		return models.AuditReport{}, err
+	}
+	in.CanMove = tree.IsLeaf(a.LocationID)
+
+	return audit.Compare(toModelAudit(tree, a), in), nil
+}
+
+func (ls *Librascan) auditInput(ctx context.Context, tree *locations.Tree, a db.Audit) (audit.Input, error) {
+	expected, err := ls.queries.GetLocationBooks(ctx, a.LocationID)
+	if err != nil {
+		return audit.Input{}, err
+	}
+	scanned, err := ls.queries.GetAuditScans(ctx, a.ID)
+	if err != nil {
+		return audit.Input{}, err
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return audit.Input{}, err
+	}
+	loans, err := ls.queries.GetActiveBorrowings(ctx)
+	if err != nil {
+		return audit.Input{}, err
+	}
+
+	in := audit.Input{
+		Expected:  expected,
+		Scanned:   scanned,
+		Books:     make(map[int64]models.Book, len(books)),
+		Borrowers: make(map[int64]string, len(loans)),
+	}
+	for _, book := range books {
+		in.Books[int64(book.ISBN)] = book
+	}
+	for _, loan := range loans {
+		in.Borrowers[loan.Isbn] = loan.PersonName
+	}
+	return in, nil
+}
+
+// lastAudits returns when each location was last audited.
+func (ls *Librascan) lastAudits(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := ls.queries.GetLastAudits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		t, err := db.ParseSQLiteTime(row.LastAuditedAt)
+		if err != nil {
+			return nil, err
+		}
+		last[row.LocationID] = t
+	}
+	return last, nil
+}
+
+func toModelAudit(tree *locations.Tree, a db.Audit) models.Audit {
+	startedAt, _ := db.ParseSQLiteTime(a.StartedAt)
+	return models.Audit{
+		ID:           int(a.ID),
+		LocationID:   int(a.LocationID),
+		LocationPath: tree.Path(a.LocationID),
+		StartedAt:    startedAt,
+		ClosedAt:     parseNullTime(a.ClosedAt),
+		AppliedAt:    parseNullTime(a.AppliedAt),
+	}
+}
+
+func parseNullTime(s sql.NullString) *time.Time {
+	if !s.Valid {
+		return nil
+	}
+	t, err := db.ParseSQLiteTime(s.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/auth"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/labstack/echo/v4"
+)
+
+type loginPage struct {
+	Name  string
+	Next  string
+	Error string
+}
+
+// LoginPage renders the form to sign in to the web interface with.
+func (ls *Librascan) LoginPage(c echo.Context) error {
+	return renderLogin(c, http.StatusOK, loginPage{Next: c.QueryParam("next")})
+}
+
+// Login checks a name and password and starts a session for them, kept in
+// a cookie.
+func (ls *Librascan) Login(c echo.Context) error {
+	page := loginPage{Name: c.FormValue("name"), Next: c.FormValue("next")}
+	ctx := c.Request().Context()
+
+	user, err := ls.queries.GetUserByName(ctx, page.Name)
+	if err != nil && err != sql.ErrNoRows {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+	if err != nil || !user.PasswordHash.Valid || !auth.CheckPassword(user.PasswordHash.String, c.FormValue("password")) {
+		page.Error = "Wrong name or password."
+		return renderLogin(c, http.StatusUnauthorized, page)
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, err.Error())
+	}
+	expires := time.Now().UTC().Add(auth.SessionTTL)
+	err = ls.queries.CreateSession(ctx, db.CreateSessionParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: expires.Format(db.SQLiteTimeLayout),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+	if err := ls.queries.DeleteExpiredSessions(ctx); err != nil {
+		c.Logger().Errorf("cannot delete expired sessions: %v", err)
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusSeeOther, localPath(page.Next))
+}
+
+// Logout ends the session of the request and signs out of the web
+// interface.
+func (ls *Librascan) Logout(c echo.Context) error {
+	if cookie, err := c.Cookie(auth.SessionCookie); err == nil {
+		if err := ls.queries.DeleteSession(c.Request().Context(), auth.HashToken(cookie.Value)); err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+		}
+	}
+	c.SetCookie(&http.Cookie{
+		Name:     auth.SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.Redirect(http.StatusSeeOther, "/login")
+}
+
+func renderLogin(c echo.Context, status int, page loginPage) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "login.html", page); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+	return c.HTML(status, buf.String())
+}
+
+// localPath returns where to go after signing in. Only paths on this server
+// are followed, so that the login form cannot send anyone elsewhere.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// borrowerName returns the name of who to borrow or hold a book for. Readers
+// may only do so for themselves, and their own name is used when none is
+// given.
+func borrowerName(c echo.Context, name string) (string, error) {
+	user, ok := auth.UserFrom(c)
+	if !ok || user.Role.Includes(auth.RoleLibrarian) {
+		return name, nil
+	}
+	if name == "" {
+		return user.Name, nil
+	}
+	if name != user.Name {
+		return "", echo.NewHTTPError(http.StatusForbidden, "readers may only borrow and hold books for themselves")
+	}
+	return name, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gouthamve/librascan/pkg/auth"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// BorrowingsCalendarHandler serves the due dates of active loans as an
+// iCalendar feed. Calendar apps cannot sign in, so the feed of one person is
+// read with their person id and token. With authentication turned on, the
+// feed of every loan is only served to signed in librarians and admins.
+func (ls *Librascan) BorrowingsCalendarHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	calendarName := "Librascan loans"
+	personID := sql.NullInt64{}
+	personStr := c.QueryParam("person")
+	if personStr == "" {
+		if user, ok := auth.UserFrom(c); auth.Enabled(c) && (!ok || !user.Role.Includes(auth.RoleLibrarian)) {
+			return errorJSON(c, http.StatusForbidden, "person and token are required")
+		}
+	} else {
+		id, err := strconv.Atoi(personStr)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, "Invalid person id")
+		}
+
+		token, err := ls.queries.GetPersonCalendarToken(ctx, int64(id))
+		if err != nil && err != sql.ErrNoRows {
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		// Unknown people and wrong tokens look the same from the outside.
+		if !token.Valid || subtle.ConstantTimeCompare([]byte(token.String), []byte(c.QueryParam("token"))) != 1 {
+			return errorJSON(c, http.StatusForbidden, "invalid calendar token")
+		}
+
+		personID = sql.NullInt64{Int64: int64(id), Valid: true}
+		if person, err := ls.queries.GetPersonByID(ctx, int64(id)); err == nil {
+			calendarName = "Librascan loans for " + person.Name
+		}
+	}
+
+	loans, err := ls.queries.GetActiveLoans(ctx, personID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var buf bytes.Buffer
+	writeCalendar(&buf, calendarName, loans, time.Now())
+
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// GetPersonCalendarHandler returns the private calendar feed URL for a person.
+func (ls *Librascan) GetPersonCalendarHandler(c echo.Context) error {
+	personID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "Invalid person id")
+	}
+
+	token, err := ls.queries.GetPersonCalendarToken(c.Request().Context(), int64(personID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Person not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if !token.Valid {
+		return errorJSON(c, http.StatusNotFound, "Person has no calendar token")
+	}
+
+	query := url.Values{}
+	query.Set("person", strconv.Itoa(personID))
+	query.Set("token", token.String)
+
+	return c.JSON(http.StatusOK, models.CalendarFeed{
+		URL: fmt.Sprintf("%s://%s/borrowings.ics?%s", c.Scheme(), c.Request().Host, query.Encode()),
+	})
+}
+
+// writeCalendar writes one all-day VEVENT per loan, on the day it is due.
+func writeCalendar(w io.Writer, name string, loans []db.GetActiveLoansRow, now time.Time) {
+	writeICSLine(w, "BEGIN:VCALENDAR")
+	writeICSLine(w, "VERSION:2.0")
+	writeICSLine(w, "PRODID:-//librascan//borrowings//EN")
+	writeICSLine(w, "CALSCALE:GREGORIAN")
+	writeICSLine(w, "METHOD:PUBLISH")
+	writeICSLine(w, "X-WR-CALNAME:"+icsEscape(name))
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, loan := range loans {
+		dueAt, err := db.ParseSQLiteTime(db.NullStringToString(loan.DueAt))
+		if err != nil {
+			continue
+		}
+
+		title := db.NullStringToString(loan.Title)
+		if title == "" {
+			title = strconv.FormatInt(loan.Isbn, 10)
+		}
+
+		description := fmt.Sprintf("%s borrowed %s (ISBN %d)", loan.PersonName, title, loan.Isbn)
+		if borrowedAt, err := db.ParseSQLiteTime(loan.BorrowedAt); err == nil {
+			description += " on " + borrowedAt.Format("2006-01-02")
+		}
+		description += ".\nDue back " + dueAt.Format("2006-01-02") + "."
+
+		writeICSLine(w, "BEGIN:VEVENT")
+		writeICSLine(w, fmt.Sprintf("UID:borrowing-%d@librascan", loan.ID))
+		writeICSLine(w, "DTSTAMP:"+stamp)
+		writeICSLine(w, "DTSTART;VALUE=DATE:"+dueAt.Format("20060102"))
+		writeICSLine(w, "DTEND;VALUE=DATE:"+dueAt.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(w, "SUMMARY:"+icsEscape(fmt.Sprintf("Return %s (%s)", title, loan.PersonName)))
+		writeICSLine(w, "DESCRIPTION:"+icsEscape(description))
+		writeICSLine(w, "END:VEVENT")
+	}
+
+	writeICSLine(w, "END:VCALENDAR")
+}
+
+var icsEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// icsEscape escapes text property values (RFC 5545 section 3.3.11).
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// writeICSLine writes a content line, folding it at 75 octets without
+// splitting UTF-8 characters (RFC 5545 section 3.1).
+func writeICSLine(w io.Writer, line string) {
+	const maxOctets = 75
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > maxOctets {
+			b.WriteString("\r\n ")
+			// The leading space counts towards the next line.
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+
+	_, _ = io.WriteString(w, b.String())
+}
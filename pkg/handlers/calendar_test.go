@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/db"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	loans := []db.GetActiveLoansRow{
+		{
+			ID:         3,
+			Isbn:       9783836526722,
+			BorrowedAt: "2025-01-01 10:00:00",
+			DueAt:      sql.NullString{String: "2025-01-29 10:00:00", Valid: true},
+			PersonID:   1,
+			PersonName: "Doe, John",
+			Title:      sql.NullString{String: "The Fairy Tales of the Brothers Grimm; Illustrated", Valid: true},
+		},
+		{
+			// Loans without a due date are skipped.
+			ID:         4,
+			Isbn:       9780134685991,
+			BorrowedAt: "2025-01-01 10:00:00",
+			PersonName: "Jane Doe",
+		},
+	}
+
+	var buf bytes.Buffer
+	writeCalendar(&buf, "Librascan loans", loans, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	ics := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:borrowing-3@librascan\r\n",
+		"DTSTAMP:20250102T030405Z\r\n",
+		"DTSTART;VALUE=DATE:20250129\r\n",
+		"DTEND;VALUE=DATE:20250130\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("expected calendar to contain %q, got:\n%s", want, ics)
+		}
+	}
+
+	if strings.Count(ics, "BEGIN:VEVENT") != 1 {
+		t.Errorf("expected exactly one event, got:\n%s", ics)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+
+	// Unfold and check the escaped summary.
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	want := `SUMMARY:Return The Fairy Tales of the Brothers Grimm\; Illustrated (Doe\, John)`
+	if !strings.Contains(unfolded, want+"\r\n") {
+		t.Errorf("expected calendar to contain %q, got:\n%s", want, unfolded)
+	}
+	if !strings.Contains(unfolded, `on 2025-01-01.\nDue back 2025-01-29.`) {
+		t.Errorf("expected escaped newline in description, got:\n%s", unfolded)
+	}
+}
+
+func TestWriteICSLineFoldsUTF8(t *testing.T) {
+	var buf bytes.Buffer
+	writeICSLine(&buf, "SUMMARY:"+strings.Repeat("ü", 60))
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if !strings.HasPrefix(line, "SUMMARY") && !strings.HasPrefix(line, " ") {
+			t.Errorf("continuation line does not start with a space: %q", line)
+		}
+	}
+
+	if got := strings.ReplaceAll(buf.String(), "\r\n ", ""); got != "SUMMARY:"+strings.Repeat("ü", 60)+"\r\n" {
+		t.Errorf("unfolded line mismatch: %q", got)
+	}
+}
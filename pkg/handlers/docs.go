@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/labstack/echo/v4"
+)
+
+// methodOrder is the order the operations on a path are listed in.
+var methodOrder = []string{"get", "post", "put", "patch", "delete"}
+
+type docsTag struct {
+	Name        string
+	Description string
+	Operations  []docsOperation
+}
+
+type docsOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Parameters  []api.Parameter
+	// Request and Response are the types of the bodies, empty when there
+	// is none.
+	Request  docsType
+	Status   string
+	Response docsType
+}
+
+// docsType is the name of a type, with the schema it refers to so that it
+// can be linked.
+type docsType struct {
+	Name string
+	Ref  string
+}
+
+type docsSchema struct {
+	Name       string
+	Properties []docsProperty
+}
+
+type docsProperty struct {
+	Name     string
+	Type     docsType
+	Required bool
+}
+
+// OpenAPIHandler serves the OpenAPI document of the JSON API.
+func (ls *Librascan) OpenAPIHandler(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, api.OpenAPI)
+}
+
+// DocsHandler renders the OpenAPI document as a web page.
+func (ls *Librascan) DocsHandler(c echo.Context) error {
+	var doc api.Document
+	if err := json.Unmarshal(api.OpenAPI, &doc); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "spec error: "+err.Error())
+	}
+
+	data := struct {
+		Info    api.Info
+		Prefix  string
+		Tags    []docsTag
+		Schemas []docsSchema
+	}{
+		Info:   doc.Info,
+		Prefix: api.Prefix,
+	}
+
+	byTag := map[string]*docsTag{}
+	for _, tag := range doc.Tags {
+		data.Tags = append(data.Tags, docsTag{Name: tag.Name, Description: tag.Description})
+	}
+	for i := range data.Tags {
+		byTag[data.Tags[i].Name] = &data.Tags[i]
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, method := range methodOrder {
+			op := doc.Paths[path][method]
+			if op == nil || len(op.Tags) == 0 || byTag[op.Tags[0]] == nil {
+				continue
+			}
+			view := docsOperation{
+				Method:      strings.ToUpper(method),
+				Path:        api.Prefix + path,
+				Summary:     op.Summary,
+				Description: op.Description,
+				Parameters:  op.Parameters,
+			}
+			if op.RequestBody != nil {
+				view.Request = bodyType(op.RequestBody.Content)
+			}
+			view.Status, view.Response = successResponse(op.Responses)
+			tag := byTag[op.Tags[0]]
+			tag.Operations = append(tag.Operations, view)
+		}
+	}
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := doc.Components.Schemas[name]
+		schema := docsSchema{Name: name}
+		for prop, ps := range s.Properties {
+			schema.Properties = append(schema.Properties, docsProperty{
+				Name:     prop,
+				Type:     schemaType(ps),
+				Required: slices.Contains(s.Required, prop),
+			})
+		}
+		sort.Slice(schema.Properties, func(i, j int) bool {
+			return schema.Properties[i].Name < schema.Properties[j].Name
+		})
+		data.Schemas = append(data.Schemas, schema)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "docs.html", data); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// successResponse returns the successful statuses of an operation and the
+// type of the body of the first.
+func successResponse(responses map[string]api.Response) (string, docsType) {
+	statuses := make([]string, 0, len(responses))
+	for status := range responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return "", docsType{}
+	}
+	sort.Strings(statuses)
+	return strings.Join(statuses, ", "), bodyType(responses[statuses[0]].Content)
+}
+
+// bodyType describes the body of a request or response.
+func bodyType(content map[string]api.MediaType) docsType {
+	if media, ok := content["application/json"]; ok {
+		return schemaType(media.Schema)
+	}
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return docsType{Name: strings.Join(types, " or ")}
+}
+
+// schemaType is a short name for the type of a schema, such as "Book[]".
+func schemaType(s *api.Schema) docsType {
+	switch {
+	case s == nil:
+		return docsType{}
+	case s.Ref != "":
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		return docsType{Name: name, Ref: name}
+	case len(s.AllOf) > 0:
+		return schemaType(s.AllOf[0])
+	case s.Type == "array":
+		t := schemaType(s.Items)
+		t.Name += "[]"
+		return t
+	case s.AdditionalProperties != nil:
+		t := schemaType(s.AdditionalProperties)
+		t.Name = "map of " + t.Name
+		return t
+	case s.Format != "":
+		return docsType{Name: s.Type + " (" + s.Format + ")"}
+	case s.Type == "":
+		return docsType{Name: "any"}
+	}
+	return docsType{Name: s.Type}
+}
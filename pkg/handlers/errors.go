@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/labstack/echo/v4"
+)
+
+// errorJSON responds with an error in the API's error envelope.
+func errorJSON(c echo.Context, status int, message string) error {
+	return errorDetailsJSON(c, status, message, nil)
+}
+
+// errorDetailsJSON responds with an error and more details about it.
+func errorDetailsJSON(c echo.Context, status int, message string, details map[string]string) error {
+	return c.JSON(status, api.ErrorResponse{Error: api.Error{
+		Code:      api.CodeForStatus(status),
+		Message:   message,
+		Details:   details,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}})
+}
+
+// invalidBody responds to a request whose body could not be bound, with
+// the reason it could not be.
+func invalidBody(c echo.Context, err error) error {
+	reason := err.Error()
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		reason = fmt.Sprint(he.Message)
+	}
+	return errorDetailsJSON(c, http.StatusBadRequest, "invalid request body", map[string]string{"reason": reason})
+}
+
+// HTTPErrorHandler responds to the errors handlers and middleware return,
+// such as for routes that do not exist, in the error envelope.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	message := http.StatusText(status)
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		status = he.Code
+		message = fmt.Sprint(he.Message)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = errorJSON(c, status, message)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/events"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+// eventsPingInterval is how often an idle event stream is written to, so
+// that proxies do not close it.
+const eventsPingInterval = 30 * time.Second
+
+// stationErrors is how many failed scans the scan station shows.
+const stationErrors = 5
+
+// publish tells subscribers about an event. Events of requests made by a
+// scanner carry its name and the code it scanned.
+func (ls *Librascan) publish(c echo.Context, e events.Event) {
+	if sc, ok := scanContextOf(c); ok {
+		e.Device = sc.device
+		e.Code = sc.code
+		if e.Code == "" && e.Book != nil {
+			e.Code = strconv.Itoa(e.Book.ISBN)
+		}
+	}
+	ls.events.Publish(e)
+}
+
+// placeOf returns the place a book is at.
+func placeOf(book models.Book) *models.BookPlace {
+	return &models.BookPlace{
+		ShelfID:      book.ShelfID,
+		ShelfName:    book.ShelfName,
+		RowNumber:    book.RowNumber,
+		Slot:         book.Slot,
+		LocationPath: book.LocationPath,
+	}
+}
+
+// describePlace names a place, e.g. "office-big, row 3".
+func describePlace(p *models.BookPlace) string {
+	if p == nil {
+		return ""
+	}
+	if len(p.LocationPath) > 0 {
+		return strings.Join(p.LocationPath, " › ")
+	}
+	if p.ShelfName == "" && p.ShelfID == 0 {
+		return ""
+	}
+
+	name := p.ShelfName
+	if name == "" {
+		name = fmt.Sprintf("shelf %d", p.ShelfID)
+	}
+	if p.RowNumber != 0 {
+		name += fmt.Sprintf(", row %d", p.RowNumber)
+	}
+	if p.Slot != 0 {
+		name += fmt.Sprintf(", slot %d", p.Slot)
+	}
+	return name
+}
+
+// StreamEvents streams books being added and moved, failed scans and
+// scanners changing shelves as Server-Sent Events. Set device to only stream
+// the events of one scanner.
+func (ls *Librascan) StreamEvents(c echo.Context) error {
+	device := c.QueryParam("device")
+
+	ch, stop := ls.events.Subscribe()
+	defer stop()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	ping := time.NewTicker(eventsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			w.Flush()
+		case e, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if device != "" && e.Device != device {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Kind, data)
+			w.Flush()
+		}
+	}
+}
+
+// ScanStationHandler shows the last scanned book, the shelf being scanned
+// onto and the latest failed scans, and keeps them up to date as scans come
+// in. Set device to follow one scanner. Photos of books taken on the page are
+// added to the shelf being scanned onto.
+func (ls *Librascan) ScanStationHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+	device := c.QueryParam("device")
+
+	scans, err := ls.queries.GetScanEvents(ctx, db.GetScanEventsParams{
+		Device:    db.StringToNullString(device),
+		MaxEvents: 50,
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	data := struct {
+		Device     string
+		Book       *models.Book
+		Status     string
+		Place      string
+		Shelf      string
+		ShelfPlace models.BookPlace
+		Errors     []models.ScanEvent
+		MaxErrors  int
+	}{
+		Device:    device,
+		MaxErrors: stationErrors,
+	}
+
+	// Scans are newest first, so the first of each kind is the current one.
+	for _, e := range scans {
+		scan := toModelScanEvent(e)
+		if data.Book == nil && scan.Book != nil && scan.UndoneAt == nil &&
+			(scan.Action == scanActionAdd || scan.Action == scanActionCopy || scan.Action == scanActionMove) {
+			data.Book = scan.Book
+			data.Status = stationStatus(scan)
+			data.Place = describePlace(placeOf(*scan.Book))
+		}
+		if data.Shelf == "" && scan.ShelfID != 0 {
+			place := &models.BookPlace{ShelfID: scan.ShelfID, RowNumber: scan.RowNumber, Slot: scan.Slot}
+			if name, err := ls.queries.GetShelfName(ctx, int64(scan.ShelfID)); err == nil {
+				place.ShelfName = name.String
+			}
+			data.Shelf = describePlace(place)
+			data.ShelfPlace = *place
+		}
+		if scan.Outcome == scanOutcomeFailed && len(data.Errors) < stationErrors {
+			data.Errors = append(data.Errors, scan)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, "station.html", data)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// stationStatus says what a scan did to a book, the way the scan station
+// page does for live events.
+func stationStatus(scan models.ScanEvent) string {
+	switch {
+	case scan.Outcome == models.AddOutcomeAlreadyHere:
+		return "Already here"
+	case scan.Action == scanActionCopy:
+		return "New copy"
+	case scan.Action == scanActionMove:
+		return "Moved"
+	default:
+		return "Added"
+	}
+}
@@ -6,20 +6,29 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"log/slog"
 	"net/http"
+	"net/mail"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/auth"
 	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/events"
+	"github.com/gouthamve/librascan/pkg/locations"
 	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/notify"
+	"github.com/gouthamve/librascan/pkg/scancode"
 )
 
 // API URLs that can be overridden for testing
@@ -28,6 +37,10 @@ var (
 	OpenLibraryAPIURL = "https://openlibrary.org/api/books"
 )
 
+// DefaultLoanPeriod is how long a book can be borrowed for when the request
+// does not say otherwise.
+var DefaultLoanPeriod = 28 * 24 * time.Hour
+
 // Embed the templates directory
 //go:embed templates/*.html
 var templateFS embed.FS
@@ -40,7 +53,8 @@ var (
 func init() {
 	// Custom template function to join string slices
 	funcMap := template.FuncMap{
-		"join": strings.Join,
+		"join":    strings.Join,
+		"percent": percent,
 	}
 	
 	// Parse templates from embedded filesystem
@@ -52,24 +66,36 @@ func init() {
 }
 
 type Librascan struct {
-	queries *db.Queries
+	db       *sql.DB
+	queries  *db.Queries
+	notifier notify.Notifier
+	events   *events.Bus
 }
 
-func NewLibrascan(database *sql.DB) *Librascan {
-	return &Librascan{queries: db.New(database)}
+func NewLibrascan(database *sql.DB, notifier notify.Notifier) *Librascan {
+	if notifier == nil {
+		notifier = notify.Nop{}
+	}
+
+	return &Librascan{
+		db:       database,
+		queries:  db.New(database),
+		notifier: notifier,
+		events:   events.NewBus(),
+	}
 }
 
 // LookupBookHandler handles requests for a book lookup by ISBN using Open Library API.
 func (ls *Librascan) LookupBookHandler(c echo.Context) error {
 	isbnStr := c.Param("isbn")
 	if isbnStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ISBN is required"})
+		return errorJSON(c, http.StatusBadRequest, "ISBN is required")
 	}
 
 	isbnStr = strings.ReplaceAll(isbnStr, "-", "") // Remove any hyphens from ISBN
 	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
 	}
 
 	gb := models.GoogleBook{}
@@ -103,62 +129,162 @@ func (ls *Librascan) LookupBookHandler(c echo.Context) error {
 func (ls *Librascan) AddBookFromISBN(c echo.Context) error {
 	isbnStr := c.Param("isbn")
 	if isbnStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ISBN is required"})
+		return errorJSON(c, http.StatusBadRequest, "ISBN is required")
 	}
 
 	isbnStr = strings.ReplaceAll(isbnStr, "-", "")
 	if len(isbnStr) != 13 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
 	}
 	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
+	}
+
+	// Scanners pass the add-on printed after the ISBN, if there is one.
+	addOn := c.QueryParam("add_on")
+	if addOn != "" {
+		if _, err := strconv.Atoi(addOn); err != nil || (len(addOn) != 2 && len(addOn) != 5) {
+			return errorJSON(c, http.StatusBadRequest, "invalid add_on")
+		}
+	}
+
+	asCopy := false
+	if copyStr := c.QueryParam("copy"); copyStr != "" {
+		if asCopy, err = strconv.ParseBool(copyStr); err != nil {
+			return errorJSON(c, http.StatusBadRequest, "invalid copy")
+		}
+	}
+
+	place, err := placeFromQuery(c)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+	tree, _, err := ls.locationTree(c.Request().Context())
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if err := placeBook(c.Request().Context(), ls.queries, tree, &place); err != nil {
+		if errors.Is(err, errLocationNotFound) || errors.Is(err, errLocationNotLeaf) {
+			return errorJSON(c, http.StatusBadRequest, err.Error())
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
 	}
 
-	// Read and validate query parameters.
-	shelfID := 0
-	rowNumber := 0
+	added, err := ls.addBook(c, tree, isbn, addOn, asCopy, place)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, err.Error())
+	}
+	if added.Outcome == models.AddOutcomeAdded {
+		return c.JSON(http.StatusCreated, api.NewAddedBook(added))
+	}
+	return c.JSON(http.StatusOK, api.NewAddedBook(added))
+}
+
+// placeFromQuery reads where to put a book from the shelf_id, row_number,
+// slot and location_id query parameters, or from a scanned shelf label passed
+// as location.
+func placeFromQuery(c echo.Context) (models.Book, error) {
+	var err error
+	place := models.Book{}
 
-	rowNumberStr := c.QueryParam("row_number")
-	shelfIDStr := c.QueryParam("shelf_id")
-	if rowNumberStr != "" {
-		rowNumber, err = strconv.Atoi(rowNumberStr)
+	if rowNumberStr := c.QueryParam("row_number"); rowNumberStr != "" {
+		place.RowNumber, err = strconv.Atoi(rowNumberStr)
+		if err != nil {
+			return models.Book{}, errors.New("invalid row_number")
+		}
+	}
+	if shelfIDStr := c.QueryParam("shelf_id"); shelfIDStr != "" {
+		place.ShelfID, err = strconv.Atoi(shelfIDStr)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid row_number"})
+			return models.Book{}, errors.New("invalid shelf_id")
 		}
 	}
-	if shelfIDStr != "" {
-		shelfID, err = strconv.Atoi(shelfIDStr)
+	if slotStr := c.QueryParam("slot"); slotStr != "" {
+		place.Slot, err = strconv.Atoi(slotStr)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid shelf_id"})
+			return models.Book{}, errors.New("invalid slot")
 		}
 	}
+	if locationIDStr := c.QueryParam("location_id"); locationIDStr != "" {
+		place.LocationID, err = strconv.Atoi(locationIDStr)
+		if err != nil {
+			return models.Book{}, errors.New("invalid location_id")
+		}
+	}
+
+	// A scanned shelf label can be passed as is instead.
+	if location := c.QueryParam("location"); location != "" {
+		code := scancode.Parse(location)
+		if code.Kind != scancode.Shelf {
+			return models.Book{}, errors.New("invalid location")
+		}
+		place.ShelfID, place.RowNumber, place.Slot = code.ShelfID, code.Row, code.Slot
+	}
+
+	return place, nil
+}
+
+// addBook adds a book to the library at a place, looking it up by its ISBN.
+// A book that is already catalogued is not looked up again but put at the
+// place instead, or counted as another copy of it when asCopy is set. The
+// add-on scanned with the ISBN, if any, is kept as the book's price
+// supplement.
+func (ls *Librascan) addBook(c echo.Context, tree *locations.Tree, isbn int, addOn string, asCopy bool, place models.Book) (models.AddedBook, error) {
+	ctx := c.Request().Context()
+	isbnStr := strconv.Itoa(isbn)
+
+	existing, err := ls.getBook(ctx, tree, int64(isbn))
+	if err == nil {
+		if addOn != "" && addOn != existing.PriceSupplement {
+			err := ls.queries.SetBookPriceSupplement(ctx, db.SetBookPriceSupplementParams{
+				PriceSupplement: db.StringToNullString(addOn),
+				Isbn:            int64(isbn),
+			})
+			if err != nil {
+				return models.AddedBook{}, fmt.Errorf("update error: %w", err)
+			}
+		}
+		if asCopy {
+			return ls.addCopy(c, tree, existing)
+		}
+		return ls.rescanBook(c, tree, existing, place)
+	}
+	if err != sql.ErrNoRows {
+		return models.AddedBook{}, fmt.Errorf("query error: %w", err)
+	}
 
 	gb := models.GoogleBook{}
 	ol := models.OpenLibraryBook{}
 
-	googleBookResp, err := getBookFromGoogleBooks(c.Request().Context(), isbnStr)
+	googleBookResp, err := getBookFromGoogleBooks(ctx, isbnStr)
 	if err == nil && googleBookResp.TotalItems > 0 {
 		gb = googleBookResp.Items[0]
 	}
 
-	openLibraryBookResp, err := getBookFromOpenLibrary(c.Request().Context(), isbnStr)
+	openLibraryBookResp, err := getBookFromOpenLibrary(ctx, isbnStr)
 	if err == nil && len(*openLibraryBookResp) > 0 {
 		ol = (*openLibraryBookResp)[fmt.Sprintf("ISBN:%s", isbnStr)]
 	}
 
 	book := createBookFromAPIData(gb, ol)
 	book.ISBN = isbn
-	book.RowNumber = rowNumber
-	book.ShelfID = shelfID
-
-	if err := ls.storeBook(c.Request().Context(), book); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	book.PriceSupplement = addOn
+	book.Price = db.AddOnPrice(addOn)
+	book.RowNumber = place.RowNumber
+	book.ShelfID = place.ShelfID
+	book.Slot = place.Slot
+	book.LocationID = place.LocationID
+	book.LocationPath = place.LocationPath
+	book.Copies = 1
+
+	if err := storeBook(ctx, ls.queries, book); err != nil {
+		return models.AddedBook{}, err
 	}
 
 	// Fetch the shelf name
 	if book.ShelfID != 0 {
-		shelfName, err := ls.queries.GetShelfName(c.Request().Context(), int64(book.ShelfID))
+		shelfName, err := ls.queries.GetShelfName(ctx, int64(book.ShelfID))
 		if err == nil && shelfName.Valid {
 			book.ShelfName = shelfName.String
 		}
@@ -166,56 +292,128 @@ func (ls *Librascan) AddBookFromISBN(c echo.Context) error {
 		book.ShelfName = "unknown"
 	}
 
-	return c.JSON(http.StatusCreated, book)
+	ls.recordScan(c, scanEvent{action: scanActionAdd, isbn: isbn, book: &book})
+	ls.publish(c, events.Event{Kind: events.KindBookAdded, Book: &book, Outcome: models.AddOutcomeAdded})
+
+	return models.AddedBook{Book: book, Outcome: models.AddOutcomeAdded}, nil
+}
+
+// addCopy counts another copy of a catalogued book. The copy goes where the
+// book is, wherever it was scanned.
+func (ls *Librascan) addCopy(c echo.Context, tree *locations.Tree, existing models.Book) (models.AddedBook, error) {
+	ctx := c.Request().Context()
+
+	if _, err := ls.queries.AddBookCopy(ctx, int64(existing.ISBN)); err != nil {
+		return models.AddedBook{}, fmt.Errorf("update error: %w", err)
+	}
+	book, err := ls.getBook(ctx, tree, int64(existing.ISBN))
+	if err != nil {
+		return models.AddedBook{}, fmt.Errorf("query error: %w", err)
+	}
+
+	ls.recordScan(c, scanEvent{action: scanActionCopy, isbn: book.ISBN, book: &book})
+	ls.publish(c, events.Event{Kind: events.KindBookAdded, Book: &book, Outcome: models.AddOutcomeNewCopy})
+
+	return models.AddedBook{Book: book, Outcome: models.AddOutcomeNewCopy}, nil
+}
+
+// rescanBook handles a book scanned in again. It goes on the right end of the
+// row it was scanned at, which moves it there if it was anywhere else.
+func (ls *Librascan) rescanBook(c echo.Context, tree *locations.Tree, existing, place models.Book) (models.AddedBook, error) {
+	ctx := c.Request().Context()
+
+	if _, err := moveBook(ctx, ls.queries, tree, existing.ISBN, *bookPlace(place)); err != nil {
+		return models.AddedBook{}, fmt.Errorf("update error: %w", err)
+	}
+	moved, err := ls.getBook(ctx, tree, int64(existing.ISBN))
+	if err != nil {
+		return models.AddedBook{}, fmt.Errorf("query error: %w", err)
+	}
+
+	if existing.LocationID == moved.LocationID && existing.ShelfID == moved.ShelfID &&
+		existing.RowNumber == moved.RowNumber && existing.Slot == moved.Slot {
+		ls.recordScan(c, scanEvent{action: scanActionAdd, outcome: models.AddOutcomeAlreadyHere, isbn: moved.ISBN, book: &moved})
+		ls.publish(c, events.Event{Kind: events.KindBookAdded, Book: &moved, Outcome: models.AddOutcomeAlreadyHere})
+		return models.AddedBook{Book: moved, Outcome: models.AddOutcomeAlreadyHere}, nil
+	}
+
+	ls.recordScan(c, scanEvent{action: scanActionMove, isbn: moved.ISBN, book: &moved, previous: bookPlace(existing)})
+	ls.publish(c, events.Event{Kind: events.KindBookMoved, Book: &moved, Outcome: models.AddOutcomeMoved, MovedFrom: placeOf(existing)})
+
+	return models.AddedBook{
+		Book:      moved,
+		Outcome:   models.AddOutcomeMoved,
+		MovedFrom: placeOf(existing),
+	}, nil
 }
 
 // GetBookByISBN handles fetching a book from the database by ISBN.
 func (ls *Librascan) GetBookByISBN(c echo.Context) error {
 	isbnStr := c.Param("isbn")
 	if isbnStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ISBN is required"})
+		return errorJSON(c, http.StatusBadRequest, "ISBN is required")
 	}
 
 	isbnStr = strings.ReplaceAll(isbnStr, "-", "")
 	if len(isbnStr) != 13 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
 	}
 
 	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
 	}
 
-	book, err := ls.getBook(c.Request().Context(), int64(isbn))
+	ctx := c.Request().Context()
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+	book, err := ls.getBook(ctx, tree, int64(isbn))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Book not found"})
+			return errorJSON(c, http.StatusNotFound, "Book not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, book)
+	return c.JSON(http.StatusOK, api.NewBook(book))
 }
 
 func (ls *Librascan) GenerateHTMLHandler(c echo.Context) error {
 	ctx := c.Request().Context()
-	books, err := getAllBooks(ctx, ls.queries)
+	tree, _, err := ls.locationTree(ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	locators, err := ls.locateBooks(ctx, tree, books)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
 	}
 
 	// Create template data
+	user, _ := auth.UserFrom(c)
 	data := struct {
-		Books []models.Book
+		Books    []models.Book
+		Locators map[int]models.BookLocator
+		// User is who signed in, empty when authentication is off.
+		User auth.User
 	}{
-		Books: books,
+		Books:    books,
+		Locators: locators,
+		User:     user,
 	}
 
 	// Execute template
 	var buf bytes.Buffer
 	err = templates.ExecuteTemplate(&buf, "books.html", data)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "template error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
 	}
 
 	return c.HTML(http.StatusOK, buf.String())
@@ -223,31 +421,35 @@ func (ls *Librascan) GenerateHTMLHandler(c echo.Context) error {
 
 func (ls *Librascan) GetAllBooks(c echo.Context) error {
 	ctx := c.Request().Context()
-	books, err := getAllBooks(ctx, ls.queries)
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, books)
+	return c.JSON(http.StatusOK, api.NewBooks(books))
 }
 
 // LookupShelfNameHandler gets shelf name by id.
 func (ls *Librascan) LookupShelfNameHandler(c echo.Context) error {
 	shelfIDStr := c.Param("id")
 	if shelfIDStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Shelf id is required"})
+		return errorJSON(c, http.StatusBadRequest, "Shelf id is required")
 	}
 	shelfID, err := strconv.Atoi(shelfIDStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid shelf id"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid shelf id")
 	}
 
 	shelf, err := ls.queries.GetShelf(c.Request().Context(), int64(shelfID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "shelf not found"})
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
 	}
 
 	return c.JSON(http.StatusOK, models.Shelf{
@@ -261,28 +463,40 @@ func (ls *Librascan) LookupShelfNameHandler(c echo.Context) error {
 func (ls *Librascan) DeleteBookByISBN(c echo.Context) error {
 	isbnStr := c.Param("isbn")
 	if isbnStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ISBN is required"})
+		return errorJSON(c, http.StatusBadRequest, "ISBN is required")
 	}
 
 	isbnStr = strings.ReplaceAll(isbnStr, "-", "")
 	if len(isbnStr) != 13 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
 	}
 
 	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid ISBN"})
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
+	}
+
+	// Keep the book so that the delete can be undone.
+	tree, _, err := ls.locationTree(c.Request().Context())
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, err.Error())
+	}
+	book, err := ls.getBook(c.Request().Context(), tree, int64(isbn))
+	if err != nil && err != sql.ErrNoRows {
+		return errorJSON(c, http.StatusInternalServerError, err.Error())
 	}
 
 	rows, err := ls.queries.DeleteBook(c.Request().Context(), int64(isbn))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, err.Error())
 	}
 
 	if rows == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Book not found"})
+		return errorJSON(c, http.StatusNotFound, "Book not found")
 	}
 
+	ls.recordScan(c, scanEvent{action: scanActionDelete, isbn: isbn, book: &book})
+
 	return c.NoContent(http.StatusNoContent)
 }
 
@@ -291,42 +505,154 @@ func (ls *Librascan) BorrowBookByISBN(c echo.Context) error {
 	// Pass BorrowRequest from body.
 	var req models.BorrowRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return invalidBody(c, err)
 	}
+	name, err := borrowerName(c, req.PersonName)
+	if err != nil {
+		return err
+	}
+	req.PersonName = name
 
 	ctx := c.Request().Context()
 
 	// Check if book exists.
-	_, err := ls.getBook(ctx, int64(req.ISBN))
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+	book, err := ls.getBook(ctx, tree, int64(req.ISBN))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Book not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	person, err := ls.getOrCreatePerson(ctx, req.PersonName, req.Email)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	loanPeriod := DefaultLoanPeriod
+	if req.Days > 0 {
+		loanPeriod = time.Duration(req.Days) * 24 * time.Hour
+	}
+	borrowedAt := time.Now().UTC()
+	dueAt := borrowedAt.Add(loanPeriod)
+
+	// Borrow book.
+	borrowingID, err := ls.queries.InsertBorrowing(ctx, db.InsertBorrowingParams{
+		Isbn:     int64(req.ISBN),
+		PersonID: int64(person.ID),
+		DueAt:    db.TimeToNullString(dueAt),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	// A hold is fulfilled once the person actually borrows the book.
+	err = ls.queries.FulfillHold(ctx, db.FulfillHoldParams{
+		Isbn:     int64(req.ISBN),
+		PersonID: int64(person.ID),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	ls.notify(notify.Event{
+		Kind:        notify.EventBorrowed,
+		ISBN:        book.ISBN,
+		Title:       book.Title,
+		PersonName:  person.Name,
+		PersonEmail: person.Email,
+		BorrowedAt:  borrowedAt,
+		DueAt:       dueAt,
+	}, nil)
+
+	ls.recordScan(c, scanEvent{action: scanActionBorrow, isbn: req.ISBN, personID: person.ID, borrowingID: int(borrowingID), book: &book})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ReturnBookByISBN handles returning a borrowed book and lets the next person
+// holding it know that it is available.
+func (ls *Librascan) ReturnBookByISBN(c echo.Context) error {
+	var req models.ReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+
+	ctx := c.Request().Context()
+
+	borrowingID, err := ls.queries.ReturnBookByISBN(ctx, int64(req.ISBN))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Book not found"})
+			return errorJSON(c, http.StatusNotFound, "Book is not borrowed")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
 	}
 
-	// Check if person exists.
-	var personID int64
-	personID, err = ls.queries.GetPerson(ctx, req.PersonName)
+	ls.recordScan(c, scanEvent{action: scanActionReturn, isbn: req.ISBN, borrowingID: int(borrowingID)})
+
+	hold, err := ls.queries.GetNextHold(ctx, int64(req.ISBN))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// Add person if not exists.
-			personID, err = ls.queries.InsertPerson(ctx, req.PersonName)
-			if err != nil {
-				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Query error: " + err.Error()})
-			}
-		} else {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Query error: " + err.Error()})
+			return c.NoContent(http.StatusNoContent)
 		}
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
 	}
 
-	// Borrow book.
-	err = ls.queries.InsertBorrowing(ctx, db.InsertBorrowingParams{
+	title := ""
+	if book, err := ls.getBook(ctx, nil, int64(req.ISBN)); err == nil {
+		title = book.Title
+	}
+
+	ls.notify(notify.Event{
+		Kind:        notify.EventHoldAvailable,
+		ISBN:        req.ISBN,
+		Title:       title,
+		PersonName:  hold.PersonName,
+		PersonEmail: db.NullStringToString(hold.PersonEmail),
+	}, func(ctx context.Context) error {
+		return ls.queries.MarkHoldNotified(ctx, hold.ID)
+	})
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HoldBookByISBN handles putting a hold on a book so the person is notified
+// when it is returned.
+func (ls *Librascan) HoldBookByISBN(c echo.Context) error {
+	var req models.BorrowRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	name, err := borrowerName(c, req.PersonName)
+	if err != nil {
+		return err
+	}
+	req.PersonName = name
+
+	ctx := c.Request().Context()
+
+	if _, err := ls.getBook(ctx, nil, int64(req.ISBN)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Book not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	person, err := ls.getOrCreatePerson(ctx, req.PersonName, req.Email)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
+	}
+
+	err = ls.queries.InsertHold(ctx, db.InsertHoldParams{
 		Isbn:     int64(req.ISBN),
-		PersonID: personID,
+		PersonID: int64(person.ID),
 	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "Query error: "+err.Error())
 	}
 
 	return c.NoContent(http.StatusNoContent)
@@ -335,34 +661,165 @@ func (ls *Librascan) BorrowBookByISBN(c echo.Context) error {
 func (ls *Librascan) GetPeople(c echo.Context) error {
 	dbPeople, err := ls.queries.GetAllPeople(c.Request().Context())
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query error: " + err.Error()})
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
 	}
 
 	people := []models.Person{}
 	for _, dbPerson := range dbPeople {
 		people = append(people, models.Person{
-			ID:   int(dbPerson.ID),
-			Name: dbPerson.Name,
+			ID:    int(dbPerson.ID),
+			Name:  dbPerson.Name,
+			Email: db.NullStringToString(dbPerson.Email),
 		})
 	}
 
 	return c.JSON(http.StatusOK, people)
 }
 
+// GetPersonByID handles fetching a person by id, e.g. from a scanned person card.
+func (ls *Librascan) GetPersonByID(c echo.Context) error {
+	personID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "Invalid person id")
+	}
+
+	dbPerson, err := ls.queries.GetPersonByID(c.Request().Context(), int64(personID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Person not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, models.Person{
+		ID:    int(dbPerson.ID),
+		Name:  dbPerson.Name,
+		Email: db.NullStringToString(dbPerson.Email),
+	})
+}
+
+// UpdatePerson changes where a person's loan and hold notifications are
+// sent.
+func (ls *Librascan) UpdatePerson(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	personID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "Invalid person id")
+	}
+
+	var req models.PersonRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	email := strings.TrimSpace(req.Email)
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return errorJSON(c, http.StatusBadRequest, "invalid email")
+		}
+	}
+
+	if _, err := ls.queries.GetPersonByID(ctx, int64(personID)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Person not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	err = ls.queries.UpdatePersonEmail(ctx, db.UpdatePersonEmailParams{
+		Email: db.StringToNullString(email),
+		ID:    int64(personID),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+
+	return ls.GetPersonByID(c)
+}
+
+// getOrCreatePerson looks a person up by name, adding them if they do not
+// exist yet. The email is only kept for a person who has none, so that a
+// loan cannot send someone else's notifications elsewhere; UpdatePerson
+// changes it.
+func (ls *Librascan) getOrCreatePerson(ctx context.Context, name, email string) (models.Person, error) {
+	personID, err := ls.queries.GetPerson(ctx, name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return models.Person{}, err
+		}
+
+		personID, err = ls.queries.InsertPerson(ctx, name)
+		if err != nil {
+			return models.Person{}, err
+		}
+	}
+
+	dbPerson, err := ls.queries.GetPersonByID(ctx, personID)
+	if err != nil {
+		return models.Person{}, err
+	}
+
+	if email != "" && !dbPerson.Email.Valid {
+		err = ls.queries.UpdatePersonEmail(ctx, db.UpdatePersonEmailParams{
+			Email: db.StringToNullString(email),
+			ID:    personID,
+		})
+		if err != nil {
+			return models.Person{}, err
+		}
+		dbPerson.Email = db.StringToNullString(email)
+	}
+
+	return models.Person{
+		ID:    int(dbPerson.ID),
+		Name:  dbPerson.Name,
+		Email: db.NullStringToString(dbPerson.Email),
+	}, nil
+}
+
+// notify sends ev in the background so slow mail servers do not hold up the
+// request. onSuccess, if set, runs once the event has been delivered.
+func (ls *Librascan) notify(ev notify.Event, onSuccess func(ctx context.Context) error) {
+	go func() {
+		ctx := context.Background()
+		if err := ls.notifier.Notify(ctx, ev); err != nil {
+			slog.Error("failed to send notification", "error", err, "event", ev.Kind, "isbn", ev.ISBN)
+			return
+		}
+
+		if onSuccess == nil {
+			return
+		}
+		if err := onSuccess(ctx); err != nil {
+			slog.Error("failed to record notification", "error", err, "event", ev.Kind, "isbn", ev.ISBN)
+		}
+	}()
+}
+
 // storeBook stores a book in the database using sqlc
-func (ls *Librascan) storeBook(ctx context.Context, book models.Book) error {
+func storeBook(ctx context.Context, queries *db.Queries, book models.Book) error {
+	// Books scanned onto a row go on its right end.
+	position, err := nextRowPosition(ctx, queries, book.ShelfID, book.RowNumber)
+	if err != nil {
+		return err
+	}
+
 	// Insert or update book
-	err := ls.queries.InsertBook(ctx, db.InsertBookParams{
-		Isbn:          int64(book.ISBN),
-		Title:         db.StringToNullString(book.Title),
-		Description:   db.StringToNullString(book.Description),
-		Publisher:     db.StringToNullString(book.Publisher),
-		PublishedDate: db.StringToNullString(book.PublishedDate),
-		Pages:         db.IntToNullInt64(book.Pages),
-		Language:      db.StringToNullString(book.Language),
-		CoverUrl:      db.StringToNullString(book.CoverURL),
-		RowNumber:     db.IntToNullInt64(book.RowNumber),
-		ShelfID:       db.IntToNullInt64(book.ShelfID),
+	err = queries.InsertBook(ctx, db.InsertBookParams{
+		Isbn:            int64(book.ISBN),
+		Title:           db.StringToNullString(book.Title),
+		Description:     db.StringToNullString(book.Description),
+		Publisher:       db.StringToNullString(book.Publisher),
+		PublishedDate:   db.StringToNullString(book.PublishedDate),
+		Pages:           db.IntToNullInt64(book.Pages),
+		Language:        db.StringToNullString(book.Language),
+		CoverUrl:        db.StringToNullString(book.CoverURL),
+		RowNumber:       db.IntToNullInt64(book.RowNumber),
+		ShelfID:         db.IntToNullInt64(book.ShelfID),
+		Slot:            db.IntToNullInt64(book.Slot),
+		LocationID:      db.IntToNullInt64(book.LocationID),
+		Position:        position,
+		Classification:  db.StringToNullString(book.Classification),
+		PriceSupplement: db.StringToNullString(book.PriceSupplement),
 	})
 	if err != nil {
 		return err
@@ -370,7 +827,7 @@ func (ls *Librascan) storeBook(ctx context.Context, book models.Book) error {
 
 	// Insert authors
 	for _, author := range book.Authors {
-		err = ls.queries.InsertAuthor(ctx, db.InsertAuthorParams{
+		err = queries.InsertAuthor(ctx, db.InsertAuthorParams{
 			Isbn: sql.NullInt64{Int64: int64(book.ISBN), Valid: true},
 			Name: sql.NullString{String: author, Valid: true},
 		})
@@ -381,7 +838,7 @@ func (ls *Librascan) storeBook(ctx context.Context, book models.Book) error {
 
 	// Insert categories
 	for _, category := range book.Categories {
-		err = ls.queries.InsertCategory(ctx, db.InsertCategoryParams{
+		err = queries.InsertCategory(ctx, db.InsertCategoryParams{
 			Isbn: sql.NullInt64{Int64: int64(book.ISBN), Valid: true},
 			Name: sql.NullString{String: category, Valid: true},
 		})
@@ -393,8 +850,9 @@ func (ls *Librascan) storeBook(ctx context.Context, book models.Book) error {
 	return nil
 }
 
-// getBook retrieves a book from the database using sqlc
-func (ls *Librascan) getBook(ctx context.Context, isbn int64) (models.Book, error) {
+// getBook retrieves a book from the database using sqlc. Its location path
+// is looked up in tree, and left empty when tree is nil.
+func (ls *Librascan) getBook(ctx context.Context, tree *locations.Tree, isbn int64) (models.Book, error) {
 	dbBook, err := ls.queries.GetBook(ctx, isbn)
 	if err != nil {
 		return models.Book{}, err
@@ -418,12 +876,16 @@ func (ls *Librascan) getBook(ctx context.Context, isbn int64) (models.Book, erro
 		}
 	}
 
-	return db.ConvertDBBookToModel(
+	book := db.ConvertDBBookToModel(
 		dbBook,
 		db.ConvertNullStringSliceToStringSlice(authors),
 		db.ConvertNullStringSliceToStringSlice(categories),
 		shelfName,
-	), nil
+	)
+	if tree != nil {
+		book.LocationPath = tree.Path(int64(book.LocationID))
+	}
+	return book, nil
 }
 
 func createBookFromAPIData(gb models.GoogleBook, ol models.OpenLibraryBook) models.Book {
@@ -446,6 +908,9 @@ func createBookFromAPIData(gb models.GoogleBook, ol models.OpenLibraryBook) mode
 		if largeCoverURL != "" {
 			book.CoverURL = largeCoverURL
 		}
+		if len(ol.Classifications.DeweyDecimalClass) > 0 {
+			book.Classification = ol.Classifications.DeweyDecimalClass[0]
+		}
 
 		if book.Title != "" {
 			return book
@@ -530,7 +995,8 @@ func getBookFromOpenLibrary(ctx context.Context, isbn string) (*models.OpenLibra
 	return &response, nil
 }
 
-func getAllBooks(ctx context.Context, queries *db.Queries) ([]models.Book, error) {
+// getAllBooks retrieves every book, with its location path looked up in tree.
+func getAllBooks(ctx context.Context, queries *db.Queries, tree *locations.Tree) ([]models.Book, error) {
 
 	dbBooks, err := queries.GetAllBooks(ctx)
 	if err != nil {
@@ -563,9 +1029,17 @@ func getAllBooks(ctx context.Context, queries *db.Queries) ([]models.Book, error
 			db.ConvertNullStringSliceToStringSlice(categories),
 			shelfName,
 		)
+		book.LocationPath = tree.Path(int64(book.LocationID))
 		books = append(books, book)
 	}
 
 	return books, nil
 }
 
+// percent returns part as a percentage of total, for use in templates.
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
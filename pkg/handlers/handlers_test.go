@@ -35,6 +35,57 @@ func TestDatabase(t *testing.T) {
 	if err := migrations.Up0002(t.Context(), tx); err != nil {
 		t.Fatalf("failed to create initial tables2: %v", err)
 	}
+	if err := migrations.Up0003(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0003: %v", err)
+	}
+	if err := migrations.Up0004(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0004: %v", err)
+	}
+	if err := migrations.Up0005(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0005: %v", err)
+	}
+	if err := migrations.Up0006(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0006: %v", err)
+	}
+	if err := migrations.Up0007(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0007: %v", err)
+	}
+	if err := migrations.Up0008(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0008: %v", err)
+	}
+	if err := migrations.Up0009(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0009: %v", err)
+	}
+	if err := migrations.Up0010(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0010: %v", err)
+	}
+	if err := migrations.Up0011(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0011: %v", err)
+	}
+	if err := migrations.Up0012(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0012: %v", err)
+	}
+	if err := migrations.Up0013(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0013: %v", err)
+	}
+	if err := migrations.Up0014(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0014: %v", err)
+	}
+	if err := migrations.Up0015(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0015: %v", err)
+	}
+	if err := migrations.Up0016(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0016: %v", err)
+	}
+	if err := migrations.Up0017(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0017: %v", err)
+	}
+	if err := migrations.Up0018(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0018: %v", err)
+	}
+	if err := migrations.Up0019(t.Context(), tx); err != nil {
+		t.Fatalf("failed to run migration 0019: %v", err)
+	}
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("failed to commit transaction: %v", err)
 	}
@@ -51,21 +102,25 @@ func TestDatabase(t *testing.T) {
 		Language:      "English",
 		CoverURL:      "https://covers.openlibrary.org/b/id/7222246-L.jpg",
 		Pages:         328,
+		Copies:        1,
 		ShelfID:       1,
 		ShelfName:     "office-big",
 		RowNumber:     1,
 	}
 
 	// Create Librascan instance with sqlc
-	ls := NewLibrascan(db)
+	ls := NewLibrascan(db, nil)
 
 	// Insert the book into the database
-	if err := ls.storeBook(t.Context(), book); err != nil {
+	if err := storeBook(t.Context(), ls.queries, book); err != nil {
 		t.Fatalf("failed to store book: %v", err)
 	}
 
+	// The first book scanned onto a row is at its left end.
+	book.Position = 1
+
 	// Retrieve the book from the database
-	book2, err := ls.getBook(t.Context(), int64(book.ISBN))
+	book2, err := ls.getBook(t.Context(), nil, int64(book.ISBN))
 	if err != nil {
 		t.Fatalf("failed to get book: %v", err)
 	}
@@ -85,7 +140,7 @@ func TestDatabase(t *testing.T) {
 	}
 
 	// Retrieve the book from the database
-	book3, err := ls.getBook(t.Context(), int64(book.ISBN))
+	book3, err := ls.getBook(t.Context(), nil, int64(book.ISBN))
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
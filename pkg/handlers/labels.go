@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/labels"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+// ShelfLabelsHandler returns printable label sheets for every row of one
+// shelf, or of all shelves when there is no id in the path.
+//
+// The sheet is a PDF unless format=svg is given; an SVG has only one sheet,
+// chosen with page. qr=true adds a QR code to each label. The label paper is
+// picked with template and adjusted with the fields of labels.Config.
+func (ls *Librascan) ShelfLabelsHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var cfg labels.Config
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &cfg); err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid label settings")
+	}
+	opts, err := cfg.Options()
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+	withQR := c.QueryParam("qr") == "true"
+
+	shelves, err := ls.listShelves(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	if idStr := c.Param("id"); idStr != "" {
+		shelfID, err := strconv.Atoi(idStr)
+		if err != nil {
+			return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+		}
+		if shelfID == 0 {
+			return errorJSON(c, http.StatusBadRequest, "the unknown shelf has no labels")
+		}
+
+		var found []models.Shelf
+		for _, shelf := range shelves {
+			if shelf.ID == shelfID {
+				found = append(found, shelf)
+			}
+		}
+		if len(found) == 0 {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		shelves = found
+	}
+	sheet := labels.ShelfLabels(shelves, withQR)
+
+	var buf bytes.Buffer
+	switch c.QueryParam("format") {
+	case "", "pdf":
+		if err := labels.WritePDF(&buf, sheet, opts); err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "label error: "+err.Error())
+		}
+		return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+	case "svg":
+		page := 1
+		if pageStr := c.QueryParam("page"); pageStr != "" {
+			page, err = strconv.Atoi(pageStr)
+			if err != nil {
+				return errorJSON(c, http.StatusBadRequest, "invalid page")
+			}
+		}
+		if page < 1 || page > opts.Template.Pages(len(sheet)) {
+			return errorJSON(c, http.StatusBadRequest, "page out of range")
+		}
+		if err := labels.WriteSVG(&buf, sheet, opts, page); err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "label error: "+err.Error())
+		}
+		return c.Blob(http.StatusOK, "image/svg+xml", buf.Bytes())
+	default:
+		return errorJSON(c, http.StatusBadRequest, "format must be pdf or svg")
+	}
+}
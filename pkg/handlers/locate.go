@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/locations"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+// LocateBook says where a book is, down to roughly how far along its row.
+func (ls *Librascan) LocateBook(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	isbn, err := strconv.Atoi(strings.ReplaceAll(c.Param("isbn"), "-", ""))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	locators, err := ls.locateBooks(ctx, tree, books)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	locator, ok := locators[isbn]
+	if !ok {
+		return errorJSON(c, http.StatusNotFound, "Book not found")
+	}
+
+	return c.JSON(http.StatusOK, locator)
+}
+
+// GetRowBooks returns the books on a shelf row from left to right.
+func (ls *Librascan) GetRowBooks(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	row, err := strconv.Atoi(c.Param("row"))
+	if err != nil || row < 1 {
+		return errorJSON(c, http.StatusBadRequest, "invalid row number")
+	}
+	if _, err := ls.queries.GetShelf(ctx, int64(shelfID)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	books, err := ls.rowBooks(ctx, shelfID, row)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, api.NewBooks(books))
+}
+
+// SetRowOrder re-sequences the books on a shelf row. The listed books come
+// first in the given order, followed by the rest of the row as it was.
+func (ls *Librascan) SetRowOrder(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	row, err := strconv.Atoi(c.Param("row"))
+	if err != nil || row < 1 {
+		return errorJSON(c, http.StatusBadRequest, "invalid row number")
+	}
+	if _, err := ls.queries.GetShelf(ctx, int64(shelfID)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var req models.RowOrderRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+
+	onRow, err := ls.queries.GetRowBooks(ctx, db.GetRowBooksParams{
+		ShelfID:   db.IntToNullInt64(shelfID),
+		RowNumber: db.IntToNullInt64(row),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	order := make([]int64, 0, len(req.ISBNs))
+	for _, isbn := range req.ISBNs {
+		if !slices.Contains(onRow, int64(isbn)) {
+			return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("book %d is not on this row", isbn))
+		}
+		if slices.Contains(order, int64(isbn)) {
+			return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("book %d is listed twice", isbn))
+		}
+		order = append(order, int64(isbn))
+	}
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := resequenceRow(ctx, ls.queries.WithTx(tx), shelfID, row, order); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	books, err := ls.rowBooks(ctx, shelfID, row)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, api.NewBooks(books))
+}
+
+// rowBooks returns the books on a shelf row from left to right.
+func (ls *Librascan) rowBooks(ctx context.Context, shelfID, row int) ([]models.Book, error) {
+	isbns, err := ls.queries.GetRowBooks(ctx, db.GetRowBooksParams{
+		ShelfID:   db.IntToNullInt64(shelfID),
+		RowNumber: db.IntToNullInt64(row),
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	byISBN := make(map[int64]models.Book, len(all))
+	for _, book := range all {
+		byISBN[int64(book.ISBN)] = book
+	}
+	books := make([]models.Book, 0, len(isbns))
+	for _, isbn := range isbns {
+		books = append(books, byISBN[isbn])
+	}
+	return books, nil
+}
+
+// locateBooks works out a locator for each of books.
+func (ls *Librascan) locateBooks(ctx context.Context, tree *locations.Tree, books []models.Book) (map[int]models.BookLocator, error) {
+	loans, err := ls.queries.GetActiveBorrowings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	borrowers := make(map[int]string, len(loans))
+	for _, loan := range loans {
+		borrowers[int(loan.Isbn)] = loan.PersonName
+	}
+	return locateBooks(tree, books, borrowers), nil
+}
+
+// locateBooks describes where each book is. Books on a row are counted from
+// the left in the order they were scanned; books that are lent out are not on
+// the shelf, so they are left out of the count.
+func locateBooks(tree *locations.Tree, books []models.Book, borrowers map[int]string) map[int]models.BookLocator {
+	type shelfRow struct{ shelfID, row int }
+	rows := map[shelfRow][]models.Book{}
+	for _, book := range books {
+		if book.ShelfID == 0 || book.RowNumber == 0 || borrowers[book.ISBN] != "" {
+			continue
+		}
+		key := shelfRow{book.ShelfID, book.RowNumber}
+		rows[key] = append(rows[key], book)
+	}
+
+	type place struct{ position, total int }
+	places := map[int]place{}
+	for _, onRow := range rows {
+		slices.SortFunc(onRow, compareRowOrder)
+		for i, book := range onRow {
+			places[book.ISBN] = place{position: i + 1, total: len(onRow)}
+		}
+	}
+
+	locators := make(map[int]models.BookLocator, len(books))
+	for _, book := range books {
+		l := models.BookLocator{
+			ISBN:         book.ISBN,
+			Title:        book.Title,
+			ShelfID:      book.ShelfID,
+			RowNumber:    book.RowNumber,
+			Slot:         book.Slot,
+			LocationPath: book.LocationPath,
+			Position:     places[book.ISBN].position,
+			RowBooks:     places[book.ISBN].total,
+			Borrower:     borrowers[book.ISBN],
+		}
+		if book.ShelfID != 0 {
+			l.ShelfName = book.ShelfName
+		}
+
+		var parts []string
+		switch {
+		case l.Borrower != "":
+			parts = append(parts, "lent to "+l.Borrower)
+		case book.ShelfID != 0:
+			parts = append(parts, book.ShelfName)
+			if book.RowNumber != 0 {
+				parts = append(parts, fmt.Sprintf("row %d", book.RowNumber))
+			}
+			if book.Slot != 0 {
+				parts = append(parts, fmt.Sprintf("slot %d", book.Slot))
+			}
+			// A box or folder on the row.
+			if n, ok := tree.Get(int64(book.LocationID)); ok && !n.ShelfID.Valid {
+				parts = append(parts, "in "+n.Name)
+			}
+			if p := locations.PositionOnRow(l.Position, l.RowBooks); p != "" {
+				parts = append(parts, p)
+			}
+		case len(book.LocationPath) > 0:
+			parts = append(parts, strings.Join(book.LocationPath, locations.Separator))
+		default:
+			parts = append(parts, "unknown location")
+		}
+		l.Description = strings.Join(parts, ", ")
+
+		locators[book.ISBN] = l
+	}
+	return locators
+}
+
+// compareRowOrder orders books on a row from left to right, the same way as
+// GetRowBooks: books without a position go last.
+func compareRowOrder(a, b models.Book) int {
+	if (a.Position == 0) != (b.Position == 0) {
+		if a.Position == 0 {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.ISBN, b.ISBN))
+}
+
+// nextRowPosition returns the position of a book put on the end of a row. It
+// is NULL for books that are not on a row.
+func nextRowPosition(ctx context.Context, q *db.Queries, shelfID, row int) (sql.NullInt64, error) {
+	if shelfID == 0 || row == 0 {
+		return sql.NullInt64{}, nil
+	}
+	position, err := q.GetNextRowPosition(ctx, db.GetNextRowPositionParams{
+		ShelfID:   db.IntToNullInt64(shelfID),
+		RowNumber: db.IntToNullInt64(row),
+	})
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: position, Valid: true}, nil
+}
+
+// resequenceRow numbers the books on a row from 1. The books in first that
+// are on the row come first, in that order, then the rest in their old order.
+func resequenceRow(ctx context.Context, q *db.Queries, shelfID, row int, first []int64) error {
+	isbns, err := q.GetRowBooks(ctx, db.GetRowBooksParams{
+		ShelfID:   db.IntToNullInt64(shelfID),
+		RowNumber: db.IntToNullInt64(row),
+	})
+	if err != nil {
+		return err
+	}
+
+	order := make([]int64, 0, len(isbns))
+	for _, isbn := range first {
+		if slices.Contains(isbns, isbn) && !slices.Contains(order, isbn) {
+			order = append(order, isbn)
+		}
+	}
+	for _, isbn := range isbns {
+		if !slices.Contains(order, isbn) {
+			order = append(order, isbn)
+		}
+	}
+
+	for i, isbn := range order {
+		err := q.SetBookPosition(ctx, db.SetBookPositionParams{
+			Position: sql.NullInt64{Int64: int64(i + 1), Valid: true},
+			Isbn:     isbn,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/events"
+	"github.com/gouthamve/librascan/pkg/locations"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	errLocationNotFound = errors.New("location not found")
+	errLocationNotLeaf  = errors.New("books can only be placed in locations without sub-locations")
+)
+
+// GetLocations lists all locations with their breadcrumb paths, the number
+// of books in and below each of them and when they were last audited.
+func (ls *Librascan) GetLocations(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	tree, nodes, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	counts, err := ls.locationBookCounts(ctx, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	audits, err := ls.lastAudits(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	result := make([]models.Location, 0, len(nodes))
+	for _, n := range nodes {
+		l := toModelLocation(tree, n, counts)
+		if t, ok := audits[n.ID]; ok {
+			l.LastAuditedAt = &t
+		}
+		result = append(result, l)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetLocation returns a single location.
+func (ls *Librascan) GetLocation(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid location id")
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	n, ok := tree.Get(int64(id))
+	if !ok {
+		return errorJSON(c, http.StatusNotFound, "location not found")
+	}
+	counts, err := ls.locationBookCounts(ctx, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	audits, err := ls.lastAudits(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	l := toModelLocation(tree, n, counts)
+	if t, ok := audits[n.ID]; ok {
+		l.LastAuditedAt = &t
+	}
+
+	return c.JSON(http.StatusOK, l)
+}
+
+// GetLocationBooks lists the books in a location and all locations below it.
+func (ls *Librascan) GetLocationBooks(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid location id")
+	}
+	if _, err := ls.queries.GetLocation(ctx, int64(id)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "location not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	isbns, err := ls.queries.GetLocationBooks(ctx, int64(id))
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	all, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	books := []models.Book{}
+	for _, book := range all {
+		if slices.Contains(isbns, int64(book.ISBN)) {
+			books = append(books, book)
+		}
+	}
+
+	return c.JSON(http.StatusOK, api.NewBooks(books))
+}
+
+// CreateLocation adds a location, at the top of the tree or below parent_id.
+func (ls *Librascan) CreateLocation(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.LocationRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.Name == nil || req.Type == nil {
+		return errorJSON(c, http.StatusBadRequest, "name and type are required")
+	}
+	if err := validateLocationRequest(req); err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	parentID := 0
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+	if parentID != 0 {
+		if _, err := ls.queries.GetLocation(ctx, int64(parentID)); err != nil {
+			if err == sql.ErrNoRows {
+				return errorJSON(c, http.StatusBadRequest, "parent location not found")
+			}
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+	}
+
+	n, err := ls.queries.InsertLocation(ctx, db.InsertLocationParams{
+		ParentID: db.IntToNullInt64(parentID),
+		Type:     *req.Type,
+		Name:     strings.TrimSpace(*req.Name),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errorJSON(c, http.StatusConflict, "a location with this name already exists here")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, toModelLocation(tree, n, nil))
+}
+
+// UpdateLocation renames a location, changes its type or moves it below
+// another one. The locations of a shelf can only be moved, they are renamed
+// through the shelf, and rows stay in their bookcase.
+func (ls *Librascan) UpdateLocation(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid location id")
+	}
+
+	var req models.LocationRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if err := validateLocationRequest(req); err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	n, ok := tree.Get(int64(id))
+	if !ok {
+		return errorJSON(c, http.StatusNotFound, "location not found")
+	}
+
+	params := db.UpdateLocationParams{
+		ID:       n.ID,
+		ParentID: n.ParentID,
+		Type:     n.Type,
+		Name:     n.Name,
+	}
+	if req.Name != nil {
+		params.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Type != nil {
+		params.Type = *req.Type
+	}
+	if n.ShelfID.Valid && (params.Name != n.Name || params.Type != n.Type) {
+		return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("this location belongs to shelf %d, rename it through /shelves/%d", n.ShelfID.Int64, n.ShelfID.Int64))
+	}
+	if req.ParentID != nil {
+		parentID := int64(*req.ParentID)
+		if n.RowNumber.Valid && parentID != n.ParentID.Int64 {
+			return errorJSON(c, http.StatusBadRequest, "shelf rows cannot leave their bookcase")
+		}
+		if parentID != 0 {
+			if _, ok := tree.Get(parentID); !ok {
+				return errorJSON(c, http.StatusBadRequest, "parent location not found")
+			}
+			if tree.IsDescendant(parentID, n.ID) {
+				return errorJSON(c, http.StatusBadRequest, "a location cannot be moved below itself")
+			}
+		}
+		params.ParentID = db.IntToNullInt64(int(parentID))
+	}
+
+	// The location and the books below it move together or not at all.
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := ls.queries.WithTx(tx)
+
+	if _, err := qtx.UpdateLocation(ctx, params); err != nil {
+		if isUniqueViolation(err) {
+			return errorJSON(c, http.StatusConflict, "a location with this name already exists here")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+
+	// Books below the location may now be on another shelf.
+	moved, _, err := loadLocationTree(ctx, qtx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if err := syncBookShelves(ctx, qtx, moved, n.ID); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	return ls.GetLocation(c)
+}
+
+// DeleteLocation removes an empty location. Shelf locations are removed with
+// their shelf.
+func (ls *Librascan) DeleteLocation(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid location id")
+	}
+
+	n, err := ls.queries.GetLocation(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "location not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if n.ShelfID.Valid {
+		return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("this location belongs to shelf %d, delete it through /shelves/%d", n.ShelfID.Int64, n.ShelfID.Int64))
+	}
+
+	children, err := ls.queries.CountLocationChildren(ctx, sql.NullInt64{Int64: n.ID, Valid: true})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if children > 0 {
+		return errorJSON(c, http.StatusConflict, fmt.Sprintf("location has %d sub-locations", children))
+	}
+	books, err := ls.queries.CountLocationBooks(ctx, sql.NullInt64{Int64: n.ID, Valid: true})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if books > 0 {
+		return errorJSON(c, http.StatusConflict, fmt.Sprintf("location has %d books", books))
+	}
+
+	if _, err := ls.queries.DeleteLocation(ctx, n.ID); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "delete error: "+err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetBookLocation moves a book to a location without sub-locations, or to a
+// shelf row.
+func (ls *Librascan) SetBookLocation(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	isbn, err := strconv.Atoi(strings.ReplaceAll(c.Param("isbn"), "-", ""))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "Invalid ISBN")
+	}
+
+	var req api.BookLocationRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.LocationID == 0 && req.ShelfID == 0 {
+		return errorJSON(c, http.StatusBadRequest, "location_id or shelf_id is required")
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	previous, err := ls.getBook(ctx, tree, int64(isbn))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "Book not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	found, err := moveBook(ctx, ls.queries, tree, isbn, req)
+	if err != nil {
+		if errors.Is(err, errLocationNotFound) || errors.Is(err, errLocationNotLeaf) {
+			return errorJSON(c, http.StatusBadRequest, err.Error())
+		}
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if !found {
+		return errorJSON(c, http.StatusNotFound, "Book not found")
+	}
+
+	updated, err := ls.getBook(ctx, tree, int64(isbn))
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	ls.recordScan(c, scanEvent{
+		action:   scanActionMove,
+		isbn:     isbn,
+		book:     &updated,
+		previous: bookPlace(previous),
+	})
+	ls.publish(c, events.Event{Kind: events.KindBookMoved, Book: &updated, MovedFrom: placeOf(previous)})
+
+	return c.JSON(http.StatusOK, api.NewBook(updated))
+}
+
+// moveBook moves a book to a location, or to a shelf row. It returns false
+// if there is no such book.
+func moveBook(ctx context.Context, queries *db.Queries, tree *locations.Tree, isbn int, req api.BookLocationRequest) (bool, error) {
+	book := models.Book{ISBN: isbn, LocationID: req.LocationID, Slot: req.Slot}
+	if req.LocationID == 0 {
+		book.ShelfID, book.RowNumber = req.ShelfID, req.RowNumber
+	}
+	if err := placeBook(ctx, queries, tree, &book); err != nil {
+		return false, err
+	}
+
+	position, err := nextRowPosition(ctx, queries, book.ShelfID, book.RowNumber)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := queries.SetBookLocation(ctx, db.SetBookLocationParams{
+		LocationID: db.IntToNullInt64(book.LocationID),
+		ShelfID:    db.IntToNullInt64(book.ShelfID),
+		RowNumber:  db.IntToNullInt64(book.RowNumber),
+		Slot:       db.IntToNullInt64(book.Slot),
+		Position:   position,
+		Isbn:       int64(isbn),
+	})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// placeBook fills in the rest of a book's location. A book put in a location
+// gets the shelf and row the location is on, and a book put on a shelf row
+// gets that row's location. It returns errLocationNotFound for shelves and
+// rows that do not exist.
+func placeBook(ctx context.Context, queries *db.Queries, tree *locations.Tree, book *models.Book) error {
+	if book.LocationID == 0 && book.ShelfID == 0 {
+		return nil
+	}
+
+	if book.LocationID != 0 {
+		if _, ok := tree.Get(int64(book.LocationID)); !ok {
+			return errLocationNotFound
+		}
+		if !tree.IsLeaf(int64(book.LocationID)) {
+			return errLocationNotLeaf
+		}
+		book.ShelfID, book.RowNumber = tree.Shelf(int64(book.LocationID))
+	} else {
+		shelf, err := queries.GetShelf(ctx, int64(book.ShelfID))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errLocationNotFound
+			}
+			return err
+		}
+		// A book may be on a shelf without saying which row.
+		if book.RowNumber < 0 || int64(book.RowNumber) > shelf.RowsCount.Int64 {
+			return errLocationNotFound
+		}
+
+		id, err := queries.ResolveShelfLocation(ctx, db.ResolveShelfLocationParams{
+			ShelfID:   db.IntToNullInt64(book.ShelfID),
+			RowNumber: db.IntToNullInt64(book.RowNumber),
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+		book.LocationID = int(id)
+	}
+
+	book.LocationPath = tree.Path(int64(book.LocationID))
+	return nil
+}
+
+// syncBookShelves updates the shelf and row of the books in and below a
+// location after it has moved, to where tree has it now.
+func syncBookShelves(ctx context.Context, queries *db.Queries, tree *locations.Tree, id int64) error {
+	isbns, err := queries.GetLocationBooks(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, isbn := range isbns {
+		dbBook, err := queries.GetBook(ctx, isbn)
+		if err != nil {
+			return err
+		}
+		shelfID, row := tree.Shelf(dbBook.LocationID.Int64)
+		if shelfID == db.NullInt64ToInt(dbBook.ShelfID) && row == db.NullInt64ToInt(dbBook.RowNumber) {
+			continue
+		}
+		position, err := nextRowPosition(ctx, queries, shelfID, row)
+		if err != nil {
+			return err
+		}
+
+		_, err = queries.SetBookLocation(ctx, db.SetBookLocationParams{
+			LocationID: dbBook.LocationID,
+			ShelfID:    db.IntToNullInt64(shelfID),
+			RowNumber:  db.IntToNullInt64(row),
+			Slot:       dbBook.Slot,
+			Position:   position,
+			Isbn:       isbn,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ls *Librascan) locationTree(ctx context.Context) (*locations.Tree, []db.Location, error) {
+	return loadLocationTree(ctx, ls.queries)
+}
+
+func loadLocationTree(ctx context.Context, queries *db.Queries) (*locations.Tree, []db.Location, error) {
+	nodes, err := queries.GetAllLocations(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return locations.New(nodes), nodes, nil
+}
+
+func (ls *Librascan) locationBookCounts(ctx context.Context, tree *locations.Tree) (map[int64]int, error) {
+	rows, err := ls.queries.GetLocationBookCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int, len(rows))
+	for _, row := range rows {
+		counts[row.LocationID] = int(row.Books)
+	}
+	return tree.RollUp(counts), nil
+}
+
+func toModelLocation(tree *locations.Tree, n db.Location, counts map[int64]int) models.Location {
+	return models.Location{
+		ID:       int(n.ID),
+		ParentID: db.NullInt64ToInt(n.ParentID),
+		Type:     n.Type,
+		Name:     n.Name,
+		Path:     tree.Path(n.ID),
+		ShelfID:  db.NullInt64ToInt(n.ShelfID),
+		Row:      db.NullInt64ToInt(n.RowNumber),
+		Books:    counts[n.ID],
+	}
+}
+
+func validateLocationRequest(req models.LocationRequest) error {
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if req.Type != nil && !locations.ValidType(*req.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(locations.Types, ", "))
+	}
+	if req.ParentID != nil && *req.ParentID < 0 {
+		return fmt.Errorf("invalid parent_id")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Phones take JPEGs.
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/ean"
+	"github.com/gouthamve/librascan/pkg/events"
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"github.com/labstack/echo/v4"
+)
+
+// maxPhotoSize is the largest photo that can be uploaded.
+const maxPhotoSize = 32 << 20
+
+// maxImagePixels is the largest image that is decoded. A small file can hold
+// an image that takes gigabytes of memory to decode.
+const maxImagePixels = 40_000_000
+
+// ScanImage reads the barcodes on an uploaded photo of a book, and adds the
+// books whose ISBNs they are as POST /books/:isbn does, to the place given by
+// the same query parameters. The JPEG or PNG photo is sent as the image field
+// of a form, or as the request body.
+func (ls *Librascan) ScanImage(c echo.Context) error {
+	place, err := placeFromQuery(c)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+	tree, _, err := ls.locationTree(c.Request().Context())
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if err := placeBook(c.Request().Context(), ls.queries, tree, &place); err != nil {
+		if errors.Is(err, errLocationNotFound) || errors.Is(err, errLocationNotLeaf) {
+			return errorJSON(c, http.StatusBadRequest, err.Error())
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	img, err := readPhoto(c)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid image: "+err.Error())
+	}
+
+	codes := ean.Decode(img)
+	if len(codes) == 0 {
+		ls.recordFailedPhoto(c)
+		return errorJSON(c, http.StatusUnprocessableEntity, "no barcode found")
+	}
+
+	scan := api.ImageScan{Codes: codes, Books: []api.AddedBook{}}
+	for _, code := range codes {
+		if scancode.Parse(code).Kind != scancode.ISBN {
+			continue
+		}
+		isbn, err := strconv.Atoi(code)
+		if err != nil {
+			continue
+		}
+		added, err := ls.addBook(c, tree, isbn, "", false, place)
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, err.Error())
+		}
+		scan.Books = append(scan.Books, api.NewAddedBook(added))
+	}
+
+	return c.JSON(http.StatusOK, scan)
+}
+
+// readPhoto decodes the photo sent with a request.
+func readPhoto(c echo.Context) (image.Image, error) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxPhotoSize)
+
+	var r io.Reader = req.Body
+	if file, err := c.FormFile("image"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return decodeImage(r)
+}
+
+// decodeImage decodes an image, after checking from its header that it is
+// not larger than maxImagePixels.
+func decodeImage(r io.Reader) (image.Image, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("%dx%d is more than %d megapixels", config.Width, config.Height, maxImagePixels/1_000_000)
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, r))
+	return img, err
+}
+
+// recordFailedPhoto records a photo without a barcode on it as a failed scan
+// of the scanner that sent it.
+func (ls *Librascan) recordFailedPhoto(c echo.Context) {
+	sc, ok := scanContextOf(c)
+	if !ok {
+		return
+	}
+	if sc.code == "" {
+		sc.code = "photo"
+	}
+	if _, err := ls.insertScanEvent(c.Request().Context(), sc, scanEvent{action: scanActionAdd, outcome: scanOutcomeFailed}); err != nil {
+		slog.Error("cannot record scan", "error", err, "device", sc.device, "code", sc.code)
+	}
+	ls.events.Publish(events.Event{Kind: events.KindScanFailed, Device: sc.device, Code: sc.code, Action: scanActionAdd})
+}
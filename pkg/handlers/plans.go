@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/planner"
+	"github.com/labstack/echo/v4"
+)
+
+// CreatePlan works out how to reshelve the books on some shelves in sorted
+// order and stores the moves to get there.
+func (ls *Librascan) CreatePlan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.PlanRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	policy := planner.Policy(req.Policy)
+	if policy == "" {
+		policy = planner.PolicyAuthor
+	}
+	if !planner.ValidPolicy(policy) {
+		return errorJSON(c, http.StatusBadRequest, "invalid policy")
+	}
+	if req.Capacity < 0 {
+		return errorJSON(c, http.StatusBadRequest, "invalid capacity")
+	}
+
+	allShelves, err := ls.queries.GetAllShelfs(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	var shelves []db.Shelf
+	if len(req.ShelfIDs) == 0 {
+		for _, shelf := range allShelves {
+			if shelf.ID != 0 {
+				shelves = append(shelves, shelf)
+			}
+		}
+	}
+	for _, id := range req.ShelfIDs {
+		i := slices.IndexFunc(allShelves, func(s db.Shelf) bool { return s.ID == int64(id) })
+		if i < 0 || id == 0 {
+			return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("shelf %d not found", id))
+		}
+		if slices.ContainsFunc(shelves, func(s db.Shelf) bool { return s.ID == int64(id) }) {
+			return errorJSON(c, http.StatusBadRequest, fmt.Sprintf("shelf %d is listed twice", id))
+		}
+		shelves = append(shelves, allShelves[i])
+	}
+
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	loans, err := ls.queries.GetActiveBorrowings(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	lent := make(map[int]bool, len(loans))
+	for _, loan := range loans {
+		lent[int(loan.Isbn)] = true
+	}
+
+	// Lent books are not on the shelf, so they are left out and slot back in
+	// wherever there is room when they are returned.
+	type shelfRow struct{ shelfID, row int }
+	onRows := map[shelfRow][]models.Book{}
+	for _, book := range books {
+		if book.RowNumber == 0 || lent[book.ISBN] {
+			continue
+		}
+		if !slices.ContainsFunc(shelves, func(s db.Shelf) bool { return s.ID == int64(book.ShelfID) }) {
+			continue
+		}
+		key := shelfRow{book.ShelfID, book.RowNumber}
+		onRows[key] = append(onRows[key], book)
+	}
+
+	var toPlace []planner.Book
+	fullest := 0
+	for key, onRow := range onRows {
+		slices.SortFunc(onRow, compareRowOrder)
+		for i, book := range onRow {
+			toPlace = append(toPlace, planner.Book{
+				ISBN:    book.ISBN,
+				Key:     planner.SortKey(policy, book),
+				Current: planner.Place{ShelfID: key.shelfID, Row: key.row, Position: i + 1},
+			})
+		}
+		fullest = max(fullest, len(onRow))
+	}
+
+	rowCount := 0
+	for _, shelf := range shelves {
+		rowCount += db.NullInt64ToInt(shelf.RowsCount)
+	}
+	capacity := req.Capacity
+	if capacity == 0 && rowCount > 0 {
+		capacity = max(fullest, (len(toPlace)+rowCount-1)/rowCount)
+	}
+	var rows []planner.Row
+	for _, shelf := range shelves {
+		for row := 1; row <= db.NullInt64ToInt(shelf.RowsCount); row++ {
+			rows = append(rows, planner.Row{ShelfID: int(shelf.ID), Row: row, Capacity: capacity})
+		}
+	}
+
+	plan, err := planner.Compute(toPlace, rows)
+	if err != nil {
+		if errors.Is(err, planner.ErrNoRoom) {
+			return errorJSON(c, http.StatusConflict, err.Error())
+		}
+		return errorJSON(c, http.StatusInternalServerError, "plan error: "+err.Error())
+	}
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	p, err := qtx.InsertPlan(ctx, string(policy))
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+	for _, t := range plan.Targets {
+		err := qtx.InsertPlanBook(ctx, db.InsertPlanBookParams{
+			PlanID:       p.ID,
+			Isbn:         int64(t.ISBN),
+			FromShelfID:  int64(t.From.ShelfID),
+			FromRow:      int64(t.From.Row),
+			FromPosition: int64(t.From.Position),
+			ToShelfID:    int64(t.To.ShelfID),
+			ToRow:        int64(t.To.Row),
+			ToPosition:   int64(t.To.Position),
+			AfterIsbn:    db.IntToNullInt64(t.After),
+			Step:         db.IntToNullInt64(t.Step),
+		})
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+		}
+	}
+	// A plan with nothing to move is done as soon as it is made.
+	if _, err := qtx.CompletePlan(ctx, p.ID); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	result, err := ls.loadPlan(ctx, p.ID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+// GetPlans lists reshelving plans, newest first.
+func (ls *Librascan) GetPlans(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	plans, err := ls.queries.GetPlans(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	names, err := ls.planNames(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	result := make([]models.Plan, 0, len(plans))
+	for _, p := range plans {
+		planBooks, err := ls.queries.GetPlanBooks(ctx, p.ID)
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		result = append(result, toModelPlan(p, planBooks, names))
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetPlan returns a reshelving plan with its moves.
+func (ls *Librascan) GetPlan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid plan id")
+	}
+	p, err := ls.loadPlan(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "plan not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PlanChecklist renders the moves of a plan as a page to print and tick off.
+func (ls *Librascan) PlanChecklist(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid plan id")
+	}
+	p, err := ls.loadPlan(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "plan not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, "plan.html", p)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// ConfirmPlanMove records that a book has been put in its new place. The book
+// is moved to its target row, and the plan is completed once every move is
+// done.
+func (ls *Librascan) ConfirmPlanMove(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid plan id")
+	}
+	p, err := ls.queries.GetPlan(ctx, int64(id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "plan not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if p.CompletedAt.Valid {
+		return errorJSON(c, http.StatusConflict, "plan is completed")
+	}
+
+	var req models.PlanConfirmRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.ISBN == 0 {
+		return errorJSON(c, http.StatusBadRequest, "isbn is required")
+	}
+
+	planBooks, err := ls.queries.GetPlanBooks(ctx, p.ID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	i := slices.IndexFunc(planBooks, func(pb db.PlanBook) bool { return pb.Isbn == int64(req.ISBN) })
+	if i < 0 || !planBooks[i].Step.Valid {
+		return errorJSON(c, http.StatusNotFound, "book does not move in this plan")
+	}
+	target := planBooks[i]
+	if target.DoneAt.Valid {
+		return errorJSON(c, http.StatusConflict, "book has already been moved")
+	}
+
+	locationID, err := ls.queries.ResolveShelfLocation(ctx, db.ResolveShelfLocationParams{
+		ShelfID:   sql.NullInt64{Int64: target.ToShelfID, Valid: true},
+		RowNumber: sql.NullInt64{Int64: target.ToRow, Valid: true},
+	})
+	if err != nil && err != sql.ErrNoRows {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	shelfID, row := int(target.ToShelfID), int(target.ToRow)
+	position, err := nextRowPosition(ctx, qtx, shelfID, row)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	n, err := qtx.SetBookLocation(ctx, db.SetBookLocationParams{
+		LocationID: db.IntToNullInt64(int(locationID)),
+		ShelfID:    db.IntToNullInt64(shelfID),
+		RowNumber:  db.IntToNullInt64(row),
+		Position:   position,
+		Isbn:       target.Isbn,
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if n == 0 {
+		return errorJSON(c, http.StatusNotFound, "Book not found")
+	}
+
+	// The books of the plan that are on the row so far go in plan order,
+	// ahead of the books still waiting to be moved off it.
+	var order []int64
+	for _, pb := range planBooks {
+		if pb.ToShelfID == target.ToShelfID && pb.ToRow == target.ToRow {
+			order = append(order, pb.Isbn)
+		}
+	}
+	if err := resequenceRow(ctx, qtx, shelfID, row, order); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+
+	n, err = qtx.CompletePlanMove(ctx, db.CompletePlanMoveParams{PlanID: p.ID, Isbn: target.Isbn})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if n == 0 {
+		return errorJSON(c, http.StatusConflict, "book has already been moved")
+	}
+	if _, err := qtx.CompletePlan(ctx, p.ID); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	updated, err := ls.loadPlan(ctx, p.ID)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	result := models.PlanConfirmation{
+		Remaining: len(updated.Moves) - updated.Done,
+		Completed: updated.CompletedAt != nil,
+	}
+	for _, move := range updated.Moves {
+		if move.ISBN == req.ISBN {
+			result.Move = move
+		}
+		if !move.Done && result.Next == nil {
+			result.Next = &move
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// planNames are the titles of books and the names of shelves, for describing
+// moves.
+type planNames struct {
+	titles  map[int64]string
+	shelves map[int64]string
+}
+
+func (ls *Librascan) planNames(ctx context.Context) (planNames, error) {
+	books, err := ls.queries.GetAllBooks(ctx)
+	if err != nil {
+		return planNames{}, err
+	}
+	shelves, err := ls.queries.GetAllShelfs(ctx)
+	if err != nil {
+		return planNames{}, err
+	}
+
+	names := planNames{
+		titles:  make(map[int64]string, len(books)),
+		shelves: make(map[int64]string, len(shelves)),
+	}
+	for _, book := range books {
+		names.titles[book.Isbn] = db.NullStringToString(book.Title)
+	}
+	for _, shelf := range shelves {
+		names.shelves[shelf.ID] = db.NullStringToString(shelf.Name)
+	}
+	return names, nil
+}
+
+func (ls *Librascan) loadPlan(ctx context.Context, id int64) (models.Plan, error) {
+	p, err := ls.queries.GetPlan(ctx, id)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	planBooks, err := ls.queries.GetPlanBooks(ctx, p.ID)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	names, err := ls.planNames(ctx)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	return toModelPlan(p, planBooks, names), nil
+}
+
+// toModelPlan turns a stored plan into its moves. Plan books are stored in
+// target order and steps are numbered in that order, so the moves come out in
+// step order.
+func toModelPlan(p db.Plan, planBooks []db.PlanBook, names planNames) models.Plan {
+	createdAt, _ := db.ParseSQLiteTime(p.CreatedAt)
+	result := models.Plan{
+		ID:          int(p.ID),
+		Policy:      p.Policy,
+		CreatedAt:   createdAt,
+		CompletedAt: parseNullTime(p.CompletedAt),
+		Books:       len(planBooks),
+		Moves:       []models.PlanMove{},
+	}
+
+	for _, pb := range planBooks {
+		if !pb.Step.Valid {
+			continue
+		}
+		move := models.PlanMove{
+			Step:  int(pb.Step.Int64),
+			ISBN:  int(pb.Isbn),
+			Title: names.titles[pb.Isbn],
+			From: models.PlanPlace{
+				ShelfID:   int(pb.FromShelfID),
+				ShelfName: names.shelves[pb.FromShelfID],
+				Row:       int(pb.FromRow),
+				Position:  int(pb.FromPosition),
+			},
+			To: models.PlanPlace{
+				ShelfID:   int(pb.ToShelfID),
+				ShelfName: names.shelves[pb.ToShelfID],
+				Row:       int(pb.ToRow),
+				Position:  int(pb.ToPosition),
+			},
+			Done: pb.DoneAt.Valid,
+		}
+		if pb.AfterIsbn.Valid {
+			move.AfterISBN = int(pb.AfterIsbn.Int64)
+			move.AfterTitle = names.titles[pb.AfterIsbn.Int64]
+		}
+		move.Instruction = planInstruction(move)
+		if move.Done {
+			result.Done++
+		}
+		result.Moves = append(result.Moves, move)
+	}
+	return result
+}
+
+// planInstruction says where to take a book from and where to put it, e.g.
+// `Move "Dune" from office-big row 2 to office-small row 1, after "Dracula".`
+func planInstruction(move models.PlanMove) string {
+	place := "at the left end"
+	if move.AfterISBN != 0 {
+		place = "after " + bookName(move.AfterTitle, move.AfterISBN)
+	}
+	return fmt.Sprintf("Move %s from %s row %d to %s row %d, %s.",
+		bookName(move.Title, move.ISBN),
+		move.From.ShelfName, move.From.Row,
+		move.To.ShelfName, move.To.Row,
+		place,
+	)
+}
+
+func bookName(title string, isbn int) string {
+	if title == "" {
+		return strconv.Itoa(isbn)
+	}
+	return strconv.Quote(title)
+}
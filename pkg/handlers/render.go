@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image/color"
+	_ "image/jpeg" // Covers are mostly JPEGs.
+	"image/png"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/shelfview"
+)
+
+// coverClient fetches covers to colour spines with.
+var coverClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// RenderShelf draws a shelf as a PNG of book spines. Pass ?row= to draw a
+// single row instead of the whole bookcase.
+func (ls *Librascan) RenderShelf(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	row := 0
+	if rowStr := c.QueryParam("row"); rowStr != "" {
+		row, err = strconv.Atoi(rowStr)
+		if err != nil || row < 1 {
+			return errorJSON(c, http.StatusBadRequest, "invalid row")
+		}
+	}
+	shelf, err := ls.queries.GetShelf(ctx, int64(shelfID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	layout, err := ls.shelfLayout(ctx, shelf, row)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, layout.Draw()); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "render error: "+err.Error())
+	}
+
+	return c.Blob(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// ShelfHTMLHandler shows the rendered shelf. Clicking a spine opens the book
+// on the books page.
+func (ls *Librascan) ShelfHTMLHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	row := 0
+	if rowStr := c.QueryParam("row"); rowStr != "" {
+		row, err = strconv.Atoi(rowStr)
+		if err != nil || row < 1 {
+			return errorJSON(c, http.StatusBadRequest, "invalid row")
+		}
+	}
+	shelf, err := ls.queries.GetShelf(ctx, int64(shelfID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	layout, err := ls.shelfLayout(ctx, shelf, row)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	data := struct {
+		Shelf  db.Shelf
+		Row    int
+		Rows   []int
+		Layout *shelfview.Layout
+	}{
+		Shelf:  shelf,
+		Row:    row,
+		Layout: layout,
+	}
+	for i := range db.NullInt64ToInt(shelf.RowsCount) {
+		data.Rows = append(data.Rows, i+1)
+	}
+
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, "shelf.html", data)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+
+	return c.HTML(http.StatusOK, buf.String())
+}
+
+// shelfLayout lays out the books on a shelf, or on one row of it when row is
+// not 0. Books that are lent out are left out.
+func (ls *Librascan) shelfLayout(ctx context.Context, shelf db.Shelf, row int) (*shelfview.Layout, error) {
+	tree, _, err := ls.locationTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	books, err := getAllBooks(ctx, ls.queries, tree)
+	if err != nil {
+		return nil, err
+	}
+	loans, err := ls.queries.GetActiveBorrowings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lent := make(map[int]bool, len(loans))
+	for _, loan := range loans {
+		lent[int(loan.Isbn)] = true
+	}
+
+	rowCount := db.NullInt64ToInt(shelf.RowsCount)
+	byRow := map[int][]models.Book{}
+	for _, book := range books {
+		if book.ShelfID != int(shelf.ID) || book.RowNumber == 0 || lent[book.ISBN] {
+			continue
+		}
+		byRow[book.RowNumber] = append(byRow[book.RowNumber], book)
+		rowCount = max(rowCount, book.RowNumber)
+	}
+
+	numbers := []int{row}
+	if row == 0 {
+		numbers = nil
+		for i := range rowCount {
+			numbers = append(numbers, i+1)
+		}
+	}
+
+	var onShelf []models.Book
+	for _, n := range numbers {
+		onShelf = append(onShelf, byRow[n]...)
+	}
+	colors, err := ls.spineColors(ctx, onShelf)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]shelfview.Row, 0, len(numbers))
+	for _, n := range numbers {
+		r := shelfview.Row{Number: n}
+		slices.SortFunc(byRow[n], compareRowOrder)
+		for _, book := range byRow[n] {
+			r.Books = append(r.Books, shelfview.Book{
+				ISBN:  book.ISBN,
+				Title: book.Title,
+				Pages: book.Pages,
+				Color: colors[book.ISBN],
+			})
+		}
+		rows = append(rows, r)
+	}
+	return shelfview.NewLayout(rows), nil
+}
+
+// spineColors returns the spine colour of each book. Colours are taken from
+// the covers the first time they are needed and stored, so each cover is only
+// fetched once. Books without a usable cover are left out.
+func (ls *Librascan) spineColors(ctx context.Context, books []models.Book) (map[int]color.RGBA, error) {
+	rows, err := ls.queries.GetSpineColors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[int]string, len(rows))
+	for _, r := range rows {
+		stored[int(r.Isbn)] = r.SpineColor
+	}
+
+	colors := map[int]color.RGBA{}
+	var missing []models.Book
+	for _, book := range books {
+		hex, ok := stored[book.ISBN]
+		if !ok && book.CoverURL != "" {
+			missing = append(missing, book)
+			continue
+		}
+		if c, ok := shelfview.ParseHex(hex); ok {
+			colors[book.ISBN] = c
+		}
+	}
+
+	// Fetch a few covers at a time.
+	type result struct {
+		isbn int
+		hex  string
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		fetched []result
+		sem     = make(chan struct{}, 4)
+	)
+	for _, book := range missing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			c, ok, err := coverColor(ctx, book.CoverURL)
+			if err != nil {
+				// Try again next time.
+				slog.Warn("failed to fetch cover", "isbn", book.ISBN, "error", err)
+				return
+			}
+			// Covers that cannot be used are stored as empty, so they are
+			// not fetched again.
+			r := result{isbn: book.ISBN}
+			if ok {
+				r.hex = shelfview.Hex(c)
+			}
+			mu.Lock()
+			fetched = append(fetched, r)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range fetched {
+		err := ls.queries.SetSpineColor(ctx, db.SetSpineColorParams{
+			SpineColor: sql.NullString{String: r.hex, Valid: true},
+			Isbn:       int64(r.isbn),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := shelfview.ParseHex(r.hex); ok {
+			colors[r.isbn] = c
+		}
+	}
+	return colors, nil
+}
+
+// coverColor fetches a cover and returns its dominant colour. It returns
+// false when the cover does not exist, is not an image or is too large to
+// decode.
+func coverColor(ctx context.Context, url string) (color.RGBA, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return color.RGBA{}, false, nil
+	}
+	resp, err := coverClient.Do(req)
+	if err != nil {
+		return color.RGBA{}, false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return color.RGBA{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return color.RGBA{}, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	img, err := decodeImage(resp.Body)
+	if err != nil {
+		return color.RGBA{}, false, nil
+	}
+	c, ok := shelfview.DominantColor(img)
+	return c, ok, nil
+}
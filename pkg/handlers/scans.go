@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/events"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+// Actions recorded for scans. Adds, copies, moves, deletes, loans and returns
+// change the library and can be undone.
+const (
+	scanActionAdd    = "add"
+	scanActionCopy   = "copy"
+	scanActionMove   = "move"
+	scanActionDelete = "delete"
+	scanActionBorrow = "borrow"
+	scanActionReturn = "return"
+	scanActionUndo   = "undo"
+
+	// scanActionShelf is a shelf label, which changes the shelf a scanner
+	// puts books on.
+	scanActionShelf = "shelf"
+)
+
+// Outcomes of scans. scanOutcomeOK is a scan the server acted on, and
+// scanOutcomeFailed one that went wrong on the scanner.
+const (
+	scanOutcomeOK     = "ok"
+	scanOutcomeFailed = "failed"
+)
+
+var undoableScanActions = map[string]bool{
+	scanActionAdd:    true,
+	scanActionCopy:   true,
+	scanActionMove:   true,
+	scanActionDelete: true,
+	scanActionBorrow: true,
+	scanActionReturn: true,
+}
+
+// errCannotUndo is returned when the library has changed since a scan in a
+// way that stops it being undone.
+var errCannotUndo = errors.New("scan cannot be undone")
+
+// scanContext is the scanner a request came from.
+type scanContext struct {
+	device string
+	source string
+	mode   string
+	code   string
+}
+
+// scanContextOf returns the scanner a request came from. Scanners name
+// themselves with the scan_device query parameter, and can add scan_source,
+// scan_mode and scan_code. Requests without it, e.g. from the web pages, are
+// not recorded.
+func scanContextOf(c echo.Context) (scanContext, bool) {
+	sc := scanContext{
+		device: c.QueryParam("scan_device"),
+		source: c.QueryParam("scan_source"),
+		mode:   c.QueryParam("scan_mode"),
+		code:   c.QueryParam("scan_code"),
+	}
+	return sc, sc.device != ""
+}
+
+// scanEvent is a scan the server acted on.
+type scanEvent struct {
+	action string
+	// outcome defaults to scanOutcomeOK.
+	outcome  string
+	isbn     int
+	personID int
+	// borrowingID is the loan a borrow or return scan made or ended.
+	borrowingID int
+	// book is the book as it is after the scan, or as it was before it was
+	// deleted.
+	book *models.Book
+	// previous is where the book was before the scan, or nil if it was not
+	// in the library.
+	previous *api.BookLocationRequest
+}
+
+// recordScan records a scan made by a scanner. Failing to record it is
+// logged but does not fail the request.
+func (ls *Librascan) recordScan(c echo.Context, ev scanEvent) {
+	sc, ok := scanContextOf(c)
+	if !ok {
+		return
+	}
+	if sc.code == "" {
+		sc.code = strconv.Itoa(ev.isbn)
+	}
+	if _, err := ls.insertScanEvent(c.Request().Context(), sc, ev); err != nil {
+		slog.Error("cannot record scan", "error", err, "device", sc.device, "code", sc.code)
+	}
+}
+
+func (ls *Librascan) insertScanEvent(ctx context.Context, sc scanContext, ev scanEvent) (db.ScanEvent, error) {
+	if ev.outcome == "" {
+		ev.outcome = scanOutcomeOK
+	}
+	params := db.InsertScanEventParams{
+		Device:      sc.device,
+		Source:      db.StringToNullString(sc.source),
+		Code:        sc.code,
+		Mode:        sc.mode,
+		Action:      ev.action,
+		Outcome:     ev.outcome,
+		Isbn:        db.IntToNullInt64(ev.isbn),
+		PersonID:    db.IntToNullInt64(ev.personID),
+		BorrowingID: db.IntToNullInt64(ev.borrowingID),
+	}
+	if ev.book != nil {
+		book, err := json.Marshal(ev.book)
+		if err != nil {
+			return db.ScanEvent{}, err
+		}
+		params.Book = db.StringToNullString(string(book))
+		params.ShelfID = db.IntToNullInt64(ev.book.ShelfID)
+		params.RowNumber = db.IntToNullInt64(ev.book.RowNumber)
+		params.Slot = db.IntToNullInt64(ev.book.Slot)
+	}
+	if ev.previous != nil {
+		previous, err := json.Marshal(ev.previous)
+		if err != nil {
+			return db.ScanEvent{}, err
+		}
+		params.Previous = db.StringToNullString(string(previous))
+	}
+	return ls.queries.InsertScanEvent(ctx, params)
+}
+
+// bookPlace returns where a book is, to put it back there later.
+func bookPlace(book models.Book) *api.BookLocationRequest {
+	return &api.BookLocationRequest{
+		LocationID: book.LocationID,
+		ShelfID:    book.ShelfID,
+		RowNumber:  book.RowNumber,
+		Slot:       book.Slot,
+	}
+}
+
+// GetScans lists the latest scans, newest first. Set device to only list the
+// scans of one scanner, and limit to list more or fewer than 50.
+func (ls *Librascan) GetScans(c echo.Context) error {
+	limit := 50
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return errorJSON(c, http.StatusBadRequest, "invalid limit")
+		}
+	}
+
+	events, err := ls.queries.GetScanEvents(c.Request().Context(), db.GetScanEventsParams{
+		Device:    db.StringToNullString(c.QueryParam("device")),
+		MaxEvents: int64(limit),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	scans := make([]models.ScanEvent, 0, len(events))
+	for _, e := range events {
+		scans = append(scans, toModelScanEvent(e))
+	}
+	return c.JSON(http.StatusOK, scans)
+}
+
+// RecordScan records a scan that did not change any books. Scans that do are
+// recorded by the request that makes the change.
+func (ls *Librascan) RecordScan(c echo.Context) error {
+	var req models.ScanEventRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.Device == "" || req.Code == "" || req.Action == "" || req.Outcome == "" {
+		return errorJSON(c, http.StatusBadRequest, "device, code, action and outcome are required")
+	}
+	if undoableScanActions[req.Action] && req.Outcome == scanOutcomeOK {
+		return errorJSON(c, http.StatusBadRequest, "scans that change books are recorded when they are made")
+	}
+
+	e, err := ls.queries.InsertScanEvent(c.Request().Context(), db.InsertScanEventParams{
+		Device:    req.Device,
+		Source:    db.StringToNullString(req.Source),
+		Code:      req.Code,
+		Mode:      req.Mode,
+		Action:    req.Action,
+		Outcome:   req.Outcome,
+		ShelfID:   db.IntToNullInt64(req.ShelfID),
+		RowNumber: db.IntToNullInt64(req.RowNumber),
+		Slot:      db.IntToNullInt64(req.Slot),
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+	ls.publishScan(c, req)
+
+	return c.JSON(http.StatusCreated, toModelScanEvent(e))
+}
+
+// publishScan tells subscribers about scanners changing shelves and scans
+// that failed.
+func (ls *Librascan) publishScan(c echo.Context, req models.ScanEventRequest) {
+	e := events.Event{Device: req.Device, Code: req.Code, Action: req.Action}
+	switch {
+	case req.Outcome == scanOutcomeFailed:
+		e.Kind = events.KindScanFailed
+	case req.Action == scanActionShelf && req.Outcome == scanOutcomeOK:
+		e.Kind = events.KindShelfChanged
+		e.Shelf = &models.BookPlace{ShelfID: req.ShelfID, RowNumber: req.RowNumber, Slot: req.Slot}
+		name, err := ls.queries.GetShelfName(c.Request().Context(), int64(req.ShelfID))
+		if err == nil {
+			e.Shelf.ShelfName = name.String
+		}
+	default:
+		return
+	}
+	ls.events.Publish(e)
+}
+
+// UndoScan reverts what a scan did: an added book is deleted, or put back
+// where it was if it was already in the library, an added copy is taken off
+// the count, a moved book is put back, a deleted book is restored, a loan is
+// cancelled and a returned book is on loan again.
+func (ls *Librascan) UndoScan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid scan id")
+	}
+
+	e, err := ls.queries.GetScanEvent(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "scan not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	e, err = ls.undoScan(ctx, e)
+	if err != nil {
+		return undoError(c, err)
+	}
+	return c.JSON(http.StatusOK, toModelScanEvent(e))
+}
+
+// UndoLastScan undoes the last scan of a device that can be undone, and
+// records the undo as a scan of its own.
+func (ls *Librascan) UndoLastScan(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req models.ScanUndoRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.Device == "" {
+		return errorJSON(c, http.StatusBadRequest, "device is required")
+	}
+
+	e, err := ls.queries.GetLastUndoableScanEvent(ctx, req.Device)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "nothing to undo")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	e, err = ls.undoScan(ctx, e)
+	if err != nil {
+		return undoError(c, err)
+	}
+
+	sc := scanContext{device: req.Device, source: req.Source, mode: e.Mode, code: "UNDO"}
+	if _, err := ls.insertScanEvent(ctx, sc, scanEvent{action: scanActionUndo, isbn: db.NullInt64ToInt(e.Isbn)}); err != nil {
+		slog.Error("cannot record scan", "error", err, "device", sc.device, "code", sc.code)
+	}
+	return c.JSON(http.StatusOK, toModelScanEvent(e))
+}
+
+// undoScan undoes a scan and returns it marked as undone. Only the last scan
+// of a book that is not undone yet can be undone, as later ones build on it.
+func (ls *Librascan) undoScan(ctx context.Context, e db.ScanEvent) (db.ScanEvent, error) {
+	if e.UndoneAt.Valid {
+		return db.ScanEvent{}, fmt.Errorf("%w: it is already undone", errCannotUndo)
+	}
+	if !undoableScanActions[e.Action] || e.Outcome != scanOutcomeOK {
+		return db.ScanEvent{}, fmt.Errorf("%w: it did not change the library", errCannotUndo)
+	}
+
+	// Marking the scan undone first stops two undos of it both going ahead.
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.ScanEvent{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := ls.queries.WithTx(tx)
+
+	n, err := qtx.MarkScanEventUndone(ctx, e.ID)
+	if err != nil {
+		return db.ScanEvent{}, err
+	}
+	if n == 0 {
+		return db.ScanEvent{}, fmt.Errorf("%w: it is already undone", errCannotUndo)
+	}
+	latest, err := qtx.GetLastUndoableBookScanEventID(ctx, e.Isbn)
+	if err != nil && err != sql.ErrNoRows {
+		return db.ScanEvent{}, err
+	}
+	if err == nil && latest > e.ID {
+		return db.ScanEvent{}, fmt.Errorf("%w: book %d has been scanned again since, undo that first", errCannotUndo, e.Isbn.Int64)
+	}
+
+	if err := revertScan(ctx, qtx, e); err != nil {
+		return db.ScanEvent{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return db.ScanEvent{}, err
+	}
+	return ls.queries.GetScanEvent(ctx, e.ID)
+}
+
+func undoError(c echo.Context, err error) error {
+	if errors.Is(err, errCannotUndo) {
+		return errorJSON(c, http.StatusConflict, err.Error())
+	}
+	return errorJSON(c, http.StatusInternalServerError, "undo error: "+err.Error())
+}
+
+// revertScan changes the library back to how it was before a scan.
+func revertScan(ctx context.Context, queries *db.Queries, e db.ScanEvent) error {
+	isbn := db.NullInt64ToInt(e.Isbn)
+
+	switch e.Action {
+	case scanActionAdd, scanActionMove:
+		if err := checkUnmoved(ctx, queries, e); err != nil {
+			return err
+		}
+		if !e.Previous.Valid {
+			// Loans are not scans of their own when made on the web, so
+			// they are checked for here.
+			borrowed, err := queries.IsBookBorrowed(ctx, int64(isbn))
+			if err != nil {
+				return err
+			}
+			if borrowed != 0 {
+				return fmt.Errorf("%w: book %d is on loan", errCannotUndo, isbn)
+			}
+			// The book was new, so it goes again.
+			if _, err := queries.DeleteBook(ctx, int64(isbn)); err != nil {
+				return err
+			}
+			return nil
+		}
+		var previous api.BookLocationRequest
+		if err := json.Unmarshal([]byte(e.Previous.String), &previous); err != nil {
+			return err
+		}
+		tree, _, err := loadLocationTree(ctx, queries)
+		if err != nil {
+			return err
+		}
+		found, err := moveBook(ctx, queries, tree, isbn, previous)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%w: book %d is no longer in the library", errCannotUndo, isbn)
+		}
+	case scanActionCopy:
+		n, err := queries.RemoveBookCopy(ctx, int64(isbn))
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: book %d has no other copies", errCannotUndo, isbn)
+		}
+	case scanActionDelete:
+		if !e.Book.Valid {
+			return fmt.Errorf("%w: the deleted book was not kept", errCannotUndo)
+		}
+		var book models.Book
+		if err := json.Unmarshal([]byte(e.Book.String), &book); err != nil {
+			return err
+		}
+		return storeBook(ctx, queries, book)
+	case scanActionBorrow:
+		if !e.BorrowingID.Valid {
+			return fmt.Errorf("%w: the loan was not kept", errCannotUndo)
+		}
+		n, err := queries.DeleteActiveBorrowing(ctx, e.BorrowingID.Int64)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: book %d has been returned since", errCannotUndo, isbn)
+		}
+	case scanActionReturn:
+		if !e.BorrowingID.Valid {
+			return fmt.Errorf("%w: the loan was not kept", errCannotUndo)
+		}
+		n, err := queries.ReopenBorrowing(ctx, e.BorrowingID.Int64)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: book %d is on loan again", errCannotUndo, isbn)
+		}
+	}
+	return nil
+}
+
+// checkUnmoved returns errCannotUndo unless a book is still where a scan put
+// it. Books moved on the web or with the API are not scans, so undoing the
+// scan would throw that move away.
+func checkUnmoved(ctx context.Context, queries *db.Queries, e db.ScanEvent) error {
+	isbn := db.NullInt64ToInt(e.Isbn)
+	if !e.Book.Valid {
+		return nil
+	}
+	var scanned models.Book
+	if err := json.Unmarshal([]byte(e.Book.String), &scanned); err != nil {
+		return err
+	}
+
+	current, err := queries.GetBook(ctx, int64(isbn))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: book %d is no longer in the library", errCannotUndo, isbn)
+	}
+	if err != nil {
+		return err
+	}
+	if db.NullInt64ToInt(current.ShelfID) != scanned.ShelfID ||
+		db.NullInt64ToInt(current.RowNumber) != scanned.RowNumber ||
+		db.NullInt64ToInt(current.Slot) != scanned.Slot ||
+		db.NullInt64ToInt(current.LocationID) != scanned.LocationID {
+		return fmt.Errorf("%w: book %d has been moved since", errCannotUndo, isbn)
+	}
+	return nil
+}
+
+func toModelScanEvent(e db.ScanEvent) models.ScanEvent {
+	scannedAt, _ := db.ParseSQLiteTime(e.ScannedAt)
+	scan := models.ScanEvent{
+		ID:        int(e.ID),
+		ScannedAt: scannedAt,
+		Device:    e.Device,
+		Source:    db.NullStringToString(e.Source),
+		Code:      e.Code,
+		Mode:      e.Mode,
+		Action:    e.Action,
+		Outcome:   e.Outcome,
+		ShelfID:   db.NullInt64ToInt(e.ShelfID),
+		RowNumber: db.NullInt64ToInt(e.RowNumber),
+		Slot:      db.NullInt64ToInt(e.Slot),
+		ISBN:      db.NullInt64ToInt(e.Isbn),
+		PersonID:  db.NullInt64ToInt(e.PersonID),
+		Undoable:  undoableScanActions[e.Action] && e.Outcome == scanOutcomeOK && !e.UndoneAt.Valid,
+		UndoneAt:  parseNullTime(e.UndoneAt),
+	}
+	if e.Book.Valid {
+		book := models.Book{}
+		if err := json.Unmarshal([]byte(e.Book.String), &book); err == nil {
+			scan.Book = &book
+		}
+	}
+	return scan
+}
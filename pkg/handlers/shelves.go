@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/labstack/echo/v4"
+)
+
+// MaxShelfRows is the most rows a shelf can have. Rows past 9 get versioned
+// location codes instead of EAN-8 labels.
+const MaxShelfRows = 99
+
+// GetShelves lists all shelves with the number of books on each row.
+func (ls *Librascan) GetShelves(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelves, err := ls.listShelves(ctx)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, shelves)
+}
+
+// CreateShelf adds a new shelf.
+func (ls *Librascan) CreateShelf(c echo.Context) error {
+	var req models.ShelfRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if req.Name == nil || req.RowCount == nil {
+		return errorJSON(c, http.StatusBadRequest, "name and rows_count are required")
+	}
+	if err := validateShelfRequest(req); err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	shelf, err := qtx.InsertShelf(ctx, db.InsertShelfParams{
+		Name:      db.StringToNullString(strings.TrimSpace(*req.Name)),
+		RowsCount: sql.NullInt64{Int64: int64(*req.RowCount), Valid: true},
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errorJSON(c, http.StatusConflict, "a shelf with this name already exists")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+	if err := syncShelfLocations(ctx, qtx, shelf); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "insert error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, models.Shelf{
+		ID:       int(shelf.ID),
+		Name:     db.NullStringToString(shelf.Name),
+		RowCount: db.NullInt64ToInt(shelf.RowsCount),
+	})
+}
+
+// UpdateShelf renames a shelf or changes its number of rows. A shelf cannot
+// lose rows that still hold books or sub-locations.
+func (ls *Librascan) UpdateShelf(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	if shelfID == 0 {
+		return errorJSON(c, http.StatusBadRequest, "the unknown shelf cannot be changed")
+	}
+
+	var req models.ShelfRequest
+	if err := c.Bind(&req); err != nil {
+		return invalidBody(c, err)
+	}
+	if err := validateShelfRequest(req); err != nil {
+		return errorJSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	params := db.UpdateShelfParams{ID: int64(shelfID)}
+	if req.Name != nil {
+		params.Name = db.StringToNullString(strings.TrimSpace(*req.Name))
+	}
+	if req.RowCount != nil {
+		maxRow, err := ls.queries.GetShelfMaxRow(ctx, sql.NullInt64{Int64: int64(shelfID), Valid: true})
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		if int64(*req.RowCount) < maxRow {
+			return errorJSON(c, http.StatusConflict, fmt.Sprintf("row %d still has books", maxRow))
+		}
+		subLocations, err := ls.queries.CountShelfSubLocations(ctx, db.CountShelfSubLocationsParams{
+			ShelfID: sql.NullInt64{Int64: int64(shelfID), Valid: true},
+			MinRow:  int64(*req.RowCount) + 1,
+		})
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		if subLocations > 0 {
+			return errorJSON(c, http.StatusConflict, "the rows to remove still hold other locations")
+		}
+		params.RowsCount = sql.NullInt64{Int64: int64(*req.RowCount), Valid: true}
+	}
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	n, err := qtx.UpdateShelf(ctx, params)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errorJSON(c, http.StatusConflict, "a shelf with this name already exists")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if n == 0 {
+		return errorJSON(c, http.StatusNotFound, "shelf not found")
+	}
+
+	if params.RowsCount.Valid {
+		err := qtx.DeleteShelfLocations(ctx, db.DeleteShelfLocationsParams{
+			ShelfID: sql.NullInt64{Int64: int64(shelfID), Valid: true},
+			MinRow:  params.RowsCount.Int64 + 1,
+		})
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+		}
+	}
+
+	shelf, err := qtx.GetShelf(ctx, int64(shelfID))
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if err := syncShelfLocations(ctx, qtx, shelf); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, models.Shelf{
+		ID:       int(shelf.ID),
+		Name:     db.NullStringToString(shelf.Name),
+		RowCount: db.NullInt64ToInt(shelf.RowsCount),
+	})
+}
+
+// DeleteShelf removes a shelf and its locations. A shelf that still has books
+// is only removed when reassign_to names the shelf to move them to; books on
+// rows the new shelf does not have lose their row. Other locations kept on
+// the shelf have to be moved first.
+func (ls *Librascan) DeleteShelf(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	shelfID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return errorJSON(c, http.StatusBadRequest, "invalid shelf id")
+	}
+	if shelfID == 0 {
+		return errorJSON(c, http.StatusBadRequest, "the unknown shelf cannot be deleted")
+	}
+
+	if _, err := ls.queries.GetShelf(ctx, int64(shelfID)); err != nil {
+		if err == sql.ErrNoRows {
+			return errorJSON(c, http.StatusNotFound, "shelf not found")
+		}
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var target *db.Shelf
+	if reassignStr := c.QueryParam("reassign_to"); reassignStr != "" {
+		reassignTo, err := strconv.Atoi(reassignStr)
+		if err != nil || reassignTo == shelfID {
+			return errorJSON(c, http.StatusBadRequest, "invalid reassign_to")
+		}
+		shelf, err := ls.queries.GetShelf(ctx, int64(reassignTo))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errorJSON(c, http.StatusBadRequest, "reassign_to shelf not found")
+			}
+			return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+		}
+		target = &shelf
+	}
+
+	subLocations, err := ls.queries.CountShelfSubLocations(ctx, db.CountShelfSubLocationsParams{
+		ShelfID: sql.NullInt64{Int64: int64(shelfID), Valid: true},
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if subLocations > 0 {
+		return errorJSON(c, http.StatusConflict, fmt.Sprintf("shelf holds %d other locations, move them first", subLocations))
+	}
+
+	tx, err := ls.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	qtx := ls.queries.WithTx(tx)
+
+	books, err := qtx.CountShelfBooks(ctx, sql.NullInt64{Int64: int64(shelfID), Valid: true})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+	if books > 0 {
+		if target == nil {
+			return errorJSON(c, http.StatusConflict, fmt.Sprintf("shelf has %d books, pass reassign_to to move them", books))
+		}
+
+		_, err := qtx.MoveShelfBooks(ctx, db.MoveShelfBooksParams{
+			ToShelfID:   db.IntToNullInt64(int(target.ID)),
+			MaxRow:      sql.NullInt64{Int64: target.RowsCount.Int64, Valid: true},
+			FromShelfID: sql.NullInt64{Int64: int64(shelfID), Valid: true},
+		})
+		if err != nil {
+			return errorJSON(c, http.StatusInternalServerError, "update error: "+err.Error())
+		}
+	}
+
+	err = qtx.DeleteShelfLocations(ctx, db.DeleteShelfLocationsParams{
+		ShelfID: sql.NullInt64{Int64: int64(shelfID), Valid: true},
+	})
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "delete error: "+err.Error())
+	}
+	if _, err := qtx.DeleteShelf(ctx, int64(shelfID)); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "delete error: "+err.Error())
+	}
+	if err := tx.Commit(); err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "transaction error: "+err.Error())
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ls *Librascan) listShelves(ctx context.Context) ([]models.Shelf, error) {
+	dbShelves, err := ls.queries.GetAllShelfs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := ls.queries.GetShelfRowCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rowCounts := map[int64]map[int64]int{}
+	for _, count := range counts {
+		if rowCounts[count.ShelfID] == nil {
+			rowCounts[count.ShelfID] = map[int64]int{}
+		}
+		rowCounts[count.ShelfID][count.RowNumber] = int(count.Books)
+	}
+
+	shelves := make([]models.Shelf, 0, len(dbShelves))
+	for _, dbShelf := range dbShelves {
+		shelf := models.Shelf{
+			ID:       int(dbShelf.ID),
+			Name:     db.NullStringToString(dbShelf.Name),
+			RowCount: db.NullInt64ToInt(dbShelf.RowsCount),
+			Rows:     []models.ShelfRow{},
+		}
+
+		// Books can sit on rows past rows_count if they were scanned before
+		// the shelf was set up, so list those rows too.
+		rows := rowCounts[dbShelf.ID]
+		lastRow := int64(shelf.RowCount)
+		for row, n := range rows {
+			shelf.Books += n
+			lastRow = max(lastRow, row)
+		}
+		if n := rows[0]; n > 0 {
+			shelf.Rows = append(shelf.Rows, models.ShelfRow{Row: 0, Books: n})
+		}
+		for row := int64(1); row <= lastRow; row++ {
+			shelf.Rows = append(shelf.Rows, models.ShelfRow{Row: int(row), Books: rows[row]})
+		}
+
+		shelves = append(shelves, shelf)
+	}
+
+	return shelves, nil
+}
+
+// syncShelfLocations makes sure a shelf has a bookcase location named after it,
+// with a row location for each of its rows.
+func syncShelfLocations(ctx context.Context, q *db.Queries, shelf db.Shelf) error {
+	shelfID := sql.NullInt64{Int64: shelf.ID, Valid: true}
+	name := db.NullStringToString(shelf.Name)
+
+	nodes, err := q.GetShelfLocations(ctx, shelfID)
+	if err != nil {
+		return err
+	}
+
+	var bookcase *db.Location
+	rows := map[int64]bool{}
+	for _, n := range nodes {
+		if n.RowNumber.Valid {
+			rows[n.RowNumber.Int64] = true
+		} else {
+			bookcase = &n
+		}
+	}
+
+	if bookcase == nil {
+		n, err := q.InsertLocation(ctx, db.InsertLocationParams{
+			Type:    "bookcase",
+			Name:    name,
+			ShelfID: shelfID,
+		})
+		if err != nil {
+			return err
+		}
+		bookcase = &n
+	} else if bookcase.Name != name {
+		if err := q.RenameShelfLocation(ctx, db.RenameShelfLocationParams{Name: name, ShelfID: shelfID}); err != nil {
+			return err
+		}
+	}
+
+	for row := int64(1); row <= shelf.RowsCount.Int64; row++ {
+		if rows[row] {
+			continue
+		}
+		_, err := q.InsertLocation(ctx, db.InsertLocationParams{
+			ParentID:  sql.NullInt64{Int64: bookcase.ID, Valid: true},
+			Type:      "row",
+			Name:      fmt.Sprintf("Row %d", row),
+			ShelfID:   shelfID,
+			RowNumber: sql.NullInt64{Int64: row, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateShelfRequest(req models.ShelfRequest) error {
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if req.RowCount != nil && (*req.RowCount < 1 || *req.RowCount > MaxShelfRows) {
+		return fmt.Errorf("rows_count must be between 1 and %d", MaxShelfRows)
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gouthamve/librascan/pkg/stats"
+	"github.com/labstack/echo/v4"
+)
+
+// StatsHandler returns collection and lending statistics as JSON.
+func (ls *Librascan) StatsHandler(c echo.Context) error {
+	s, err := stats.Compute(c.Request().Context(), ls.queries)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	return c.JSON(http.StatusOK, s)
+}
+
+// StatsHTMLHandler renders the statistics dashboard.
+func (ls *Librascan) StatsHTMLHandler(c echo.Context) error {
+	s, err := stats.Compute(c.Request().Context(), ls.queries)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "query error: "+err.Error())
+	}
+
+	var buf bytes.Buffer
+	err = templates.ExecuteTemplate(&buf, "stats.html", s)
+	if err != nil {
+		return errorJSON(c, http.StatusInternalServerError, "template error: "+err.Error())
+	}
+
+	return c.HTML(http.StatusOK, buf.String())
+}
@@ -0,0 +1,426 @@
+// Package labels lays out barcode labels on sheets of label paper and writes
+// them as PDF or SVG.
+//
+// All measurements are in millimetres with the origin at the top left of the
+// page. Bars are snapped to whole printer dots at the configured DPI so that
+// every bar of a barcode prints with the same width.
+package labels
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
+)
+
+// Template describes a sheet of label paper.
+type Template struct {
+	PageWidth  float64
+	PageHeight float64
+
+	Columns int
+	Rows    int
+
+	MarginTop   float64
+	MarginLeft  float64
+	LabelWidth  float64
+	LabelHeight float64
+	GapX        float64
+	GapY        float64
+}
+
+// Templates are common label papers by name.
+var Templates = map[string]Template{
+	// 21 labels per A4 sheet.
+	"avery-l7160": {
+		PageWidth: 210, PageHeight: 297,
+		Columns: 3, Rows: 7,
+		MarginTop: 15.15, MarginLeft: 7.2,
+		LabelWidth: 63.5, LabelHeight: 38.1,
+		GapX: 2.5,
+	},
+	// 14 labels per A4 sheet.
+	"avery-l7163": {
+		PageWidth: 210, PageHeight: 297,
+		Columns: 2, Rows: 7,
+		MarginTop: 15.15, MarginLeft: 4.65,
+		LabelWidth: 99.1, LabelHeight: 38.1,
+		GapX: 2.5,
+	},
+	// 65 labels per A4 sheet.
+	"avery-l7651": {
+		PageWidth: 210, PageHeight: 297,
+		Columns: 5, Rows: 13,
+		MarginTop: 10.7, MarginLeft: 4.7,
+		LabelWidth: 38.1, LabelHeight: 21.2,
+		GapX: 2.5,
+	},
+	// 30 labels per US letter sheet.
+	"avery-5160": {
+		PageWidth: 215.9, PageHeight: 279.4,
+		Columns: 3, Rows: 10,
+		MarginTop: 12.7, MarginLeft: 4.8,
+		LabelWidth: 66.7, LabelHeight: 25.4,
+		GapX: 3.2,
+	},
+}
+
+// DefaultTemplate is used when no template is chosen.
+const DefaultTemplate = "avery-l7160"
+
+// TemplateNames returns the names of all templates, sorted.
+func TemplateNames() []string {
+	names := make([]string, 0, len(Templates))
+	for name := range Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the labels fit on the page.
+func (t Template) Validate() error {
+	if t.Columns < 1 || t.Rows < 1 {
+		return fmt.Errorf("columns and rows must be at least 1")
+	}
+	if t.LabelWidth <= 0 || t.LabelHeight <= 0 {
+		return fmt.Errorf("label size must be positive")
+	}
+	if t.MarginTop < 0 || t.MarginLeft < 0 || t.GapX < 0 || t.GapY < 0 {
+		return fmt.Errorf("margins and gaps must not be negative")
+	}
+
+	// Allow for rounding in the published template sizes.
+	const slack = 0.5
+	width := t.MarginLeft + float64(t.Columns)*t.LabelWidth + float64(t.Columns-1)*t.GapX
+	if width > t.PageWidth+slack {
+		return fmt.Errorf("%d columns need %.1fmm but the page is %.1fmm wide", t.Columns, width, t.PageWidth)
+	}
+	height := t.MarginTop + float64(t.Rows)*t.LabelHeight + float64(t.Rows-1)*t.GapY
+	if height > t.PageHeight+slack {
+		return fmt.Errorf("%d rows need %.1fmm but the page is %.1fmm high", t.Rows, height, t.PageHeight)
+	}
+	return nil
+}
+
+// PerPage is the number of labels on one sheet.
+func (t Template) PerPage() int {
+	return t.Columns * t.Rows
+}
+
+// Pages is the number of sheets needed for n labels.
+func (t Template) Pages(n int) int {
+	if n == 0 {
+		return 1
+	}
+	return (n + t.PerPage() - 1) / t.PerPage()
+}
+
+// Config selects a template and overrides parts of it. Zero values keep the
+// template's own settings. The margins and gaps are nil to keep them, as 0
+// is a setting of its own for paper with labels up to the edges.
+type Config struct {
+	Template    string   `query:"template"`
+	Columns     int      `query:"columns"`
+	Rows        int      `query:"rows"`
+	MarginTop   *float64 `query:"margin_top"`
+	MarginLeft  *float64 `query:"margin_left"`
+	LabelWidth  float64  `query:"label_width"`
+	LabelHeight float64  `query:"label_height"`
+	GapX        *float64 `query:"gap_x"`
+	GapY        *float64 `query:"gap_y"`
+	DPI         float64  `query:"dpi"`
+}
+
+// DefaultDPI is the printer resolution used when none is configured.
+const DefaultDPI = 300
+
+// Options resolves the config into the options for writing a sheet.
+func (c Config) Options() (Options, error) {
+	name := c.Template
+	if name == "" {
+		name = DefaultTemplate
+	}
+	t, ok := Templates[name]
+	if !ok {
+		return Options{}, fmt.Errorf("unknown template %q, choose one of %s", name, strings.Join(TemplateNames(), ", "))
+	}
+
+	if c.Columns != 0 {
+		t.Columns = c.Columns
+	}
+	if c.Rows != 0 {
+		t.Rows = c.Rows
+	}
+	if c.MarginTop != nil {
+		t.MarginTop = *c.MarginTop
+	}
+	if c.MarginLeft != nil {
+		t.MarginLeft = *c.MarginLeft
+	}
+	if c.LabelWidth != 0 {
+		t.LabelWidth = c.LabelWidth
+	}
+	if c.LabelHeight != 0 {
+		t.LabelHeight = c.LabelHeight
+	}
+	if c.GapX != nil {
+		t.GapX = *c.GapX
+	}
+	if c.GapY != nil {
+		t.GapY = *c.GapY
+	}
+	if err := t.Validate(); err != nil {
+		return Options{}, err
+	}
+
+	dpi := c.DPI
+	if dpi == 0 {
+		dpi = DefaultDPI
+	}
+	if dpi < 72 {
+		return Options{}, fmt.Errorf("dpi must be at least 72")
+	}
+
+	return Options{Template: t, DPI: dpi}, nil
+}
+
+// Options control how labels are drawn.
+type Options struct {
+	Template Template
+	DPI      float64
+}
+
+// Label is the content of one label.
+type Label struct {
+	Title    string
+	Subtitle string
+	// Barcode is printed as an EAN if it is 8 or 13 digits, including the
+	// check digit, and as Code 128 otherwise.
+	Barcode string
+	// QR is printed as a QR code next to the barcode if set.
+	QR string
+}
+
+// ShelfLabels returns a label for every row of the shelves. Shelf 0, where
+// books without a shelf go, has no labels.
+//
+// Rows that fit in an EAN-8 keep it, so that reprinted labels match the old
+// ones; other rows get a versioned location code. The QR code always has the
+// versioned location code.
+func ShelfLabels(shelves []models.Shelf, withQR bool) []Label {
+	labels := []Label{}
+	for _, shelf := range shelves {
+		if shelf.ID == 0 {
+			continue
+		}
+		for row := 1; row <= shelf.RowCount; row++ {
+			location := scancode.LocationCode(shelf.ID, row, 0)
+			label := Label{
+				Title:    shelf.Name,
+				Subtitle: fmt.Sprintf("Row %d", row),
+				Barcode:  location,
+			}
+			if shelf.ID <= scancode.MaxShelfCodeID && row <= scancode.MaxShelfCodeRow {
+				label.Barcode = scancode.ShelfCode(shelf.ID, row)
+			}
+			if withQR {
+				label.QR = location
+			}
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// controlTitles are the titles of the control code labels.
+var controlTitles = map[scancode.Command]string{
+	scancode.CommandCatalogueMode: "Add books",
+	scancode.CommandDeleteMode:    "Delete books",
+	scancode.CommandMoveMode:      "Move books here",
+	scancode.CommandAuditMode:     "Audit a row",
+	scancode.CommandReshelveMode:  "Reshelve",
+	scancode.CommandReturnMode:    "Return books",
+	scancode.CommandUndo:          "Undo last scan",
+	scancode.CommandEndSession:    "End session",
+}
+
+// ControlLabels returns a label for every control code, which switch the
+// scanner between modes. The QR code has the same control code.
+func ControlLabels(withQR bool) []Label {
+	labels := []Label{}
+	for _, c := range scancode.ControlCommands() {
+		code := scancode.ControlCode(c)
+		label := Label{Title: controlTitles[c], Subtitle: "Scanner control", Barcode: code}
+		if withQR {
+			label.QR = code
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// canvas is a page that can be drawn on in millimetres.
+type canvas interface {
+	// rect fills a black rectangle.
+	rect(x, y, w, h float64)
+	// text draws black text with its baseline at y.
+	text(x, y, size float64, s string)
+}
+
+// drawPage draws the labels of one page.
+func drawPage(c canvas, labels []Label, opts Options) error {
+	t := opts.Template
+	for i, label := range labels {
+		col := i % t.Columns
+		row := i / t.Columns
+		x := t.MarginLeft + float64(col)*(t.LabelWidth+t.GapX)
+		y := t.MarginTop + float64(row)*(t.LabelHeight+t.GapY)
+
+		if err := drawLabel(c, label, x, y, t.LabelWidth, t.LabelHeight, opts.DPI); err != nil {
+			return fmt.Errorf("label %q: %w", label.Barcode, err)
+		}
+	}
+	return nil
+}
+
+// pageLabels returns the labels on the given page, counting from 0.
+func pageLabels(labels []Label, t Template, page int) []Label {
+	start := page * t.PerPage()
+	end := min(start+t.PerPage(), len(labels))
+	if start >= end {
+		return nil
+	}
+	return labels[start:end]
+}
+
+func drawLabel(c canvas, label Label, x, y, w, h, dpi float64) error {
+	dot := 25.4 / dpi
+
+	pad := min(2, h*0.08)
+	x, y, w, h = x+pad, y+pad, w-2*pad, h-2*pad
+
+	// The QR code sits on the right and the rest of the label on the left.
+	if label.QR != "" {
+		code, err := qr.Encode(label.QR, qr.M, qr.Auto)
+		if err != nil {
+			return err
+		}
+		size := min(h, w*0.4)
+		// Keep a quiet zone of 2 modules, scanners cope with less than the
+		// 4 the standard asks for.
+		modules := code.Bounds().Dx() + 4
+		module := snap(size/float64(modules), dot)
+		size = module * float64(modules)
+		drawMatrix(c, code, x+w-size+2*module, y+(h-size)/2+2*module, module)
+		w -= size + pad
+	}
+
+	titleSize := h * 0.2
+	subtitleSize := titleSize * 0.75
+	digitSize := subtitleSize * 0.8
+	gap := h * 0.04
+
+	c.text(x, y+titleSize*0.8, fitText(label.Title, titleSize, w), label.Title)
+	c.text(x, y+titleSize+gap+subtitleSize*0.8, fitText(label.Subtitle, subtitleSize, w), label.Subtitle)
+
+	code, err := encodeBarcode(label.Barcode)
+	if err != nil {
+		return err
+	}
+	barsTop := y + titleSize + subtitleSize + 2*gap
+	barsHeight := y + h - barsTop - digitSize*1.2
+	if barsHeight <= 0 {
+		return fmt.Errorf("label is too small")
+	}
+
+	// EAN asks for a quiet zone of 7 modules and Code 128 for 10 on both
+	// sides, but the label padding provides part of it.
+	quiet := 5
+	modules := code.Bounds().Dx() + 2*quiet
+	module := min(snap(w/float64(modules), dot), snap(0.5, dot))
+	drawBars(c, code, x+float64(quiet)*module, barsTop, module, barsHeight)
+	c.text(x+float64(quiet)*module, y+h, fitText(label.Barcode, digitSize, w), label.Barcode)
+
+	return nil
+}
+
+func encodeBarcode(s string) (barcode.Barcode, error) {
+	if isDigits(s) && (len(s) == 8 || len(s) == 13) {
+		return ean.Encode(s)
+	}
+	return code128.Encode(s)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// drawBars draws a one dimensional barcode, merging adjacent bars.
+func drawBars(c canvas, code barcode.Barcode, x, y, module, height float64) {
+	b := code.Bounds()
+	start := -1
+	for i := b.Min.X; i <= b.Max.X; i++ {
+		black := i < b.Max.X && isBlack(code, i, b.Min.Y)
+		if black && start < 0 {
+			start = i
+		}
+		if !black && start >= 0 {
+			c.rect(x+float64(start-b.Min.X)*module, y, float64(i-start)*module, height)
+			start = -1
+		}
+	}
+}
+
+// drawMatrix draws a two dimensional barcode, merging adjacent modules in a
+// row.
+func drawMatrix(c canvas, code barcode.Barcode, x, y, module float64) {
+	b := code.Bounds()
+	for j := b.Min.Y; j < b.Max.Y; j++ {
+		start := -1
+		for i := b.Min.X; i <= b.Max.X; i++ {
+			black := i < b.Max.X && isBlack(code, i, j)
+			if black && start < 0 {
+				start = i
+			}
+			if !black && start >= 0 {
+				c.rect(x+float64(start-b.Min.X)*module, y+float64(j-b.Min.Y)*module, float64(i-start)*module, module)
+				start = -1
+			}
+		}
+	}
+}
+
+func isBlack(img image.Image, x, y int) bool {
+	r, _, _, _ := img.At(x, y).RGBA()
+	return r < 0x8000
+}
+
+// snap rounds size down to whole printer dots, but never below one dot.
+func snap(size, dot float64) float64 {
+	dots := float64(int(size / dot))
+	return max(dots, 1) * dot
+}
+
+// fitText shrinks the font size until s fits in width. The width is an
+// estimate for Helvetica.
+func fitText(s string, size, width float64) float64 {
+	n := float64(len([]rune(s)))
+	if n == 0 {
+		return size
+	}
+	return min(size, width/(n*0.55))
+}
@@ -0,0 +1,202 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
+)
+
+var testShelves = []models.Shelf{
+	{ID: 0, Name: "unknown"},
+	{ID: 1, Name: "office-big", RowCount: 6},
+	{ID: 2, Name: "home-bedroom-right", RowCount: 9},
+	{ID: 3, Name: "hallway (left)", RowCount: 9},
+}
+
+// recordingCanvas keeps everything drawn on it.
+type recordingCanvas struct {
+	rects [][4]float64
+	texts []string
+}
+
+func (c *recordingCanvas) rect(x, y, w, h float64) {
+	c.rects = append(c.rects, [4]float64{x, y, w, h})
+}
+
+func (c *recordingCanvas) text(x, y, size float64, s string) {
+	c.texts = append(c.texts, s)
+}
+
+func TestConfigOptions(t *testing.T) {
+	opts, err := Config{}.Options()
+	if err != nil {
+		t.Fatalf("Options() returned error: %v", err)
+	}
+	if opts.Template != Templates[DefaultTemplate] || opts.DPI != DefaultDPI {
+		t.Errorf("unexpected default options: %+v", opts)
+	}
+
+	marginTop, noGap := 20.0, 0.0
+	opts, err = Config{Template: "avery-5160", Rows: 5, MarginTop: &marginTop, GapX: &noGap, DPI: 600}.Options()
+	if err != nil {
+		t.Fatalf("Options() returned error: %v", err)
+	}
+	if opts.Template.Rows != 5 || opts.Template.MarginTop != 20 || opts.Template.GapX != 0 || opts.Template.Columns != 3 || opts.DPI != 600 {
+		t.Errorf("overrides not applied: %+v", opts)
+	}
+
+	for _, c := range []Config{
+		{Template: "nope"},
+		{Columns: 4},
+		{Rows: 8},
+		{DPI: 10},
+	} {
+		if _, err := c.Options(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+
+	for name, tmpl := range Templates {
+		if err := tmpl.Validate(); err != nil {
+			t.Errorf("template %s is invalid: %v", name, err)
+		}
+	}
+}
+
+func TestShelfLabels(t *testing.T) {
+	labels := ShelfLabels(testShelves, false)
+	if len(labels) != 24 {
+		t.Fatalf("expected 24 labels, got %d", len(labels))
+	}
+	if labels[0] != (Label{Title: "office-big", Subtitle: "Row 1", Barcode: "00000116"}) {
+		t.Errorf("unexpected first label: %+v", labels[0])
+	}
+
+	labels = ShelfLabels(testShelves, true)
+	if labels[0].QR != "LS:L:1:1" {
+		t.Errorf("expected QR code with the location code, got %+v", labels[0])
+	}
+
+	// Rows past 9 do not fit in an EAN-8.
+	labels = ShelfLabels([]models.Shelf{{ID: 4, Name: "cellar", RowCount: 12}}, false)
+	if labels[8].Barcode != "00000499" || labels[11].Barcode != "LS:L:4:12" {
+		t.Errorf("unexpected barcodes: %q, %q", labels[8].Barcode, labels[11].Barcode)
+	}
+	c := &recordingCanvas{}
+	if err := drawPage(c, labels, Options{Template: Templates[DefaultTemplate], DPI: DefaultDPI}); err != nil {
+		t.Fatalf("drawPage() returned error: %v", err)
+	}
+}
+
+func TestControlLabels(t *testing.T) {
+	labels := ControlLabels(true)
+	if len(labels) != len(scancode.ControlCommands()) {
+		t.Fatalf("expected a label per control code, got %d", len(labels))
+	}
+	for _, label := range labels {
+		code := scancode.Parse(label.Barcode)
+		if code.Kind != scancode.CommandCard || label.QR != label.Barcode || label.Title == "" {
+			t.Errorf("unexpected label: %+v", label)
+		}
+	}
+	c := &recordingCanvas{}
+	if err := drawPage(c, labels, Options{Template: Templates[DefaultTemplate], DPI: DefaultDPI}); err != nil {
+		t.Fatalf("drawPage() returned error: %v", err)
+	}
+}
+
+func TestDrawPageSnapsToDots(t *testing.T) {
+	for _, dpi := range []float64{203, 300, 600} {
+		t.Run(fmt.Sprint(dpi), func(t *testing.T) {
+			opts, err := Config{Template: "avery-l7651", DPI: dpi}.Options()
+			if err != nil {
+				t.Fatalf("Options() returned error: %v", err)
+			}
+
+			c := &recordingCanvas{}
+			if err := drawPage(c, ShelfLabels(testShelves, true), opts); err != nil {
+				t.Fatalf("drawPage() returned error: %v", err)
+			}
+
+			dot := 25.4 / dpi
+			for _, r := range c.rects {
+				if dots := r[2] / dot; math.Abs(dots-math.Round(dots)) > 1e-6 {
+					t.Fatalf("bar width %f is not a whole number of dots", r[2])
+				}
+			}
+			if !strings.Contains(strings.Join(c.texts, "\n"), "00000116") {
+				t.Errorf("expected the shelf code to be printed, got %v", c.texts)
+			}
+		})
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	opts, err := Config{}.Options()
+	if err != nil {
+		t.Fatalf("Options() returned error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, ShelfLabels(testShelves, true), opts); err != nil {
+		t.Fatalf("WritePDF() returned error: %v", err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("not a PDF:\n%s", pdf)
+	}
+	// 24 labels at 21 per sheet.
+	if !strings.Contains(pdf, "/Count 2 >>") {
+		t.Errorf("expected 2 pages")
+	}
+	if !strings.Contains(pdf, "(hallway \\(left\\)) Tj") {
+		t.Errorf("expected escaped shelf name in PDF")
+	}
+
+	// Every xref entry must point at the start of its object.
+	xref := regexp.MustCompile(`(?s)startxref\n(\d+)`).FindStringSubmatch(pdf)
+	start, _ := strconv.Atoi(xref[1])
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[start:], -1)
+	if len(entries) != 7 {
+		t.Fatalf("expected 7 objects, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(pdf[offset:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	opts, err := Config{}.Options()
+	if err != nil {
+		t.Fatalf("Options() returned error: %v", err)
+	}
+	labels := ShelfLabels(testShelves, false)
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, labels, opts, 2); err != nil {
+		t.Fatalf("WriteSVG() returned error: %v", err)
+	}
+	svg := buf.String()
+	if !strings.Contains(svg, `width="210mm" height="297mm"`) {
+		t.Errorf("expected an A4 page")
+	}
+	// The second page has the last 3 labels.
+	if strings.Count(svg, "Row ") != 3 || !strings.Contains(svg, "hallway (left)") {
+		t.Errorf("unexpected second page:\n%s", svg)
+	}
+
+	if err := WriteSVG(&buf, labels, opts, 3); err == nil {
+		t.Errorf("expected error for page out of range")
+	}
+}
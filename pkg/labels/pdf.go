@@ -0,0 +1,89 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ptPerMM converts millimetres to PDF points.
+const ptPerMM = 72 / 25.4
+
+// WritePDF writes all labels as a PDF with one page per sheet. The PDF only
+// uses vector drawing and the built-in Helvetica font, so it prints sharply
+// at any resolution.
+func WritePDF(w io.Writer, labels []Label, opts Options) error {
+	t := opts.Template
+	pages := t.Pages(len(labels))
+
+	var buf bytes.Buffer
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 3 are the catalog, the page tree and the font. Each page
+	// is followed by its content stream.
+	kids := &bytes.Buffer{}
+	for i := range pages {
+		fmt.Fprintf(kids, "%d 0 R ", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids.Bytes()), pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i := range pages {
+		c := &pdfCanvas{pageHeight: t.PageHeight}
+		if err := drawPage(c, pageLabels(labels, t, i), opts); err != nil {
+			return err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			t.PageWidth*ptPerMM, t.PageHeight*ptPerMM, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", c.buf.Len(), c.buf.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type pdfCanvas struct {
+	buf        bytes.Buffer
+	pageHeight float64
+}
+
+func (c *pdfCanvas) rect(x, y, w, h float64) {
+	fmt.Fprintf(&c.buf, "%.3f %.3f %.3f %.3f re f\n", x*ptPerMM, (c.pageHeight-y-h)*ptPerMM, w*ptPerMM, h*ptPerMM)
+}
+
+func (c *pdfCanvas) text(x, y, size float64, s string) {
+	fmt.Fprintf(&c.buf, "BT /F1 %.2f Tf %.3f %.3f Td (%s) Tj ET\n", size*ptPerMM, x*ptPerMM, (c.pageHeight-y)*ptPerMM, pdfString(s))
+}
+
+// pdfString escapes s for a PDF string literal in WinAnsiEncoding. Characters
+// outside Latin-1 are replaced with "?".
+func pdfString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package labels
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// WriteSVG writes one sheet of labels as an SVG. Pages are numbered from 1.
+func WriteSVG(w io.Writer, labels []Label, opts Options, page int) error {
+	t := opts.Template
+	if page < 1 || page > t.Pages(len(labels)) {
+		return fmt.Errorf("page %d out of range, there are %d pages", page, t.Pages(len(labels)))
+	}
+
+	c := &svgCanvas{}
+	if err := drawPage(c, pageLabels(labels, t, page-1), opts); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%gmm" height="%gmm" viewBox="0 0 %g %g">
+<rect width="100%%" height="100%%" fill="white"/>
+<g fill="black" font-family="Helvetica, Arial, sans-serif">
+%s</g>
+</svg>
+`, t.PageWidth, t.PageHeight, t.PageWidth, t.PageHeight, c.buf.String())
+	return err
+}
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64) {
+	fmt.Fprintf(&c.buf, "<rect x=\"%.3f\" y=\"%.3f\" width=\"%.3f\" height=\"%.3f\"/>\n", x, y, w, h)
+}
+
+func (c *svgCanvas) text(x, y, size float64, s string) {
+	fmt.Fprintf(&c.buf, "<text x=\"%.3f\" y=\"%.3f\" font-size=\"%.3f\">", x, y, size)
+	_ = xml.EscapeText(&c.buf, []byte(s))
+	c.buf.WriteString("</text>\n")
+}
@@ -0,0 +1,163 @@
+// Package locations builds the tree of places books are kept in, from
+// buildings and rooms down to bookcases, rows and boxes.
+//
+// Every shelf has a bookcase location with one row location per row, linked
+// back to the shelf. Books scanned onto a shelf row are attached to its row
+// location, so shelf codes and the tree always agree.
+package locations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/db"
+)
+
+// Types are the kinds of location, roughly from the largest to the smallest.
+var Types = []string{"building", "room", "bookcase", "row", "box"}
+
+// Separator joins the names in a breadcrumb path.
+const Separator = " › "
+
+// ValidType reports whether t is one of Types.
+func ValidType(t string) bool {
+	return slices.Contains(Types, t)
+}
+
+// Tree is an in-memory copy of the locations table.
+type Tree struct {
+	nodes    map[int64]db.Location
+	children map[int64][]int64
+}
+
+// New builds a tree from all locations. Locations whose parent is missing are
+// treated as roots.
+func New(nodes []db.Location) *Tree {
+	t := &Tree{
+		nodes:    make(map[int64]db.Location, len(nodes)),
+		children: map[int64][]int64{},
+	}
+	for _, n := range nodes {
+		t.nodes[n.ID] = n
+	}
+	for _, n := range nodes {
+		if n.ParentID.Valid {
+			t.children[n.ParentID.Int64] = append(t.children[n.ParentID.Int64], n.ID)
+		}
+	}
+	return t
+}
+
+// Get returns the location with the given id.
+func (t *Tree) Get(id int64) (db.Location, bool) {
+	n, ok := t.nodes[id]
+	return n, ok
+}
+
+// IsLeaf reports whether the location has no sub-locations.
+func (t *Tree) IsLeaf(id int64) bool {
+	return len(t.children[id]) == 0
+}
+
+// Ancestors returns the location and its parents, starting from the root.
+func (t *Tree) Ancestors(id int64) []db.Location {
+	var path []db.Location
+	seen := map[int64]bool{}
+	for {
+		n, ok := t.nodes[id]
+		if !ok || seen[id] {
+			break
+		}
+		seen[id] = true
+		path = append(path, n)
+		if !n.ParentID.Valid {
+			break
+		}
+		id = n.ParentID.Int64
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// Path returns the names of the location and its parents, starting from the
+// root. It is nil for unknown locations.
+func (t *Tree) Path(id int64) []string {
+	var path []string
+	for _, n := range t.Ancestors(id) {
+		path = append(path, n.Name)
+	}
+	return path
+}
+
+// Breadcrumb returns the path as a single string, e.g.
+// "Home › Living room › home-living-room › Row 2".
+func (t *Tree) Breadcrumb(id int64) string {
+	return strings.Join(t.Path(id), Separator)
+}
+
+// IsDescendant reports whether id is ancestor or lies below it.
+func (t *Tree) IsDescendant(id, ancestor int64) bool {
+	for _, n := range t.Ancestors(id) {
+		if n.ID == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Shelf returns the shelf and row of the closest location at or above id that
+// belongs to a shelf. Both are 0 when the location is not on a shelf.
+func (t *Tree) Shelf(id int64) (shelfID, row int) {
+	ancestors := t.Ancestors(id)
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		if n := ancestors[i]; n.ShelfID.Valid {
+			return int(n.ShelfID.Int64), db.NullInt64ToInt(n.RowNumber)
+		}
+	}
+	return 0, 0
+}
+
+// RollUp adds the counts of every location to all of its parents, so the
+// count of a building includes every room, bookcase and box in it.
+func (t *Tree) RollUp(counts map[int64]int) map[int64]int {
+	total := make(map[int64]int, len(t.nodes))
+	for id, n := range counts {
+		for _, a := range t.Ancestors(id) {
+			total[a.ID] += n
+		}
+	}
+	return total
+}
+
+// PositionOnRow describes where the n-th of total books on a row is, counting
+// from whichever end is closer, e.g. "about 12th from the left". It is empty
+// when the book is alone on the row.
+func PositionOnRow(n, total int) string {
+	switch {
+	case n < 1 || total < 2 || n > total:
+		return ""
+	case n == 1:
+		return "at the left end"
+	case n == total:
+		return "at the right end"
+	case n <= (total+1)/2:
+		return "about " + ordinal(n) + " from the left"
+	default:
+		return "about " + ordinal(total-n+1) + " from the right"
+	}
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
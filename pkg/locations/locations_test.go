@@ -0,0 +1,122 @@
+package locations
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gouthamve/librascan/pkg/db"
+)
+
+func testTree() *Tree {
+	parent := func(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: true} }
+	return New([]db.Location{
+		{ID: 1, Type: "building", Name: "Home"},
+		{ID: 2, ParentID: parent(1), Type: "room", Name: "Living room"},
+		{ID: 3, ParentID: parent(2), Type: "bookcase", Name: "home-living-room", ShelfID: parent(3)},
+		{ID: 4, ParentID: parent(3), Type: "row", Name: "Row 1", ShelfID: parent(3), RowNumber: parent(1)},
+		{ID: 5, ParentID: parent(4), Type: "box", Name: "Comics"},
+		{ID: 6, Type: "building", Name: "Office"},
+		// A parent that does not exist makes a root.
+		{ID: 7, ParentID: parent(99), Type: "box", Name: "Lost"},
+	})
+}
+
+func TestPath(t *testing.T) {
+	tree := testTree()
+
+	if diff := cmp.Diff([]string{"Home", "Living room", "home-living-room", "Row 1", "Comics"}, tree.Path(5)); diff != "" {
+		t.Errorf("unexpected path (-want +got):\n%s", diff)
+	}
+	if got := tree.Breadcrumb(3); got != "Home › Living room › home-living-room" {
+		t.Errorf("unexpected breadcrumb: %q", got)
+	}
+	if diff := cmp.Diff([]string{"Lost"}, tree.Path(7)); diff != "" {
+		t.Errorf("unexpected path (-want +got):\n%s", diff)
+	}
+	if got := tree.Path(42); len(got) != 0 {
+		t.Errorf("expected an empty path for an unknown location, got %v", got)
+	}
+}
+
+func TestShelf(t *testing.T) {
+	tree := testTree()
+
+	tests := []struct {
+		id            int64
+		shelfID, row  int
+		leaf, present bool
+	}{
+		{id: 1, present: true},
+		{id: 3, shelfID: 3, present: true},
+		{id: 4, shelfID: 3, row: 1, present: true},
+		// Boxes on a row are on that row's shelf.
+		{id: 5, shelfID: 3, row: 1, leaf: true, present: true},
+		{id: 6, leaf: true, present: true},
+		{id: 42, leaf: true},
+	}
+	for _, tt := range tests {
+		shelfID, row := tree.Shelf(tt.id)
+		if shelfID != tt.shelfID || row != tt.row {
+			t.Errorf("Shelf(%d) = %d, %d, want %d, %d", tt.id, shelfID, row, tt.shelfID, tt.row)
+		}
+		if got := tree.IsLeaf(tt.id); got != tt.leaf {
+			t.Errorf("IsLeaf(%d) = %v, want %v", tt.id, got, tt.leaf)
+		}
+		if _, ok := tree.Get(tt.id); ok != tt.present {
+			t.Errorf("Get(%d) found = %v, want %v", tt.id, ok, tt.present)
+		}
+	}
+}
+
+func TestIsDescendant(t *testing.T) {
+	tree := testTree()
+
+	if !tree.IsDescendant(5, 1) || !tree.IsDescendant(5, 5) {
+		t.Error("expected the box to be below home and itself")
+	}
+	if tree.IsDescendant(1, 5) || tree.IsDescendant(5, 6) {
+		t.Error("unexpected descendant")
+	}
+}
+
+func TestRollUp(t *testing.T) {
+	tree := testTree()
+
+	got := tree.RollUp(map[int64]int{3: 1, 4: 2, 5: 3, 6: 4})
+	want := map[int64]int{1: 6, 2: 6, 3: 6, 4: 5, 5: 3, 6: 4}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected counts (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidType(t *testing.T) {
+	if !ValidType("room") || ValidType("garage") {
+		t.Error("unexpected type validation")
+	}
+}
+
+func TestPositionOnRow(t *testing.T) {
+	tests := []struct {
+		n, total int
+		want     string
+	}{
+		{n: 1, total: 1, want: ""},
+		{n: 0, total: 5, want: ""},
+		{n: 1, total: 40, want: "at the left end"},
+		{n: 2, total: 40, want: "about 2nd from the left"},
+		{n: 12, total: 40, want: "about 12th from the left"},
+		{n: 20, total: 39, want: "about 20th from the left"},
+		{n: 21, total: 40, want: "about 20th from the right"},
+		{n: 38, total: 40, want: "about 3rd from the right"},
+		{n: 40, total: 40, want: "at the right end"},
+		{n: 11, total: 30, want: "about 11th from the left"},
+		{n: 21, total: 50, want: "about 21st from the left"},
+	}
+	for _, tt := range tests {
+		if got := PositionOnRow(tt.n, tt.total); got != tt.want {
+			t.Errorf("PositionOnRow(%d, %d) = %q, want %q", tt.n, tt.total, got, tt.want)
+		}
+	}
+}
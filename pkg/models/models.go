@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type GoogleBooksResponse struct {
 	Kind       string       `json:"kind"`
 	TotalItems int          `json:"totalItems"`
@@ -101,6 +103,9 @@ type OpenLibraryBook struct {
 		Medium string `json:"medium"`
 		Large  string `json:"large"`
 	} `json:"cover"`
+	Classifications struct {
+		DeweyDecimalClass []string `json:"dewey_decimal_class"`
+	} `json:"classifications"`
 }
 
 type DebugResponse struct {
@@ -120,24 +125,373 @@ type Book struct {
 	Pages         int      `json:"pages"`
 	Language      string   `json:"language"`
 	CoverURL      string   `json:"cover_url"`
+	// Classification is the Dewey Decimal class, when Open Library knows it.
+	Classification string `json:"classification,omitempty"`
+	// PriceSupplement is the add-on scanned after the ISBN, and Price the
+	// price in it if it has one.
+	PriceSupplement string `json:"price_supplement,omitempty"`
+	Price           *Price `json:"price,omitempty"`
+	// Copies is how many copies of the book the library has. They are all
+	// kept in the book's place.
+	Copies int `json:"copies,omitempty"`
 
 	ShelfID   int    `json:"shelf_id"`
 	ShelfName string `json:"shelf_name"`
 	RowNumber int    `json:"row_number"`
+	Slot      int    `json:"slot,omitempty"`
+	// Position is the order the book was scanned onto its row in. Books
+	// scanned later are further to the right.
+	Position int `json:"position,omitempty"`
+
+	LocationID int `json:"location_id,omitempty"`
+	// LocationPath is the breadcrumb of the book's location, starting from
+	// the root. It is empty when the location is not known.
+	LocationPath []string `json:"location_path"`
 }
 
 type Shelf struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	RowCount int    `json:"rows_count"`
+
+	// Books and Rows are only filled in when listing shelves.
+	Books int        `json:"books,omitempty"`
+	Rows  []ShelfRow `json:"rows,omitempty"`
+}
+
+// ShelfRow is the number of books on one row of a shelf. Row 0 holds the
+// books whose row is not known.
+type ShelfRow struct {
+	Row   int `json:"row"`
+	Books int `json:"books"`
+}
+
+// ShelfRequest creates or updates a shelf. Fields left out of a PATCH are
+// not changed.
+type ShelfRequest struct {
+	Name     *string `json:"name"`
+	RowCount *int    `json:"rows_count"`
+}
+
+// Location is a place books are kept in. Locations form a tree, e.g. a
+// building holding rooms, bookcases, rows and boxes.
+type Location struct {
+	ID       int      `json:"id"`
+	ParentID int      `json:"parent_id,omitempty"`
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Path     []string `json:"path"`
+
+	// ShelfID and Row are set on the locations that stand for a shelf or one
+	// of its rows.
+	ShelfID int `json:"shelf_id,omitempty"`
+	Row     int `json:"row,omitempty"`
+
+	// Books counts the books in this location and everywhere below it.
+	Books int `json:"books"`
+
+	// LastAuditedAt is when the location was last checked by an audit.
+	LastAuditedAt *time.Time `json:"last_audited_at,omitempty"`
+}
+
+// LocationRequest creates or updates a location. A parent_id of 0 moves the
+// location to the top of the tree. Fields left out of a PATCH are not
+// changed.
+type LocationRequest struct {
+	ParentID *int    `json:"parent_id"`
+	Type     *string `json:"type"`
+	Name     *string `json:"name"`
+}
+
+// What adding a scanned book did.
+const (
+	// AddOutcomeAdded is a book that was not catalogued yet.
+	AddOutcomeAdded = "added"
+	// AddOutcomeAlreadyHere is a book scanned where it already is.
+	AddOutcomeAlreadyHere = "already_here"
+	// AddOutcomeMoved is a book scanned somewhere else than where it was,
+	// which moves it there.
+	AddOutcomeMoved = "moved"
+	// AddOutcomeNewCopy is another copy of a catalogued book, added with
+	// copy=true. It is counted with the book and stays in the book's place.
+	AddOutcomeNewCopy = "new_copy"
+)
+
+// Price is a price printed on a book. Amount is a decimal, e.g. "12.99".
+type Price struct {
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
+}
+
+// AddedBook is a scanned book and what adding it did. Books that are already
+// catalogued are not looked up again.
+type AddedBook struct {
+	Book
+	Outcome string `json:"outcome"`
+	// MovedFrom is where a moved book was before.
+	MovedFrom *BookPlace `json:"moved_from,omitempty"`
+}
+
+// BookPlace is a place on a shelf row.
+type BookPlace struct {
+	ShelfID      int      `json:"shelf_id,omitempty"`
+	ShelfName    string   `json:"shelf_name,omitempty"`
+	RowNumber    int      `json:"row_number,omitempty"`
+	Slot         int      `json:"slot,omitempty"`
+	LocationPath []string `json:"location_path,omitempty"`
+}
+
+// BookLocator says where to find a book on its shelf.
+type BookLocator struct {
+	ISBN         int      `json:"isbn"`
+	Title        string   `json:"title"`
+	ShelfID      int      `json:"shelf_id,omitempty"`
+	ShelfName    string   `json:"shelf_name,omitempty"`
+	RowNumber    int      `json:"row_number,omitempty"`
+	Slot         int      `json:"slot,omitempty"`
+	LocationPath []string `json:"location_path"`
+	// Position counts the books on the row from the left, skipping books
+	// that are lent out. RowBooks is the number of books counted.
+	Position int    `json:"position,omitempty"`
+	RowBooks int    `json:"row_books,omitempty"`
+	Borrower string `json:"borrower,omitempty"`
+	// Description is a short sentence such as "office-big, row 3, about
+	// 12th from the left".
+	Description string `json:"description"`
+}
+
+// RowOrderRequest lists the books on a row from left to right.
+type RowOrderRequest struct {
+	ISBNs []int `json:"isbns"`
+}
+
+// Audit is a check of the books on a shelf row or another location.
+type Audit struct {
+	ID           int        `json:"id"`
+	LocationID   int        `json:"location_id"`
+	LocationPath []string   `json:"location_path"`
+	StartedAt    time.Time  `json:"started_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	AppliedAt    *time.Time `json:"applied_at,omitempty"`
+}
+
+// AuditRequest starts an audit, either from a scanned location code or a
+// location id.
+type AuditRequest struct {
+	Location   string `json:"location,omitempty"`
+	LocationID int    `json:"location_id,omitempty"`
+}
+
+// AuditScanRequest records a book found during an audit.
+type AuditScanRequest struct {
+	ISBN int `json:"isbn"`
+}
+
+// AuditScan is the outcome of one scan during an audit.
+type AuditScan struct {
+	ISBN int `json:"isbn"`
+	// Status is one of "found", "misplaced" or "unknown".
+	Status   string     `json:"status"`
+	Book     *AuditBook `json:"book,omitempty"`
+	Borrowed bool       `json:"borrowed"`
+}
+
+// AuditReport compares the books scanned during an audit with the books
+// recorded in its location. Books on loan are listed in Borrowed and not
+// counted as missing.
+type AuditReport struct {
+	Audit
+	Found       []AuditBook       `json:"found"`
+	Missing     []AuditBook       `json:"missing"`
+	Misplaced   []AuditBook       `json:"misplaced"`
+	Unknown     []int             `json:"unknown"`
+	Borrowed    []AuditBook       `json:"borrowed"`
+	Corrections []AuditCorrection `json:"corrections"`
+}
+
+// AuditBook is a book in an audit report, with the location it is recorded
+// in.
+type AuditBook struct {
+	ISBN         int      `json:"isbn"`
+	Title        string   `json:"title"`
+	LocationPath []string `json:"location_path"`
+	Borrower     string   `json:"borrower,omitempty"`
+}
+
+// AuditCorrection is a change an audit suggests to the recorded locations.
+type AuditCorrection struct {
+	ISBN int `json:"isbn"`
+	// Action is "move" to record a misplaced book in the audited location,
+	// or "remove" to take a missing book off its shelf.
+	Action string `json:"action"`
+}
+
+// PlanRequest asks for a reshelving plan.
+type PlanRequest struct {
+	// Policy is what the books are sorted by: author, title, category or
+	// classification. It defaults to author.
+	Policy string `json:"policy,omitempty"`
+	// ShelfIDs are the shelves to reshelve, filled in this order. All shelves
+	// are used when empty.
+	ShelfIDs []int `json:"shelf_ids,omitempty"`
+	// Capacity is how many books fit on a row. By default it is enough for
+	// the fullest row, or for the books spread evenly over the rows.
+	Capacity int `json:"capacity,omitempty"`
+}
+
+// Plan is a reshelving plan: a sorted target layout and the moves to get
+// there.
+type Plan struct {
+	ID          int        `json:"id"`
+	Policy      string     `json:"policy"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Books is how many books the plan covers, including those that stay.
+	Books int        `json:"books"`
+	Done  int        `json:"done"`
+	Moves []PlanMove `json:"moves"`
+}
+
+// PlanMove is one step of a reshelving plan.
+type PlanMove struct {
+	Step       int       `json:"step"`
+	ISBN       int       `json:"isbn"`
+	Title      string    `json:"title"`
+	From       PlanPlace `json:"from"`
+	To         PlanPlace `json:"to"`
+	AfterISBN  int       `json:"after_isbn,omitempty"`
+	AfterTitle string    `json:"after_title,omitempty"`
+	Done       bool      `json:"done"`
+	// Instruction says what to do in words.
+	Instruction string `json:"instruction"`
+}
+
+// PlanPlace is a spot on a shelf row.
+type PlanPlace struct {
+	ShelfID   int    `json:"shelf_id"`
+	ShelfName string `json:"shelf_name"`
+	Row       int    `json:"row"`
+	Position  int    `json:"position"`
+}
+
+// PlanConfirmRequest confirms that a book has been moved.
+type PlanConfirmRequest struct {
+	ISBN int `json:"isbn"`
+}
+
+// PlanConfirmation is the outcome of confirming a move.
+type PlanConfirmation struct {
+	Move      PlanMove  `json:"move"`
+	Remaining int       `json:"remaining"`
+	Completed bool      `json:"completed"`
+	Next      *PlanMove `json:"next,omitempty"`
+}
+
+// ScanEvent is a code scanned by a scanner and what came of it. Adds, moves,
+// deletes, loans and returns can be undone until they have been.
+type ScanEvent struct {
+	ID        int        `json:"id"`
+	ScannedAt time.Time  `json:"scanned_at"`
+	Device    string     `json:"device"`
+	Source    string     `json:"source,omitempty"`
+	Code      string     `json:"code"`
+	Mode      string     `json:"mode"`
+	Action    string     `json:"action"`
+	Outcome   string     `json:"outcome"`
+	ShelfID   int        `json:"shelf_id,omitempty"`
+	RowNumber int        `json:"row_number,omitempty"`
+	Slot      int        `json:"slot,omitempty"`
+	ISBN      int        `json:"isbn,omitempty"`
+	PersonID  int        `json:"person_id,omitempty"`
+	Book      *Book      `json:"book,omitempty"`
+	Undoable  bool       `json:"undoable"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+}
+
+// ScanEventRequest records a scan that did not change any books, such as a
+// shelf label, a mode card or a code that could not be used.
+type ScanEventRequest struct {
+	Device    string `json:"device"`
+	Source    string `json:"source,omitempty"`
+	Code      string `json:"code"`
+	Mode      string `json:"mode"`
+	Action    string `json:"action"`
+	Outcome   string `json:"outcome"`
+	ShelfID   int    `json:"shelf_id,omitempty"`
+	RowNumber int    `json:"row_number,omitempty"`
+	Slot      int    `json:"slot,omitempty"`
+}
+
+// ScanUndoRequest undoes the last scan of a device that can be undone.
+type ScanUndoRequest struct {
+	Device string `json:"device"`
+	Source string `json:"source,omitempty"`
 }
 
 type BorrowRequest struct {
 	ISBN       int    `json:"isbn"`
 	PersonName string `json:"person"`
+	// Email is kept for people who have none yet.
+	Email string `json:"email,omitempty"`
+	// Days overrides the default loan period.
+	Days int `json:"days,omitempty"`
+}
+
+type ReturnRequest struct {
+	ISBN int `json:"isbn"`
 }
 
 type Person struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// PersonRequest changes a person. An empty email stops their notifications.
+type PersonRequest struct {
+	Email string `json:"email"`
+}
+
+type CalendarFeed struct {
+	URL string `json:"url"`
+}
+
+// Stats is a summary of the collection and its lending history.
+type Stats struct {
+	Books           int               `json:"books"`
+	Pages           int               `json:"pages"`
+	AIEnriched      int               `json:"ai_enriched"`
+	EnrichedRatio   float64           `json:"enriched_ratio"`
+	ByShelf         []ShelfCount      `json:"by_shelf"`
+	ByLanguage      []LabelCount      `json:"by_language"`
+	ByCategory      []LabelCount      `json:"by_category"`
+	ByDecade        []LabelCount      `json:"by_decade"`
+	AddedByMonth    []LabelCount      `json:"added_by_month"`
+	MostBorrowed    []BookLoanCount   `json:"most_borrowed"`
+	TopBorrowers    []PersonLoanCount `json:"top_borrowers"`
+	ReturnedLoans   int               `json:"returned_loans"`
+	AverageLoanDays float64           `json:"average_loan_days"`
+}
+
+type ShelfCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Books int    `json:"books"`
+}
+
+type LabelCount struct {
+	Label string `json:"label"`
+	Books int    `json:"books"`
+}
+
+type BookLoanCount struct {
+	ISBN  int    `json:"isbn"`
+	Title string `json:"title"`
+	Loans int    `json:"loans"`
+}
+
+type PersonLoanCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Loans int    `json:"loans"`
 }
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"time"
+)
+
+// EventKind identifies what happened to a loan.
+type EventKind string
+
+const (
+	EventBorrowed      EventKind = "borrowed"
+	EventDueSoon       EventKind = "due_soon"
+	EventOverdue       EventKind = "overdue"
+	EventHoldAvailable EventKind = "hold_available"
+)
+
+// Embed the templates directory
+//
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates *template.Template
+
+var subjects = map[EventKind]string{
+	EventBorrowed:      "You borrowed %q",
+	EventDueSoon:       "%q is due soon",
+	EventOverdue:       "%q is overdue",
+	EventHoldAvailable: "%q is available for you",
+}
+
+func init() {
+	funcMap := template.FuncMap{
+		"date": func(t time.Time) string {
+			return t.Format("Mon, 2 Jan 2006")
+		},
+	}
+
+	var err error
+	templates, err = template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		log.Fatalf("Failed to parse notification templates: %v", err)
+	}
+}
+
+// Event is a single loan notification.
+type Event struct {
+	Kind        EventKind
+	ISBN        int
+	Title       string
+	PersonName  string
+	PersonEmail string
+	BorrowedAt  time.Time
+	DueAt       time.Time
+}
+
+// Notifier delivers loan events to people.
+type Notifier interface {
+	Notify(ctx context.Context, ev Event) error
+}
+
+// Nop is a Notifier that drops every event.
+type Nop struct{}
+
+func (Nop) Notify(context.Context, Event) error { return nil }
+
+// Multi fans an event out to several notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, ev Event) error {
+	var err error
+	for _, n := range m {
+		err = errors.Join(err, n.Notify(ctx, ev))
+	}
+	return err
+}
+
+// Render returns the subject and HTML body for an event.
+func Render(ev Event) (string, string, error) {
+	subject, ok := subjects[ev.Kind]
+	if !ok {
+		return "", "", fmt.Errorf("unknown event kind %q", ev.Kind)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, string(ev.Kind)+".html", ev); err != nil {
+		return "", "", fmt.Errorf("template error: %w", err)
+	}
+
+	return fmt.Sprintf(subject, ev.Title), buf.String(), nil
+}
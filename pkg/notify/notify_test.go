@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer is a minimal SMTP stand-in that records every message it receives.
+type fakeSMTPServer struct {
+	listener net.Listener
+	messages chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &fakeSMTPServer{listener: l, messages: make(chan string, 10)}
+	go s.serve()
+	t.Cleanup(func() {
+		if err := l.Close(); err != nil {
+			t.Logf("failed to close listener: %v", err)
+		}
+	})
+
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"), strings.HasPrefix(cmd, "RSET"):
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var msg strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				msg.WriteString(l)
+			}
+			s.messages <- msg.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func testEvent(kind EventKind) Event {
+	return Event{
+		Kind:        kind,
+		ISBN:        9783836526722,
+		Title:       "The Fairy Tales of the Brothers Grimm",
+		PersonName:  "John Doe",
+		PersonEmail: "john@example.com",
+		BorrowedAt:  time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+		DueAt:       time.Date(2025, 1, 29, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	n := NewSMTPNotifier(server.listener.Addr().String(), "library@example.com", "", "")
+
+	if err := n.Notify(t.Context(), testEvent(EventOverdue)); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	select {
+	case msg := <-server.messages:
+		for _, want := range []string{
+			"To: john@example.com",
+			"From: library@example.com",
+			"Content-Type: text/html",
+			"Hi John Doe",
+			"Wed, 29 Jan 2025",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("expected message to contain %q, got:\n%s", want, msg)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	// People without an email address are skipped.
+	ev := testEvent(EventDueSoon)
+	ev.PersonEmail = ""
+	if err := n.Notify(t.Context(), ev); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	select {
+	case msg := <-server.messages:
+		t.Fatalf("expected no message, got:\n%s", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	payloads := make(chan WebhookPayload, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		payloads <- payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	n := NewWebhookNotifier(ts.URL)
+	if err := n.Notify(t.Context(), testEvent(EventHoldAvailable)); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+
+	payload := <-payloads
+	if payload.Event != EventHoldAvailable {
+		t.Errorf("expected event %q, got %q", EventHoldAvailable, payload.Event)
+	}
+	if payload.ISBN != 9783836526722 {
+		t.Errorf("expected ISBN 9783836526722, got %d", payload.ISBN)
+	}
+	if payload.Subject != `"The Fairy Tales of the Brothers Grimm" is available for you` {
+		t.Errorf("unexpected subject %q", payload.Subject)
+	}
+	if payload.DueAt == nil || !payload.DueAt.Equal(testEvent(EventHoldAvailable).DueAt) {
+		t.Errorf("unexpected due_at %v", payload.DueAt)
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	if err := NewWebhookNotifier(ts.URL).Notify(t.Context(), testEvent(EventBorrowed)); err == nil {
+		t.Fatal("expected error for 500 response, got nil")
+	}
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPNotifier emails events to the borrower over plain SMTP.
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier returns a notifier that sends mail through the SMTP server
+// at addr (host:port). Authentication is only used if username is set.
+func NewSMTPNotifier(addr, from, username, password string) *SMTPNotifier {
+	s := &SMTPNotifier{
+		addr: addr,
+		from: from,
+	}
+
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+func (s *SMTPNotifier) Notify(_ context.Context, ev Event) error {
+	// People without an email address only get webhooks.
+	if ev.PersonEmail == "" {
+		return nil
+	}
+
+	subject, body, err := Render(ev)
+	if err != nil {
+		return err
+	}
+
+	msg := buildMessage(s.from, ev.PersonEmail, subject, body)
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{ev.PersonEmail}, msg); err != nil {
+		return fmt.Errorf("send mail to %s: %w", ev.PersonEmail, err)
+	}
+
+	return nil
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(body)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// HTTPClient interface for making HTTP requests (allows mocking in tests)
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WebhookNotifier POSTs every event as JSON to a URL.
+type WebhookNotifier struct {
+	url        string
+	httpClient HTTPClient
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// WebhookPayload is the JSON body sent to webhooks.
+type WebhookPayload struct {
+	Event       EventKind  `json:"event"`
+	Subject     string     `json:"subject"`
+	ISBN        int        `json:"isbn"`
+	Title       string     `json:"title"`
+	PersonName  string     `json:"person"`
+	PersonEmail string     `json:"email,omitempty"`
+	BorrowedAt  *time.Time `json:"borrowed_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, ev Event) error {
+	subject, _, err := Render(ev)
+	if err != nil {
+		return err
+	}
+
+	payload := WebhookPayload{
+		Event:       ev.Kind,
+		Subject:     subject,
+		ISBN:        ev.ISBN,
+		Title:       ev.Title,
+		PersonName:  ev.PersonName,
+		PersonEmail: ev.PersonEmail,
+	}
+	if !ev.BorrowedAt.IsZero() {
+		payload.BorrowedAt = &ev.BorrowedAt
+	}
+	if !ev.DueAt.IsZero() {
+		payload.DueAt = &ev.DueAt
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("JSON marshal error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected webhook status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package planner works out how to reshelve books into a sorted order with as
+// few moves as possible.
+//
+// The target layout fills the rows in order with the sorted books, up to each
+// row's capacity. A book has to move when its target row is not the row it is
+// on. Within a row, the largest set of books that are already in the right
+// order relative to each other stays put, and only the rest are moved around
+// them.
+package planner
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// Policy is how books are sorted.
+type Policy string
+
+const (
+	// PolicyAuthor sorts by the surname of the first author, then by title.
+	PolicyAuthor Policy = "author"
+	// PolicyTitle sorts by title, ignoring a leading article.
+	PolicyTitle Policy = "title"
+	// PolicyCategory groups books by their first category, sorted by author
+	// within each category.
+	PolicyCategory Policy = "category"
+	// PolicyClassification sorts by Dewey Decimal class, then by author.
+	PolicyClassification Policy = "classification"
+)
+
+// Policies are all the sort policies.
+var Policies = []Policy{PolicyAuthor, PolicyTitle, PolicyCategory, PolicyClassification}
+
+// ValidPolicy reports whether p is one of Policies.
+func ValidPolicy(p Policy) bool {
+	return slices.Contains(Policies, p)
+}
+
+// ErrNoRoom is returned when the books do not fit on the rows.
+var ErrNoRoom = errors.New("not enough room on the rows for all the books")
+
+// last sorts after any key that starts with a letter or digit, so books
+// missing what they are sorted by go at the end.
+const last = "\uffff"
+
+// SortKey returns the key a book is sorted by under a policy.
+func SortKey(p Policy, book models.Book) string {
+	author := last
+	if len(book.Authors) > 0 && strings.TrimSpace(book.Authors[0]) != "" {
+		name := strings.ToLower(strings.TrimSpace(book.Authors[0]))
+		fields := strings.Fields(name)
+		author = fields[len(fields)-1] + " " + name
+	}
+	title := strings.ToLower(strings.TrimSpace(book.Title))
+	for _, article := range []string{"the ", "a ", "an "} {
+		title = strings.TrimPrefix(title, article)
+	}
+	if title == "" {
+		title = last
+	}
+
+	switch p {
+	case PolicyTitle:
+		return title + "\x00" + author
+	case PolicyCategory:
+		category := last
+		if len(book.Categories) > 0 && book.Categories[0] != "" {
+			category = strings.ToLower(book.Categories[0])
+		}
+		return category + "\x00" + author + "\x00" + title
+	case PolicyClassification:
+		class := last
+		if book.Classification != "" {
+			class = book.Classification
+		}
+		return class + "\x00" + author + "\x00" + title
+	default:
+		return author + "\x00" + title
+	}
+}
+
+// Place is a spot on a shelf row. Positions count from 1 at the left.
+type Place struct {
+	ShelfID  int
+	Row      int
+	Position int
+}
+
+// Row is a shelf row that books can be put on, and how many books fit on it.
+type Row struct {
+	ShelfID  int
+	Row      int
+	Capacity int
+}
+
+// Book is a book to reshelve.
+type Book struct {
+	ISBN    int
+	Key     string
+	Current Place
+}
+
+// Target is where a book ends up.
+type Target struct {
+	ISBN int
+	From Place
+	To   Place
+	// After is the book to the left of the target place, or 0 when the book
+	// goes at the left end of the row.
+	After int
+	// Step is the number of the move in the plan, or 0 for books that stay
+	// where they are.
+	Step int
+}
+
+// Plan is a target layout and the moves to get there.
+type Plan struct {
+	// Targets are all the books in their new order.
+	Targets []Target
+	// Moves are the books that have to move, in the order to move them.
+	Moves []Target
+}
+
+// Compute sorts the books into the rows, which are filled in the given order.
+func Compute(books []Book, rows []Row) (Plan, error) {
+	capacity := 0
+	for _, r := range rows {
+		capacity += r.Capacity
+	}
+	if len(books) > capacity {
+		return Plan{}, ErrNoRoom
+	}
+
+	sorted := slices.Clone(books)
+	slices.SortStableFunc(sorted, func(a, b Book) int {
+		return cmp.Or(strings.Compare(a.Key, b.Key), cmp.Compare(a.ISBN, b.ISBN))
+	})
+
+	var plan Plan
+	next := 0
+	for _, r := range rows {
+		onRow := sorted[next:min(next+r.Capacity, len(sorted))]
+		next += len(onRow)
+
+		targets := make([]Target, len(onRow))
+		for i, book := range onRow {
+			targets[i] = Target{
+				ISBN: book.ISBN,
+				From: book.Current,
+				To:   Place{ShelfID: r.ShelfID, Row: r.Row, Position: i + 1},
+			}
+			if i > 0 {
+				targets[i].After = onRow[i-1].ISBN
+			}
+		}
+
+		// Books already on this row keep their place if they are in the
+		// longest run that is already in order.
+		var stay []int
+		for i, t := range targets {
+			if t.From.ShelfID == r.ShelfID && t.From.Row == r.Row {
+				stay = append(stay, i)
+			}
+		}
+		keep := map[int]bool{}
+		for _, i := range longestIncreasing(stay, func(i int) int { return targets[i].From.Position }) {
+			keep[i] = true
+		}
+
+		for i := range targets {
+			if !keep[i] {
+				targets[i].Step = len(plan.Moves) + 1
+				plan.Moves = append(plan.Moves, targets[i])
+			}
+		}
+		plan.Targets = append(plan.Targets, targets...)
+	}
+	return plan, nil
+}
+
+// longestIncreasing returns the longest subsequence of items whose values are
+// strictly increasing.
+func longestIncreasing(items []int, value func(int) int) []int {
+	if len(items) == 0 {
+		return nil
+	}
+
+	// tails[k] is the index into items of the smallest last value of an
+	// increasing subsequence of length k+1.
+	var tails []int
+	prev := make([]int, len(items))
+	for i, item := range items {
+		v := value(item)
+		k, _ := slices.BinarySearchFunc(tails, v, func(t, v int) int {
+			return cmp.Compare(value(items[t]), v)
+		})
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	result := make([]int, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		result[i] = items[k]
+	}
+	return result
+}
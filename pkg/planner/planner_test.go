@@ -0,0 +1,138 @@
+package planner
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+func TestSortKey(t *testing.T) {
+	books := []models.Book{
+		{ISBN: 1, Title: "Oliver Twist", Authors: []string{"Charles Dickens"}, Categories: []string{"Fiction"}, Classification: "823.8"},
+		{ISBN: 2, Title: "The Go Programming Language", Authors: []string{"Alan Donovan", "Brian Kernighan"}, Categories: []string{"Computers"}, Classification: "005.133"},
+		{ISBN: 3, Title: "Emma", Authors: []string{"Jane Austen"}, Categories: []string{"Fiction"}, Classification: "823.7"},
+		{ISBN: 4, Title: "A Brief History of Time"},
+	}
+	order := func(p Policy) []int {
+		sorted := slices.Clone(books)
+		slices.SortFunc(sorted, func(a, b models.Book) int {
+			return strings.Compare(SortKey(p, a), SortKey(p, b))
+		})
+		var isbns []int
+		for _, b := range sorted {
+			isbns = append(isbns, b.ISBN)
+		}
+		return isbns
+	}
+
+	tests := map[Policy][]int{
+		// Books without an author go last.
+		PolicyAuthor: {3, 1, 2, 4},
+		// "The" and "A" are ignored.
+		PolicyTitle:          {4, 3, 2, 1},
+		PolicyCategory:       {2, 3, 1, 4},
+		PolicyClassification: {2, 3, 1, 4},
+	}
+	for p, want := range tests {
+		if diff := cmp.Diff(want, order(p)); diff != "" {
+			t.Errorf("unexpected %s order (-want +got):\n%s", p, diff)
+		}
+	}
+
+	if !ValidPolicy(PolicyCategory) || ValidPolicy("colour") {
+		t.Error("unexpected policy validation")
+	}
+}
+
+func TestComputeSorted(t *testing.T) {
+	books := []Book{
+		{ISBN: 1, Key: "a", Current: Place{ShelfID: 1, Row: 1, Position: 1}},
+		{ISBN: 2, Key: "b", Current: Place{ShelfID: 1, Row: 1, Position: 2}},
+		{ISBN: 3, Key: "c", Current: Place{ShelfID: 1, Row: 2, Position: 1}},
+	}
+	plan, err := Compute(books, []Row{{ShelfID: 1, Row: 1, Capacity: 2}, {ShelfID: 1, Row: 2, Capacity: 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Moves) != 0 {
+		t.Errorf("expected no moves for sorted books, got %+v", plan.Moves)
+	}
+	if len(plan.Targets) != 3 {
+		t.Errorf("expected 3 targets, got %d", len(plan.Targets))
+	}
+}
+
+func TestCompute(t *testing.T) {
+	// Row 1 holds e, b, c, a and row 2 holds d. Sorted with two books per
+	// row, a and b belong on row 1, c and d on row 2, and e on row 3.
+	books := []Book{
+		{ISBN: 5, Key: "e", Current: Place{ShelfID: 1, Row: 1, Position: 1}},
+		{ISBN: 2, Key: "b", Current: Place{ShelfID: 1, Row: 1, Position: 2}},
+		{ISBN: 3, Key: "c", Current: Place{ShelfID: 1, Row: 1, Position: 3}},
+		{ISBN: 1, Key: "a", Current: Place{ShelfID: 1, Row: 1, Position: 4}},
+		{ISBN: 4, Key: "d", Current: Place{ShelfID: 1, Row: 2, Position: 1}},
+	}
+	rows := []Row{{ShelfID: 1, Row: 1, Capacity: 2}, {ShelfID: 1, Row: 2, Capacity: 2}, {ShelfID: 2, Row: 1, Capacity: 2}}
+
+	plan, err := Compute(books, rows)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Target{
+		// Of a and b only one can stay, since a is to the right of b.
+		{ISBN: 1, From: Place{1, 1, 4}, To: Place{1, 1, 1}, Step: 1},
+		{ISBN: 2, From: Place{1, 1, 2}, To: Place{1, 1, 2}, After: 1},
+		{ISBN: 3, From: Place{1, 1, 3}, To: Place{1, 2, 1}, Step: 2},
+		{ISBN: 4, From: Place{1, 2, 1}, To: Place{1, 2, 2}, After: 3},
+		{ISBN: 5, From: Place{1, 1, 1}, To: Place{2, 1, 1}, Step: 3},
+	}
+	if diff := cmp.Diff(want, plan.Targets); diff != "" {
+		t.Errorf("unexpected targets (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]Target{want[0], want[2], want[4]}, plan.Moves); diff != "" {
+		t.Errorf("unexpected moves (-want +got):\n%s", diff)
+	}
+
+	if _, err := Compute(books, rows[:2]); !errors.Is(err, ErrNoRoom) {
+		t.Errorf("expected ErrNoRoom, got %v", err)
+	}
+}
+
+func TestComputeReversedRow(t *testing.T) {
+	var books []Book
+	for i := range 5 {
+		books = append(books, Book{ISBN: i + 1, Key: string(rune('a' + i)), Current: Place{ShelfID: 1, Row: 1, Position: 5 - i}})
+	}
+	plan, err := Compute(books, []Row{{ShelfID: 1, Row: 1, Capacity: 10}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Only one book of a reversed row can stay.
+	if len(plan.Moves) != 4 {
+		t.Errorf("expected 4 moves, got %d", len(plan.Moves))
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	values := []int{3, 1, 4, 1, 5, 9, 2, 6}
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	got := longestIncreasing(items, func(i int) int { return values[i] })
+
+	if len(got) != 4 {
+		t.Fatalf("expected a subsequence of 4, got %v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] || values[got[i]] <= values[got[i-1]] {
+			t.Errorf("subsequence %v is not increasing", got)
+		}
+	}
+	if got := longestIncreasing(nil, func(i int) int { return i }); got != nil {
+		t.Errorf("expected nothing, got %v", got)
+	}
+}
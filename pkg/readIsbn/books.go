@@ -0,0 +1,42 @@
+package readIsbn
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/client"
+)
+
+// removeBook deletes a book in delete mode. The server records scan, so that
+// it can be undone. It reports whether the book was deleted.
+func removeBook(apiClient *client.Client, isbn string, scan client.Scan) bool {
+	book, err := apiClient.GetBook(isbn)
+	if err != nil {
+		slog.Error("cannot get book", "error", err, "isbn", isbn)
+		return false
+	}
+	if err := apiClient.DeleteBook(isbn, scan); err != nil {
+		slog.Error("cannot delete book", "error", err, "isbn", isbn)
+		return false
+	}
+	fmt.Println("Deleted", book.Title)
+	return true
+}
+
+// relocateBook moves a book in move mode. The server records scan, so that it
+// can be undone. It reports whether the book was moved.
+func relocateBook(apiClient *client.Client, isbn string, shelfID, row, slot int, scan client.Scan) bool {
+	book, err := apiClient.GetBook(isbn)
+	if err != nil {
+		slog.Error("cannot get book", "error", err, "isbn", isbn)
+		return false
+	}
+	moved, err := apiClient.MoveBook(isbn, api.BookLocationRequest{ShelfID: shelfID, RowNumber: row, Slot: slot}, scan)
+	if err != nil {
+		slog.Error("cannot move book", "error", err, "isbn", isbn)
+		return false
+	}
+	fmt.Println("Moved", moved.Title, "from", book.ShelfName, "row", book.RowNumber, "to", moved.ShelfName, "row", moved.RowNumber)
+	return true
+}
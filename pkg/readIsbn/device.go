@@ -0,0 +1,147 @@
+package readIsbn
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is what a scanner is used for. It decides the mode the scanner starts
+// in and falls back to when a session ends.
+type Role string
+
+const (
+	// RoleCatalogue adds scanned books to the current shelf.
+	RoleCatalogue Role = "catalogue"
+	// RoleLending is a lending desk: books scanned are returned, and books
+	// scanned after a person card are lent to them.
+	RoleLending Role = "lending"
+	// RoleAudit checks rows: scan a row, then the books on it.
+	RoleAudit Role = "audit"
+)
+
+func (r Role) mode() scanMode {
+	switch r {
+	case RoleLending:
+		return modeReturn
+	case RoleAudit:
+		return modeAudit
+	default:
+		return modeCatalogue
+	}
+}
+
+// Device is a scanner with its own role, location and state. Codes from any
+// of its sources act on that state; other devices are not affected.
+type Device struct {
+	Name    string
+	Sources []ScanSource
+	Role    Role
+	// Location is the shelf code cataloguing starts at. The zero value is
+	// shelf 0, row 0.
+	Location scancode.Code
+	// Debounce drops a code scanned again this soon after it was last seen.
+	Debounce time.Duration
+	// Bell rings the terminal bell when a book being catalogued is already
+	// catalogued.
+	Bell bool
+}
+
+// Config is the config file of the scanner daemon. Flags given on the command
+// line take precedence over the settings in it.
+type Config struct {
+	ServerURL      string         `yaml:"server_url"`
+	Token          string         `yaml:"token"`
+	MetricsAddr    string         `yaml:"metrics_addr"`
+	QueuePath      string         `yaml:"queue_path"`
+	LendingTimeout time.Duration  `yaml:"lending_timeout"`
+	Debounce       time.Duration  `yaml:"debounce"`
+	Bell           bool           `yaml:"bell"`
+	Devices        []DeviceConfig `yaml:"devices"`
+}
+
+// DeviceConfig is a scanner in the config file.
+type DeviceConfig struct {
+	Name string `yaml:"name"`
+	// Source is where the scanner is read from, as given to --source. Use
+	// the stable /dev/input/by-id or /dev/serial/by-id paths so the device is
+	// found again when it is plugged into another port.
+	Source string `yaml:"source"`
+	// Role defaults to catalogue.
+	Role Role `yaml:"role"`
+	// Location is a shelf label or location code, e.g. "LS:L:3:1".
+	Location string `yaml:"location"`
+}
+
+// LoadConfig reads a config file.
+func LoadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	cfg := Config{}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Device parses the device config. Websocket sources only let in pages on
+// serverURL.
+func (c DeviceConfig) Device(serverURL string) (Device, error) {
+	if c.Name == "" {
+		return Device{}, fmt.Errorf("device needs a name")
+	}
+	if c.Source == "" {
+		return Device{}, fmt.Errorf("device %q needs a source", c.Name)
+	}
+	source, err := ParseSource(c.Source, serverURL)
+	if err != nil {
+		return Device{}, fmt.Errorf("device %q: %w", c.Name, err)
+	}
+
+	d := Device{Name: c.Name, Sources: []ScanSource{source}, Role: c.Role}
+	switch c.Role {
+	case "":
+		d.Role = RoleCatalogue
+	case RoleCatalogue, RoleLending, RoleAudit:
+	default:
+		return Device{}, fmt.Errorf("device %q has unknown role %q, want %s, %s or %s", c.Name, c.Role, RoleCatalogue, RoleLending, RoleAudit)
+	}
+	if c.Location != "" {
+		d.Location = scancode.Parse(c.Location)
+		if d.Location.Kind != scancode.Shelf {
+			return Device{}, fmt.Errorf("device %q has invalid location %q", c.Name, c.Location)
+		}
+	}
+	return d, nil
+}
+
+// ParseDevices parses the devices in the config. Device names must be unique,
+// and the debounce time and bell apply to them all.
+func (c Config) ParseDevices() ([]Device, error) {
+	devices := []Device{}
+	names := map[string]bool{}
+	for _, dc := range c.Devices {
+		d, err := dc.Device(c.ServerURL)
+		if err != nil {
+			return nil, err
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("device %q is configured twice", d.Name)
+		}
+		names[d.Name] = true
+		d.Debounce, d.Bell = c.Debounce, c.Bell
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
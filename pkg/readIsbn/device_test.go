@@ -0,0 +1,187 @@
+package readIsbn
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scanners.yaml")
+	config := `server_url: http://books.local:8080
+metrics_addr: ":9100"
+lending_timeout: 5m
+debounce: 500ms
+bell: true
+devices:
+  - name: office
+    source: evdev:/dev/input/by-id/usb-Barcode-event-kbd?layout=de
+    location: LS:L:3:1
+  - name: desk
+    source: serial:/dev/serial/by-id/usb-Scanner-if00
+    role: lending
+  - name: audit
+    source: ws::7778?token=secret
+    role: audit
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("cannot load config: %v", err)
+	}
+	if cfg.ServerURL != "http://books.local:8080" || cfg.MetricsAddr != ":9100" || cfg.LendingTimeout != 5*time.Minute {
+		t.Errorf("unexpected settings: %+v", cfg)
+	}
+
+	devices, err := cfg.ParseDevices()
+	if err != nil {
+		t.Fatalf("cannot parse devices: %v", err)
+	}
+	want := []Device{
+		{
+			Name:     "office",
+			Sources:  []ScanSource{&evdevSource{path: "/dev/input/by-id/usb-Barcode-event-kbd", layout: Layouts["de"]}},
+			Role:     RoleCatalogue,
+			Location: scancode.Code{Raw: "LS:L:3:1", Kind: scancode.Shelf, ShelfID: 3, Row: 1},
+			Debounce: 500 * time.Millisecond,
+			Bell:     true,
+		},
+		{
+			Name:     "desk",
+			Sources:  []ScanSource{&serialSource{path: "/dev/serial/by-id/usb-Scanner-if00", baud: 9600}},
+			Role:     RoleLending,
+			Debounce: 500 * time.Millisecond,
+			Bell:     true,
+		},
+		{
+			Name:     "audit",
+			Sources:  []ScanSource{&websocketSource{addr: ":7778", token: "secret", origin: "http://books.local:8080"}},
+			Role:     RoleAudit,
+			Debounce: 500 * time.Millisecond,
+			Bell:     true,
+		},
+	}
+	if diff := cmp.Diff(want, devices, cmp.AllowUnexported(evdevSource{}, Layout{}, keyChars{}, serialSource{}, websocketSource{})); diff != "" {
+		t.Errorf("unexpected devices (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDevicesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []DeviceConfig
+	}{
+		{name: "no name", devices: []DeviceConfig{{Source: "stdin"}}},
+		{name: "no source", devices: []DeviceConfig{{Name: "office"}}},
+		{name: "bad source", devices: []DeviceConfig{{Name: "office", Source: "bluetooth:scanner"}}},
+		{name: "bad role", devices: []DeviceConfig{{Name: "office", Source: "stdin", Role: "stocktake"}}},
+		{name: "bad location", devices: []DeviceConfig{{Name: "office", Source: "stdin", Location: "9783836526722"}}},
+		{name: "duplicate", devices: []DeviceConfig{{Name: "office", Source: "stdin"}, {Name: "office", Source: "tcp::7777"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (Config{Devices: tt.devices}).ParseDevices(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDevicesKeepTheirOwnState(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(device Device, codes ...string) {
+		scans := make(chan scan, len(codes))
+		for _, code := range codes {
+			scans <- scan{source: "test", code: code}
+		}
+		close(scans)
+		inputLoop(client.New(ts.URL, nil), device, scans, queue, make(chan struct{}, 1), time.Minute)
+	}
+
+	office := Device{Name: "office", Role: RoleCatalogue, Location: scancode.Parse("LS:L:3:1")}
+	home := Device{Name: "home", Role: RoleCatalogue}
+	run(office, "9780000000001", "LS:L:5:2", "9780000000002")
+	run(home, "9780000000003")
+	// A lending desk returns books instead of cataloguing them.
+	run(Device{Name: "desk", Role: RoleLending}, "9780000000004")
+
+	want := []string{"9780000000001 3/1", "9780000000002 5/2", "9780000000003 /", "returned"}
+	if diff := cmp.Diff(want, server.got()); diff != "" {
+		t.Errorf("unexpected books (-want +got):\n%s", diff)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make(chan scan, 5)
+	// The repeats on another source of the same device are dropped too, but
+	// a code scanned again after another one is not.
+	scans <- scan{source: "test", code: "9780000000001"}
+	scans <- scan{source: "test", code: "9780000000001"}
+	scans <- scan{source: "other", code: "9780000000001"}
+	scans <- scan{source: "test", code: "9780000000002"}
+	scans <- scan{source: "test", code: "9780000000001"}
+	close(scans)
+	inputLoop(client.New(ts.URL, nil), Device{Name: "shaky", Debounce: time.Hour}, scans, queue, make(chan struct{}, 1), time.Minute)
+
+	want := []string{"9780000000001 /", "9780000000002 /", "9780000000001 /"}
+	if diff := cmp.Diff(want, server.got()); diff != "" {
+		t.Errorf("unexpected books (-want +got):\n%s", diff)
+	}
+}
+
+func TestDebounceFromAcceptedScan(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scanner that keeps firing at a code held in front of it sends it
+	// again once the debounce time has passed since it was accepted.
+	scans := make(chan scan)
+	go func() {
+		defer close(scans)
+		for i := 0; i < 3; i++ {
+			if i > 0 {
+				time.Sleep(120 * time.Millisecond)
+			}
+			scans <- scan{source: "test", code: "9780000000001"}
+		}
+	}()
+	inputLoop(client.New(ts.URL, nil), Device{Name: "held", Debounce: 200 * time.Millisecond}, scans, queue, make(chan struct{}, 1), time.Minute)
+
+	want := []string{"9780000000001 /", "9780000000001 /"}
+	if diff := cmp.Diff(want, server.got()); diff != "" {
+		t.Errorf("unexpected books (-want +got):\n%s", diff)
+	}
+}
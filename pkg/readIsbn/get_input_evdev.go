@@ -2,6 +2,8 @@ package readIsbn
 
 import (
 	"fmt"
+	"log/slog"
+	"path/filepath"
 	"time"
 
 	"github.com/holoplot/go-evdev"
@@ -16,12 +18,17 @@ type deviceInput struct {
 	device     *evdev.InputDevice
 
 	bufferedCodes chan string
+
+	keyboard keyboard
+	framer   framer
 }
 
-func grabAndSetupDevice(inputDevicePath string) (*deviceInput, error) {
+func grabAndSetupDevice(inputDevicePath string, layout *Layout, framing Framing) (*deviceInput, error) {
 	di := &deviceInput{
 		devicePath:    inputDevicePath,
 		bufferedCodes: make(chan string, 100),
+		keyboard:      keyboard{layout: layout},
+		framer:        framer{Framing: framing},
 	}
 
 	if err := di.open(); err != nil {
@@ -33,10 +40,17 @@ func grabAndSetupDevice(inputDevicePath string) (*deviceInput, error) {
 	return di, nil
 }
 
+// open waits for the device to be plugged in and grabs it. The path can be a
+// glob, such as /dev/input/by-id/*Barcode*-event-kbd, to take the first
+// matching device.
 func (d *deviceInput) open() error {
 	for {
 		time.Sleep(500 * time.Millisecond)
-		inputDevice, err := evdev.Open(d.devicePath)
+		path, err := resolveDevicePath(d.devicePath)
+		if err != nil {
+			continue
+		}
+		inputDevice, err := evdev.Open(path)
 		if err != nil {
 			continue
 		}
@@ -56,7 +70,8 @@ func (d *deviceInput) open() error {
 			continue
 		}
 
-		fmt.Println("device found and opened.")
+		slog.Info("Device connected", "device", d.devicePath, "path", path)
+		deviceConnectedGauge.WithLabelValues(d.devicePath).Set(1)
 		d.device = inputDevice
 
 		return nil
@@ -64,8 +79,6 @@ func (d *deviceInput) open() error {
 }
 
 func (d *deviceInput) readToBuffer() {
-	var barcode string
-
 	for {
 		// If device is not open, open it.
 		if _, err := d.device.Name(); err != nil {
@@ -82,54 +95,39 @@ func (d *deviceInput) readToBuffer() {
 			ev, err := d.device.ReadOne()
 			if err != nil {
 				fmt.Println("device read error", err)
-
-				d.bufferedCodes <- barcode
-				barcode = ""
+				// A code cut off by the device going away is dropped.
+				d.framer = framer{Framing: d.framer.Framing}
+				if _, err := d.device.Name(); err != nil {
+					slog.Warn("Device disconnected; waiting for it to be plugged back in", "device", d.devicePath)
+					deviceConnectedGauge.WithLabelValues(d.devicePath).Set(0)
+					_ = d.device.Close()
+				}
 				break
 			}
-
-			if ev.Type == evdev.EV_KEY && ev.Value == 1 {
-				if ev.Code == evdev.KEY_ENTER {
-					d.bufferedCodes <- barcode
-					barcode = ""
-					break
-				}
+			if ev.Type != evdev.EV_KEY {
+				continue
 			}
 
-			if ev.Type == evdev.EV_KEY && ev.Value == 1 {
-				barcode += eventToString(*ev)
+			ch := d.keyboard.key(ev.Code, ev.Value)
+			if ch == "" {
+				continue
+			}
+			if code, ok := d.framer.add(ch); ok {
+				d.bufferedCodes <- code
 			}
 		}
 	}
 }
 
-func (d *deviceInput) read() string {
-	return <-d.bufferedCodes
-}
-
-func eventToString(ev evdev.InputEvent) string {
-	switch ev.Code {
-	case evdev.KEY_0:
-		return "0"
-	case evdev.KEY_1:
-		return "1"
-	case evdev.KEY_2:
-		return "2"
-	case evdev.KEY_3:
-		return "3"
-	case evdev.KEY_4:
-		return "4"
-	case evdev.KEY_5:
-		return "5"
-	case evdev.KEY_6:
-		return "6"
-	case evdev.KEY_7:
-		return "7"
-	case evdev.KEY_8:
-		return "8"
-	case evdev.KEY_9:
-		return "9"
-	default:
-		return ""
+// resolveDevicePath returns path if the device is there, or the first device
+// that matches it if it is a glob.
+func resolveDevicePath(path string) (string, error) {
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no device matches %s", path)
 	}
+	return matches[0], nil
 }
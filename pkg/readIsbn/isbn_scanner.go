@@ -1,15 +1,23 @@
 package readIsbn
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/audit"
+	"github.com/gouthamve/librascan/pkg/client"
 	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,8 +28,18 @@ import (
 var (
 	currentShelfGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "librascan_current_shelf",
-		Help: "The current shelf and row",
-	}, []string{"shelf", "shelfID", "row"})
+		Help: "The current shelf, row and mode, by device",
+	}, []string{"device", "shelf", "shelfID", "row", "mode"})
+
+	scansCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "librascan_scans",
+		Help: "The total number of codes scanned, by source",
+	}, []string{"source"})
+
+	scansDebouncedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "librascan_scans_debounced",
+		Help: "The total number of repeated scans dropped, by source",
+	}, []string{"source"})
 
 	booksProcessedCounter = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "librascan_books_processed",
@@ -33,6 +51,41 @@ var (
 		Help: "The total number of books that failed to process",
 	})
 
+	booksLentCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "librascan_books_lent",
+		Help: "The total number of books lent by scanning",
+	})
+
+	booksReturnedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "librascan_books_returned",
+		Help: "The total number of books returned by scanning",
+	})
+
+	deviceConnectedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "librascan_device_connected",
+		Help: "Whether a scanner device is plugged in, by device path",
+	}, []string{"device"})
+
+	queuedScansGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "librascan_queued_scans",
+		Help: "The number of scans waiting for the server to be reachable",
+	})
+
+	lendingFailedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "librascan_lending_failed",
+		Help: "The total number of borrows and returns that failed",
+	})
+
+	planMovesCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "librascan_plan_moves",
+		Help: "The total number of reshelving moves confirmed by scanning",
+	})
+
+	auditScansCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "librascan_audit_scans",
+		Help: "The total number of books scanned during audits, by status",
+	}, []string{"status"})
+
 	librascanAPIRequests = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "librascan_api_requests",
 		Help:    "Histogram of API requests",
@@ -40,150 +93,648 @@ var (
 	}, []string{"code", "method"})
 )
 
-func StartCLI(serverURL string, inputDevicePath string) {
-	// Start an echo server and run Prometheus.
-	go func() {
-		e := echo.New()
-		e.Use(echoprometheus.NewMiddleware("librascan"))
-		e.GET("/metrics", echoprometheus.NewHandler())
-		e.Logger.Fatal(e.Start(":8080"))
-	}()
+// scanMode decides what scanning an ISBN does.
+type scanMode int
+
+const (
+	// modeCatalogue adds scanned books to the current shelf.
+	modeCatalogue scanMode = iota
+	// modeLending lends scanned books to the person whose card was scanned.
+	modeLending
+	// modeReturn returns scanned books.
+	modeReturn
+	// modeAudit checks the books on a row against the library.
+	modeAudit
+	// modeReshelve confirms the moves of a reshelving plan.
+	modeReshelve
+	// modeDelete deletes scanned books.
+	modeDelete
+	// modeMove moves scanned books to the current shelf.
+	modeMove
+)
+
+func (m scanMode) String() string {
+	switch m {
+	case modeLending:
+		return "lending"
+	case modeReturn:
+		return "return"
+	case modeAudit:
+		return "audit"
+	case modeReshelve:
+		return "reshelve"
+	case modeDelete:
+		return "delete"
+	case modeMove:
+		return "move"
+	default:
+		return "catalogue"
+	}
+}
+
+// scanState is what the input loop remembers between scans.
+type scanState struct {
+	shelf     models.Shelf
+	rowNumber int
+	slot      int
+
+	mode scanMode
+	// base is the mode of the device's role, which sessions fall back to.
+	base   scanMode
+	person models.Person
+	// audit is the open audit in audit mode, if a row has been scanned.
+	audit models.Audit
+	// plan is the reshelving plan in reshelve mode.
+	plan models.Plan
+
+	// lastCode is the last code scanned and lastSeen when it was last
+	// accepted, to drop repeated scans.
+	lastCode string
+	lastSeen time.Time
+
+	// queued is the last book scanned if it went into the queue. Undoing it
+	// takes it off the queue; other scans are undone by the server.
+	queued *scanqueue.Scan
+}
+
+func (s *scanState) prompt() string {
+	switch s.mode {
+	case modeLending:
+		return fmt.Sprintf("Lending to %s. Scan ISBN13 to lend, or a card: ", s.person.Name)
+	case modeReturn:
+		return "Returning books. Scan ISBN13 to return, or a card: "
+	case modeAudit:
+		if s.audit.ID == 0 {
+			return "Auditing. Scan the code of the row to audit: "
+		}
+		return fmt.Sprintf("Auditing %s. Scan every book on the row, then end the session: ", strings.Join(s.audit.LocationPath, " › "))
+	case modeReshelve:
+		return fmt.Sprintf("Reshelving (plan %d). Scan each book after putting it in its new place: ", s.plan.ID)
+	case modeDelete:
+		return "Deleting books. Scan ISBN13 to delete, or a control code: "
+	case modeMove:
+		return fmt.Sprintf("Moving books to %s, row %d. Scan ISBN13 to move, or shelfCode: ", s.shelf.Name, s.rowNumber)
+	default:
+		return "Enter ISBN13 or shelfCode: "
+	}
+}
+
+// report shows the shelf, row and mode in the librascan_current_shelf metric
+// and on stdout.
+func (s *scanState) report(device string) {
+	currentShelfGauge.DeletePartialMatch(prometheus.Labels{"device": device})
+	currentShelfGauge.WithLabelValues(device, s.shelf.Name, strconv.Itoa(s.shelf.ID), strconv.Itoa(s.rowNumber), s.mode.String()).Set(1)
+	fmt.Printf("[%s] Mode: %s, Shelf: %s, Row: %d\n", device, s.mode, s.shelf.Name, s.rowNumber)
+}
+
+func (s *scanState) resetMode() {
+	s.mode = s.base
+	s.person = models.Person{}
+	s.audit = models.Audit{}
+	s.plan = models.Plan{}
+}
+
+// StartCLI reads codes from the devices and acts on them, each device with
+// its own state. Books that cannot be sent to the server are kept in the
+// queue until it is back. token is the API token sent to the server, if it
+// needs one. Metrics are served on metricsAddr unless it is empty.
+func StartCLI(serverURL, token, metricsAddr string, devices []Device, queue *scanqueue.Queue, lendingTimeout time.Duration) {
+	if metricsAddr != "" {
+		go func() {
+			e := echo.New()
+			e.HideBanner = true
+			e.Use(echoprometheus.NewMiddleware("librascan"))
+			e.GET("/metrics", echoprometheus.NewHandler())
+			e.Logger.Fatal(e.Start(metricsAddr))
+		}()
+	}
 
-	client := &http.Client{
-		Transport: http.DefaultTransport,
+	httpClient := &http.Client{
+		Transport: promhttp.InstrumentRoundTripperDuration(librascanAPIRequests, http.DefaultTransport),
 	}
-	client.Transport = promhttp.InstrumentRoundTripperDuration(librascanAPIRequests, client.Transport)
 
-	inputLoop(client, serverURL, inputDevicePath)
+	replay := make(chan struct{}, 1)
+	apiClient := client.New(serverURL, httpClient).WithToken(token)
+	go replayQueue(context.Background(), apiClient, queue, replay)
+
+	var wg sync.WaitGroup
+	for _, device := range devices {
+		slog.Info("Device started", "device", device.Name, "role", device.Role)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inputLoop(apiClient, device, runSources(context.Background(), device.Sources), queue, replay, lendingTimeout)
+		}()
+	}
+	wg.Wait()
 }
 
-func inputLoop(httpClient *http.Client, serverURL string, inputDevicePath string) {
-	shelf, rowNumber, err := getShelfFromCode(httpClient, serverURL, "00000")
+// inputLoop acts on the scans of a device until scans is closed. All the
+// device's sources share the same state, so a shelf or mode scanned on one
+// applies to the others too.
+func inputLoop(apiClient *client.Client, device Device, scans <-chan scan, queue *scanqueue.Queue, replay chan<- struct{}, lendingTimeout time.Duration) {
+	shelf, err := apiClient.GetShelf(device.Location.ShelfID)
 	if err != nil {
 		log.Fatalln("Cannot get shelf:", err)
 	}
-	currentShelfGauge.WithLabelValues(shelf.Name, strconv.Itoa(shelf.ID), strconv.Itoa(rowNumber)).Set(1)
+	base := device.Role.mode()
+	state := &scanState{
+		shelf:     shelf,
+		rowNumber: device.Location.Row,
+		slot:      device.Location.Slot,
+		mode:      base,
+		base:      base,
+	}
 
-	var getInput func() string
+	var reported string
+	for {
+		// Show where and how the device scans whenever that changes.
+		if now := fmt.Sprint(state.shelf.ID, state.rowNumber, state.mode); now != reported {
+			state.report(device.Name)
+			reported = now
+		}
+		fmt.Printf("[%s] %s\n", device.Name, state.prompt())
 
-	getInput = func() string {
-		var input string
-		_, _ = fmt.Scanln(&input)
-		return input
-	}
+		// Lending and return mode fall back to the device's own mode when
+		// nobody scans for a while.
+		var timeout <-chan time.Time
+		if (state.mode == modeLending || state.mode == modeReturn) && state.mode != state.base {
+			timeout = time.After(lendingTimeout)
+		}
 
-	if inputDevicePath != "" {
-		device, err := grabAndSetupDevice(inputDevicePath)
-		if err != nil {
-			log.Fatalln("Cannot open device:", err)
+		var in scan
+		select {
+		case s, ok := <-scans:
+			if !ok {
+				return
+			}
+			in = s
+		case <-timeout:
+			slog.Info("No scans for a while; leaving mode", "mode", state.mode, "device", device.Name)
+			state.resetMode()
+			continue
 		}
 
-		getInput = func() string {
-			return device.read()
+		// A shaky trigger can scan the same code twice in a row. Codes seen
+		// again within the debounce time of when they were accepted are
+		// dropped, so a code held in front of the scanner still goes through
+		// once per debounce time.
+		now := time.Now()
+		if in.code == state.lastCode && now.Sub(state.lastSeen) < device.Debounce {
+			scansDebouncedCounter.WithLabelValues(in.source).Inc()
+			slog.Info("Ignoring repeated scan", "code", in.code, "device", device.Name, "source", in.source)
+			continue
 		}
-	}
+		state.lastCode, state.lastSeen = in.code, now
 
-	for {
-		fmt.Println("Enter ISBN13 or shelfCode: ")
+		input := in.code
+		scansCounter.WithLabelValues(in.source).Inc()
+		fmt.Println("Input:", input, "Device:", device.Name, "Source:", in.source)
 
-		input := getInput()
+		// record tells the server about a scan it has not acted on, so that
+		// every scan shows up in its log.
+		record := func(action, outcome string) {
+			recordScan(apiClient, models.ScanEventRequest{
+				Device:    device.Name,
+				Source:    in.source,
+				Code:      input,
+				Mode:      state.mode.String(),
+				Action:    action,
+				Outcome:   outcome,
+				ShelfID:   state.shelf.ID,
+				RowNumber: state.rowNumber,
+				Slot:      state.slot,
+			})
+		}
 
-		fmt.Println("Input:", input)
+		code := scancode.Parse(input)
+		switch code.Kind {
+		case scancode.Shelf:
+			if state.mode == modeAudit {
+				if state.audit.ID != 0 {
+					closeAudit(apiClient, state.audit)
+				}
+				a, err := apiClient.StartAudit(models.AuditRequest{Location: input})
+				if err != nil {
+					slog.Error("cannot start audit", "error", err, "location", input, "device", device.Name, "source", in.source)
+					record("audit", outcomeFailed)
+					continue
+				}
+				state.audit = a
+				slog.Info("Audit started", "audit", a.ID, "location", strings.Join(a.LocationPath, " › "), "device", device.Name, "source", in.source)
+				record("audit", outcomeOK)
+				continue
+			}
 
-		// EAN Codes can be 8 or 13 digits long.
-		// We are using the 8 digit EAN codes for shelf codes.
-		if len(input) == 8 {
-			prevShelf := shelf
-			prevRow := rowNumber
+			prevShelf := state.shelf
 
-			shelf, rowNumber, err = getShelfFromCode(httpClient, serverURL, input)
+			shelf, err := apiClient.GetShelf(code.ShelfID)
 			if err != nil {
-				slog.Error("cannot get shelf; using previous shelf", "error", err, "prev_shelf", prevShelf.Name)
-				shelf = prevShelf
-				rowNumber = prevRow
-			} else {
-				currentShelfGauge.WithLabelValues(shelf.Name, strconv.Itoa(shelf.ID), strconv.Itoa(rowNumber)).Set(1)
-				slog.Info("Shelf changed", "shelf", shelf.Name, "row", rowNumber)
+				slog.Error("cannot get shelf; using previous shelf", "error", err, "prev_shelf", prevShelf.Name, "device", device.Name, "source", in.source)
+				record("shelf", outcomeFailed)
+				continue
 			}
 
-			continue
-		}
+			state.shelf = shelf
+			state.rowNumber = code.Row
+			state.slot = code.Slot
+			slog.Info("Shelf changed", "shelf", shelf.Name, "row", code.Row, "slot", code.Slot, "device", device.Name, "source", in.source)
+			record("shelf", outcomeOK)
+
+		case scancode.PersonCard:
+			person, err := apiClient.GetPerson(code.PersonID)
+			if err != nil {
+				slog.Error("cannot get person", "error", err, "person_id", code.PersonID, "device", device.Name, "source", in.source)
+				record("person", outcomeFailed)
+				continue
+			}
 
-		if len(input) != 13 {
+			state.mode = modeLending
+			state.person = person
+			slog.Info("Mode changed", "mode", state.mode, "person", person.Name, "device", device.Name, "source", in.source)
+			record("person", outcomeOK)
+
+		case scancode.CommandCard:
+			if code.Command == scancode.CommandUndo {
+				if state.queued != nil {
+					removed, err := unqueue(queue, *state.queued)
+					if err != nil {
+						slog.Error("cannot take scan off the queue", "error", err, "isbn", state.queued.ISBN, "device", device.Name, "source", in.source)
+						continue
+					}
+					if removed {
+						fmt.Println("Undone: add", state.queued.ISBN, "(taken off the queue)")
+						state.queued = nil
+						record("undo", outcomeOK)
+						continue
+					}
+					// It has reached the server since, so the server undoes it.
+					state.queued = nil
+				}
+				undone, err := undoLastScan(apiClient, device.Name, in.source)
+				if err != nil {
+					slog.Error("cannot undo", "error", err, "device", device.Name, "source", in.source)
+					continue
+				}
+				fmt.Println("Undone:", undone.Action, undone.Code)
+				continue
+			}
+
+			switch code.Command {
+			case scancode.CommandReturnMode:
+				state.resetMode()
+				state.mode = modeReturn
+			case scancode.CommandAuditMode:
+				if state.audit.ID != 0 {
+					closeAudit(apiClient, state.audit)
+				}
+				state.resetMode()
+				state.mode = modeAudit
+			case scancode.CommandReshelveMode:
+				if state.audit.ID != 0 {
+					closeAudit(apiClient, state.audit)
+				}
+				state.resetMode()
+				plan, err := openPlan(apiClient)
+				if err != nil {
+					slog.Error("cannot get reshelving plan", "error", err, "device", device.Name, "source", in.source)
+					continue
+				}
+				state.mode = modeReshelve
+				state.plan = plan
+				printNextMove(plan.Moves)
+			case scancode.CommandCatalogueMode, scancode.CommandDeleteMode, scancode.CommandMoveMode:
+				if state.audit.ID != 0 {
+					closeAudit(apiClient, state.audit)
+				}
+				state.resetMode()
+				state.mode = map[scancode.Command]scanMode{
+					scancode.CommandCatalogueMode: modeCatalogue,
+					scancode.CommandDeleteMode:    modeDelete,
+					scancode.CommandMoveMode:      modeMove,
+				}[code.Command]
+			case scancode.CommandEndSession:
+				if state.audit.ID != 0 {
+					closeAudit(apiClient, state.audit)
+				}
+				state.resetMode()
+			default:
+				fmt.Println("Unknown command:", code.Command)
+				record("mode", outcomeFailed)
+				continue
+			}
+			slog.Info("Mode changed", "mode", state.mode, "device", device.Name, "source", in.source)
+			record("mode", outcomeOK)
+
+		case scancode.ISBN:
+			// Scanners can send an add-on after the ISBN, which only
+			// matters when the book is added.
+			isbn := code.EAN
+			state.queued = nil
+			forScan := scanFor(device.Name, in, state.mode)
+			switch state.mode {
+			case modeLending:
+				fmt.Println("ISBN:", isbn, "Lending to:", state.person.Name)
+				if !borrowBook(apiClient, isbn, state.person, forScan) {
+					record("borrow", outcomeFailed)
+				}
+			case modeReturn:
+				fmt.Println("ISBN:", isbn, "Returning")
+				if !returnBook(apiClient, isbn, forScan) {
+					record("return", outcomeFailed)
+				}
+			case modeAudit:
+				if state.audit.ID == 0 {
+					fmt.Println("Scan the code of the row to audit first")
+					record("audit", outcomeFailed)
+					continue
+				}
+				if !auditBook(apiClient, state.audit, isbn) {
+					record("audit", outcomeFailed)
+					continue
+				}
+				record("audit", outcomeOK)
+			case modeReshelve:
+				done, ok := confirmMove(apiClient, state.plan, isbn)
+				if !ok {
+					record("reshelve", outcomeFailed)
+					continue
+				}
+				record("reshelve", outcomeOK)
+				if done {
+					slog.Info("Reshelving plan completed", "plan", state.plan.ID, "device", device.Name, "source", in.source)
+					state.resetMode()
+				}
+			case modeDelete:
+				fmt.Println("ISBN:", isbn, "Deleting")
+				if !removeBook(apiClient, isbn, forScan) {
+					record("delete", outcomeFailed)
+				}
+			case modeMove:
+				fmt.Println("ISBN:", isbn, "Moving to:", state.shelf.Name, "Row:", state.rowNumber, "Slot:", state.slot)
+				if !relocateBook(apiClient, isbn, state.shelf.ID, state.rowNumber, state.slot, forScan) {
+					record("move", outcomeFailed)
+				}
+			default:
+				fmt.Println("ISBN:", isbn, "Shelf:", state.shelf.Name, "Row:", state.rowNumber, "Slot:", state.slot)
+				booksProcessedCounter.Inc()
+				s := scanqueue.Scan{
+					ISBN:      isbn,
+					AddOn:     code.AddOn,
+					ShelfID:   state.shelf.ID,
+					RowNumber: state.rowNumber,
+					Slot:      state.slot,
+					Source:    in.source,
+					Device:    device.Name,
+					ScannedAt: time.Now(),
+				}
+				book, status := ingestBook(apiClient, queue, s, replay)
+				switch status {
+				case ingestSent:
+					if device.Bell {
+						ringBell(book.Outcome)
+					}
+				case ingestFailed:
+					record("add", outcomeFailed)
+				case ingestQueued:
+					state.queued = &s
+					record("add", outcomeQueued)
+				}
+			}
+
+		case scancode.Media:
+			// Non-book media such as CDs and DVDs are not catalogued.
+			fmt.Println("Not a book:", input)
+			record("media", outcomeSkipped)
+
+		default:
 			fmt.Println("Invalid ISBN")
-			continue
+			record("invalid", outcomeFailed)
 		}
-
-		fmt.Println("ISBN:", input, "Shelf:", shelf.Name, "Row:", rowNumber)
-		booksProcessedCounter.Inc()
-		ingestBook(httpClient, serverURL, input, shelf.ID, rowNumber)
 	}
 }
 
-func ingestBook(httpClient *http.Client, serverURL, isbn string, shelfID, rowNumber int) {
-	// Use the provided serverURL instead of the hardcoded value.
-	fullURL := fmt.Sprintf("%s/api/v1/books/%s?shelf_id=%d&row_number=%d", serverURL, isbn, shelfID, rowNumber)
-	resp, err := httpClient.Post(fullURL, "application/json", io.Reader(nil))
+// ingestStatus is what became of a scanned book.
+type ingestStatus int
+
+const (
+	ingestFailed ingestStatus = iota
+	ingestSent
+	ingestQueued
+)
+
+// ingestBook adds a scanned book to the library. When the server cannot be
+// reached the scan is queued, and scans go behind any that are queued already
+// so they reach the server in the order they were scanned.
+func ingestBook(apiClient *client.Client, queue *scanqueue.Queue, s scanqueue.Scan, replay chan<- struct{}) (api.AddedBook, ingestStatus) {
+	queued, err := queue.Len()
 	if err != nil {
-		slog.Error("cannot post ISBN", "error", err)
-		booksFailedCounter.Inc()
-		return
+		slog.Error("cannot read scan queue", "error", err)
 	}
-
-	if resp.StatusCode/100 != 2 {
-		slog.Error("unexpected status code", "status", resp.StatusCode)
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			slog.Error("cannot read response body", "error", err)
+	if queued == 0 {
+		book, err := postBook(apiClient, s)
+		if err == nil {
+			fmt.Println(describeAdded(book))
+			return book, ingestSent
 		}
-		fmt.Println("Body:", string(body))
-		booksFailedCounter.Inc()
-		return
-	} else {
-		book := models.Book{}
-		if err := json.NewDecoder(resp.Body).Decode(&book); err != nil {
-			slog.Error("cannot decode response body", "error", err)
+		if errors.Is(err, errRejected) {
+			slog.Error("cannot add book", "error", err, "isbn", s.ISBN)
 			booksFailedCounter.Inc()
-			return
+			return api.AddedBook{}, ingestFailed
 		}
+		slog.Warn("cannot reach server; queueing scan", "error", err, "isbn", s.ISBN)
+	}
 
-		fmt.Println("Book:", book)
+	if err := queue.Push(s); err != nil {
+		slog.Error("cannot queue scan; it is lost", "error", err, "isbn", s.ISBN)
+		booksFailedCounter.Inc()
+		return api.AddedBook{}, ingestFailed
 	}
+	queuedScansGauge.Set(float64(queued + 1))
+	fmt.Println("Queued", s.ISBN, "to send when the server is back;", queued+1, "scans waiting")
+	select {
+	case replay <- struct{}{}:
+	default:
+	}
+	return api.AddedBook{}, ingestQueued
+}
 
-	if err := resp.Body.Close(); err != nil {
-		slog.Error("cannot close response body", "error", err)
+// describeAdded says what adding a book did.
+func describeAdded(book api.AddedBook) string {
+	title := book.Title
+	if title == "" {
+		title = strconv.Itoa(book.ISBN)
+	}
+	switch book.Outcome {
+	case models.AddOutcomeAlreadyHere:
+		return fmt.Sprintf("Already here: %s", title)
+	case models.AddOutcomeNewCopy:
+		return fmt.Sprintf("New copy of %s, %d copies", title, book.Copies)
+	case models.AddOutcomeMoved:
+		from := api.Place{}
+		if book.MovedFrom != nil {
+			from = *book.MovedFrom
+		}
+		return fmt.Sprintf("Moved %s from %s, row %d to %s, row %d", title, from.ShelfName, from.RowNumber, book.ShelfName, book.RowNumber)
+	default:
+		return fmt.Sprintf("Added: %s", title)
 	}
 }
 
-func getShelfFromCode(httpClient *http.Client, serverURL, shelfCodeStr string) (models.Shelf, int, error) {
-	shelfCode, err := strconv.Atoi(shelfCodeStr)
+// ringBell rings the terminal bell for books that were not new: twice for a
+// book that is already where it was scanned, and once for a book that moved.
+func ringBell(outcome string) {
+	switch outcome {
+	case models.AddOutcomeAlreadyHere:
+		fmt.Print("\a\a")
+	case models.AddOutcomeMoved:
+		fmt.Print("\a")
+	}
+}
+
+// borrowBook lends a book to person and reports whether it was lent. The
+// server records scan.
+func borrowBook(apiClient *client.Client, isbnStr string, person models.Person, scan client.Scan) bool {
+	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return models.Shelf{}, 0, fmt.Errorf("invalid shelf code: %w", err)
+		slog.Error("invalid ISBN", "error", err)
+		return false
+	}
+
+	req := models.BorrowRequest{ISBN: isbn, PersonName: person.Name}
+	if err := apiClient.BorrowBook(req, scan); err != nil {
+		slog.Error("cannot borrow book", "error", err, "isbn", isbn, "person", person.Name)
+		lendingFailedCounter.Inc()
+		return false
 	}
-	// The last digit is a checksum.
-	shelfCode /= 10
 
-	rowNumber := shelfCode % 10
-	shelfID := shelfCode / 10
+	booksLentCounter.Inc()
+	fmt.Println("Lent", isbn, "to", person.Name)
+	return true
+}
 
-	fullURL := fmt.Sprintf("%s/api/v1/shelf/%d", serverURL, shelfID)
-	resp, err := httpClient.Get(fullURL)
+// returnBook returns a book and reports whether it was returned. The server
+// records scan.
+func returnBook(apiClient *client.Client, isbnStr string, scan client.Scan) bool {
+	isbn, err := strconv.Atoi(isbnStr)
 	if err != nil {
-		return models.Shelf{}, 0, fmt.Errorf("cannot get shelf: %w", err)
+		slog.Error("invalid ISBN", "error", err)
+		return false
+	}
+
+	if err := apiClient.ReturnBook(models.ReturnRequest{ISBN: isbn}, scan); err != nil {
+		slog.Error("cannot return book", "error", err, "isbn", isbn)
+		lendingFailedCounter.Inc()
+		return false
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("failed to close response body: %v", err)
+
+	booksReturnedCounter.Inc()
+	fmt.Println("Returned", isbn)
+	return true
+}
+
+// auditBook records a book found in the row being audited and reports
+// whether it was recorded.
+func auditBook(apiClient *client.Client, a models.Audit, isbnStr string) bool {
+	isbn, err := strconv.Atoi(isbnStr)
+	if err != nil {
+		slog.Error("invalid ISBN", "error", err)
+		return false
+	}
+
+	scan, err := apiClient.AddAuditScan(a.ID, models.AuditScanRequest{ISBN: isbn})
+	if err != nil {
+		slog.Error("cannot record audit scan", "error", err, "isbn", isbn)
+		return false
+	}
+
+	auditScansCounter.WithLabelValues(scan.Status).Inc()
+	switch {
+	case scan.Book == nil:
+		fmt.Println("Unknown book:", isbn)
+	case scan.Status == audit.StatusMisplaced:
+		fmt.Println("Misplaced:", scan.Book.Title, "is recorded in", strings.Join(scan.Book.LocationPath, " › "))
+	default:
+		fmt.Println("Found:", scan.Book.Title)
+	}
+	if scan.Borrowed {
+		fmt.Println("Still on loan to", scan.Book.Borrower)
+	}
+	return true
+}
+
+func closeAudit(apiClient *client.Client, a models.Audit) {
+	report, err := apiClient.CloseAudit(a.ID)
+	if err != nil {
+		slog.Error("cannot close audit", "error", err, "audit", a.ID)
+		return
+	}
+
+	fmt.Printf("Audit of %s: %d found, %d missing, %d misplaced, %d unknown, %d on loan\n",
+		strings.Join(report.LocationPath, " › "), len(report.Found), len(report.Missing),
+		len(report.Misplaced), len(report.Unknown), len(report.Borrowed))
+	for _, book := range report.Missing {
+		fmt.Println("  Missing:", book.ISBN, book.Title)
+	}
+	for _, book := range report.Misplaced {
+		fmt.Println("  Misplaced:", book.ISBN, book.Title, "recorded in", strings.Join(book.LocationPath, " › "))
+	}
+	for _, isbn := range report.Unknown {
+		fmt.Println("  Unknown:", isbn)
+	}
+	if len(report.Corrections) > 0 {
+		fmt.Printf("Apply the %d corrections with POST %s/audits/%d/apply\n", len(report.Corrections), api.Prefix, a.ID)
+	}
+}
+
+// openPlan returns the newest reshelving plan that is not completed yet.
+func openPlan(apiClient *client.Client) (models.Plan, error) {
+	plans, err := apiClient.ListPlans()
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("cannot get plans: %w", err)
+	}
+	for _, plan := range plans {
+		if plan.CompletedAt == nil {
+			return plan, nil
 		}
-	}()
+	}
+	return models.Plan{}, fmt.Errorf("no open reshelving plan")
+}
 
-	if resp.StatusCode/100 != 2 {
-		return models.Shelf{}, 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// confirmMove confirms that a book has been put in its new place. It reports
+// whether that was the last move of the plan, and whether it was confirmed.
+func confirmMove(apiClient *client.Client, plan models.Plan, isbnStr string) (done, ok bool) {
+	isbn, err := strconv.Atoi(isbnStr)
+	if err != nil {
+		slog.Error("invalid ISBN", "error", err)
+		return false, false
 	}
 
-	shelf := models.Shelf{}
-	if err := json.NewDecoder(resp.Body).Decode(&shelf); err != nil {
-		return models.Shelf{}, 0, fmt.Errorf("cannot decode shelf response: %w", err)
+	result, err := apiClient.ConfirmPlanMove(plan.ID, models.PlanConfirmRequest{ISBN: isbn})
+	if err != nil {
+		slog.Error("cannot confirm move", "error", err, "isbn", isbn, "plan", plan.ID)
+		return false, false
+	}
+
+	planMovesCounter.Inc()
+	fmt.Printf("Done step %d: %s\n", result.Move.Step, result.Move.Title)
+	if result.Completed {
+		fmt.Println("All books are in place.")
+		return true, true
+	}
+	fmt.Println(result.Remaining, "moves left")
+	if result.Next != nil {
+		printNextMove([]models.PlanMove{*result.Next})
+	}
+	return false, true
+}
+
+// printNextMove prints the first move that is not done yet.
+func printNextMove(moves []models.PlanMove) {
+	for _, move := range moves {
+		if !move.Done {
+			fmt.Printf("Next, step %d: %s\n", move.Step, move.Instruction)
+			return
+		}
 	}
-	return shelf, rowNumber, nil
 }
@@ -0,0 +1,233 @@
+package readIsbn
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// keyChars are what a key types on its own, with Shift and with AltGr.
+type keyChars struct {
+	base, shift, altGr string
+}
+
+// Layout maps the keys a scanner presses to the characters they type. A
+// scanner that acts as a keyboard presses the keys for the layout it is set
+// to, so the reader has to use the same one.
+type Layout struct {
+	Name string
+	keys map[evdev.EvCode]keyChars
+}
+
+// letterRow are the letter keys on a QWERTY keyboard.
+var letterRow = []evdev.EvCode{
+	evdev.KEY_A, evdev.KEY_B, evdev.KEY_C, evdev.KEY_D, evdev.KEY_E, evdev.KEY_F,
+	evdev.KEY_G, evdev.KEY_H, evdev.KEY_I, evdev.KEY_J, evdev.KEY_K, evdev.KEY_L,
+	evdev.KEY_M, evdev.KEY_N, evdev.KEY_O, evdev.KEY_P, evdev.KEY_Q, evdev.KEY_R,
+	evdev.KEY_S, evdev.KEY_T, evdev.KEY_U, evdev.KEY_V, evdev.KEY_W, evdev.KEY_X,
+	evdev.KEY_Y, evdev.KEY_Z,
+}
+
+// newLayout starts from the US layout and applies the keys that differ.
+func newLayout(name string, keys map[evdev.EvCode]keyChars) *Layout {
+	l := &Layout{Name: name, keys: map[evdev.EvCode]keyChars{}}
+	for i, code := range letterRow {
+		letter := string(rune('a' + i))
+		l.keys[code] = keyChars{base: letter, shift: strings.ToUpper(letter)}
+	}
+	for code, chars := range usKeys {
+		l.keys[code] = chars
+	}
+	// The keypad types the same everywhere, assuming Num Lock is on.
+	keypad := []evdev.EvCode{
+		evdev.KEY_KP0, evdev.KEY_KP1, evdev.KEY_KP2, evdev.KEY_KP3, evdev.KEY_KP4,
+		evdev.KEY_KP5, evdev.KEY_KP6, evdev.KEY_KP7, evdev.KEY_KP8, evdev.KEY_KP9,
+	}
+	for i, code := range keypad {
+		l.keys[code] = keyChars{base: fmt.Sprint(i), shift: fmt.Sprint(i)}
+	}
+	for code, chars := range map[evdev.EvCode]string{
+		evdev.KEY_KPMINUS: "-", evdev.KEY_KPPLUS: "+", evdev.KEY_KPASTERISK: "*",
+		evdev.KEY_KPSLASH: "/", evdev.KEY_KPDOT: ".",
+	} {
+		l.keys[code] = keyChars{base: chars, shift: chars}
+	}
+	for code, chars := range keys {
+		l.keys[code] = chars
+	}
+	return l
+}
+
+var usKeys = map[evdev.EvCode]keyChars{
+	evdev.KEY_GRAVE: {"`", "~", ""},
+	evdev.KEY_1:     {"1", "!", ""}, evdev.KEY_2: {"2", "@", ""}, evdev.KEY_3: {"3", "#", ""},
+	evdev.KEY_4: {"4", "$", ""}, evdev.KEY_5: {"5", "%", ""}, evdev.KEY_6: {"6", "^", ""},
+	evdev.KEY_7: {"7", "&", ""}, evdev.KEY_8: {"8", "*", ""}, evdev.KEY_9: {"9", "(", ""},
+	evdev.KEY_0: {"0", ")", ""}, evdev.KEY_MINUS: {"-", "_", ""}, evdev.KEY_EQUAL: {"=", "+", ""},
+	evdev.KEY_LEFTBRACE: {"[", "{", ""}, evdev.KEY_RIGHTBRACE: {"]", "}", ""},
+	evdev.KEY_BACKSLASH: {"\\", "|", ""}, evdev.KEY_SEMICOLON: {";", ":", ""},
+	evdev.KEY_APOSTROPHE: {"'", "\"", ""}, evdev.KEY_COMMA: {",", "<", ""},
+	evdev.KEY_DOT: {".", ">", ""}, evdev.KEY_SLASH: {"/", "?", ""},
+	evdev.KEY_SPACE: {" ", " ", ""},
+}
+
+// Layouts are the keyboard layouts scanners can be read with.
+var Layouts = map[string]*Layout{
+	"us": newLayout("us", nil),
+	// German QWERTZ.
+	"de": newLayout("de", map[evdev.EvCode]keyChars{
+		evdev.KEY_Y: {"z", "Z", ""}, evdev.KEY_Z: {"y", "Y", ""},
+		evdev.KEY_Q: {"q", "Q", "@"}, evdev.KEY_E: {"e", "E", "€"},
+		evdev.KEY_GRAVE: {"^", "°", ""},
+		evdev.KEY_2:     {"2", "\"", "²"}, evdev.KEY_3: {"3", "§", "³"},
+		evdev.KEY_6: {"6", "&", ""}, evdev.KEY_7: {"7", "/", "{"},
+		evdev.KEY_8: {"8", "(", "["}, evdev.KEY_9: {"9", ")", "]"},
+		evdev.KEY_0: {"0", "=", "}"}, evdev.KEY_MINUS: {"ß", "?", "\\"},
+		evdev.KEY_EQUAL: {"´", "`", ""}, evdev.KEY_LEFTBRACE: {"ü", "Ü", ""},
+		evdev.KEY_RIGHTBRACE: {"+", "*", "~"}, evdev.KEY_SEMICOLON: {"ö", "Ö", ""},
+		evdev.KEY_APOSTROPHE: {"ä", "Ä", ""}, evdev.KEY_BACKSLASH: {"#", "'", ""},
+		evdev.KEY_COMMA: {",", ";", ""}, evdev.KEY_DOT: {".", ":", ""},
+		evdev.KEY_SLASH: {"-", "_", ""}, evdev.KEY_102ND: {"<", ">", "|"},
+	}),
+	// French AZERTY, where the digits need Shift.
+	"fr": newLayout("fr", map[evdev.EvCode]keyChars{
+		evdev.KEY_Q: {"a", "A", ""}, evdev.KEY_A: {"q", "Q", ""},
+		evdev.KEY_W: {"z", "Z", ""}, evdev.KEY_Z: {"w", "W", ""},
+		evdev.KEY_SEMICOLON: {"m", "M", ""}, evdev.KEY_M: {",", "?", ""},
+		evdev.KEY_E:     {"e", "E", "€"},
+		evdev.KEY_GRAVE: {"²", "", ""},
+		evdev.KEY_1:     {"&", "1", ""}, evdev.KEY_2: {"é", "2", "~"},
+		evdev.KEY_3: {"\"", "3", "#"}, evdev.KEY_4: {"'", "4", "{"},
+		evdev.KEY_5: {"(", "5", "["}, evdev.KEY_6: {"-", "6", "|"},
+		evdev.KEY_7: {"è", "7", "`"}, evdev.KEY_8: {"_", "8", "\\"},
+		evdev.KEY_9: {"ç", "9", "^"}, evdev.KEY_0: {"à", "0", "@"},
+		evdev.KEY_MINUS: {")", "°", "]"}, evdev.KEY_EQUAL: {"=", "+", "}"},
+		evdev.KEY_LEFTBRACE: {"^", "¨", ""}, evdev.KEY_RIGHTBRACE: {"$", "£", "¤"},
+		evdev.KEY_APOSTROPHE: {"ù", "%", ""}, evdev.KEY_BACKSLASH: {"*", "µ", ""},
+		evdev.KEY_COMMA: {";", ".", ""}, evdev.KEY_DOT: {":", "/", ""},
+		evdev.KEY_SLASH: {"!", "§", ""}, evdev.KEY_102ND: {"<", ">", ""},
+	}),
+}
+
+// LayoutNames returns the names of the layouts, sorted.
+func LayoutNames() []string {
+	names := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// keyboard turns key events into characters, keeping track of the modifier
+// keys.
+type keyboard struct {
+	layout *Layout
+
+	leftShift, rightShift bool
+	altGr                 bool
+	capsLock              bool
+}
+
+// key handles a key event and returns the character it typed, if any. Enter
+// types "\n".
+func (k *keyboard) key(code evdev.EvCode, value int32) string {
+	pressed := value != 0
+	switch code {
+	case evdev.KEY_LEFTSHIFT:
+		k.leftShift = pressed
+		return ""
+	case evdev.KEY_RIGHTSHIFT:
+		k.rightShift = pressed
+		return ""
+	case evdev.KEY_RIGHTALT:
+		k.altGr = pressed
+		return ""
+	case evdev.KEY_CAPSLOCK:
+		if value == 1 {
+			k.capsLock = !k.capsLock
+		}
+		return ""
+	}
+
+	// Only presses type; releases and auto-repeat do not.
+	if value != 1 {
+		return ""
+	}
+	if code == evdev.KEY_ENTER || code == evdev.KEY_KPENTER {
+		return "\n"
+	}
+
+	chars, ok := k.layout.keys[code]
+	if !ok {
+		return ""
+	}
+	if k.altGr {
+		return chars.altGr
+	}
+	shift := k.leftShift || k.rightShift
+	// Caps Lock works like Shift, but only on letters.
+	if k.capsLock && isLetter(chars.base) {
+		shift = !shift
+	}
+	if shift {
+		return chars.shift
+	}
+	return chars.base
+}
+
+func isLetter(s string) bool {
+	r := []rune(s)
+	return len(r) == 1 && unicode.IsLetter(r[0]) && unicode.ToUpper(r[0]) != r[0]
+}
+
+// Framing is how a scanner marks where a code starts and ends. Scanners can
+// be set up to send a prefix before and a suffix after every code, which
+// keeps stray key presses out of the codes.
+type Framing struct {
+	// Prefix comes before every code. Anything typed outside a prefix and
+	// suffix is ignored. Empty when the scanner sends no prefix.
+	Prefix string
+	// Suffix ends every code. Enter when empty.
+	Suffix string
+}
+
+// framer cuts the characters a scanner types into codes.
+type framer struct {
+	Framing
+
+	buf     strings.Builder
+	started bool
+}
+
+// add adds a character and returns the code it completes, if any.
+func (f *framer) add(ch string) (string, bool) {
+	suffix := f.Suffix
+	if suffix == "" {
+		suffix = "\n"
+	}
+
+	f.buf.WriteString(ch)
+	s := f.buf.String()
+	if f.Prefix != "" && !f.started {
+		if strings.HasSuffix(s, f.Prefix) {
+			f.started = true
+			f.buf.Reset()
+		} else if ch == "\n" {
+			// Enter outside a code; start over.
+			f.buf.Reset()
+		}
+		return "", false
+	}
+	if !strings.HasSuffix(s, suffix) {
+		return "", false
+	}
+
+	f.buf.Reset()
+	f.started = false
+	code := strings.TrimSpace(strings.TrimSuffix(s, suffix))
+	return code, code != ""
+}
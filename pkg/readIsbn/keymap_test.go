@@ -0,0 +1,188 @@
+package readIsbn
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/holoplot/go-evdev"
+)
+
+// press is a key pressed and released, with Shift or AltGr held if set.
+type press struct {
+	code  evdev.EvCode
+	shift bool
+	altGr bool
+}
+
+// typeKeys sends the events for presses to a keyboard with layout and returns
+// the codes the framer cuts them into.
+func typeKeys(layout string, framing Framing, presses []press) []string {
+	k := keyboard{layout: Layouts[layout]}
+	f := framer{Framing: framing}
+
+	var codes []string
+	send := func(code evdev.EvCode, value int32) {
+		ch := k.key(code, value)
+		if ch == "" {
+			return
+		}
+		if code, ok := f.add(ch); ok {
+			codes = append(codes, code)
+		}
+	}
+	for _, p := range presses {
+		if p.shift {
+			send(evdev.KEY_LEFTSHIFT, 1)
+		}
+		if p.altGr {
+			send(evdev.KEY_RIGHTALT, 1)
+		}
+		send(p.code, 1)
+		send(p.code, 0)
+		if p.altGr {
+			send(evdev.KEY_RIGHTALT, 0)
+		}
+		if p.shift {
+			send(evdev.KEY_LEFTSHIFT, 0)
+		}
+	}
+	return codes
+}
+
+var enter = press{code: evdev.KEY_ENTER}
+
+func TestKeyboardLayouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		framing Framing
+		presses []press
+		want    []string
+	}{
+		{
+			name:   "us isbn-10 with check digit X",
+			layout: "us",
+			presses: []press{
+				{code: evdev.KEY_0}, {code: evdev.KEY_3}, {code: evdev.KEY_0}, {code: evdev.KEY_6},
+				{code: evdev.KEY_4}, {code: evdev.KEY_0}, {code: evdev.KEY_6}, {code: evdev.KEY_1},
+				{code: evdev.KEY_5}, {code: evdev.KEY_X, shift: true}, enter,
+			},
+			want: []string{"030640615X"},
+		},
+		{
+			name:   "us shelf label",
+			layout: "us",
+			presses: []press{
+				{code: evdev.KEY_L, shift: true}, {code: evdev.KEY_S, shift: true},
+				{code: evdev.KEY_SEMICOLON, shift: true}, {code: evdev.KEY_L, shift: true},
+				{code: evdev.KEY_SEMICOLON, shift: true}, {code: evdev.KEY_2},
+				{code: evdev.KEY_SEMICOLON, shift: true}, {code: evdev.KEY_3}, enter,
+			},
+			want: []string{"LS:L:2:3"},
+		},
+		{
+			name:   "de shelf label",
+			layout: "de",
+			presses: []press{
+				{code: evdev.KEY_L, shift: true}, {code: evdev.KEY_S, shift: true},
+				{code: evdev.KEY_DOT, shift: true}, {code: evdev.KEY_L, shift: true},
+				{code: evdev.KEY_DOT, shift: true}, {code: evdev.KEY_2},
+				{code: evdev.KEY_DOT, shift: true}, {code: evdev.KEY_3}, enter,
+			},
+			want: []string{"LS:L:2:3"},
+		},
+		{
+			name:   "de swaps y and z",
+			layout: "de",
+			presses: []press{
+				{code: evdev.KEY_Y, shift: true}, {code: evdev.KEY_Z}, {code: evdev.KEY_Q, altGr: true}, enter,
+			},
+			want: []string{"Zy@"},
+		},
+		{
+			name:   "fr digits need shift",
+			layout: "fr",
+			presses: []press{
+				{code: evdev.KEY_9, shift: true}, {code: evdev.KEY_7, shift: true}, {code: evdev.KEY_8, shift: true},
+				{code: evdev.KEY_1}, {code: evdev.KEY_2}, enter,
+			},
+			want: []string{"978&é"},
+		},
+		{
+			name:   "fr shelf label",
+			layout: "fr",
+			presses: []press{
+				{code: evdev.KEY_L, shift: true}, {code: evdev.KEY_S, shift: true},
+				{code: evdev.KEY_DOT}, {code: evdev.KEY_L, shift: true},
+				{code: evdev.KEY_DOT}, {code: evdev.KEY_2, shift: true},
+				{code: evdev.KEY_DOT}, {code: evdev.KEY_3, shift: true}, enter,
+			},
+			want: []string{"LS:L:2:3"},
+		},
+		{
+			name:   "caps lock only affects letters",
+			layout: "us",
+			presses: []press{
+				{code: evdev.KEY_CAPSLOCK}, {code: evdev.KEY_L}, {code: evdev.KEY_S},
+				{code: evdev.KEY_SEMICOLON, shift: true}, {code: evdev.KEY_1}, {code: evdev.KEY_A, shift: true},
+				{code: evdev.KEY_CAPSLOCK}, {code: evdev.KEY_B}, enter,
+			},
+			want: []string{"LS:1ab"},
+		},
+		{
+			name:   "keypad and keypad enter",
+			layout: "fr",
+			presses: []press{
+				{code: evdev.KEY_KP9}, {code: evdev.KEY_KP7}, {code: evdev.KEY_KP8}, {code: evdev.KEY_KPENTER},
+			},
+			want: []string{"978"},
+		},
+		{
+			name:    "prefix and suffix",
+			layout:  "us",
+			framing: Framing{Prefix: "#", Suffix: "$"},
+			presses: []press{
+				// A stray key press and Enter before the code are ignored.
+				{code: evdev.KEY_Q}, enter,
+				{code: evdev.KEY_3, shift: true}, {code: evdev.KEY_1}, {code: evdev.KEY_2},
+				{code: evdev.KEY_4, shift: true}, enter,
+				{code: evdev.KEY_3, shift: true}, {code: evdev.KEY_3},
+				{code: evdev.KEY_4, shift: true},
+			},
+			want: []string{"12", "3"},
+		},
+		{
+			name:   "empty lines are skipped",
+			layout: "us",
+			presses: []press{
+				enter, {code: evdev.KEY_SPACE}, enter, {code: evdev.KEY_4}, {code: evdev.KEY_2}, enter,
+			},
+			want: []string{"42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := typeKeys(tt.layout, tt.framing, tt.presses)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected codes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestKeyboardIgnoresRepeatsAndReleases(t *testing.T) {
+	k := keyboard{layout: Layouts["us"]}
+	if got := k.key(evdev.KEY_1, 1); got != "1" {
+		t.Errorf("press typed %q, want \"1\"", got)
+	}
+	if got := k.key(evdev.KEY_1, 2); got != "" {
+		t.Errorf("auto-repeat typed %q", got)
+	}
+	if got := k.key(evdev.KEY_1, 0); got != "" {
+		t.Errorf("release typed %q", got)
+	}
+	if got := k.key(evdev.KEY_F1, 1); got != "" {
+		t.Errorf("F1 typed %q", got)
+	}
+}
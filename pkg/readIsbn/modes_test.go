@@ -0,0 +1,250 @@
+package readIsbn
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scancode"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeLibrary keeps the books it is sent by ISBN, as "shelf/row". Like the
+// server, it undoes the last change made for a scanner.
+type fakeLibrary struct {
+	mu    sync.Mutex
+	books map[string]string
+	// changes are the places of the books changed for scanners before they
+	// were changed, newest last. A book that was not there has no place.
+	changes []bookChange
+	// recorded are the actions and outcomes of the other scans.
+	recorded []string
+	// addOns are the add-ons sent with the books that had them.
+	addOns map[string]string
+}
+
+type bookChange struct {
+	isbn, before string
+}
+
+func (f *fakeLibrary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	if id, ok := strings.CutPrefix(path, "shelf/"); ok {
+		n, _ := strconv.Atoi(id)
+		_ = json.NewEncoder(w).Encode(models.Shelf{ID: n, Name: "shelf " + id})
+		return
+	}
+	switch path {
+	case "scans":
+		req := models.ScanEventRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.recorded = append(f.recorded, req.Action+" "+req.Outcome)
+		_ = json.NewEncoder(w).Encode(models.ScanEvent{})
+		return
+	case "scans/undo":
+		if len(f.changes) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		last := f.changes[len(f.changes)-1]
+		f.changes = f.changes[:len(f.changes)-1]
+		if last.before == "" {
+			delete(f.books, last.isbn)
+		} else {
+			f.books[last.isbn] = last.before
+		}
+		_ = json.NewEncoder(w).Encode(models.ScanEvent{Action: "undo", Code: last.isbn})
+		return
+	}
+
+	isbn, moving := strings.CutSuffix(strings.TrimPrefix(path, "books/"), "/location")
+	if r.Method != http.MethodGet && r.URL.Query().Get("scan_device") != "" {
+		f.changes = append(f.changes, bookChange{isbn: isbn, before: f.books[isbn]})
+	}
+	switch {
+	case r.Method == http.MethodPost:
+		f.books[isbn] = r.URL.Query().Get("shelf_id") + "/" + r.URL.Query().Get("row_number")
+		if addOn := r.URL.Query().Get("add_on"); addOn != "" {
+			f.addOns[isbn] = addOn
+		}
+	case f.books[isbn] == "":
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case r.Method == http.MethodDelete:
+		delete(f.books, isbn)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case moving:
+		req := api.BookLocationRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.books[isbn] = strconv.Itoa(req.ShelfID) + "/" + strconv.Itoa(req.RowNumber)
+	}
+
+	shelf, row, _ := strings.Cut(f.books[isbn], "/")
+	book := models.Book{Title: isbn}
+	book.ShelfID, _ = strconv.Atoi(shelf)
+	book.RowNumber, _ = strconv.Atoi(row)
+	_ = json.NewEncoder(w).Encode(book)
+}
+
+func (f *fakeLibrary) got() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	got := map[string]string{}
+	for isbn, place := range f.books {
+		got[isbn] = place
+	}
+	return got
+}
+
+func TestControlCodes(t *testing.T) {
+	library := &fakeLibrary{books: map[string]string{}, addOns: map[string]string{}}
+	ts := httptest.NewServer(library)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		codes []string
+		want  map[string]string
+	}{
+		{
+			codes: []string{"LS:L:1:1", "9780000000001", "9780000000002"},
+			want:  map[string]string{"9780000000001": "1/1", "9780000000002": "1/1"},
+		},
+		{
+			codes: []string{"UNDO"},
+			want:  map[string]string{"9780000000001": "1/1"},
+		},
+		{
+			codes: []string{"MODE:MOVE", "LS:L:2:3", "9780000000001"},
+			want:  map[string]string{"9780000000001": "2/3"},
+		},
+		{
+			codes: []string{"UNDO"},
+			want:  map[string]string{"9780000000001": "1/1"},
+		},
+		{
+			codes: []string{"mode:delete", "9780000000001"},
+			want:  map[string]string{},
+		},
+		{
+			// Deleted books come back where they were.
+			codes: []string{"UNDO"},
+			want:  map[string]string{"9780000000001": "1/1"},
+		},
+		{
+			// Ending the session goes back to adding books.
+			codes: []string{"MODE:DELETE", "END SESSION", "9780000000003"},
+			want:  map[string]string{"9780000000001": "1/1", "9780000000003": "2/3"},
+		},
+		{
+			// Each UNDO goes one scan further back.
+			codes: []string{"UNDO", "UNDO"},
+			want:  map[string]string{},
+		},
+	}
+
+	scans := make(chan scan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inputLoop(client.New(ts.URL, nil), Device{Name: "control-test"}, scans, queue, make(chan struct{}, 1), time.Minute)
+	}()
+
+	for i, step := range steps {
+		for _, code := range step.codes {
+			scans <- scan{source: "test", code: code}
+		}
+		// Wait for the last scan to be handled by sending a code that does
+		// nothing.
+		scans <- scan{source: "test", code: "nothing"}
+		if diff := cmp.Diff(step.want, library.got()); diff != "" {
+			t.Errorf("step %d: unexpected books (-want +got):\n%s", i, diff)
+		}
+	}
+
+	// Scans that do not change books are recorded too.
+	library.mu.Lock()
+	recorded := library.recorded
+	library.mu.Unlock()
+	for _, want := range []string{"shelf ok", "mode ok", "invalid failed"} {
+		if !slices.Contains(recorded, want) {
+			t.Errorf("scan %q was not recorded, got %q", want, recorded)
+		}
+	}
+
+	scans <- scan{source: "test", code: scancode.ControlCode(scancode.CommandMoveMode)}
+	scans <- scan{source: "test", code: "nothing"}
+	if got := testutil.ToFloat64(currentShelfGauge.WithLabelValues("control-test", "shelf 2", "2", "3", "move")); got != 1 {
+		t.Errorf("current shelf metric does not show move mode")
+	}
+	if currentShelfGauge.DeleteLabelValues("control-test", "shelf 1", "1", "1", "catalogue") {
+		t.Errorf("current shelf metric still has the shelf the device left")
+	}
+
+	close(scans)
+	<-done
+}
+
+func TestAddOnsAndMedia(t *testing.T) {
+	library := &fakeLibrary{books: map[string]string{}, addOns: map[string]string{}}
+	ts := httptest.NewServer(library)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scans := make(chan scan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inputLoop(client.New(ts.URL, nil), Device{Name: "addon-test"}, scans, queue, make(chan struct{}, 1), time.Minute)
+	}()
+
+	for _, code := range []string{
+		"LS:L:1:1",
+		// An ISBN with its price.
+		"978000000000251299",
+		// A CD and a UPC-A magazine with its issue number.
+		"4006381333931",
+		"01234567890507",
+		"nothing",
+	} {
+		scans <- scan{source: "test", code: code}
+	}
+	close(scans)
+	<-done
+
+	library.mu.Lock()
+	defer library.mu.Unlock()
+	if diff := cmp.Diff(map[string]string{"9780000000002": "1/1"}, library.books); diff != "" {
+		t.Errorf("unexpected books (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"9780000000002": "51299"}, library.addOns); diff != "" {
+		t.Errorf("unexpected add-ons (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"shelf ok", "media skipped", "media skipped", "invalid failed"}, library.recorded); diff != "" {
+		t.Errorf("unexpected recorded scans (-want +got):\n%s", diff)
+	}
+}
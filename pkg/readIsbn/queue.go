@@ -0,0 +1,115 @@
+package readIsbn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+)
+
+const (
+	minReplayBackoff = time.Second
+	maxReplayBackoff = time.Minute
+)
+
+// errRejected is returned for scans the server refused. Sending them again
+// will not help.
+var errRejected = errors.New("server rejected the scan")
+
+// postBook sends a scanned book to the server.
+func postBook(apiClient *client.Client, s scanqueue.Scan) (api.AddedBook, error) {
+	place := client.Place{ShelfID: s.ShelfID, RowNumber: s.RowNumber, Slot: s.Slot}
+	scan := client.Scan{Device: s.Device, Source: s.Source, Mode: modeCatalogue.String(), Code: s.ISBN + s.AddOn}
+	book, err := apiClient.AddBook(s.ISBN, place, s.AddOn, scan)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode/100 == 4 {
+		err = fmt.Errorf("%w: %w", errRejected, err)
+	}
+	return book, err
+}
+
+// replayQueue sends queued scans to the server, oldest first. While the
+// server cannot be reached it waits longer and longer between tries, up to a
+// minute. A send on wake makes it look at the queue again straight away.
+func replayQueue(ctx context.Context, apiClient *client.Client, queue *scanqueue.Queue, wake <-chan struct{}) {
+	backoff := minReplayBackoff
+	for {
+		scans, err := queue.List()
+		if err != nil {
+			slog.Error("cannot read scan queue", "error", err)
+		}
+		queuedScansGauge.Set(float64(len(scans)))
+
+		wait := maxReplayBackoff
+		if len(scans) > 0 {
+			err := sendQueued(apiClient, queue, scans[0])
+			switch {
+			case err == nil:
+				backoff = minReplayBackoff
+				continue
+			case errors.Is(err, errRejected):
+				// Dropped, so move on to the next one.
+				continue
+			default:
+				slog.Warn("server still unreachable", "error", err, "queued", len(scans), "retry_in", backoff)
+				wait = backoff
+				backoff = min(2*backoff, maxReplayBackoff)
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FlushQueue sends every queued scan to the server now, oldest first. It
+// stops at the first scan that cannot be sent and returns how many were
+// added and how many the server rejected.
+func FlushQueue(serverURL, token string, queue *scanqueue.Queue) (sent, rejected int, err error) {
+	scans, err := queue.List()
+	if err != nil {
+		return 0, 0, err
+	}
+	apiClient := client.New(serverURL, nil).WithToken(token)
+	for _, s := range scans {
+		err := sendQueued(apiClient, queue, s)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, errRejected):
+			rejected++
+		default:
+			return sent, rejected, err
+		}
+	}
+	return sent, rejected, nil
+}
+
+// sendQueued sends a queued scan and takes it off the queue once the server
+// has it, or has rejected it.
+func sendQueued(apiClient *client.Client, queue *scanqueue.Queue, s scanqueue.Scan) error {
+	book, err := postBook(apiClient, s)
+	if err != nil && !errors.Is(err, errRejected) {
+		return err
+	}
+	if err != nil {
+		slog.Error("server rejected queued scan; dropping it", "error", err, "isbn", s.ISBN, "scanned_at", s.ScannedAt)
+		booksFailedCounter.Inc()
+	} else {
+		fmt.Println("Sent queued scan from", s.ScannedAt.Format(time.DateTime)+":", describeAdded(book))
+	}
+
+	if rmErr := queue.Remove(s); rmErr != nil {
+		return fmt.Errorf("cannot remove scan from queue: %w", rmErr)
+	}
+	return err
+}
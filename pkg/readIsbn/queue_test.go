@@ -0,0 +1,182 @@
+package readIsbn
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+)
+
+// fakeServer accepts books unless it is down, and rejects ISBNs that start
+// with 0. Every shelf exists, every book can be returned and scans it is told
+// about are ignored.
+type fakeServer struct {
+	mu       sync.Mutex
+	down     bool
+	received []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	isbn := strings.TrimPrefix(r.URL.Path, "/api/v1/books/")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/v1/shelf/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/shelf/"))
+		_ = json.NewEncoder(w).Encode(models.Shelf{ID: id, Name: "shelf " + strconv.Itoa(id)})
+	case r.URL.Path == "/api/v1/books/return":
+		f.received = append(f.received, "returned")
+	case r.URL.Path == "/api/v1/scans":
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(models.ScanEvent{})
+	case f.down:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case strings.HasPrefix(isbn, "0"):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		f.received = append(f.received, isbn+" "+r.URL.Query().Get("shelf_id")+"/"+r.URL.Query().Get("row_number"))
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(api.Book{Title: isbn})
+	}
+}
+
+func (f *fakeServer) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeServer) got() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.received...)
+}
+
+func TestIngestBookQueuesWhileServerIsDown(t *testing.T) {
+	server := &fakeServer{down: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatalf("cannot open queue: %v", err)
+	}
+	replay := make(chan struct{}, 1)
+
+	for i, isbn := range []string{"9780000000002", "0000000000000", "9780000000019"} {
+		ingestBook(client.New(ts.URL, nil), queue, scanqueue.Scan{ISBN: isbn, ShelfID: 1, RowNumber: i + 1, ScannedAt: time.Now()}, replay)
+	}
+	if n, err := queue.Len(); err != nil || n != 3 {
+		t.Fatalf("expected 3 queued scans, got %d, %v", n, err)
+	}
+
+	// Once the server is back, a new scan still waits behind the queued ones.
+	server.setDown(false)
+	ingestBook(client.New(ts.URL, nil), queue, scanqueue.Scan{ISBN: "9780000000026", ShelfID: 2, RowNumber: 1, ScannedAt: time.Now()}, replay)
+	if got := server.got(); len(got) != 0 {
+		t.Fatalf("expected nothing to be sent ahead of the queue, got %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go replayQueue(ctx, client.New(ts.URL, nil), queue, replay)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := queue.Len()
+		if err != nil {
+			t.Fatalf("cannot read queue: %v", err)
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out with %d scans still queued", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The rejected scan is dropped and the rest arrive in order.
+	want := []string{"9780000000002 1/1", "9780000000019 1/3", "9780000000026 2/1"}
+	if diff := cmp.Diff(want, server.got()); diff != "" {
+		t.Errorf("unexpected books sent (-want +got):\n%s", diff)
+	}
+}
+
+func TestFlushQueue(t *testing.T) {
+	server := &fakeServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatalf("cannot open queue: %v", err)
+	}
+	for _, isbn := range []string{"9780000000002", "0000000000000", "9780000000019"} {
+		if err := queue.Push(scanqueue.Scan{ISBN: isbn, ShelfID: 1, RowNumber: 1, ScannedAt: time.Now()}); err != nil {
+			t.Fatalf("cannot push: %v", err)
+		}
+	}
+
+	server.setDown(true)
+	if sent, rejected, err := FlushQueue(ts.URL, "", queue); err == nil || sent != 0 || rejected != 0 {
+		t.Errorf("expected flushing to stop while the server is down, got %d sent, %d rejected, %v", sent, rejected, err)
+	}
+	if n, _ := queue.Len(); n != 3 {
+		t.Errorf("expected the queue to be kept, got %d scans", n)
+	}
+
+	server.setDown(false)
+	sent, rejected, err := FlushQueue(ts.URL, "", queue)
+	if err != nil || sent != 2 || rejected != 1 {
+		t.Errorf("expected 2 sent and 1 rejected, got %d, %d, %v", sent, rejected, err)
+	}
+	if n, _ := queue.Len(); n != 0 {
+		t.Errorf("expected an empty queue, got %d scans", n)
+	}
+}
+
+func TestUndoTakesScanOffTheQueue(t *testing.T) {
+	server := &fakeServer{down: true}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	queue, err := scanqueue.Open(filepath.Join(t.TempDir(), "queue.jsonl"))
+	if err != nil {
+		t.Fatalf("cannot open queue: %v", err)
+	}
+
+	scans := make(chan scan)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inputLoop(client.New(ts.URL, nil), Device{Name: "undo-test"}, scans, queue, make(chan struct{}, 1), time.Minute)
+	}()
+
+	for _, code := range []string{"9780000000002", "9780000000019", "UNDO", "nothing"} {
+		scans <- scan{source: "test", code: code}
+	}
+	queued, err := queue.List()
+	if err != nil {
+		t.Fatalf("cannot read queue: %v", err)
+	}
+	if len(queued) != 1 || queued[0].ISBN != "9780000000002" || queued[0].Device != "undo-test" {
+		t.Errorf("expected only the first scan to be queued, got %+v", queued)
+	}
+
+	close(scans)
+	<-done
+}
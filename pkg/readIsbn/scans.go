@@ -0,0 +1,52 @@
+package readIsbn
+
+import (
+	"log/slog"
+
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/models"
+	"github.com/gouthamve/librascan/pkg/scanqueue"
+)
+
+// Outcomes of the scans the scanner records itself. Scans that change books
+// are recorded by the server when it makes the change.
+const (
+	outcomeOK      = "ok"
+	outcomeFailed  = "failed"
+	outcomeQueued  = "queued"
+	outcomeSkipped = "skipped"
+)
+
+// scanFor returns the scan to make a request for, so that the server records
+// it with the change the request makes.
+func scanFor(device string, in scan, mode scanMode) client.Scan {
+	return client.Scan{Device: device, Source: in.source, Mode: mode.String(), Code: in.code}
+}
+
+// recordScan tells the server about a scan that did not change any books.
+// Failing to is only logged.
+func recordScan(apiClient *client.Client, req models.ScanEventRequest) {
+	if _, err := apiClient.RecordScan(req); err != nil {
+		slog.Warn("cannot record scan", "error", err, "code", req.Code, "device", req.Device)
+	}
+}
+
+// undoLastScan asks the server to undo the last scan of a device.
+func undoLastScan(apiClient *client.Client, device, source string) (models.ScanEvent, error) {
+	return apiClient.UndoLastScan(models.ScanUndoRequest{Device: device, Source: source})
+}
+
+// unqueue takes a scan off the queue. It reports false if the scan is not in
+// the queue anymore, e.g. because it has been sent since.
+func unqueue(queue *scanqueue.Queue, s scanqueue.Scan) (bool, error) {
+	scans, err := queue.List()
+	if err != nil {
+		return false, err
+	}
+	for _, queued := range scans {
+		if queued.ISBN == s.ISBN && queued.ScannedAt.Equal(s.ScannedAt) {
+			return true, queue.Remove(s)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,315 @@
+package readIsbn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanSource is somewhere scanned codes come from.
+type ScanSource interface {
+	// Name identifies the source in logs and metrics.
+	Name() string
+	// Run sends every code scanned to out until ctx is done or the source
+	// cannot go on.
+	Run(ctx context.Context, out chan<- string) error
+}
+
+// ParseSource parses a source given on the command line:
+//
+//	stdin                      lines typed on the terminal
+//	evdev:/dev/input/event0    a scanner that acts as a keyboard; add ?layout=de to read a
+//	                           German or French (fr) keyboard, and prefix= and suffix= for
+//	                           the characters the scanner sends around each code
+//	serial:/dev/ttyACM0        a serial or CDC-ACM scanner; add ?baud=9600 to set the speed
+//	tcp::7777                  lines sent to a TCP port; without a host it listens on localhost
+//	ws::7778?token=SECRET      messages sent to ws://host:7778/scan?token=SECRET, e.g. from a
+//	                           phone; browsers must open it from a page on serverURL
+func ParseSource(spec, serverURL string) (ScanSource, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "stdin":
+		return &stdinSource{}, nil
+	case "evdev":
+		path, query, _ := strings.Cut(arg, "?")
+		if path == "" {
+			return nil, fmt.Errorf("evdev source needs a device path: %q", spec)
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid evdev options %q: %w", query, err)
+		}
+		source := &evdevSource{
+			path:    path,
+			layout:  Layouts["us"],
+			framing: Framing{Prefix: values.Get("prefix"), Suffix: values.Get("suffix")},
+		}
+		if name := values.Get("layout"); name != "" {
+			layout, ok := Layouts[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown keyboard layout %q, want one of %s", name, strings.Join(LayoutNames(), ", "))
+			}
+			source.layout = layout
+		}
+		return source, nil
+	case "serial":
+		path, query, _ := strings.Cut(arg, "?")
+		if path == "" {
+			return nil, fmt.Errorf("serial source needs a device path: %q", spec)
+		}
+		baud := 9600
+		if query != "" {
+			values, err := url.ParseQuery(query)
+			if err != nil {
+				return nil, fmt.Errorf("invalid serial options %q: %w", query, err)
+			}
+			if b := values.Get("baud"); b != "" {
+				if baud, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("invalid baud rate %q", b)
+				}
+			}
+		}
+		if err := checkSerial(baud); err != nil {
+			return nil, err
+		}
+		return &serialSource{path: path, baud: baud}, nil
+	case "tcp":
+		if arg == "" {
+			return nil, fmt.Errorf("tcp source needs an address: %q", spec)
+		}
+		// Anyone who can connect can scan, so only this machine can unless
+		// a host is given.
+		if strings.HasPrefix(arg, ":") {
+			arg = "localhost" + arg
+		}
+		return &tcpSource{addr: arg}, nil
+	case "ws":
+		addr, query, _ := strings.Cut(arg, "?")
+		if addr == "" {
+			return nil, fmt.Errorf("ws source needs an address: %q", spec)
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ws options %q: %w", query, err)
+		}
+		token := values.Get("token")
+		if token == "" {
+			return nil, fmt.Errorf("ws source needs a token for scanners to send: %q", spec)
+		}
+		server, err := url.Parse(serverURL)
+		if err != nil || server.Host == "" {
+			return nil, fmt.Errorf("ws source needs the server URL to check origins against, got %q", serverURL)
+		}
+		return &websocketSource{addr: addr, token: token, origin: server.Scheme + "://" + server.Host}, nil
+	default:
+		return nil, fmt.Errorf("unknown scan source %q", spec)
+	}
+}
+
+// scan is a code and the source it was scanned on.
+type scan struct {
+	source string
+	code   string
+}
+
+// runSources runs the sources side by side and merges what they scan.
+func runSources(ctx context.Context, sources []ScanSource) <-chan scan {
+	scans := make(chan scan)
+	for _, source := range sources {
+		codes := make(chan string)
+		go func() {
+			for code := range codes {
+				scans <- scan{source: source.Name(), code: code}
+			}
+		}()
+		go func() {
+			slog.Info("Scan source started", "source", source.Name())
+			err := source.Run(ctx, codes)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("scan source stopped", "source", source.Name(), "error", err)
+			} else {
+				slog.Info("Scan source stopped", "source", source.Name())
+			}
+			close(codes)
+		}()
+	}
+	return scans
+}
+
+// readLines sends every non-empty line read from r to out. Scanners end a
+// code with a carriage return, a line feed or both.
+func readLines(ctx context.Context, r io.Reader, out chan<- string) error {
+	lines := bufio.NewScanner(r)
+	lines.Split(splitLines)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			continue
+		}
+		select {
+		case out <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return lines.Err()
+}
+
+// splitLines is bufio.ScanLines that also ends a line at a lone "\r".
+func splitLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// stdinSource reads codes typed on the terminal.
+type stdinSource struct{}
+
+func (s *stdinSource) Name() string { return "stdin" }
+
+func (s *stdinSource) Run(ctx context.Context, out chan<- string) error {
+	return readLines(ctx, os.Stdin, out)
+}
+
+// evdevSource reads a scanner that types codes like a keyboard.
+type evdevSource struct {
+	path    string
+	layout  *Layout
+	framing Framing
+}
+
+func (s *evdevSource) Name() string { return "evdev:" + s.path }
+
+func (s *evdevSource) Run(ctx context.Context, out chan<- string) error {
+	device, err := grabAndSetupDevice(s.path, s.layout, s.framing)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case code := <-device.bufferedCodes:
+			if code == "" {
+				continue
+			}
+			select {
+			case out <- code:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// serialSource reads a scanner on a serial port, including USB scanners that
+// show up as a CDC-ACM tty. The port is reopened when the scanner is
+// unplugged and plugged back in. Like evdev paths, the path can be a glob.
+type serialSource struct {
+	path string
+	baud int
+}
+
+func (s *serialSource) Name() string { return "serial:" + s.path }
+
+func (s *serialSource) Run(ctx context.Context, out chan<- string) error {
+	for {
+		err := s.read(ctx, out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Warn("serial port closed; reopening", "source", s.Name(), "error", err)
+
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *serialSource) read(ctx context.Context, out chan<- string) error {
+	path, err := resolveDevicePath(s.path)
+	if err != nil {
+		return err
+	}
+	port, err := openSerial(path, s.baud)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = port.Close()
+	})
+	defer func() {
+		if stop() {
+			_ = port.Close()
+		}
+	}()
+
+	if err := readLines(ctx, port, out); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// tcpSource listens for connections that send one code per line, e.g. from
+// another machine with a scanner attached.
+type tcpSource struct {
+	addr string
+}
+
+func (s *tcpSource) Name() string { return "tcp:" + s.addr }
+
+func (s *tcpSource) Run(ctx context.Context, out chan<- string) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, listener, out)
+}
+
+func (s *tcpSource) serve(ctx context.Context, listener net.Listener, out chan<- string) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = listener.Close()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stop := context.AfterFunc(ctx, func() {
+				_ = conn.Close()
+			})
+			defer stop()
+			defer func() {
+				_ = conn.Close()
+			}()
+
+			if err := readLines(ctx, conn, out); err != nil && ctx.Err() == nil {
+				slog.Warn("cannot read scans", "source", s.Name(), "remote", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
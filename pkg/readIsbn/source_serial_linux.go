@@ -0,0 +1,57 @@
+//go:build linux
+
+package readIsbn
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// baudRates are the serial speeds scanners are usually set to.
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+}
+
+// checkSerial reports whether a serial source can be read at baud.
+func checkSerial(baud int) error {
+	if _, ok := baudRates[baud]; !ok {
+		return fmt.Errorf("unsupported baud rate %d", baud)
+	}
+	return nil
+}
+
+// openSerial opens a tty in raw mode, so codes come through exactly as the
+// scanner sends them. CDC-ACM scanners ignore the speed.
+func openSerial(path string, baud int) (*os.File, error) {
+	port, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := unix.IoctlGetTermios(int(port.Fd()), unix.TCGETS)
+	if err != nil {
+		_ = port.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %w", path, err)
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.CBAUD
+	t.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | baudRates[baud]
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(int(port.Fd()), unix.TCSETS, t); err != nil {
+		_ = port.Close()
+		return nil, fmt.Errorf("cannot set up %s: %w", path, err)
+	}
+	return port, nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package readIsbn
+
+import (
+	"errors"
+	"os"
+)
+
+var errSerialUnsupported = errors.New("serial scan sources are only supported on Linux")
+
+// checkSerial reports whether a serial source can be read at baud.
+func checkSerial(int) error {
+	return errSerialUnsupported
+}
+
+// openSerial opens a tty in raw mode.
+func openSerial(string, int) (*os.File, error) {
+	return nil, errSerialUnsupported
+}
@@ -0,0 +1,153 @@
+package readIsbn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/websocket"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    ScanSource
+		wantErr bool
+	}{
+		{spec: "stdin", want: &stdinSource{}},
+		{spec: "evdev:/dev/input/event0", want: &evdevSource{path: "/dev/input/event0", layout: Layouts["us"]}},
+		{spec: "evdev:/dev/input/event0?layout=fr&prefix=%23&suffix=%24", want: &evdevSource{path: "/dev/input/event0", layout: Layouts["fr"], framing: Framing{Prefix: "#", Suffix: "$"}}},
+		{spec: "serial:/dev/ttyACM0", want: &serialSource{path: "/dev/ttyACM0", baud: 9600}},
+		{spec: "serial:/dev/ttyUSB0?baud=115200", want: &serialSource{path: "/dev/ttyUSB0", baud: 115200}},
+		{spec: "tcp::7777", want: &tcpSource{addr: "localhost:7777"}},
+		{spec: "tcp:0.0.0.0:7777", want: &tcpSource{addr: "0.0.0.0:7777"}},
+		{spec: "ws:0.0.0.0:7778?token=secret", want: &websocketSource{addr: "0.0.0.0:7778", token: "secret", origin: "http://books.local:8080"}},
+		{spec: "evdev:", wantErr: true},
+		{spec: "evdev:/dev/input/event0?layout=dvorak", wantErr: true},
+		{spec: "serial:/dev/ttyACM0?baud=12345", wantErr: true},
+		{spec: "serial:/dev/ttyACM0?baud=fast", wantErr: true},
+		{spec: "tcp", wantErr: true},
+		{spec: "ws::7778", wantErr: true},
+		{spec: "bluetooth:scanner", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseSource(tt.spec, "http://books.local:8080/")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(stdinSource{}, evdevSource{}, Layout{}, keyChars{}, serialSource{}, tcpSource{}, websocketSource{})); diff != "" {
+				t.Errorf("unexpected source (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	out := make(chan string, 10)
+	if err := readLines(context.Background(), strings.NewReader("9783836526722\r00000235\r\n\r\nLS:L:2:3\n 9780000000002 "), out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(out)
+
+	var got []string
+	for code := range out {
+		got = append(got, code)
+	}
+	if diff := cmp.Diff([]string{"9783836526722", "00000235", "LS:L:2:3", "9780000000002"}, got); diff != "" {
+		t.Errorf("unexpected codes (-want +got):\n%s", diff)
+	}
+}
+
+func TestNetworkSources(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+	wsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %v", err)
+	}
+
+	tcp := &tcpSource{addr: tcpListener.Addr().String()}
+	ws := &websocketSource{addr: wsListener.Addr().String(), token: "secret", origin: "http://books.local"}
+	out := make(chan string)
+	done := make(chan error, 2)
+	go func() { done <- tcp.serve(ctx, tcpListener, out) }()
+	go func() { done <- ws.serve(ctx, wsListener, out) }()
+
+	receive := func() string {
+		select {
+		case code := <-out:
+			return code
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a scan")
+			return ""
+		}
+	}
+
+	conn, err := net.Dial("tcp", tcp.addr)
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err := fmt.Fprint(conn, "9783836526722\r\n"); err != nil {
+		t.Fatalf("cannot send: %v", err)
+	}
+	if got := receive(); got != "9783836526722" {
+		t.Errorf("expected the code sent over TCP, got %q", got)
+	}
+
+	// Pages elsewhere and scanners without the token are turned away.
+	for _, tt := range []struct{ token, origin string }{
+		{token: "secret", origin: "http://evil.example/"},
+		{token: "guess", origin: "http://books.local/"},
+		{origin: "http://books.local/"},
+	} {
+		if conn, err := websocket.Dial(fmt.Sprintf("ws://%s/scan?token=%s", ws.addr, tt.token), "", tt.origin); err == nil {
+			_ = conn.Close()
+			t.Errorf("expected token %q from %s to be turned away", tt.token, tt.origin)
+		}
+	}
+
+	wsConn, err := websocket.Dial(fmt.Sprintf("ws://%s/scan?token=secret", ws.addr), "", "http://books.local/")
+	if err != nil {
+		t.Fatalf("cannot connect: %v", err)
+	}
+	defer func() {
+		_ = wsConn.Close()
+	}()
+	if err := websocket.Message.Send(wsConn, "00000235\nLS:L:2:3"); err != nil {
+		t.Fatalf("cannot send: %v", err)
+	}
+	for _, want := range []string{"00000235", "LS:L:2:3"} {
+		if got := receive(); got != want {
+			t.Errorf("expected %q sent over the websocket, got %q", want, got)
+		}
+	}
+
+	cancel()
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the sources to stop")
+		}
+	}
+}
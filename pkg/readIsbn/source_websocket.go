@@ -0,0 +1,96 @@
+package readIsbn
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// websocketSource accepts codes pushed over a websocket at /scan, so a phone
+// can be used as a scanner. Each message is one or more codes, one per line.
+type websocketSource struct {
+	addr string
+	// token must be given as the token query parameter to connect.
+	token string
+	// origin is the scheme and host of the library server. Browsers may
+	// only connect from its pages.
+	origin string
+}
+
+func (s *websocketSource) Name() string { return "ws:" + s.addr }
+
+func (s *websocketSource) Run(ctx context.Context, out chan<- string) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, listener, out)
+}
+
+func (s *websocketSource) serve(ctx context.Context, listener net.Listener, out chan<- string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/scan", websocket.Server{Handshake: s.handshake, Handler: func(ws *websocket.Conn) {
+		stop := context.AfterFunc(ctx, func() {
+			_ = ws.Close()
+		})
+		defer stop()
+
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			for _, code := range strings.Split(msg, "\n") {
+				code = strings.TrimSpace(code)
+				if code == "" {
+					continue
+				}
+				select {
+				case out <- code:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	stop := context.AfterFunc(ctx, func() {
+		if err := server.Close(); err != nil {
+			slog.Warn("cannot close websocket server", "source", s.Name(), "error", err)
+		}
+	})
+	defer stop()
+
+	err := server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// handshake turns away connections without the token, and those opened by
+// pages anywhere but on the library server. Clients other than browsers may
+// not send an origin; the token is enough for them.
+func (s *websocketSource) handshake(config *websocket.Config, req *http.Request) error {
+	if subtle.ConstantTimeCompare([]byte(req.URL.Query().Get("token")), []byte(s.token)) != 1 {
+		return errors.New("wrong token")
+	}
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Scheme+"://"+origin.Host != s.origin {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	config.Origin = origin
+	return nil
+}
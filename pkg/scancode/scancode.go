@@ -0,0 +1,371 @@
+// Package scancode classifies the codes read by a barcode scanner.
+//
+// Besides book ISBNs the scanner reads a few codes of our own:
+//
+//   - 8-digit EAN-8 shelf labels: a 6-digit shelf id, a row digit and a check digit.
+//   - Versioned location codes, printed as Code 128 or QR: "LS:L:<shelf>:<row>"
+//     with an optional ":<slot>". Fields added in later versions are appended
+//     and ignored by older parsers.
+//   - 13-digit person cards: "21", a 10-digit person id and a check digit.
+//   - 13-digit command cards: "20", a 10-digit command number and a check digit.
+//   - Control codes, printed as Code 128 or QR: the same commands as words,
+//     such as "MODE:DELETE", "UNDO" or "END SESSION".
+//
+// Person and command cards use the EAN-13 "restricted circulation" prefixes,
+// which never clash with ISBNs (978/979).
+//
+// Other product codes are media that are not books: EAN-13s with any other
+// prefix, and 12-digit UPC-As. Scanners can send a 2- or 5-digit add-on after
+// the EAN-13 or UPC-A. On books the 5-digit add-on is the price supplement,
+// e.g. "51299" for $12.99.
+package scancode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a scanned code.
+type Kind int
+
+const (
+	Unknown Kind = iota
+	Shelf
+	ISBN
+	PersonCard
+	CommandCard
+	Media
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Shelf:
+		return "shelf"
+	case ISBN:
+		return "isbn"
+	case PersonCard:
+		return "person"
+	case CommandCard:
+		return "command"
+	case Media:
+		return "media"
+	default:
+		return "unknown"
+	}
+}
+
+// Command is an action printed on a command card.
+type Command int
+
+const (
+	// CommandReturnMode makes subsequent ISBN scans return books.
+	CommandReturnMode Command = 1
+	// CommandCatalogueMode leaves lending or return mode.
+	CommandCatalogueMode Command = 2
+	// CommandAuditMode makes the next shelf code start an audit of that row.
+	CommandAuditMode Command = 3
+	// CommandEndSession closes the current audit, or leaves lending or
+	// return mode.
+	CommandEndSession Command = 4
+	// CommandReshelveMode makes subsequent ISBN scans confirm the moves of
+	// the open reshelving plan.
+	CommandReshelveMode Command = 5
+	// CommandDeleteMode makes subsequent ISBN scans delete books.
+	CommandDeleteMode Command = 6
+	// CommandMoveMode makes subsequent ISBN scans move books to the current
+	// shelf.
+	CommandMoveMode Command = 7
+	// CommandUndo reverts the last scan.
+	CommandUndo Command = 8
+)
+
+func (c Command) String() string {
+	switch c {
+	case CommandReturnMode:
+		return "return mode"
+	case CommandCatalogueMode:
+		return "catalogue mode"
+	case CommandAuditMode:
+		return "audit mode"
+	case CommandEndSession:
+		return "end session"
+	case CommandReshelveMode:
+		return "reshelve mode"
+	case CommandDeleteMode:
+		return "delete mode"
+	case CommandMoveMode:
+		return "move mode"
+	case CommandUndo:
+		return "undo"
+	default:
+		return fmt.Sprintf("command %d", int(c))
+	}
+}
+
+const (
+	commandPrefix = "20"
+	personPrefix  = "21"
+
+	// locationPrefix starts a versioned location code.
+	locationPrefix = "LS:L:"
+
+	// Books are numbered in the Bookland prefixes.
+	booklandPrefix    = "978"
+	newBooklandPrefix = "979"
+)
+
+// Code is a parsed scan.
+type Code struct {
+	Raw  string
+	Kind Kind
+
+	// Set for Shelf codes. Slot is 0 unless the code has one.
+	ShelfID int
+	Row     int
+	Slot    int
+
+	// Set for PersonCard codes.
+	PersonID int
+
+	// Set for CommandCard codes.
+	Command Command
+
+	// Set for ISBN and Media codes. EAN is the EAN-13, with a UPC-A written
+	// as one, and AddOn the 2- or 5-digit add-on if the scanner sent it.
+	EAN   string
+	AddOn string
+}
+
+// Parse classifies a scanned code. Codes that are not recognised have Kind Unknown.
+func Parse(raw string) Code {
+	code := Code{Raw: raw, Kind: Unknown}
+	// Scanners in keyboard mode may send the letters in either case.
+	if strings.HasPrefix(strings.ToUpper(raw), locationPrefix) {
+		return parseLocation(code)
+	}
+	if c, ok := controlCommands[strings.ToUpper(raw)]; ok {
+		code.Kind = CommandCard
+		code.Command = c
+		return code
+	}
+	if !isDigits(raw) {
+		return code
+	}
+
+	switch len(raw) {
+	case 12, 14, 17:
+		// A UPC-A is an EAN-13 starting with 0.
+		return parseProduct(code, "0"+raw[:12], raw[12:])
+	case 15, 18:
+		return parseProduct(code, raw[:13], raw[13:])
+	case 8:
+		// EAN Codes can be 8 or 13 digits long.
+		// We are using the 8 digit EAN codes for shelf codes.
+		n, _ := strconv.Atoi(raw)
+		// The last digit is a checksum.
+		n /= 10
+
+		code.Kind = Shelf
+		code.Row = n % 10
+		code.ShelfID = n / 10
+	case 13:
+		switch raw[:2] {
+		case personPrefix, commandPrefix:
+			if !validEAN13(raw) {
+				return code
+			}
+
+			n, _ := strconv.Atoi(raw[2:12])
+			if raw[:2] == personPrefix {
+				code.Kind = PersonCard
+				code.PersonID = n
+			} else {
+				code.Kind = CommandCard
+				code.Command = Command(n)
+			}
+		default:
+			return parseProduct(code, raw, "")
+		}
+	}
+
+	return code
+}
+
+// parseProduct classifies the EAN-13 of a product, which is a book if it has
+// a Bookland prefix.
+func parseProduct(code Code, ean, addOn string) Code {
+	code.EAN = ean
+	code.AddOn = addOn
+	if strings.HasPrefix(ean, booklandPrefix) || strings.HasPrefix(ean, newBooklandPrefix) {
+		code.Kind = ISBN
+	} else {
+		code.Kind = Media
+	}
+	return code
+}
+
+// Price is the price of a book, in hundredths of its currency.
+type Price struct {
+	Currency string
+	Amount   int
+}
+
+func (p Price) String() string {
+	return fmt.Sprintf("%s %d.%02d", p.Currency, p.Amount/100, p.Amount%100)
+}
+
+// priceCurrencies are the currencies of 5-digit add-ons by their first digit.
+var priceCurrencies = map[byte]string{
+	'0': "GBP",
+	'3': "AUD",
+	'4': "NZD",
+	'5': "USD",
+	'6': "CAD",
+}
+
+// AddOnPrice returns the price in a book's 5-digit add-on: the first digit is
+// the currency and the others the price. It reports false for 2-digit
+// add-ons, which number the issues of periodicals, and for add-ons without a
+// price, such as 90000.
+func AddOnPrice(addOn string) (Price, bool) {
+	if len(addOn) != 5 || !isDigits(addOn) {
+		return Price{}, false
+	}
+	currency, ok := priceCurrencies[addOn[0]]
+	if !ok {
+		return Price{}, false
+	}
+	amount, _ := strconv.Atoi(addOn[1:])
+	return Price{Currency: currency, Amount: amount}, true
+}
+
+// parseLocation parses a versioned location code.
+func parseLocation(code Code) Code {
+	fields := strings.Split(code.Raw[len(locationPrefix):], ":")
+	if len(fields) < 2 {
+		return code
+	}
+
+	values := []int{}
+	for _, field := range fields[:min(len(fields), 3)] {
+		if !isDigits(field) {
+			return code
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return code
+		}
+		values = append(values, n)
+	}
+
+	code.Kind = Shelf
+	code.ShelfID = values[0]
+	code.Row = values[1]
+	if len(values) > 2 {
+		code.Slot = values[2]
+	}
+	return code
+}
+
+// LocationCode returns the versioned location code for a shelf row, and a
+// slot within it if slot is not 0.
+func LocationCode(shelfID, row, slot int) string {
+	if slot == 0 {
+		return fmt.Sprintf("%s%d:%d", locationPrefix, shelfID, row)
+	}
+	return fmt.Sprintf("%s%d:%d:%d", locationPrefix, shelfID, row, slot)
+}
+
+// The largest shelf id and row that fit in an EAN-8 shelf code.
+const (
+	MaxShelfCodeID  = 999999
+	MaxShelfCodeRow = 9
+)
+
+// ShelfCode returns the EAN-8 printed on the label of a shelf row. Shelves
+// and rows that do not fit need a LocationCode.
+func ShelfCode(shelfID, row int) string {
+	digits := fmt.Sprintf("%06d%d", shelfID, row)
+	// EAN-8 uses the same weights as EAN-13, aligned to the last digit.
+	return digits + strconv.Itoa(EAN13CheckDigit("00000"+digits))
+}
+
+// PersonCardCode returns the EAN-13 printed on a person's card.
+func PersonCardCode(personID int) string {
+	return withCheckDigit(fmt.Sprintf("%s%010d", personPrefix, personID))
+}
+
+// CommandCardCode returns the EAN-13 printed on a command card.
+func CommandCardCode(c Command) string {
+	return withCheckDigit(fmt.Sprintf("%s%010d", commandPrefix, int(c)))
+}
+
+// controlCodes are the words printed on control code labels.
+var controlCodes = map[Command]string{
+	CommandCatalogueMode: "MODE:ADD",
+	CommandReturnMode:    "MODE:RETURN",
+	CommandAuditMode:     "MODE:AUDIT",
+	CommandReshelveMode:  "MODE:RESHELVE",
+	CommandDeleteMode:    "MODE:DELETE",
+	CommandMoveMode:      "MODE:MOVE",
+	CommandUndo:          "UNDO",
+	CommandEndSession:    "END SESSION",
+}
+
+// controlCommands maps control codes back to their commands.
+var controlCommands = func() map[string]Command {
+	m := map[string]Command{"MODE:CATALOGUE": CommandCatalogueMode}
+	for c, code := range controlCodes {
+		m[code] = c
+	}
+	return m
+}()
+
+// ControlCode returns the words printed on the control code label of a
+// command, or "" if it has none.
+func ControlCode(c Command) string {
+	return controlCodes[c]
+}
+
+// ControlCommands returns the commands that have control codes, in the order
+// they are printed.
+func ControlCommands() []Command {
+	return []Command{
+		CommandCatalogueMode, CommandDeleteMode, CommandMoveMode, CommandAuditMode,
+		CommandReshelveMode, CommandReturnMode, CommandUndo, CommandEndSession,
+	}
+}
+
+// EAN13CheckDigit computes the check digit for the first 12 digits of an EAN-13.
+func EAN13CheckDigit(digits string) int {
+	sum := 0
+	for i, r := range digits[:12] {
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+func withCheckDigit(digits string) string {
+	return digits + strconv.Itoa(EAN13CheckDigit(digits))
+}
+
+func validEAN13(s string) bool {
+	return int(s[12]-'0') == EAN13CheckDigit(s)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package scancode
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Code
+	}{
+		{
+			input: "00000235",
+			want:  Code{Raw: "00000235", Kind: Shelf, ShelfID: 2, Row: 3},
+		},
+		{
+			input: ShelfCode(2, 3),
+			want:  Code{Raw: "00000239", Kind: Shelf, ShelfID: 2, Row: 3},
+		},
+		{
+			input: LocationCode(1234567, 12, 0),
+			want:  Code{Raw: "LS:L:1234567:12", Kind: Shelf, ShelfID: 1234567, Row: 12},
+		},
+		{
+			input: LocationCode(2, 3, 4),
+			want:  Code{Raw: "LS:L:2:3:4", Kind: Shelf, ShelfID: 2, Row: 3, Slot: 4},
+		},
+		{
+			// Lower case and fields from a later version.
+			input: "ls:l:2:3:4:5",
+			want:  Code{Raw: "ls:l:2:3:4:5", Kind: Shelf, ShelfID: 2, Row: 3, Slot: 4},
+		},
+		{
+			input: "LS:L:2",
+			want:  Code{Raw: "LS:L:2", Kind: Unknown},
+		},
+		{
+			input: "LS:L:2:x",
+			want:  Code{Raw: "LS:L:2:x", Kind: Unknown},
+		},
+		{
+			input: "9783836526722",
+			want:  Code{Raw: "9783836526722", Kind: ISBN, EAN: "9783836526722"},
+		},
+		{
+			input: "979108861720",
+			want:  Code{Raw: "979108861720", Kind: Media, EAN: "0979108861720"},
+		},
+		{
+			// Price add-on.
+			input: "978383652672251299",
+			want:  Code{Raw: "978383652672251299", Kind: ISBN, EAN: "9783836526722", AddOn: "51299"},
+		},
+		{
+			input: "979100001000012",
+			want:  Code{Raw: "979100001000012", Kind: ISBN, EAN: "9791000010000", AddOn: "12"},
+		},
+		{
+			input: "4006381333931",
+			want:  Code{Raw: "4006381333931", Kind: Media, EAN: "4006381333931"},
+		},
+		{
+			// UPC-A with an issue number.
+			input: "01234567890507",
+			want:  Code{Raw: "01234567890507", Kind: Media, EAN: "0012345678905", AddOn: "07"},
+		},
+		{
+			input: "03600029145290000",
+			want:  Code{Raw: "03600029145290000", Kind: Media, EAN: "0036000291452", AddOn: "90000"},
+		},
+		{
+			input: PersonCardCode(42),
+			want:  Code{Raw: "2100000000425", Kind: PersonCard, PersonID: 42},
+		},
+		{
+			input: CommandCardCode(CommandReturnMode),
+			want:  Code{Raw: "2000000000015", Kind: CommandCard, Command: CommandReturnMode},
+		},
+		{
+			input: "MODE:DELETE",
+			want:  Code{Raw: "MODE:DELETE", Kind: CommandCard, Command: CommandDeleteMode},
+		},
+		{
+			input: "mode:move",
+			want:  Code{Raw: "mode:move", Kind: CommandCard, Command: CommandMoveMode},
+		},
+		{
+			input: "MODE:CATALOGUE",
+			want:  Code{Raw: "MODE:CATALOGUE", Kind: CommandCard, Command: CommandCatalogueMode},
+		},
+		{
+			input: "UNDO",
+			want:  Code{Raw: "UNDO", Kind: CommandCard, Command: CommandUndo},
+		},
+		{
+			input: "END SESSION",
+			want:  Code{Raw: "END SESSION", Kind: CommandCard, Command: CommandEndSession},
+		},
+		{
+			input: "MODE:DANCE",
+			want:  Code{Raw: "MODE:DANCE", Kind: Unknown},
+		},
+		{
+			// Bad check digit.
+			input: "2100000000421",
+			want:  Code{Raw: "2100000000421", Kind: Unknown},
+		},
+		{
+			input: "12345",
+			want:  Code{Raw: "12345", Kind: Unknown},
+		},
+		{
+			input: "97838365X6722",
+			want:  Code{Raw: "97838365X6722", Kind: Unknown},
+		},
+		{
+			input: "",
+			want:  Code{Raw: "", Kind: Unknown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Parse(tt.input)); diff != "" {
+				t.Errorf("Parse(%q) mismatch (-want +got):\n%s", tt.input, diff)
+			}
+		})
+	}
+}
+
+func TestAddOnPrice(t *testing.T) {
+	tests := []struct {
+		addOn string
+		want  string
+		ok    bool
+	}{
+		{addOn: "51299", want: "USD 12.99", ok: true},
+		{addOn: "00799", want: "GBP 7.99", ok: true},
+		{addOn: "62495", want: "CAD 24.95", ok: true},
+		{addOn: "90000"},
+		{addOn: "07"},
+		{addOn: "5x299"},
+	}
+
+	for _, tt := range tests {
+		price, ok := AddOnPrice(tt.addOn)
+		if ok != tt.ok || (ok && price.String() != tt.want) {
+			t.Errorf("AddOnPrice(%q) = %v, %v, want %q, %v", tt.addOn, price, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEAN13CheckDigit(t *testing.T) {
+	for _, isbn := range []string{"9783836526722", "9780134685991", "9780261103573"} {
+		if got, want := EAN13CheckDigit(isbn), int(isbn[12]-'0'); got != want {
+			t.Errorf("EAN13CheckDigit(%q) = %d, want %d", isbn, got, want)
+		}
+	}
+}
+
+func TestControlCodes(t *testing.T) {
+	for _, c := range ControlCommands() {
+		code := ControlCode(c)
+		if code == "" {
+			t.Errorf("%s has no control code", c)
+			continue
+		}
+		if got := Parse(code); got.Kind != CommandCard || got.Command != c {
+			t.Errorf("Parse(%q) = %+v, want %s", code, got, c)
+		}
+	}
+	if len(ControlCommands()) != len(controlCodes) {
+		t.Errorf("ControlCommands has %d commands, but there are %d control codes", len(ControlCommands()), len(controlCodes))
+	}
+}
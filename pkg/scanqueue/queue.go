@@ -0,0 +1,205 @@
+// Package scanqueue keeps scans that could not be sent to the server on disk,
+// so they survive the server or the scanner restarting.
+//
+// The queue is a file with one JSON scan per line, oldest first. Every change
+// holds a lock on a file next to it, so the scanner and the queue command can
+// use the same queue at the same time.
+package scanqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Scan is a book scanned while cataloguing, with where it was scanned.
+type Scan struct {
+	ISBN string `json:"isbn"`
+	// AddOn is the add-on scanned after the ISBN, if any.
+	AddOn     string    `json:"add_on,omitempty"`
+	ShelfID   int       `json:"shelf_id"`
+	RowNumber int       `json:"row_number"`
+	Slot      int       `json:"slot,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+func (s Scan) equal(o Scan) bool {
+	return s.ISBN == o.ISBN && s.AddOn == o.AddOn && s.ShelfID == o.ShelfID && s.RowNumber == o.RowNumber &&
+		s.Slot == o.Slot && s.Source == o.Source && s.Device == o.Device && s.ScannedAt.Equal(o.ScannedAt)
+}
+
+// Queue is a queue of scans in a file.
+type Queue struct {
+	path string
+}
+
+// Open opens the queue in path, creating its directory if needed. The file
+// itself is created when the first scan is pushed.
+func Open(path string) (*Queue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create queue directory: %w", err)
+	}
+	return &Queue{path: path}, nil
+}
+
+// Path returns the file the queue is kept in.
+func (q *Queue) Path() string {
+	return q.path
+}
+
+// Push adds a scan to the end of the queue. It returns once the scan is on
+// disk.
+func (q *Queue) Push(s Scan) error {
+	return q.locked(func() error {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(q.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(line, '\n')); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// List returns the scans in the queue, oldest first.
+func (q *Queue) List() ([]Scan, error) {
+	var scans []Scan
+	err := q.locked(func() error {
+		var err error
+		scans, err = q.read()
+		return err
+	})
+	return scans, err
+}
+
+// Len returns how many scans are in the queue.
+func (q *Queue) Len() (int, error) {
+	scans, err := q.List()
+	return len(scans), err
+}
+
+// Remove takes the oldest scan equal to s out of the queue. It is not an
+// error if s is no longer in the queue.
+func (q *Queue) Remove(s Scan) error {
+	return q.locked(func() error {
+		scans, err := q.read()
+		if err != nil {
+			return err
+		}
+		for i, queued := range scans {
+			if queued.equal(s) {
+				return q.write(append(scans[:i], scans[i+1:]...))
+			}
+		}
+		return nil
+	})
+}
+
+// Clear drops every scan in the queue and returns how many there were.
+func (q *Queue) Clear() (int, error) {
+	n := 0
+	err := q.locked(func() error {
+		scans, err := q.read()
+		if err != nil {
+			return err
+		}
+		n = len(scans)
+		return q.write(nil)
+	})
+	return n, err
+}
+
+func (q *Queue) read() ([]Scan, error) {
+	f, err := os.Open(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var scans []Scan
+	lines := bufio.NewScanner(f)
+	for n := 1; lines.Scan(); n++ {
+		if len(lines.Bytes()) == 0 {
+			continue
+		}
+		var s Scan
+		if err := json.Unmarshal(lines.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", q.path, n, err)
+		}
+		scans = append(scans, s)
+	}
+	return scans, lines.Err()
+}
+
+// write replaces the queue with scans. The new queue is written next to the
+// old one and renamed over it, so a crash leaves one or the other.
+func (q *Queue) write(scans []Scan) error {
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, s := range scans {
+		line, err := json.Marshal(s)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		_, _ = w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// locked runs fn while holding the queue's lock.
+func (q *Queue) locked(fn func() error) error {
+	lock, err := os.OpenFile(q.path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Close()
+	}()
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return fmt.Errorf("cannot lock queue: %w", err)
+	}
+	defer func() {
+		_ = unix.Flock(int(lock.Fd()), unix.LOCK_UN)
+	}()
+
+	return fn()
+}
@@ -0,0 +1,68 @@
+package scanqueue
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueue(t *testing.T) {
+	q, err := Open(filepath.Join(t.TempDir(), "spool", "queue.jsonl"))
+	if err != nil {
+		t.Fatalf("cannot open queue: %v", err)
+	}
+
+	if n, err := q.Len(); err != nil || n != 0 {
+		t.Fatalf("expected an empty queue, got %d, %v", n, err)
+	}
+
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	scans := []Scan{
+		{ISBN: "9783836526722", ShelfID: 1, RowNumber: 2, Source: "stdin", ScannedAt: now},
+		{ISBN: "9780000000002", ShelfID: 1, RowNumber: 2, Slot: 3, ScannedAt: now.Add(time.Second)},
+		{ISBN: "9783836526722", ShelfID: 2, RowNumber: 1, ScannedAt: now.Add(2 * time.Second)},
+	}
+	for _, s := range scans {
+		if err := q.Push(s); err != nil {
+			t.Fatalf("cannot push: %v", err)
+		}
+	}
+
+	// A second handle on the same file sees the same queue.
+	other, err := Open(q.Path())
+	if err != nil {
+		t.Fatalf("cannot open queue: %v", err)
+	}
+	got, err := other.List()
+	if err != nil {
+		t.Fatalf("cannot list: %v", err)
+	}
+	if diff := cmp.Diff(scans, got); diff != "" {
+		t.Errorf("unexpected queue (-want +got):\n%s", diff)
+	}
+
+	if err := q.Remove(got[1]); err != nil {
+		t.Fatalf("cannot remove: %v", err)
+	}
+	// Removing a scan that is gone already is fine.
+	if err := q.Remove(got[1]); err != nil {
+		t.Fatalf("cannot remove: %v", err)
+	}
+	got, err = q.List()
+	if err != nil {
+		t.Fatalf("cannot list: %v", err)
+	}
+	if diff := cmp.Diff([]Scan{scans[0], scans[2]}, got); diff != "" {
+		t.Errorf("unexpected queue after removing a scan (-want +got):\n%s", diff)
+	}
+
+	n, err := q.Clear()
+	if err != nil || n != 2 {
+		t.Fatalf("expected to clear 2 scans, got %d, %v", n, err)
+	}
+	if n, err := q.Len(); err != nil || n != 0 {
+		t.Errorf("expected an empty queue, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,77 @@
+package shelfview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// DominantColor returns the most common colour of an image. Colours are
+// grouped into buckets so that slightly different shades count together,
+// and near-white and near-black pixels, usually the background and the text of
+// a cover, only count when there is nothing else. It returns false for empty
+// images.
+func DominantColor(img image.Image) (color.RGBA, bool) {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	var colours, plain [4096]bucket
+
+	b := img.Bounds()
+	// Look at no more than about 100x100 pixels.
+	step := max(1, max(b.Dx(), b.Dy())/100)
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if c.A < 128 {
+				continue
+			}
+			buckets := &colours
+			if isPlain(c) {
+				buckets = &plain
+			}
+			bk := &buckets[int(c.R>>4)<<8|int(c.G>>4)<<4|int(c.B>>4)]
+			bk.count++
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+		}
+	}
+
+	for _, buckets := range []*[4096]bucket{&colours, &plain} {
+		best := -1
+		for i, bk := range buckets {
+			if bk.count > 0 && (best < 0 || bk.count > buckets[best].count) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			bk := buckets[best]
+			return color.RGBA{uint8(bk.r / bk.count), uint8(bk.g / bk.count), uint8(bk.b / bk.count), 0xff}, true
+		}
+	}
+	return color.RGBA{}, false
+}
+
+func isPlain(c color.RGBA) bool {
+	return (c.R > 235 && c.G > 235 && c.B > 235) || (c.R < 20 && c.G < 20 && c.B < 20)
+}
+
+// Hex formats a colour as "#rrggbb".
+func Hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// ParseHex parses a colour formatted by Hex.
+func ParseHex(s string) (color.RGBA, bool) {
+	var c color.RGBA
+	if len(s) != 7 {
+		return c, false
+	}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, false
+	}
+	c.A = 0xff
+	return c, true
+}
@@ -0,0 +1,206 @@
+// Package shelfview draws shelf rows as images of book spines, so a shelf can
+// be looked at without standing in front of it.
+//
+// Spines are as wide as the book is thick, estimated from its page count, and
+// coloured with the dominant colour of its cover. All sizes are in pixels.
+package shelfview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	margin      = 10
+	labelWidth  = 60
+	rowHeight   = 200
+	boardHeight = 12
+	spineHeight = 180
+	gap         = 1
+	minWidth    = 320
+)
+
+var (
+	background = color.RGBA{0xf5, 0xf0, 0xe6, 0xff}
+	backPanel  = color.RGBA{0xd9, 0xc3, 0xa0, 0xff}
+	board      = color.RGBA{0x8b, 0x5a, 0x2b, 0xff}
+
+	// fallback colours books without a cover are drawn in.
+	fallback = []color.RGBA{
+		{0x8e, 0x44, 0x3d, 0xff},
+		{0x2c, 0x5f, 0x7c, 0xff},
+		{0x3d, 0x6b, 0x45, 0xff},
+		{0x7a, 0x5c, 0x8e, 0xff},
+		{0xb0, 0x7d, 0x2b, 0xff},
+		{0x4a, 0x4a, 0x5a, 0xff},
+		{0x9c, 0x6b, 0x5a, 0xff},
+		{0x2f, 0x7f, 0x7a, 0xff},
+	}
+)
+
+var regular, _ = truetype.Parse(goregular.TTF)
+
+// Book is a book to draw the spine of.
+type Book struct {
+	ISBN  int
+	Title string
+	Pages int
+	// Color is the colour of the spine. Books without one get a colour
+	// picked from their ISBN.
+	Color color.RGBA
+}
+
+// Row is a shelf row with its books from left to right.
+type Row struct {
+	Number int
+	Books  []Book
+}
+
+// Spine is where a book is drawn in the image.
+type Spine struct {
+	Book
+	Rect image.Rectangle
+}
+
+// Layout places the spines of one or more rows, one row above the other.
+type Layout struct {
+	Width  int
+	Height int
+	Spines []Spine
+
+	rows []Row
+}
+
+// NewLayout lays out the rows from top to bottom.
+func NewLayout(rows []Row) *Layout {
+	l := &Layout{Width: minWidth, Height: 2*margin + len(rows)*(rowHeight+boardHeight), rows: rows}
+	for i, row := range rows {
+		bottom := margin + i*(rowHeight+boardHeight) + rowHeight
+		x := margin + labelWidth
+		for _, book := range row.Books {
+			width := SpineWidth(book.Pages)
+			height := spineHeight - book.ISBN%31
+			l.Spines = append(l.Spines, Spine{
+				Book: book,
+				Rect: image.Rect(x, bottom-height, x+width, bottom),
+			})
+			x += width + gap
+		}
+		l.Width = max(l.Width, x+margin)
+	}
+	return l
+}
+
+// SpineWidth estimates how thick a book is from its page count. Books with an
+// unknown page count get an average width.
+func SpineWidth(pages int) int {
+	if pages <= 0 {
+		return 24
+	}
+	return min(max(6+pages/15, 10), 60)
+}
+
+// Draw renders the layout.
+func (l *Layout) Draw() image.Image {
+	dc := gg.NewContext(l.Width, l.Height)
+	fonts := faces{}
+	dc.SetColor(background)
+	dc.Clear()
+
+	for i, row := range l.rows {
+		top := float64(margin + i*(rowHeight+boardHeight))
+		dc.SetColor(backPanel)
+		dc.DrawRectangle(margin+labelWidth-4, top, float64(l.Width-2*margin-labelWidth+4), rowHeight)
+		dc.Fill()
+		dc.SetColor(board)
+		dc.DrawRectangle(margin, top+rowHeight, float64(l.Width-2*margin), boardHeight)
+		dc.Fill()
+
+		dc.SetFontFace(fonts.get(14))
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(fmt.Sprintf("Row %d", row.Number), margin+labelWidth/2, top+rowHeight/2, 0.5, 0.5)
+	}
+
+	for _, s := range l.Spines {
+		drawSpine(dc, fonts, s)
+	}
+	return dc.Image()
+}
+
+func drawSpine(dc *gg.Context, faces faces, s Spine) {
+	fill := s.Color
+	if fill.A == 0 {
+		fill = fallback[s.ISBN%len(fallback)]
+	}
+	r := s.Rect
+	x, y := float64(r.Min.X), float64(r.Min.Y)
+	w, h := float64(r.Dx()), float64(r.Dy())
+
+	dc.SetColor(fill)
+	dc.DrawRectangle(x, y, w, h)
+	dc.Fill()
+	dc.SetColor(shade(fill, 0.7))
+	dc.SetLineWidth(1)
+	dc.DrawRectangle(x+0.5, y+0.5, w-1, h-1)
+	dc.Stroke()
+
+	if s.Title == "" {
+		return
+	}
+	dc.SetFontFace(faces.get(math.Min(14, w*0.6)))
+	if luminance(fill) > 0.55 {
+		dc.SetColor(color.Black)
+	} else {
+		dc.SetColor(color.White)
+	}
+	cx, cy := x+w/2, y+h/2
+	dc.Push()
+	dc.RotateAbout(-math.Pi/2, cx, cy)
+	dc.DrawStringAnchored(fit(dc, s.Title, h-10), cx, cy, 0.5, 0.35)
+	dc.Pop()
+}
+
+// fit shortens the title until it fits in width.
+func fit(dc *gg.Context, title string, width float64) string {
+	if w, _ := dc.MeasureString(title); w <= width {
+		return title
+	}
+	runes := []rune(title)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		s := string(runes) + "…"
+		if w, _ := dc.MeasureString(s); w <= width {
+			return s
+		}
+	}
+	return ""
+}
+
+// faces are the font faces of one drawing by size. Faces cache glyphs, so
+// they are not shared between drawings.
+type faces map[float64]font.Face
+
+func (f faces) get(size float64) font.Face {
+	size = math.Round(size)
+	if face, ok := f[size]; ok {
+		return face
+	}
+	face := truetype.NewFace(regular, &truetype.Options{Size: size})
+	f[size] = face
+	return face
+}
+
+func shade(c color.RGBA, f float64) color.RGBA {
+	return color.RGBA{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f), c.A}
+}
+
+func luminance(c color.RGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+}
@@ -0,0 +1,135 @@
+package shelfview
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewLayout(t *testing.T) {
+	rows := []Row{
+		{Number: 1, Books: []Book{{ISBN: 9780000000002, Pages: 300}, {ISBN: 9780000000019}}},
+		{Number: 2},
+		{Number: 3, Books: []Book{{ISBN: 9780000000026, Pages: 1200}}},
+	}
+	l := NewLayout(rows)
+
+	if len(l.Spines) != 3 {
+		t.Fatalf("expected 3 spines, got %d", len(l.Spines))
+	}
+	if l.Height != 2*margin+3*(rowHeight+boardHeight) || l.Width != minWidth {
+		t.Errorf("unexpected size %dx%d", l.Width, l.Height)
+	}
+
+	first, second, third := l.Spines[0].Rect, l.Spines[1].Rect, l.Spines[2].Rect
+	if first.Dx() != 26 || second.Dx() != 24 || third.Dx() != 60 {
+		t.Errorf("unexpected spine widths %d, %d, %d", first.Dx(), second.Dx(), third.Dx())
+	}
+	if second.Min.X != first.Max.X+gap || first.Max.Y != second.Max.Y {
+		t.Errorf("expected spines side by side on the same row, got %v and %v", first, second)
+	}
+	// The third book stands on the third row.
+	if third.Max.Y != margin+2*(rowHeight+boardHeight)+rowHeight || third.Min.X != first.Min.X {
+		t.Errorf("unexpected third spine %v", third)
+	}
+	for _, s := range l.Spines {
+		if !s.Rect.In(image.Rect(0, 0, l.Width, l.Height)) {
+			t.Errorf("spine %v is outside the image", s.Rect)
+		}
+	}
+}
+
+func TestNewLayoutWidth(t *testing.T) {
+	var books []Book
+	for i := range 20 {
+		books = append(books, Book{ISBN: i, Pages: 900})
+	}
+	l := NewLayout([]Row{{Number: 1, Books: books}})
+	if want := margin + labelWidth + 20*(60+gap) + margin; l.Width != want {
+		t.Errorf("expected width %d, got %d", want, l.Width)
+	}
+}
+
+func TestSpineWidth(t *testing.T) {
+	for pages, want := range map[int]int{0: 24, 20: 10, 150: 16, 450: 36, 5000: 60} {
+		if got := SpineWidth(pages); got != want {
+			t.Errorf("SpineWidth(%d) = %d, want %d", pages, got, want)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	red := color.RGBA{0xc0, 0x20, 0x20, 0xff}
+	l := NewLayout([]Row{{Number: 1, Books: []Book{
+		{ISBN: 9780000000002, Title: "A rather long title that does not fit on the spine at all", Pages: 300, Color: red},
+		{ISBN: 9780000000019},
+	}}})
+	img := l.Draw()
+
+	if img.Bounds() != image.Rect(0, 0, l.Width, l.Height) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	// Near the bottom of the spine, below the title.
+	r := l.Spines[0].Rect
+	if got := color.RGBAModel.Convert(img.At(r.Min.X+3, r.Max.Y-3)); got != red {
+		t.Errorf("expected the spine to be red, got %v", got)
+	}
+	r = l.Spines[1].Rect
+	if got := color.RGBAModel.Convert(img.At(r.Min.X+3, r.Max.Y-3)); got != fallback[9780000000019%len(fallback)] {
+		t.Errorf("expected the fallback colour, got %v", got)
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	blue := color.RGBA{0x20, 0x40, 0xa0, 0xff}
+	img := image.NewRGBA(image.Rect(0, 0, 300, 450))
+	for y := range 450 {
+		for x := range 300 {
+			switch {
+			// A white border and black text take up most of the cover.
+			case x < 60 || x >= 240:
+				img.Set(x, y, color.White)
+			case y < 200:
+				img.Set(x, y, color.Black)
+			case y < 300:
+				img.Set(x, y, blue)
+			default:
+				img.Set(x, y, color.RGBA{0x22, 0x42, 0xa3, 0xff})
+			}
+		}
+	}
+
+	got, ok := DominantColor(img)
+	if !ok {
+		t.Fatal("expected a colour")
+	}
+	if got.R>>4 != blue.R>>4 || got.G>>4 != blue.G>>4 || got.B>>4 != blue.B>>4 {
+		t.Errorf("expected about %v, got %v", blue, got)
+	}
+
+	if got, ok := DominantColor(image.NewRGBA(image.Rectangle{})); ok {
+		t.Errorf("expected no colour for an empty image, got %v", got)
+	}
+	plain := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := range plain.Pix {
+		plain.Pix[i] = 0xff
+	}
+	if got, ok := DominantColor(plain); !ok || got != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("expected white for a plain cover, got %v", got)
+	}
+}
+
+func TestHex(t *testing.T) {
+	c := color.RGBA{0x12, 0xab, 0xff, 0xff}
+	if got := Hex(c); got != "#12abff" {
+		t.Errorf("unexpected hex %q", got)
+	}
+	if got, ok := ParseHex("#12abff"); !ok || got != c {
+		t.Errorf("unexpected colour %v", got)
+	}
+	for _, s := range []string{"", "12abff", "#12abzz"} {
+		if _, ok := ParseHex(s); ok {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
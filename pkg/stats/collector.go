@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	booksDesc = prometheus.NewDesc(
+		"librascan_books",
+		"Number of books in the collection.",
+		nil, nil,
+	)
+	pagesDesc = prometheus.NewDesc(
+		"librascan_pages",
+		"Total number of pages across all books.",
+		nil, nil,
+	)
+	aiEnrichedDesc = prometheus.NewDesc(
+		"librascan_books_ai_enriched",
+		"Number of books that have been enriched by the AI job.",
+		nil, nil,
+	)
+	booksByShelfDesc = prometheus.NewDesc(
+		"librascan_books_by_shelf",
+		"Number of books per shelf.",
+		[]string{"shelf_id", "shelf"}, nil,
+	)
+	booksByLanguageDesc = prometheus.NewDesc(
+		"librascan_books_by_language",
+		"Number of books per language.",
+		[]string{"language"}, nil,
+	)
+	booksByCategoryDesc = prometheus.NewDesc(
+		"librascan_books_by_category",
+		"Number of books per category.",
+		[]string{"category"}, nil,
+	)
+	booksByDecadeDesc = prometheus.NewDesc(
+		"librascan_books_by_decade",
+		"Number of books per publication decade.",
+		[]string{"decade"}, nil,
+	)
+	booksAddedDesc = prometheus.NewDesc(
+		"librascan_books_added",
+		"Number of books added per month.",
+		[]string{"month"}, nil,
+	)
+	// The loans are labelled by ISBN and person id only: /metrics is public,
+	// and what people borrow is nobody else's business.
+	bookLoansDesc = prometheus.NewDesc(
+		"librascan_book_loans",
+		"Number of loans of the most borrowed books.",
+		[]string{"isbn"}, nil,
+	)
+	personLoansDesc = prometheus.NewDesc(
+		"librascan_person_loans",
+		"Number of loans of the most active borrowers.",
+		[]string{"person_id"}, nil,
+	)
+	returnedLoansDesc = prometheus.NewDesc(
+		"librascan_returned_loans",
+		"Number of loans that have been returned.",
+		nil, nil,
+	)
+	averageLoanDaysDesc = prometheus.NewDesc(
+		"librascan_average_loan_days",
+		"Average duration of returned loans in days.",
+		nil, nil,
+	)
+	scrapeErrorDesc = prometheus.NewDesc(
+		"librascan_stats_scrape_error",
+		"1 if computing the statistics failed during the last scrape.",
+		nil, nil,
+	)
+)
+
+// Collector exports the collection statistics as Prometheus gauges. The
+// numbers are computed from the database on every scrape.
+type Collector struct {
+	queries *db.Queries
+	timeout time.Duration
+}
+
+func NewCollector(database *sql.DB) *Collector {
+	return &Collector{
+		queries: db.New(database),
+		timeout: 10 * time.Second,
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- booksDesc
+	ch <- pagesDesc
+	ch <- aiEnrichedDesc
+	ch <- booksByShelfDesc
+	ch <- booksByLanguageDesc
+	ch <- booksByCategoryDesc
+	ch <- booksByDecadeDesc
+	ch <- booksAddedDesc
+	ch <- bookLoansDesc
+	ch <- personLoansDesc
+	ch <- returnedLoansDesc
+	ch <- averageLoanDaysDesc
+	ch <- scrapeErrorDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	stats, err := Compute(ctx, c.queries)
+	if err != nil {
+		log.Printf("failed to compute stats: %v", err)
+		ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 0)
+
+	ch <- prometheus.MustNewConstMetric(booksDesc, prometheus.GaugeValue, float64(stats.Books))
+	ch <- prometheus.MustNewConstMetric(pagesDesc, prometheus.GaugeValue, float64(stats.Pages))
+	ch <- prometheus.MustNewConstMetric(aiEnrichedDesc, prometheus.GaugeValue, float64(stats.AIEnriched))
+	ch <- prometheus.MustNewConstMetric(returnedLoansDesc, prometheus.GaugeValue, float64(stats.ReturnedLoans))
+	ch <- prometheus.MustNewConstMetric(averageLoanDaysDesc, prometheus.GaugeValue, stats.AverageLoanDays)
+
+	for _, s := range stats.ByShelf {
+		ch <- prometheus.MustNewConstMetric(booksByShelfDesc, prometheus.GaugeValue, float64(s.Books), strconv.Itoa(s.ID), s.Name)
+	}
+	for _, l := range stats.ByLanguage {
+		ch <- prometheus.MustNewConstMetric(booksByLanguageDesc, prometheus.GaugeValue, float64(l.Books), l.Label)
+	}
+	for _, cat := range stats.ByCategory {
+		ch <- prometheus.MustNewConstMetric(booksByCategoryDesc, prometheus.GaugeValue, float64(cat.Books), cat.Label)
+	}
+	for _, d := range stats.ByDecade {
+		ch <- prometheus.MustNewConstMetric(booksByDecadeDesc, prometheus.GaugeValue, float64(d.Books), d.Label)
+	}
+	for _, m := range stats.AddedByMonth {
+		ch <- prometheus.MustNewConstMetric(booksAddedDesc, prometheus.GaugeValue, float64(m.Books), m.Label)
+	}
+	for _, b := range stats.MostBorrowed {
+		ch <- prometheus.MustNewConstMetric(bookLoansDesc, prometheus.GaugeValue, float64(b.Loans), strconv.Itoa(b.ISBN))
+	}
+	for _, p := range stats.TopBorrowers {
+		ch <- prometheus.MustNewConstMetric(personLoansDesc, prometheus.GaugeValue, float64(p.Loans), strconv.Itoa(p.ID))
+	}
+}
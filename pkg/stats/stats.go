@@ -0,0 +1,103 @@
+// Package stats summarises the collection and its lending history.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/gouthamve/librascan/pkg/models"
+)
+
+// TopN is the number of entries in the most borrowed and most active
+// borrower lists.
+const TopN = 10
+
+// Compute gathers all statistics in one go.
+func Compute(ctx context.Context, queries *db.Queries) (models.Stats, error) {
+	stats := models.Stats{}
+
+	totals, err := queries.GetBookTotals(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get book totals: %v", err)
+	}
+	stats.Books = int(totals.Books)
+	stats.Pages = int(totals.Pages)
+	stats.AIEnriched = int(totals.AiEnriched)
+	if totals.Books > 0 {
+		stats.EnrichedRatio = float64(totals.AiEnriched) / float64(totals.Books)
+	}
+
+	shelves, err := queries.CountBooksByShelf(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count books by shelf: %v", err)
+	}
+	stats.ByShelf = make([]models.ShelfCount, 0, len(shelves))
+	for _, s := range shelves {
+		stats.ByShelf = append(stats.ByShelf, models.ShelfCount{ID: int(s.ShelfID), Name: s.ShelfName, Books: int(s.Books)})
+	}
+
+	languages, err := queries.CountBooksByLanguage(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count books by language: %v", err)
+	}
+	stats.ByLanguage = make([]models.LabelCount, 0, len(languages))
+	for _, l := range languages {
+		stats.ByLanguage = append(stats.ByLanguage, models.LabelCount{Label: l.Language, Books: int(l.Books)})
+	}
+
+	categories, err := queries.CountBooksByCategory(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count books by category: %v", err)
+	}
+	stats.ByCategory = make([]models.LabelCount, 0, len(categories))
+	for _, c := range categories {
+		stats.ByCategory = append(stats.ByCategory, models.LabelCount{Label: c.Category, Books: int(c.Books)})
+	}
+
+	decades, err := queries.CountBooksByDecade(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count books by decade: %v", err)
+	}
+	stats.ByDecade = make([]models.LabelCount, 0, len(decades))
+	for _, d := range decades {
+		stats.ByDecade = append(stats.ByDecade, models.LabelCount{Label: strconv.FormatInt(d.Decade, 10) + "s", Books: int(d.Books)})
+	}
+
+	months, err := queries.CountBooksAddedByMonth(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count books added by month: %v", err)
+	}
+	stats.AddedByMonth = make([]models.LabelCount, 0, len(months))
+	for _, m := range months {
+		stats.AddedByMonth = append(stats.AddedByMonth, models.LabelCount{Label: m.Month, Books: int(m.Books)})
+	}
+
+	borrowed, err := queries.GetMostBorrowedBooks(ctx, TopN)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get most borrowed books: %v", err)
+	}
+	stats.MostBorrowed = make([]models.BookLoanCount, 0, len(borrowed))
+	for _, b := range borrowed {
+		stats.MostBorrowed = append(stats.MostBorrowed, models.BookLoanCount{ISBN: int(b.Isbn), Title: db.NullStringToString(b.Title), Loans: int(b.Loans)})
+	}
+
+	borrowers, err := queries.GetMostActiveBorrowers(ctx, TopN)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get most active borrowers: %v", err)
+	}
+	stats.TopBorrowers = make([]models.PersonLoanCount, 0, len(borrowers))
+	for _, p := range borrowers {
+		stats.TopBorrowers = append(stats.TopBorrowers, models.PersonLoanCount{ID: int(p.ID), Name: p.Name, Loans: int(p.Loans)})
+	}
+
+	durations, err := queries.GetLoanDurations(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get loan durations: %v", err)
+	}
+	stats.ReturnedLoans = int(durations.ReturnedLoans)
+	stats.AverageLoanDays = durations.AverageDays
+
+	return stats, nil
+}
@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/gouthamve/librascan/migrations"
+	"github.com/gouthamve/librascan/pkg/db"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	_ "modernc.org/sqlite"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	database, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := database.Close(); err != nil {
+			t.Logf("failed to close database: %v", err)
+		}
+	})
+
+	ctx := t.Context()
+	tx, err := database.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	for i, up := range []func(ctx context.Context, tx *sql.Tx) error{
+		migrations.Up0001, migrations.Up0002, migrations.Up0003, migrations.Up0004,
+		migrations.Up0005, migrations.Up0006, migrations.Up0007, migrations.Up0008,
+		migrations.Up0009, migrations.Up0010, migrations.Up0011, migrations.Up0012,
+		migrations.Up0013, migrations.Up0014, migrations.Up0015, migrations.Up0016,
+		migrations.Up0017, migrations.Up0018, migrations.Up0019,
+	} {
+		if err := up(ctx, tx); err != nil {
+			t.Fatalf("failed to run migration %04d: %v", i+1, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+
+	stmts := []string{
+		`INSERT INTO books (isbn, title, published_date, pages, language, shelf_id, is_ai_enriched, added_at) VALUES
+			(9783836526722, 'Grimm', '2011-11-01', 300, 'de', 1, 1, '2025-01-05 10:00:00'),
+			(9780141182550, '1984', '1949', 200, 'en', 1, 0, '2025-01-20 10:00:00'),
+			(9780261103573, 'The Hobbit', '1937-09-21', 100, 'en', NULL, 1, '2025-02-01 10:00:00'),
+			(9780000000002, NULL, 'unknown', NULL, NULL, NULL, 0, NULL)`,
+		`INSERT INTO categories (name, isbn) VALUES ('Fiction', 9780141182550), ('Fiction', 9780261103573), ('Fairy tales', 9783836526722)`,
+		`INSERT INTO people (id, name) VALUES (1, 'John Doe'), (2, 'Jane Doe')`,
+		`INSERT INTO borrowing (isbn, person_id, borrowed_at, returned_at) VALUES
+			(9780141182550, 1, '2025-01-01 00:00:00', '2025-01-11 00:00:00'),
+			(9780141182550, 2, '2025-02-01 00:00:00', '2025-02-21 00:00:00'),
+			(9780261103573, 1, '2025-03-01 00:00:00', NULL)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("failed to insert test data: %v", err)
+		}
+	}
+
+	return database
+}
+
+func TestCompute(t *testing.T) {
+	database := setupTestDB(t)
+
+	stats, err := Compute(t.Context(), db.New(database))
+	if err != nil {
+		t.Fatalf("Compute() returned error: %v", err)
+	}
+
+	if stats.Books != 4 || stats.Pages != 600 || stats.AIEnriched != 2 || stats.EnrichedRatio != 0.5 {
+		t.Errorf("unexpected totals: %+v", stats)
+	}
+
+	if len(stats.ByShelf) != 2 || stats.ByShelf[0].ID != 0 || stats.ByShelf[0].Books != 2 ||
+		stats.ByShelf[1].ID != 1 || stats.ByShelf[1].Name != "office-big" || stats.ByShelf[1].Books != 2 {
+		t.Errorf("unexpected shelf counts: %+v", stats.ByShelf)
+	}
+
+	if len(stats.ByLanguage) != 3 || stats.ByLanguage[0].Label != "en" || stats.ByLanguage[0].Books != 2 {
+		t.Errorf("unexpected language counts: %+v", stats.ByLanguage)
+	}
+
+	if len(stats.ByCategory) != 2 || stats.ByCategory[0].Label != "Fiction" || stats.ByCategory[0].Books != 2 {
+		t.Errorf("unexpected category counts: %+v", stats.ByCategory)
+	}
+
+	decades := []string{}
+	for _, d := range stats.ByDecade {
+		decades = append(decades, d.Label)
+	}
+	if got := strings.Join(decades, ","); got != "1930s,1940s,2010s" {
+		t.Errorf("unexpected decades: %s", got)
+	}
+
+	if len(stats.AddedByMonth) != 2 || stats.AddedByMonth[0].Label != "2025-01" || stats.AddedByMonth[0].Books != 2 {
+		t.Errorf("unexpected added by month: %+v", stats.AddedByMonth)
+	}
+
+	if len(stats.MostBorrowed) != 2 || stats.MostBorrowed[0].ISBN != 9780141182550 || stats.MostBorrowed[0].Loans != 2 {
+		t.Errorf("unexpected most borrowed: %+v", stats.MostBorrowed)
+	}
+
+	if len(stats.TopBorrowers) != 2 || stats.TopBorrowers[0].Name != "John Doe" || stats.TopBorrowers[0].Loans != 2 {
+		t.Errorf("unexpected top borrowers: %+v", stats.TopBorrowers)
+	}
+
+	if stats.ReturnedLoans != 2 || stats.AverageLoanDays != 15 {
+		t.Errorf("unexpected loan durations: %d loans, %f days", stats.ReturnedLoans, stats.AverageLoanDays)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	database := setupTestDB(t)
+
+	expected := `
+# HELP librascan_books Number of books in the collection.
+# TYPE librascan_books gauge
+librascan_books 4
+# HELP librascan_books_by_language Number of books per language.
+# TYPE librascan_books_by_language gauge
+librascan_books_by_language{language="de"} 1
+librascan_books_by_language{language="en"} 2
+librascan_books_by_language{language="unknown"} 1
+# HELP librascan_average_loan_days Average duration of returned loans in days.
+# TYPE librascan_average_loan_days gauge
+librascan_average_loan_days 15
+# HELP librascan_book_loans Number of loans of the most borrowed books.
+# TYPE librascan_book_loans gauge
+librascan_book_loans{isbn="9780141182550"} 2
+librascan_book_loans{isbn="9780261103573"} 1
+# HELP librascan_person_loans Number of loans of the most active borrowers.
+# TYPE librascan_person_loans gauge
+librascan_person_loans{person_id="1"} 2
+librascan_person_loans{person_id="2"} 1
+`
+	err := testutil.CollectAndCompare(NewCollector(database), strings.NewReader(expected),
+		"librascan_books", "librascan_books_by_language", "librascan_average_loan_days",
+		"librascan_book_loans", "librascan_person_loans")
+	if err != nil {
+		t.Error(err)
+	}
+}
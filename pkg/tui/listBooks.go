@@ -1,9 +1,7 @@
 package tui
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
 
@@ -11,19 +9,21 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
+	"github.com/gouthamve/librascan/pkg/api"
+	"github.com/gouthamve/librascan/pkg/client"
 	"github.com/gouthamve/librascan/pkg/models"
 )
 
-func listBooks(serverURL string, app *tview.Application) tview.Primitive {
+func listBooks(apiClient *client.Client, app *tview.Application) tview.Primitive {
 	flex := tview.NewFlex().SetDirection(tview.FlexRow)
 	flex.SetBorder(true).SetTitle("Books").SetTitleAlign(tview.AlignCenter)
 
-	go getAndRenderBooks(serverURL, app, flex)
+	go getAndRenderBooks(apiClient, app, flex)
 
 	return flex
 }
 
-func getAndRenderBooks(serverURL string, app *tview.Application, flex *tview.Flex) {
+func getAndRenderBooks(apiClient *client.Client, app *tview.Application, flex *tview.Flex) {
 	loadingBooks := tview.NewTextView().SetText("Loading Books").SetTextAlign(tview.AlignCenter)
 	flex.
 		AddItem(nil, 0, 1, false).
@@ -31,30 +31,24 @@ func getAndRenderBooks(serverURL string, app *tview.Application, flex *tview.Fle
 		AddItem(nil, 0, 1, false)
 
 	// Fetch the books
-	resp, err := http.Get(serverURL + "/books")
+	books, err := apiClient.ListBooks()
 	if err != nil {
 		loadingBooks.SetText("Error fetching books: " + err.Error())
 		return
 	}
 
-	books := []models.Book{}
-	if err := json.NewDecoder(resp.Body).Decode(&books); err != nil {
-		loadingBooks.SetText("Error decoding books: " + err.Error())
-		return
-	}
-
 	searchIndex, err := indexBooks(books)
 	if err != nil {
 		loadingBooks.SetText("Error indexing books: " + err.Error())
 		return
 	}
 
-	renderBooks(searchIndex, books, flex, app, serverURL)
+	renderBooks(searchIndex, books, flex, app, apiClient)
 }
 
-func renderBooks(searchIndex *bookIndex, books []models.Book, flex *tview.Flex, app *tview.Application, serverURL string) {
+func renderBooks(searchIndex *bookIndex, books []api.Book, flex *tview.Flex, app *tview.Application, apiClient *client.Client) {
 	table := bookTable(books)
-	table.SetSelectedFunc(selectedFunc(books, flex, app, serverURL))
+	table.SetSelectedFunc(selectedFunc(books, flex, app, apiClient))
 
 	flex.Clear()
 
@@ -99,7 +93,7 @@ func renderBooks(searchIndex *bookIndex, books []models.Book, flex *tview.Flex,
 				}
 
 				app.SetFocus(flex)
-				go renderBooks(searchIndex, matchingBooks, flex, app, serverURL)
+				go renderBooks(searchIndex, matchingBooks, flex, app, apiClient)
 			})
 		}
 
@@ -107,10 +101,10 @@ func renderBooks(searchIndex *bookIndex, books []models.Book, flex *tview.Flex,
 	})
 }
 
-func bookTable(books []models.Book) *tview.Table {
+func bookTable(books []api.Book) *tview.Table {
 	// Display the books
 	table := tview.NewTable().SetBorders(true).SetSelectable(true, false).SetFixed(1, 0)
-	columns := []string{"ISBN", "Title", "Authors", "Published Date", "Categories", "Pages", "Language", "Shelf Name", "Row Number"}
+	columns := []string{"ISBN", "Title", "Authors", "Published Date", "Categories", "Pages", "Language", "Location"}
 
 	// Header row.
 	for i, col := range columns {
@@ -127,14 +121,13 @@ func bookTable(books []models.Book) *tview.Table {
 		table.SetCell(i+1, 4, tview.NewTableCell(strings.Join(book.Categories, ", ")))
 		table.SetCell(i+1, 5, tview.NewTableCell(strconv.Itoa(book.Pages)))
 		table.SetCell(i+1, 6, tview.NewTableCell(book.Language))
-		table.SetCell(i+1, 7, tview.NewTableCell(book.ShelfName))
-		table.SetCell(i+1, 8, tview.NewTableCell(strconv.Itoa(book.RowNumber)))
+		table.SetCell(i+1, 7, tview.NewTableCell(bookLocation(book)))
 	}
 
 	return table
 }
 
-func selectedFunc(books []models.Book, flex *tview.Flex, app *tview.Application, serverURL string) func(row int, _ int) {
+func selectedFunc(books []api.Book, flex *tview.Flex, app *tview.Application, apiClient *client.Client) func(row int, _ int) {
 	return func(row int, _ int) {
 		if row == 0 {
 			return
@@ -146,18 +139,23 @@ func selectedFunc(books []models.Book, flex *tview.Flex, app *tview.Application,
 		}
 
 		book := books[row]
+		text := fmt.Sprintf("Selected book: %s with ISBN: %d", book.Title, book.ISBN)
+		if locator, err := apiClient.LocateBook(strconv.Itoa(book.ISBN)); err == nil {
+			text += "\n\nFind it at: " + locator.Description
+		}
+
 		modal := tview.NewModal()
 		modal.
-			SetText(fmt.Sprintf("Selected book: %s with ISBN: %d", book.Title, book.ISBN)).
+			SetText(text).
 			AddButtons([]string{"Delete", "Borrow"}).
 			SetDoneFunc(func(_ int, buttonLabel string) {
 				if buttonLabel == "Delete" {
-					handleDeleteModal(book, modal, flex, app, serverURL)
+					handleDeleteModal(book, modal, flex, app, apiClient)
 					return
 				}
 
 				if buttonLabel == "Borrow" {
-					handleBorrowModal(book, flex, app, serverURL)
+					handleBorrowModal(book, flex, app, apiClient)
 					return
 				}
 			})
@@ -167,53 +165,30 @@ func selectedFunc(books []models.Book, flex *tview.Flex, app *tview.Application,
 	}
 }
 
-func handleDeleteModal(book models.Book, modal *tview.Modal, flex *tview.Flex, app *tview.Application, serverURL string) {
-	req, err := http.NewRequest(http.MethodDelete, serverURL+"/books/"+strconv.Itoa(book.ISBN), nil)
-	if err != nil {
-		modal.SetText("Error deleting book: " + err.Error())
-		return
-	}
-
+func handleDeleteModal(book api.Book, modal *tview.Modal, flex *tview.Flex, app *tview.Application, apiClient *client.Client) {
 	// Delete the book
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
+	if err := apiClient.DeleteBook(strconv.Itoa(book.ISBN), client.Scan{}); err != nil {
 		modal.SetText("Error deleting book: " + err.Error())
 		return
 	}
 
-	if resp.StatusCode != http.StatusNoContent {
-		modal.SetText("Error deleting book: " + resp.Status)
-		return
-	}
-
 	modal.SetText("Book deleted successfully")
 	flex.Clear()
-	go getAndRenderBooks(serverURL, app, flex)
+	go getAndRenderBooks(apiClient, app, flex)
 }
 
-func handleBorrowModal(book models.Book, flex *tview.Flex, app *tview.Application, serverURL string) {
+func handleBorrowModal(book api.Book, flex *tview.Flex, app *tview.Application, apiClient *client.Client) {
 	flex.Clear()
 	loadingPeople := tview.NewTextView().SetText("Loading People").SetTextAlign(tview.AlignCenter)
 	flex.AddItem(loadingPeople, 0, 1, true)
 	app.SetFocus(flex)
 
-	resp, err := http.Get(serverURL + "/people")
+	people, err := apiClient.ListPeople()
 	if err != nil {
 		loadingPeople.SetText("Error fetching people: " + err.Error())
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		loadingPeople.SetText("Error fetching people. Status: " + resp.Status)
-		return
-	}
-
-	people := []models.Person{}
-	if err := json.NewDecoder(resp.Body).Decode(&people); err != nil {
-		loadingPeople.SetText("Error decoding people: " + err.Error())
-		return
-	}
-
 	inputField := tview.NewInputField().SetLabel("Person Name").SetFieldWidth(20)
 	inputField.SetAutocompleteFunc(func(currentText string) (entries []string) {
 		for _, person := range people {
@@ -244,37 +219,25 @@ func handleBorrowModal(book models.Book, flex *tview.Flex, app *tview.Applicatio
 		flex.AddItem(loadingPeople, 0, 1, true)
 		app.SetFocus(flex)
 
-		reqBytes, err := json.Marshal(req)
-		if err != nil {
-			loadingPeople.SetText("Error borrowing book: " + err.Error())
-			return
-		}
-
-		resp, err := http.Post(serverURL+"/books/borrow", "application/json", strings.NewReader(string(reqBytes)))
-		if err != nil {
+		if err := apiClient.BorrowBook(req, client.Scan{}); err != nil {
 			loadingPeople.SetText("Error borrowing book: " + err.Error())
 			return
 		}
 
-		if resp.StatusCode != http.StatusNoContent {
-			loadingPeople.SetText("Error borrowing book. Status: " + resp.Status)
-			return
-		}
-
 		loadingPeople.SetText("Book borrowed successfully")
 		flex.Clear()
-		go getAndRenderBooks(serverURL, app, flex)
+		go getAndRenderBooks(apiClient, app, flex)
 	})
 	form.AddButton("Cancel", func() {
 		flex.Clear()
-		go getAndRenderBooks(serverURL, app, flex)
+		go getAndRenderBooks(apiClient, app, flex)
 	})
 	flex.AddItem(form, 0, 1, true)
 	app.SetFocus(form)
 }
 
-func indexBooks(books []models.Book) (*bookIndex, error) {
-	booksByISBN := map[string]models.Book{}
+func indexBooks(books []api.Book) (*bookIndex, error) {
+	booksByISBN := map[string]api.Book{}
 
 	mapping := bleve.NewIndexMapping()
 
@@ -304,16 +267,16 @@ func indexBooks(books []models.Book) (*bookIndex, error) {
 
 type bookIndex struct {
 	searchIndex bleve.Index
-	books       map[string]models.Book
+	books       map[string]api.Book
 }
 
-func (b *bookIndex) Search(req *bleve.SearchRequest) ([]models.Book, error) {
+func (b *bookIndex) Search(req *bleve.SearchRequest) ([]api.Book, error) {
 	searchResults, err := b.searchIndex.Search(req)
 	if err != nil {
 		return nil, err
 	}
 
-	var books []models.Book
+	var books []api.Book
 	for _, hit := range searchResults.Hits {
 		book, ok := b.books[hit.ID]
 		if !ok {
@@ -325,3 +288,15 @@ func (b *bookIndex) Search(req *bleve.SearchRequest) ([]models.Book, error) {
 
 	return books, nil
 }
+
+// bookLocation returns the breadcrumb of the book's location.
+func bookLocation(book api.Book) string {
+	if len(book.LocationPath) == 0 {
+		return "unknown"
+	}
+	location := strings.Join(book.LocationPath, " › ")
+	if book.Slot != 0 {
+		location += fmt.Sprintf(", slot %d", book.Slot)
+	}
+	return location
+}
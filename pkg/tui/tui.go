@@ -3,10 +3,13 @@ package tui
 import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"github.com/gouthamve/librascan/pkg/client"
 )
 
-func Start(startURL string) {
+func Start(startURL, token string) {
 	// Start the TUI
+	apiClient := client.New(startURL, nil).WithToken(token)
 	app := tview.NewApplication()
 	flex := tview.NewFlex()
 	flex.SetBorder(true).SetTitle("Librascan").SetTitleAlign(tview.AlignCenter)
@@ -46,7 +49,7 @@ func Start(startURL string) {
 	modeList := tview.NewList()
 	modeList.
 		AddItem("Manage Books", "Press l to list books", 'l', func() {
-			setSecondItem(flex, listBooks(startURL, app))
+			setSecondItem(flex, listBooks(apiClient, app))
 		}).
 		AddItem("Manage Shelves", "Press s to list shelves", 's', nil).
 		AddItem("Manage Borrowings", "Press b to list borrowings", 'b', nil).
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/ean"
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"github.com/gouthamve/librascan/pkg/client"
+	"github.com/gouthamve/librascan/pkg/scancode"
+)
+
+func main() {
+	serverURL := flag.String("server-url", "http://localhost:8080", "Server URL to fetch people from.")
+	token := flag.String("token", os.Getenv("LIBRASCAN_TOKEN"), "API token to send to the server. Defaults to $LIBRASCAN_TOKEN.")
+	flag.Parse()
+
+	people, err := client.New(*serverURL, nil).WithToken(*token).ListPeople()
+	if err != nil {
+		log.Fatalf("failed to list people: %v", err)
+	}
+
+	for _, person := range people {
+		drawCard(person.Name, scancode.PersonCardCode(person.ID), fmt.Sprintf("./cards/card-person-%d.png", person.ID))
+	}
+
+	drawCard("Return books", scancode.CommandCardCode(scancode.CommandReturnMode), "./cards/card-return-mode.png")
+	drawCard("Done lending", scancode.CommandCardCode(scancode.CommandCatalogueMode), "./cards/card-catalogue-mode.png")
+	drawCard("Audit a row", scancode.CommandCardCode(scancode.CommandAuditMode), "./cards/card-audit-mode.png")
+	drawCard("End session", scancode.CommandCardCode(scancode.CommandEndSession), "./cards/card-end-session.png")
+	drawCard("Reshelve", scancode.CommandCardCode(scancode.CommandReshelveMode), "./cards/card-reshelve-mode.png")
+}
+
+func drawCard(label, code, path string) {
+	eanCode, err := ean.Encode(code)
+	if err != nil {
+		log.Fatalf("failed to encode %s: %v", code, err)
+	}
+
+	eanCodeScaled, err := barcode.Scale(eanCode, 300, 90)
+	if err != nil {
+		log.Fatalf("failed to scale barcode: %v", err)
+	}
+
+	font, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		log.Fatalf("failed to parse font: %v", err)
+	}
+	face := truetype.NewFace(font, &truetype.Options{Size: 24})
+
+	// Roughly the aspect ratio of a credit card.
+	imgWidth := 340.0
+	imgHeight := 200.0
+	imgCtx := gg.NewContext(int(imgWidth), int(imgHeight))
+	imgCtx.SetFontFace(face)
+
+	imgCtx.DrawRectangle(0, 0, imgWidth, imgHeight)
+	imgCtx.SetRGB(1, 1, 1)
+	imgCtx.Fill()
+
+	imgCtx.SetRGB(0, 0, 0)
+
+	imgCtx.DrawStringAnchored(label, imgWidth/2, 30, 0.5, 0.5)
+	imgCtx.DrawImage(eanCodeScaled, 20, 60)
+	imgCtx.DrawStringAnchored(code, imgWidth/2, 175, 0.5, 0.5)
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("failed to create %s: %v", path, err)
+	}
+
+	if err := png.Encode(file, imgCtx.Image()); err != nil {
+		log.Printf("failed to encode png: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("failed to close file: %v", err)
+	}
+
+	fmt.Println("Wrote", path)
+}
@@ -0,0 +1,30 @@
+-- name: InsertAudit :one
+INSERT INTO audits (location_id, started_at) VALUES (?, datetime('now'))
+RETURNING id, location_id, started_at, closed_at, applied_at, report;
+
+-- name: GetAudit :one
+SELECT id, location_id, started_at, closed_at, applied_at, report FROM audits WHERE id = ?;
+
+-- name: GetAudits :many
+SELECT id, location_id, started_at, closed_at, applied_at, report FROM audits
+WHERE sqlc.narg(location_id) IS NULL OR location_id = sqlc.narg(location_id)
+ORDER BY id DESC;
+
+-- name: CloseAudit :execrows
+UPDATE audits SET closed_at = datetime('now'), report = ? WHERE id = ? AND closed_at IS NULL;
+
+-- name: MarkAuditApplied :execrows
+UPDATE audits SET applied_at = datetime('now') WHERE id = ? AND applied_at IS NULL;
+
+-- name: InsertAuditScan :exec
+INSERT INTO audit_scans (audit_id, isbn, scanned_at) VALUES (?, ?, datetime('now'))
+ON CONFLICT(audit_id, isbn) DO NOTHING;
+
+-- name: GetAuditScans :many
+SELECT isbn FROM audit_scans WHERE audit_id = ? ORDER BY id;
+
+-- name: GetLastAudits :many
+SELECT location_id, CAST(MAX(closed_at) AS TEXT) AS last_audited_at
+FROM audits
+WHERE closed_at IS NOT NULL
+GROUP BY location_id;
@@ -1,19 +1,29 @@
 -- name: GetBook :one
-SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id 
+SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id, slot, location_id, position, classification, price_supplement, copies 
 FROM books 
 WHERE isbn = ?;
 
 -- name: GetAllBooks :many
-SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, shelf_id, row_number 
+SELECT isbn, title, description, publisher, published_date, pages, language, cover_url, shelf_id, row_number, slot, location_id, position, classification, price_supplement, copies 
 FROM books;
 
 -- name: InsertBook :exec
 INSERT INTO books 
-(isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+(isbn, title, description, publisher, published_date, pages, language, cover_url, row_number, shelf_id, slot, location_id, position, classification, price_supplement, added_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))
 ON CONFLICT(isbn) DO UPDATE SET
     row_number = excluded.row_number,
-    shelf_id = excluded.shelf_id;
+    shelf_id = excluded.shelf_id,
+    slot = excluded.slot,
+    location_id = excluded.location_id,
+    position = excluded.position,
+    price_supplement = COALESCE(excluded.price_supplement, books.price_supplement);
+
+-- name: AddBookCopy :execrows
+UPDATE books SET copies = copies + 1 WHERE isbn = ?;
+
+-- name: RemoveBookCopy :execrows
+UPDATE books SET copies = copies - 1 WHERE isbn = ? AND copies > 1;
 
 -- name: DeleteBook :execrows
 DELETE FROM books WHERE isbn = ?;
@@ -31,4 +41,26 @@ UPDATE books SET description = ? WHERE isbn = ? AND (description IS NULL OR desc
 UPDATE books SET published_date = ? WHERE isbn = ? AND (published_date IS NULL OR published_date = '');
 
 -- name: MarkBookAsEnriched :exec
-UPDATE books SET is_ai_enriched = 1 WHERE isbn = ?;
\ No newline at end of file
+UPDATE books SET is_ai_enriched = 1 WHERE isbn = ?;
+
+-- name: GetNextRowPosition :one
+SELECT CAST(COALESCE(MAX(position), 0) + 1 AS INTEGER) AS position
+FROM books
+WHERE shelf_id = ? AND row_number = ?;
+
+-- name: GetRowBooks :many
+SELECT isbn FROM books
+WHERE shelf_id = ? AND row_number = ?
+ORDER BY position IS NULL, position, isbn;
+
+-- name: SetBookPosition :exec
+UPDATE books SET position = ? WHERE isbn = ?;
+
+-- name: GetSpineColors :many
+SELECT isbn, CAST(spine_color AS TEXT) AS spine_color FROM books WHERE spine_color IS NOT NULL;
+
+-- name: SetBookPriceSupplement :exec
+UPDATE books SET price_supplement = ? WHERE isbn = ?;
+
+-- name: SetSpineColor :exec
+UPDATE books SET spine_color = ? WHERE isbn = ?;
@@ -0,0 +1,31 @@
+-- name: GetLoansDueBetween :many
+SELECT b.id, b.isbn, b.borrowed_at, b.due_at, p.id AS person_id, p.name AS person_name, p.email AS person_email, bk.title
+FROM borrowing b
+JOIN people p ON b.person_id = p.id
+JOIN books bk ON b.isbn = bk.isbn
+WHERE b.returned_at IS NULL
+    AND b.due_at > sqlc.arg(due_after)
+    AND b.due_at <= sqlc.arg(due_before)
+    AND NOT EXISTS (
+        SELECT 1 FROM loan_notifications n WHERE n.borrowing_id = b.id AND n.kind = sqlc.arg(kind)
+    );
+
+-- name: RecordLoanNotification :exec
+INSERT OR IGNORE INTO loan_notifications (borrowing_id, kind, sent_at) VALUES (?, ?, datetime('now'));
+
+-- name: InsertHold :exec
+INSERT INTO holds (isbn, person_id, created_at) VALUES (?, ?, datetime('now'));
+
+-- name: GetNextHold :one
+SELECT h.id, h.isbn, h.person_id, p.name AS person_name, p.email AS person_email
+FROM holds h
+JOIN people p ON h.person_id = p.id
+WHERE h.isbn = ? AND h.notified_at IS NULL AND h.fulfilled_at IS NULL
+ORDER BY h.created_at, h.id
+LIMIT 1;
+
+-- name: MarkHoldNotified :exec
+UPDATE holds SET notified_at = datetime('now') WHERE id = ?;
+
+-- name: FulfillHold :exec
+UPDATE holds SET fulfilled_at = datetime('now') WHERE isbn = ? AND person_id = ? AND fulfilled_at IS NULL;
\ No newline at end of file
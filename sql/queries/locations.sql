@@ -0,0 +1,69 @@
+-- name: GetLocation :one
+SELECT id, parent_id, type, name, shelf_id, row_number FROM locations WHERE id = ?;
+
+-- name: GetAllLocations :many
+SELECT id, parent_id, type, name, shelf_id, row_number FROM locations ORDER BY id;
+
+-- name: InsertLocation :one
+INSERT INTO locations (parent_id, type, name, shelf_id, row_number) VALUES (?, ?, ?, ?, ?)
+RETURNING id, parent_id, type, name, shelf_id, row_number;
+
+-- name: UpdateLocation :execrows
+UPDATE locations SET parent_id = ?, type = ?, name = ? WHERE id = ?;
+
+-- name: DeleteLocation :execrows
+DELETE FROM locations WHERE id = ?;
+
+-- name: CountLocationChildren :one
+SELECT COUNT(*) FROM locations WHERE parent_id = ?;
+
+-- name: CountLocationBooks :one
+SELECT COUNT(*) FROM books WHERE location_id = ?;
+
+-- name: GetLocationBookCounts :many
+SELECT CAST(location_id AS INTEGER) AS location_id, COUNT(*) AS books
+FROM books
+WHERE location_id IS NOT NULL
+GROUP BY location_id;
+
+-- name: GetLocationBooks :many
+WITH RECURSIVE subtree(id) AS (
+    SELECT locations.id FROM locations WHERE locations.id = sqlc.arg(id)
+    UNION ALL
+    SELECT l.id FROM locations l JOIN subtree s ON l.parent_id = s.id
+)
+SELECT isbn FROM books
+WHERE location_id IN (SELECT id FROM subtree)
+ORDER BY isbn;
+
+-- name: GetShelfLocations :many
+SELECT id, parent_id, type, name, shelf_id, row_number FROM locations
+WHERE shelf_id = ?
+ORDER BY COALESCE(row_number, 0);
+
+-- name: RenameShelfLocation :exec
+UPDATE locations SET name = ? WHERE shelf_id = ? AND row_number IS NULL;
+
+-- name: ResolveShelfLocation :one
+SELECT id FROM locations
+WHERE shelf_id = sqlc.arg(shelf_id)
+    AND (row_number = sqlc.narg(row_number) OR row_number IS NULL)
+ORDER BY row_number IS NULL
+LIMIT 1;
+
+-- name: CountShelfSubLocations :one
+SELECT COUNT(*) FROM locations
+WHERE shelf_id IS NULL
+    AND parent_id IN (
+        SELECT l.id FROM locations l
+        WHERE l.shelf_id = sqlc.arg(shelf_id) AND COALESCE(l.row_number, 0) >= sqlc.arg(min_row)
+    );
+
+-- name: DeleteShelfLocations :exec
+DELETE FROM locations
+WHERE shelf_id = sqlc.arg(shelf_id) AND COALESCE(row_number, 0) >= sqlc.arg(min_row);
+
+-- name: SetBookLocation :execrows
+UPDATE books
+SET location_id = ?, shelf_id = ?, row_number = ?, slot = ?, position = ?
+WHERE isbn = ?;
@@ -1,14 +1,35 @@
 -- name: GetPerson :one
 SELECT id FROM people WHERE name = ?;
 
+-- name: GetPersonByID :one
+SELECT id, name, email FROM people WHERE id = ?;
+
 -- name: InsertPerson :one
-INSERT INTO people (name) VALUES (?) RETURNING id;
+INSERT INTO people (name, calendar_token) VALUES (?, lower(hex(randomblob(16)))) RETURNING id;
+
+-- name: GetPersonCalendarToken :one
+SELECT calendar_token FROM people WHERE id = ?;
+
+-- name: UpdatePersonEmail :exec
+UPDATE people SET email = ? WHERE id = ?;
 
 -- name: GetAllPeople :many
-SELECT id, name FROM people;
+SELECT id, name, email FROM people;
+
+-- name: InsertBorrowing :one
+INSERT INTO borrowing (isbn, person_id, borrowed_at, due_at) VALUES (?, ?, datetime('now'), ?) RETURNING id;
+
+-- name: IsBookBorrowed :one
+SELECT EXISTS (SELECT 1 FROM borrowing WHERE isbn = ? AND returned_at IS NULL);
 
--- name: InsertBorrowing :exec
-INSERT INTO borrowing (isbn, person_id, borrowed_at) VALUES (?, ?, datetime('now'));
+-- name: GetActiveLoans :many
+SELECT b.id, b.isbn, b.borrowed_at, b.due_at, b.person_id, p.name AS person_name, bk.title
+FROM borrowing b
+JOIN people p ON b.person_id = p.id
+JOIN books bk ON b.isbn = bk.isbn
+WHERE b.returned_at IS NULL
+    AND (sqlc.narg(person_id) IS NULL OR b.person_id = sqlc.narg(person_id))
+ORDER BY b.due_at;
 
 -- name: GetActiveBorrowings :many
 SELECT b.id, b.isbn, b.person_id, b.borrowed_at, p.name as person_name
@@ -19,4 +40,19 @@ WHERE b.returned_at IS NULL;
 -- name: ReturnBook :exec
 UPDATE borrowing 
 SET returned_at = datetime('now') 
-WHERE isbn = ? AND person_id = ? AND returned_at IS NULL;
\ No newline at end of file
+WHERE isbn = ? AND person_id = ? AND returned_at IS NULL;
+
+-- name: ReturnBookByISBN :one
+UPDATE borrowing
+SET returned_at = datetime('now')
+WHERE isbn = ? AND returned_at IS NULL
+RETURNING id;
+
+-- name: DeleteActiveBorrowing :execrows
+DELETE FROM borrowing WHERE id = ? AND returned_at IS NULL;
+
+-- name: ReopenBorrowing :execrows
+UPDATE borrowing
+SET returned_at = NULL
+WHERE id = ? AND returned_at IS NOT NULL
+AND NOT EXISTS (SELECT 1 FROM borrowing b WHERE b.isbn = borrowing.isbn AND b.returned_at IS NULL);
\ No newline at end of file
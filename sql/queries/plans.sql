@@ -0,0 +1,29 @@
+-- name: InsertPlan :one
+INSERT INTO plans (policy, created_at) VALUES (?, datetime('now'))
+RETURNING id, policy, created_at, completed_at;
+
+-- name: GetPlan :one
+SELECT id, policy, created_at, completed_at FROM plans WHERE id = ?;
+
+-- name: GetPlans :many
+SELECT id, policy, created_at, completed_at FROM plans ORDER BY id DESC;
+
+-- name: CompletePlan :execrows
+UPDATE plans SET completed_at = datetime('now')
+WHERE id = ? AND completed_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM plan_books
+    WHERE plan_books.plan_id = plans.id AND step IS NOT NULL AND done_at IS NULL
+  );
+
+-- name: InsertPlanBook :exec
+INSERT INTO plan_books (plan_id, isbn, from_shelf_id, from_row, from_position, to_shelf_id, to_row, to_position, after_isbn, step)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetPlanBooks :many
+SELECT id, plan_id, isbn, from_shelf_id, from_row, from_position, to_shelf_id, to_row, to_position, after_isbn, step, done_at
+FROM plan_books WHERE plan_id = ? ORDER BY id;
+
+-- name: CompletePlanMove :execrows
+UPDATE plan_books SET done_at = datetime('now')
+WHERE plan_id = ? AND isbn = ? AND step IS NOT NULL AND done_at IS NULL;
@@ -0,0 +1,34 @@
+-- name: InsertScanEvent :one
+INSERT INTO scan_events (scanned_at, device, source, code, mode, action, outcome, shelf_id, row_number, slot, isbn, person_id, book, previous, borrowing_id)
+VALUES (datetime('now'), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, scanned_at, device, source, code, mode, action, outcome, shelf_id, row_number, slot, isbn, person_id, book, previous, undone_at, borrowing_id;
+
+-- name: GetScanEvent :one
+SELECT id, scanned_at, device, source, code, mode, action, outcome, shelf_id, row_number, slot, isbn, person_id, book, previous, undone_at, borrowing_id
+FROM scan_events WHERE id = ?;
+
+-- name: GetScanEvents :many
+SELECT id, scanned_at, device, source, code, mode, action, outcome, shelf_id, row_number, slot, isbn, person_id, book, previous, undone_at, borrowing_id
+FROM scan_events
+WHERE sqlc.narg(device) IS NULL OR device = sqlc.narg(device)
+ORDER BY id DESC
+LIMIT sqlc.arg(max_events);
+
+-- name: GetLastUndoableScanEvent :one
+SELECT id, scanned_at, device, source, code, mode, action, outcome, shelf_id, row_number, slot, isbn, person_id, book, previous, undone_at, borrowing_id
+FROM scan_events
+WHERE device = ? AND undone_at IS NULL AND outcome = 'ok'
+    AND action IN ('add', 'copy', 'move', 'delete', 'borrow', 'return')
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: GetLastUndoableBookScanEventID :one
+SELECT id
+FROM scan_events
+WHERE isbn = ? AND undone_at IS NULL AND outcome = 'ok'
+    AND action IN ('add', 'copy', 'move', 'delete', 'borrow', 'return')
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: MarkScanEventUndone :execrows
+UPDATE scan_events SET undone_at = datetime('now') WHERE id = ? AND undone_at IS NULL;
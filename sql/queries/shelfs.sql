@@ -4,8 +4,47 @@ SELECT id, name, rows_count FROM shelfs WHERE id = ?;
 -- name: GetShelfName :one
 SELECT name FROM shelfs WHERE id = ?;
 
--- name: InsertShelf :exec
-INSERT INTO shelfs (name, rows_count) VALUES (?, ?);
+-- name: InsertShelf :one
+INSERT INTO shelfs (name, rows_count) VALUES (?, ?)
+RETURNING id, name, rows_count;
 
 -- name: GetAllShelfs :many
-SELECT id, name, rows_count FROM shelfs;
\ No newline at end of file
+SELECT id, name, rows_count FROM shelfs;
+
+-- name: UpdateShelf :execrows
+UPDATE shelfs
+SET name = COALESCE(sqlc.narg(name), name),
+    rows_count = COALESCE(sqlc.narg(rows_count), rows_count)
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteShelf :execrows
+DELETE FROM shelfs WHERE id = ?;
+
+-- name: CountShelfBooks :one
+SELECT COUNT(*) FROM books WHERE shelf_id = ?;
+
+-- name: GetShelfMaxRow :one
+SELECT CAST(COALESCE(MAX(row_number), 0) AS INTEGER) AS max_row FROM books WHERE shelf_id = ?;
+
+-- name: GetShelfRowCounts :many
+SELECT CAST(COALESCE(shelf_id, 0) AS INTEGER) AS shelf_id,
+    CAST(COALESCE(row_number, 0) AS INTEGER) AS row_number,
+    COUNT(*) AS books
+FROM books
+GROUP BY 1, 2
+ORDER BY 1, 2;
+
+-- name: MoveShelfBooks :execrows
+UPDATE books
+SET shelf_id = sqlc.narg(to_shelf_id),
+    row_number = CASE WHEN row_number <= sqlc.arg(max_row) THEN row_number ELSE NULL END,
+    slot = CASE WHEN row_number <= sqlc.arg(max_row) THEN slot ELSE NULL END,
+    position = CASE WHEN row_number <= sqlc.arg(max_row) THEN position ELSE NULL END,
+    location_id = (
+        SELECT l.id FROM locations l
+        WHERE l.shelf_id = sqlc.narg(to_shelf_id)
+            AND (l.row_number = CASE WHEN books.row_number <= sqlc.arg(max_row) THEN books.row_number END OR l.row_number IS NULL)
+        ORDER BY l.row_number IS NULL
+        LIMIT 1
+    )
+WHERE shelf_id = sqlc.arg(from_shelf_id);
\ No newline at end of file
@@ -0,0 +1,63 @@
+-- name: GetBookTotals :one
+SELECT COUNT(*) AS books,
+    CAST(COALESCE(SUM(pages), 0) AS INTEGER) AS pages,
+    CAST(COALESCE(SUM(is_ai_enriched), 0) AS INTEGER) AS ai_enriched
+FROM books;
+
+-- name: CountBooksByShelf :many
+SELECT CAST(COALESCE(b.shelf_id, 0) AS INTEGER) AS shelf_id,
+    CAST(COALESCE(s.name, 'unknown') AS TEXT) AS shelf_name,
+    COUNT(*) AS books
+FROM books b
+LEFT JOIN shelfs s ON b.shelf_id = s.id
+GROUP BY 1, 2
+ORDER BY 1;
+
+-- name: CountBooksByLanguage :many
+SELECT CAST(COALESCE(NULLIF(language, ''), 'unknown') AS TEXT) AS language, COUNT(*) AS books
+FROM books
+GROUP BY 1
+ORDER BY books DESC, language;
+
+-- name: CountBooksByCategory :many
+SELECT CAST(name AS TEXT) AS category, COUNT(*) AS books
+FROM categories
+WHERE name IS NOT NULL
+GROUP BY name
+ORDER BY books DESC, category;
+
+-- name: CountBooksByDecade :many
+SELECT CAST(substr(published_date, 1, 3) AS INTEGER) * 10 AS decade, COUNT(*) AS books
+FROM books
+WHERE published_date GLOB '[0-9][0-9][0-9][0-9]*'
+GROUP BY 1
+ORDER BY 1;
+
+-- name: CountBooksAddedByMonth :many
+SELECT CAST(substr(added_at, 1, 7) AS TEXT) AS month, COUNT(*) AS books
+FROM books
+WHERE added_at IS NOT NULL
+GROUP BY 1
+ORDER BY 1;
+
+-- name: GetMostBorrowedBooks :many
+SELECT b.isbn, bk.title, COUNT(*) AS loans
+FROM borrowing b
+JOIN books bk ON b.isbn = bk.isbn
+GROUP BY b.isbn, bk.title
+ORDER BY loans DESC, b.isbn
+LIMIT ?;
+
+-- name: GetMostActiveBorrowers :many
+SELECT p.id, p.name, COUNT(*) AS loans
+FROM borrowing b
+JOIN people p ON b.person_id = p.id
+GROUP BY p.id, p.name
+ORDER BY loans DESC, p.name
+LIMIT ?;
+
+-- name: GetLoanDurations :one
+SELECT COUNT(*) AS returned_loans,
+    CAST(COALESCE(AVG(julianday(returned_at) - julianday(borrowed_at)), 0) AS REAL) AS average_days
+FROM borrowing
+WHERE returned_at IS NOT NULL;
\ No newline at end of file
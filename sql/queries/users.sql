@@ -0,0 +1,43 @@
+-- name: CreateUser :one
+INSERT INTO users (name, role, password_hash, created_at)
+VALUES (?, ?, ?, datetime('now'))
+RETURNING id, name, role, password_hash, created_at;
+
+-- name: GetUserByName :one
+SELECT id, name, role, password_hash, created_at FROM users WHERE name = ?;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = ? WHERE id = ?;
+
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash, created_at)
+VALUES (?, ?, ?, datetime('now'))
+RETURNING id, user_id, name, token_hash, created_at, last_used_at;
+
+-- name: GetUserByAPIToken :one
+SELECT u.id, u.name, u.role, u.password_hash, u.created_at
+FROM api_tokens t
+JOIN users u ON t.user_id = u.id
+WHERE t.token_hash = ?;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = datetime('now') WHERE token_hash = ?;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+VALUES (?, ?, datetime('now'), ?);
+
+-- name: GetUserBySession :one
+SELECT u.id, u.name, u.role, u.password_hash, u.created_at
+FROM sessions s
+JOIN users u ON s.user_id = u.id
+WHERE s.token_hash = ? AND s.expires_at > datetime('now');
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = ?;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at <= datetime('now');
@@ -8,6 +8,20 @@ CREATE TABLE shelfs (
     UNIQUE(name)
 );
 
+-- Locations table, a tree of buildings, rooms, bookcases, rows and boxes.
+-- Bookcases and rows created for a shelf point back to it.
+CREATE TABLE locations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    parent_id INTEGER,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL,
+    shelf_id INTEGER,
+    row_number INTEGER,
+    UNIQUE(parent_id, name),
+    FOREIGN KEY(parent_id) REFERENCES locations(id),
+    FOREIGN KEY(shelf_id) REFERENCES shelfs(id)
+);
+
 -- Books table (after migrations)
 CREATE TABLE books (
     ISBN INTEGER PRIMARY KEY,
@@ -21,7 +35,16 @@ CREATE TABLE books (
     shelf_id INTEGER,
     row_number INTEGER,
     is_ai_enriched INTEGER DEFAULT 0,
-    FOREIGN KEY(shelf_id) REFERENCES shelfs(id)
+    added_at TEXT,
+    slot INTEGER,
+    location_id INTEGER,
+    position INTEGER,
+    spine_color TEXT,
+    classification TEXT,
+    price_supplement TEXT,
+    copies INTEGER NOT NULL DEFAULT 1,
+    FOREIGN KEY(shelf_id) REFERENCES shelfs(id),
+    FOREIGN KEY(location_id) REFERENCES locations(id)
 );
 
 -- Authors table
@@ -46,6 +69,8 @@ CREATE TABLE categories (
 CREATE TABLE people (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
+    email TEXT,
+    calendar_token TEXT,
     UNIQUE(name)
 );
 
@@ -56,9 +81,127 @@ CREATE TABLE borrowing (
     person_id INTEGER NOT NULL,
     borrowed_at TEXT NOT NULL,
     returned_at TEXT,
+    due_at TEXT,
+    FOREIGN KEY(isbn) REFERENCES books(ISBN),
+    FOREIGN KEY(person_id) REFERENCES people(id)
+);
+
+-- Holds table
+CREATE TABLE holds (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    isbn INTEGER NOT NULL,
+    person_id INTEGER NOT NULL,
+    created_at TEXT NOT NULL,
+    notified_at TEXT,
+    fulfilled_at TEXT,
     FOREIGN KEY(isbn) REFERENCES books(ISBN),
     FOREIGN KEY(person_id) REFERENCES people(id)
 );
 
+-- Loan notifications table
+CREATE TABLE loan_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    borrowing_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    sent_at TEXT NOT NULL,
+    UNIQUE(borrowing_id, kind),
+    FOREIGN KEY(borrowing_id) REFERENCES borrowing(id)
+);
+
+-- Audits table, one per check of a shelf row or other location
+CREATE TABLE audits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location_id INTEGER NOT NULL,
+    started_at TEXT NOT NULL,
+    closed_at TEXT,
+    applied_at TEXT,
+    report TEXT,
+    FOREIGN KEY(location_id) REFERENCES locations(id)
+);
+
+-- Audit scans table
+CREATE TABLE audit_scans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audit_id INTEGER NOT NULL,
+    isbn INTEGER NOT NULL,
+    scanned_at TEXT NOT NULL,
+    UNIQUE(audit_id, isbn),
+    FOREIGN KEY(audit_id) REFERENCES audits(id)
+);
+
+-- Reshelving plans
+CREATE TABLE plans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    completed_at TEXT
+);
+
+-- Where each book in a plan is and where it goes. Books that move have a step.
+CREATE TABLE plan_books (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    plan_id INTEGER NOT NULL,
+    isbn INTEGER NOT NULL,
+    from_shelf_id INTEGER NOT NULL,
+    from_row INTEGER NOT NULL,
+    from_position INTEGER NOT NULL,
+    to_shelf_id INTEGER NOT NULL,
+    to_row INTEGER NOT NULL,
+    to_position INTEGER NOT NULL,
+    after_isbn INTEGER,
+    step INTEGER,
+    done_at TEXT,
+    UNIQUE(plan_id, isbn),
+    FOREIGN KEY(plan_id) REFERENCES plans(id)
+);
+
+-- Every code scanned and what it did. Scans that change the library keep the
+-- book (as JSON) and where it was before, so that they can be undone.
+CREATE TABLE scan_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    scanned_at TEXT NOT NULL,
+    device TEXT NOT NULL,
+    source TEXT,
+    code TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    action TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    shelf_id INTEGER,
+    row_number INTEGER,
+    slot INTEGER,
+    isbn INTEGER,
+    person_id INTEGER,
+    book TEXT,
+    previous TEXT,
+    undone_at TEXT,
+    borrowing_id INTEGER
+);
+
+-- The people who may sign in, and what they may do: reader, librarian or
+-- admin. Only hashes of passwords, API tokens and sessions are kept.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL,
+    password_hash TEXT,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL,
+    last_used_at TEXT
+);
+
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
+
 -- Enable foreign keys
 PRAGMA foreign_keys = ON;
\ No newline at end of file